@@ -0,0 +1,173 @@
+// Copyright 2024 Google LLC.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package safeopen
+
+import (
+	"io"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// latencyBounds are the upper bounds of the buckets of the latency histograms.
+var latencyBounds = []time.Duration{
+	10 * time.Microsecond,
+	100 * time.Microsecond,
+	time.Millisecond,
+	10 * time.Millisecond,
+	100 * time.Millisecond,
+	time.Second,
+}
+
+// WithAccounting makes d account for the I/O done through it, as reported by Usage, e.g. for
+// capacity planning or for billing the tenant d belongs to.
+//
+// Only the I/O d does on its own is accounted for: the bytes read and written by ReadFile,
+// WriteFile and the files of OpenCached, the files opened and created through d, and the files
+// removed by the retention policy. The files returned as *os.File, such as by OpenFile, count as
+// opened, but what is done with them is not accounted for.
+func WithAccounting() Option {
+	return func(o *options) {
+		o.accounting = true
+	}
+}
+
+// Usage is a snapshot of the I/O done through a Dir, as returned by Dir.Usage.
+type Usage struct {
+	BytesRead    int64
+	BytesWritten int64
+	FilesOpened  int64
+	FilesCreated int64
+	FilesRemoved int64
+	// Latency holds the latency histograms of the Dir methods called at least once, keyed by
+	// method name (e.g. "ReadFile").
+	Latency map[string]LatencyHistogram
+}
+
+// LatencyHistogram is a histogram of the latencies of an operation.
+type LatencyHistogram struct {
+	// Bounds are the inclusive upper bounds of the buckets, in increasing order. The last
+	// bucket, which has no upper bound, comes on top of them.
+	Bounds []time.Duration
+	// Counts holds the number of operations per bucket, with len(Bounds)+1 elements.
+	Counts []int64
+	// Count and Sum are the number of operations and their total latency.
+	Count int64
+	Sum   time.Duration
+}
+
+// Usage returns a snapshot of the I/O done through d so far. It is zero unless d was opened with
+// WithAccounting.
+func (d *Dir) Usage() Usage {
+	a := d.acct
+	if a == nil {
+		return Usage{}
+	}
+	u := Usage{
+		BytesRead:    a.bytesRead.Load(),
+		BytesWritten: a.bytesWritten.Load(),
+		FilesOpened:  a.opened.Load(),
+		FilesCreated: a.created.Load(),
+		FilesRemoved: a.removed.Load(),
+		Latency:      make(map[string]LatencyHistogram),
+	}
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	for op, h := range a.latency {
+		h.Bounds = append([]time.Duration(nil), h.Bounds...)
+		h.Counts = append([]int64(nil), h.Counts...)
+		u.Latency[op] = *h
+	}
+	return u
+}
+
+// accounting holds the counters of a Dir opened with WithAccounting. All its methods are no-ops on
+// a nil accounting.
+type accounting struct {
+	bytesRead    atomic.Int64
+	bytesWritten atomic.Int64
+	opened       atomic.Int64
+	created      atomic.Int64
+	removed      atomic.Int64
+
+	mu      sync.Mutex
+	latency map[string]*LatencyHistogram
+}
+
+func newAccounting() *accounting {
+	return &accounting{latency: make(map[string]*LatencyHistogram)}
+}
+
+func (a *accounting) read(n int) {
+	if a != nil {
+		a.bytesRead.Add(int64(n))
+	}
+}
+
+func (a *accounting) written(n int) {
+	if a != nil {
+		a.bytesWritten.Add(int64(n))
+	}
+}
+
+func (a *accounting) open(created bool) {
+	if a == nil {
+		return
+	}
+	a.opened.Add(1)
+	if created {
+		a.created.Add(1)
+	}
+}
+
+func (a *accounting) remove() {
+	if a != nil {
+		a.removed.Add(1)
+	}
+}
+
+// since records the latency of the operation op started at start.
+func (a *accounting) since(op string, start time.Time) {
+	if a == nil {
+		return
+	}
+	lat := time.Since(start)
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	h := a.latency[op]
+	if h == nil {
+		h = &LatencyHistogram{Bounds: latencyBounds, Counts: make([]int64, len(latencyBounds)+1)}
+		a.latency[op] = h
+	}
+	i := 0
+	for i < len(h.Bounds) && lat > h.Bounds[i] {
+		i++
+	}
+	h.Counts[i]++
+	h.Count++
+	h.Sum += lat
+}
+
+// countingReaderAt accounts for the bytes read from r.
+type countingReaderAt struct {
+	r    io.ReaderAt
+	acct *accounting
+}
+
+func (c countingReaderAt) ReadAt(p []byte, off int64) (int, error) {
+	n, err := c.r.ReadAt(p, off)
+	c.acct.read(n)
+	return n, err
+}
@@ -0,0 +1,87 @@
+// Copyright 2024 Google LLC.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package safeopen
+
+import (
+	"io"
+	"testing"
+)
+
+func TestAccounting(t *testing.T) {
+	d, err := OpenDir(t.TempDir(), WithAccounting(), WithRetention(Retention{MaxFiles: 1}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer d.Close()
+
+	if err := d.WriteFile("a", []byte("hello"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if err := d.WriteFile("a", []byte("hi"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := d.ReadFile("a"); err != nil {
+		t.Fatal(err)
+	}
+	c, err := d.OpenCached("a")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := io.ReadAll(c); err != nil {
+		t.Fatal(err)
+	}
+	c.Close()
+	// Creating b expires a.
+	if err := d.WriteFile("b", nil, 0600); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := d.ReadFile("missing"); err == nil {
+		t.Fatal("ReadFile(missing) succeeded")
+	}
+
+	u := d.Usage()
+	want := Usage{BytesRead: 4, BytesWritten: 7, FilesOpened: 5, FilesCreated: 2, FilesRemoved: 1}
+	if u.BytesRead != want.BytesRead || u.BytesWritten != want.BytesWritten || u.FilesOpened != want.FilesOpened || u.FilesCreated != want.FilesCreated || u.FilesRemoved != want.FilesRemoved {
+		t.Errorf("Usage() = %+v, want %+v", u, want)
+	}
+	for op, count := range map[string]int64{"ReadFile": 2, "WriteFile": 3, "OpenCached": 1} {
+		h := u.Latency[op]
+		if h.Count != count {
+			t.Errorf("Latency[%q].Count = %d, want %d", op, h.Count, count)
+		}
+		var sum int64
+		for _, n := range h.Counts {
+			sum += n
+		}
+		if sum != count || len(h.Counts) != len(h.Bounds)+1 {
+			t.Errorf("Latency[%q] = %+v, inconsistent with %d operations", op, h, count)
+		}
+	}
+}
+
+func TestAccountingDisabled(t *testing.T) {
+	d, err := OpenDir(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer d.Close()
+
+	if err := d.WriteFile("a", []byte("hello"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if u := d.Usage(); u.BytesWritten != 0 || u.Latency != nil {
+		t.Errorf("Usage() = %+v, want zero", u)
+	}
+}
@@ -0,0 +1,206 @@
+// Copyright 2024 Google LLC.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+//go:build linux
+// +build linux
+
+package safeopen
+
+import (
+	"encoding/binary"
+	"errors"
+	"io/fs"
+	"os"
+	"sort"
+
+	"golang.org/x/sys/unix"
+)
+
+// ACLTag is the type of an entry of a POSIX ACL.
+type ACLTag uint16
+
+// The values are the ones of the extended attributes, from linux/posix_acl.h.
+const (
+	ACLUserObj  ACLTag = 0x01 // The owner of the file.
+	ACLUser     ACLTag = 0x02 // The user ACLEntry.ID.
+	ACLGroupObj ACLTag = 0x04 // The owning group of the file.
+	ACLGroup    ACLTag = 0x08 // The group ACLEntry.ID.
+	ACLMask     ACLTag = 0x10 // The maximum permissions of ACLUser, ACLGroupObj and ACLGroup.
+	ACLOther    ACLTag = 0x20 // Everyone else.
+)
+
+// ACLEntry is an entry of a POSIX ACL.
+type ACLEntry struct {
+	Tag ACLTag
+	// ID is the user or group id of ACLUser and ACLGroup entries, and is ignored otherwise.
+	ID uint32
+	// Perm is a combination of read (4), write (2) and execute (1).
+	Perm fs.FileMode
+}
+
+// ACL is a POSIX ACL.
+type ACL []ACLEntry
+
+// ACLKind selects the ACL of a file: its access ACL, or the default ACL of a directory, which
+// the files created in it inherit.
+type ACLKind int
+
+const (
+	ACLAccess  ACLKind = iota // The access ACL (system.posix_acl_access).
+	ACLDefault                // The default ACL of a directory (system.posix_acl_default).
+)
+
+const (
+	aclXattrVersion = 2
+	aclUndefinedID  = 0xffffffff
+	aclHeaderLen    = 4
+	aclEntryLen     = 8
+)
+
+func (k ACLKind) xattr() string {
+	if k == ACLDefault {
+		return "system.posix_acl_default"
+	}
+	return "system.posix_acl_access"
+}
+
+// GetACL returns the ACL of kind of f. A file without access ACL gets the minimal ACL matching
+// its permission bits, as shown by getfacl; a directory without default ACL gets a nil ACL.
+func GetACL(f *os.File, kind ACLKind) (ACL, error) {
+	var data []byte
+	err := controlFd(f, func(fd int) error {
+		for {
+			n, err := unix.Fgetxattr(fd, kind.xattr(), nil)
+			if err != nil {
+				return err
+			}
+			data = make([]byte, n)
+			n, err = unix.Fgetxattr(fd, kind.xattr(), data)
+			// The ACL may have grown in the meantime.
+			if err == unix.ERANGE {
+				continue
+			}
+			data = data[:n]
+			return err
+		}
+	})
+	if err == unix.ENODATA {
+		if kind == ACLDefault {
+			return nil, nil
+		}
+		fi, err := f.Stat()
+		if err != nil {
+			return nil, err
+		}
+		perm := fi.Mode().Perm()
+		return ACL{{Tag: ACLUserObj, Perm: perm >> 6}, {Tag: ACLGroupObj, Perm: perm >> 3 & 7}, {Tag: ACLOther, Perm: perm & 7}}, nil
+	}
+	if err != nil {
+		return nil, &os.PathError{Op: "GetACL", Path: f.Name(), Err: err}
+	}
+	acl, err := parseACL(data)
+	if err != nil {
+		return nil, &os.PathError{Op: "GetACL", Path: f.Name(), Err: err}
+	}
+	return acl, nil
+}
+
+// SetACL sets the ACL of kind of f to acl, whose entries are sorted as required by the kernel.
+// The ACL must be valid: with exactly one ACLUserObj, ACLGroupObj and ACLOther entry, and an
+// ACLMask entry if there are ACLUser or ACLGroup entries. Setting the access ACL updates the
+// permission bits of f accordingly. A nil acl removes the ACL of kind altogether.
+func SetACL(f *os.File, kind ACLKind, acl ACL) error {
+	err := controlFd(f, func(fd int) error {
+		if acl == nil {
+			err := unix.Fremovexattr(fd, kind.xattr())
+			if err == unix.ENODATA {
+				return nil
+			}
+			return err
+		}
+		return unix.Fsetxattr(fd, kind.xattr(), formatACL(acl), 0)
+	})
+	if err != nil {
+		return &os.PathError{Op: "SetACL", Path: f.Name(), Err: err}
+	}
+	return nil
+}
+
+// GetACLBeneath is like GetACL for the named file beneath the named directory, opened with the
+// same rules as OpenBeneath.
+func GetACLBeneath(directory, file string, kind ACLKind) (ACL, error) {
+	f, err := openForMetadataBeneath(directory, file)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return GetACL(f, kind)
+}
+
+// SetACLBeneath is like SetACL for the named file beneath the named directory, opened with the
+// same rules as OpenBeneath.
+func SetACLBeneath(directory, file string, kind ACLKind, acl ACL) error {
+	f, err := openForMetadataBeneath(directory, file)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	return SetACL(f, kind, acl)
+}
+
+// openForMetadataBeneath opens file beneath directory to access its metadata, without blocking
+// on named pipes.
+func openForMetadataBeneath(directory, file string) (*os.File, error) {
+	return OpenFileBeneath(directory, file, os.O_RDONLY|unix.O_NONBLOCK, 0)
+}
+
+func parseACL(data []byte) (ACL, error) {
+	if len(data) < aclHeaderLen || (len(data)-aclHeaderLen)%aclEntryLen != 0 || binary.LittleEndian.Uint32(data) != aclXattrVersion {
+		return nil, errors.New("malformed ACL")
+	}
+	acl := ACL{}
+	for b := data[aclHeaderLen:]; len(b) > 0; b = b[aclEntryLen:] {
+		e := ACLEntry{
+			Tag:  ACLTag(binary.LittleEndian.Uint16(b)),
+			Perm: fs.FileMode(binary.LittleEndian.Uint16(b[2:])),
+		}
+		if e.Tag == ACLUser || e.Tag == ACLGroup {
+			e.ID = binary.LittleEndian.Uint32(b[4:])
+		}
+		acl = append(acl, e)
+	}
+	return acl, nil
+}
+
+func formatACL(acl ACL) []byte {
+	sorted := append(ACL(nil), acl...)
+	sort.SliceStable(sorted, func(i, j int) bool {
+		if sorted[i].Tag != sorted[j].Tag {
+			return sorted[i].Tag < sorted[j].Tag
+		}
+		return sorted[i].ID < sorted[j].ID
+	})
+	data := make([]byte, aclHeaderLen, aclHeaderLen+len(sorted)*aclEntryLen)
+	binary.LittleEndian.PutUint32(data, aclXattrVersion)
+	for _, e := range sorted {
+		id := uint32(aclUndefinedID)
+		if e.Tag == ACLUser || e.Tag == ACLGroup {
+			id = e.ID
+		}
+		data = binary.LittleEndian.AppendUint16(data, uint16(e.Tag))
+		data = binary.LittleEndian.AppendUint16(data, uint16(e.Perm&7))
+		data = binary.LittleEndian.AppendUint32(data, id)
+	}
+	return data
+}
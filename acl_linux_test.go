@@ -0,0 +1,98 @@
+// Copyright 2024 Google LLC.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+//go:build linux
+// +build linux
+
+package safeopen
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"golang.org/x/sys/unix"
+)
+
+func TestLinuxACL(t *testing.T) {
+	tmpDir := t.TempDir()
+	if err := os.Mkdir(filepath.Join(tmpDir, "shared"), 0750); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chmod(filepath.Join(tmpDir, "shared"), 0750); err != nil {
+		t.Fatal(err)
+	}
+
+	acl, err := GetACLBeneath(tmpDir, "shared", ACLAccess)
+	if err != nil {
+		t.Fatalf("GetACLBeneath(ACLAccess) error: %v", err)
+	}
+	want := ACL{{Tag: ACLUserObj, Perm: 7}, {Tag: ACLGroupObj, Perm: 5}, {Tag: ACLOther, Perm: 0}}
+	if !reflect.DeepEqual(acl, want) {
+		t.Errorf("GetACLBeneath(ACLAccess) = %v, want %v", acl, want)
+	}
+	if acl, err := GetACLBeneath(tmpDir, "shared", ACLDefault); err != nil || acl != nil {
+		t.Errorf("GetACLBeneath(ACLDefault) = %v, %v, want nil, nil", acl, err)
+	}
+
+	// Unsorted on purpose.
+	acl = ACL{
+		{Tag: ACLOther, Perm: 0},
+		{Tag: ACLGroup, ID: 1234, Perm: 5},
+		{Tag: ACLUserObj, Perm: 7},
+		{Tag: ACLMask, Perm: 7},
+		{Tag: ACLUser, ID: 4321, Perm: 7},
+		{Tag: ACLGroupObj, Perm: 5},
+	}
+	err = SetACLBeneath(tmpDir, "shared", ACLDefault, acl)
+	if errors.Is(err, unix.EOPNOTSUPP) {
+		t.Skipf("ACLs not supported here: %v", err)
+	}
+	if err != nil {
+		t.Fatalf("SetACLBeneath(ACLDefault) error: %v", err)
+	}
+	want = ACL{
+		{Tag: ACLUserObj, Perm: 7},
+		{Tag: ACLUser, ID: 4321, Perm: 7},
+		{Tag: ACLGroupObj, Perm: 5},
+		{Tag: ACLGroup, ID: 1234, Perm: 5},
+		{Tag: ACLMask, Perm: 7},
+		{Tag: ACLOther, Perm: 0},
+	}
+	if got, err := GetACLBeneath(tmpDir, "shared", ACLDefault); err != nil || !reflect.DeepEqual(got, want) {
+		t.Errorf("GetACLBeneath(ACLDefault) = %v, %v, want %v, nil", got, err, want)
+	}
+
+	// Files created in the directory inherit the default ACL.
+	if err := WriteFileBeneath(tmpDir, filepath.Join("shared", "file"), nil, 0666); err != nil {
+		t.Fatal(err)
+	}
+	got, err := GetACLBeneath(tmpDir, filepath.Join("shared", "file"), ACLAccess)
+	if err != nil || len(got) != len(want) {
+		t.Errorf("GetACLBeneath(%q) = %v, %v, want the inherited ACL", filepath.Join("shared", "file"), got, err)
+	}
+
+	if err := SetACLBeneath(tmpDir, "shared", ACLDefault, nil); err != nil {
+		t.Errorf("SetACLBeneath(ACLDefault, nil) error: %v", err)
+	}
+	if acl, err := GetACLBeneath(tmpDir, "shared", ACLDefault); err != nil || acl != nil {
+		t.Errorf("GetACLBeneath(ACLDefault) after removal = %v, %v, want nil, nil", acl, err)
+	}
+	// An invalid ACL, missing its mask, is rejected.
+	if err := SetACLBeneath(tmpDir, "shared", ACLAccess, want[:4]); err == nil {
+		t.Errorf("SetACLBeneath() of an ACL without mask succeeded, want error")
+	}
+}
@@ -0,0 +1,99 @@
+// Copyright 2024 Google LLC.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package safeopen
+
+import (
+	"container/list"
+	"path/filepath"
+	"strings"
+	"sync"
+)
+
+// cacheKey returns the key identifying file beneath a Dir in caches.
+func cacheKey(file string) string {
+	return strings.TrimLeft(filepath.ToSlash(filepath.Clean(file)), "/")
+}
+
+// lru is a fixed size, least recently used cache safe for concurrent use.
+type lru[V any] struct {
+	mu      sync.Mutex
+	size    int
+	ll      *list.List
+	items   map[string]*list.Element
+	onEvict func(V)
+}
+
+type lruEntry[V any] struct {
+	key   string
+	value V
+}
+
+// newLRU returns a cache holding up to size entries. onEvict, if not nil, is called with the
+// cache lock held for every value dropped from the cache.
+func newLRU[V any](size int, onEvict func(V)) *lru[V] {
+	return &lru[V]{
+		size:    size,
+		ll:      list.New(),
+		items:   make(map[string]*list.Element),
+		onEvict: onEvict,
+	}
+}
+
+func (c *lru[V]) get(key string) (V, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if e, ok := c.items[key]; ok {
+		c.ll.MoveToFront(e)
+		return e.Value.(*lruEntry[V]).value, true
+	}
+	var zero V
+	return zero, false
+}
+
+func (c *lru[V]) add(key string, value V) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if e, ok := c.items[key]; ok {
+		c.evict(e)
+	}
+	c.items[key] = c.ll.PushFront(&lruEntry[V]{key, value})
+	if c.ll.Len() > c.size {
+		c.evict(c.ll.Back())
+	}
+}
+
+func (c *lru[V]) remove(key string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if e, ok := c.items[key]; ok {
+		c.evict(e)
+	}
+}
+
+func (c *lru[V]) purge() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for c.ll.Len() > 0 {
+		c.evict(c.ll.Back())
+	}
+}
+
+func (c *lru[V]) evict(e *list.Element) {
+	entry := c.ll.Remove(e).(*lruEntry[V])
+	delete(c.items, entry.key)
+	if c.onEvict != nil {
+		c.onEvict(entry.value)
+	}
+}
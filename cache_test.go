@@ -0,0 +1,62 @@
+// Copyright 2024 Google LLC.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package safeopen
+
+import (
+	"testing"
+)
+
+func TestLRU(t *testing.T) {
+	var evicted []int
+	c := newLRU[int](2, func(v int) { evicted = append(evicted, v) })
+
+	c.add("a", 1)
+	c.add("b", 2)
+	if _, ok := c.get("a"); !ok {
+		t.Fatalf("get(%q) missed", "a")
+	}
+	// "b" is the least recently used entry now.
+	c.add("c", 3)
+	if _, ok := c.get("b"); ok {
+		t.Errorf("get(%q) hit after eviction", "b")
+	}
+	if v, ok := c.get("c"); !ok || v != 3 {
+		t.Errorf("get(%q) = %v, %v, want 3, true", "c", v, ok)
+	}
+
+	c.remove("a")
+	c.purge()
+	want := []int{2, 1, 3}
+	if len(evicted) != len(want) {
+		t.Fatalf("evicted %v, want %v", evicted, want)
+	}
+	for i := range want {
+		if evicted[i] != want[i] {
+			t.Errorf("evicted %v, want %v", evicted, want)
+			break
+		}
+	}
+}
+
+func TestCacheKey(t *testing.T) {
+	for _, file := range []string{"a/b", "/a/b", "a//b", "./a/b", "a/c/../b"} {
+		if got := cacheKey(file); got != "a/b" {
+			t.Errorf("cacheKey(%q) = %q, want %q", file, got, "a/b")
+		}
+	}
+	if got := cacheKey("../a"); got != "../a" {
+		t.Errorf("cacheKey(%q) = %q, want %q", "../a", got, "../a")
+	}
+}
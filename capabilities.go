@@ -0,0 +1,67 @@
+// Copyright 2024 Google LLC.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package safeopen
+
+import "runtime"
+
+// PlatformCapabilities describes the protections the functions of this package get from the
+// current platform and kernel, e.g. to be logged at startup.
+type PlatformCapabilities struct {
+	// OS is the operating system, as runtime.GOOS.
+	OS string
+
+	// KernelBeneath reports whether the kernel itself confines lookups beneath a directory,
+	// with openat2 and RESOLVE_BENEATH. Otherwise, paths are walked one component at a time.
+	KernelBeneath bool
+
+	// KernelNoCrossDevice reports whether the kernel itself rejects lookups crossing mount
+	// points, with RESOLVE_NO_XDEV. Otherwise, WithNoCrossDevice compares the devices itself.
+	KernelNoCrossDevice bool
+
+	// FollowsSymlinks reports whether OpenBeneath and the related functions follow the symbolic
+	// links staying beneath the directory, whether thanks to the kernel, to os.Root, or to
+	// emulation. Otherwise, symbolic links are rejected.
+	FollowsSymlinks bool
+
+	// SymlinkEmulation reports whether symbolic link emulation is enabled and effective, see
+	// SetSymlinkEmulation.
+	SymlinkEmulation bool
+
+	// OSRoot reports whether OpenFileBeneath relies on os.Root, available since Go 1.24, to
+	// follow symbolic links with symlink emulation.
+	OSRoot bool
+
+	// StrictMode reports whether strict mode is enabled, see SetStrictMode.
+	StrictMode bool
+
+	// ExactCase reports whether exact case is enabled and effective, see SetExactCase.
+	ExactCase bool
+}
+
+// Capabilities returns the capabilities of the current platform. The kernel is probed on each
+// call, which opens the root directory.
+func Capabilities() PlatformCapabilities {
+	c := PlatformCapabilities{
+		OS:            runtime.GOOS,
+		KernelBeneath: kernelBeneathSupported(),
+		StrictMode:    strictMode.Load(),
+		ExactCase:     exactCaseEnforced(),
+	}
+	c.KernelNoCrossDevice = c.KernelBeneath
+	c.SymlinkEmulation = symlinkEmulation.Load() && !c.KernelBeneath && !c.StrictMode
+	c.OSRoot = osRootAvailable && symlinkEmulation.Load() && !c.StrictMode && !c.ExactCase
+	c.FollowsSymlinks = c.KernelBeneath || c.OSRoot || c.SymlinkEmulation
+	return c
+}
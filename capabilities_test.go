@@ -0,0 +1,42 @@
+// Copyright 2024 Google LLC.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package safeopen
+
+import (
+	"runtime"
+	"testing"
+)
+
+func TestCapabilities(t *testing.T) {
+	c := Capabilities()
+	if c.OS != runtime.GOOS {
+		t.Errorf("Capabilities().OS = %q, want %q", c.OS, runtime.GOOS)
+	}
+	if c.KernelBeneath && runtime.GOOS != "linux" {
+		t.Errorf("Capabilities().KernelBeneath = true on %s", runtime.GOOS)
+	}
+	if c.KernelNoCrossDevice && !c.KernelBeneath {
+		t.Error("Capabilities().KernelNoCrossDevice = true without KernelBeneath")
+	}
+	if c.OSRoot || c.FollowsSymlinks != c.KernelBeneath {
+		t.Errorf("Capabilities() without emulation = %+v, want FollowsSymlinks only with KernelBeneath, and no OSRoot", c)
+	}
+
+	SetStrictMode(true)
+	defer SetStrictMode(false)
+	if c := Capabilities(); !c.StrictMode || c.FollowsSymlinks != c.KernelBeneath {
+		t.Errorf("Capabilities() in strict mode = %+v, want StrictMode and FollowsSymlinks only with KernelBeneath", c)
+	}
+}
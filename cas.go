@@ -0,0 +1,242 @@
+// Copyright 2024 Google LLC.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package safeopen
+
+import (
+	"crypto/sha256"
+	"errors"
+	"io"
+	"io/fs"
+	"math/rand"
+	"os"
+	"strconv"
+	"time"
+)
+
+var (
+	// ErrFileChanged is returned by CompareAndSwapFileAt if the content of the file did not
+	// match the expected one.
+	ErrFileChanged = errors.New("file has changed")
+	// ErrFileBusy is returned by CompareAndSwapFileAt if another swap of the file is in
+	// progress. The content of the file may well still match: the swap can be retried as is.
+	ErrFileBusy = errors.New("file is being swapped")
+)
+
+// ContentHashAt returns the SHA-256 digest of the named file in the named directory, suitable
+// to be passed to CompareAndSwapFileAt.
+// file may not contain path separators.
+func ContentHashAt(directory, file string) ([sha256.Size]byte, error) {
+	dfd, err := openDirHandle(directory)
+	if err != nil {
+		return [sha256.Size]byte{}, err
+	}
+	defer closeHandle(dfd)
+
+	_, sum, err := hashIn(dfd, directory, file)
+	return sum, err
+}
+
+// casLockTTL bounds the time a swap may hold the lock file of a file. A lock file older than
+// that has been left by a crashed process, and is removed by the next swap.
+const casLockTTL = time.Minute
+
+// CompareAndSwapFileAt atomically replaces the content of the named file in the named directory
+// with data, if and only if the SHA-256 digest of its current content equals expected.
+// file may not contain path separators.
+//
+// Concurrent swaps are serialized by a lock file, named after file with a leading dot and a
+// .cas suffix, created exclusively, so out of concurrent callers at most one can succeed,
+// without relying on file locks. The others fail with an error wrapping ErrFileBusy, and may
+// retry the same swap, or with an error wrapping ErrFileChanged once the file has been swapped,
+// and are expected to re-read the file and retry. The new content is written to a temporary file and
+// renamed over file, so readers always find either the old or the new content. A lock file left
+// by a crashed process is removed once older than one minute.
+// The permission bits of the original file are kept.
+func CompareAndSwapFileAt(directory, file string, expected [sha256.Size]byte, data []byte) error {
+	if !isFilename(file) {
+		return &os.PathError{Op: "CompareAndSwapFileAt", Path: file, Err: ErrInvalidFilename}
+	}
+
+	dfd, err := openDirHandle(directory)
+	if err != nil {
+		return err
+	}
+	defer closeHandle(dfd)
+
+	return compareAndSwapIn(dfd, directory, file, expected, data)
+}
+
+func compareAndSwapIn(dfd handle, directory, file string, expected [sha256.Size]byte, data []byte) error {
+	unlock, err := casLockIn(dfd, directory, file)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	perm, err := checkHashIn(dfd, directory, file, expected)
+	if err != nil {
+		return err
+	}
+	return replaceIn(dfd, directory, file, data, perm)
+}
+
+// removeIfUnchangedIn removes file directly in the directory referred by dfd if and only if the
+// SHA-256 digest of its content equals expected, under the same lock as CompareAndSwapFileAt.
+func removeIfUnchangedIn(dfd handle, directory, file string, expected [sha256.Size]byte) error {
+	unlock, err := casLockIn(dfd, directory, file)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	if _, err := checkHashIn(dfd, directory, file, expected); err != nil {
+		return err
+	}
+	return removeIn(dfd, file)
+}
+
+// checkHashIn returns the permission bits of file, or an error wrapping ErrFileChanged if the
+// digest of its content is not expected.
+func checkHashIn(dfd handle, directory, file string, expected [sha256.Size]byte) (os.FileMode, error) {
+	perm, sum, err := hashIn(dfd, directory, file)
+	if errors.Is(err, fs.ErrNotExist) || err == nil && sum != expected {
+		return 0, &os.PathError{Op: "CompareAndSwapFileAt", Path: file, Err: ErrFileChanged}
+	}
+	return perm, err
+}
+
+// casLockIn creates the lock file guarding the swaps of file, directly in the directory
+// referred by dfd, and returns the function releasing it. If the lock is held, an error wrapping
+// ErrFileBusy is returned, unless the lock file has expired, in which case it is removed and
+// created again.
+func casLockIn(dfd handle, directory, file string) (func(), error) {
+	lock := "." + file + ".cas"
+	token, err := randomName("", "")
+	if err != nil {
+		return nil, err
+	}
+	content := []byte(time.Now().Add(casLockTTL).UTC().Format(time.RFC3339Nano) + " " + token + "\n")
+
+	for try := 0; try < 2; try++ {
+		f, err := openFileAtIn(dfd, directory, lock, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0600)
+		if errors.Is(err, fs.ErrExist) {
+			removed, err := removeExpiredLockIn(dfd, directory, lock)
+			if err != nil {
+				return nil, err
+			}
+			if !removed {
+				break
+			}
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		_, err = f.Write(content)
+		if err1 := f.Close(); err1 != nil && err == nil {
+			err = err1
+		}
+		if err != nil {
+			removeIn(dfd, lock)
+			return nil, err
+		}
+		return func() {
+			// The lock may have expired and been taken over in the meantime.
+			removeLockIn(dfd, directory, lock, content)
+		}, nil
+	}
+	return nil, &os.PathError{Op: "CompareAndSwapFileAt", Path: file, Err: ErrFileBusy}
+}
+
+// removeExpiredLockIn removes the lock file lock if it has expired, and reports whether it did.
+func removeExpiredLockIn(dfd handle, directory, lock string) (bool, error) {
+	f, err := openFileAtIn(dfd, directory, lock, os.O_RDONLY, 0)
+	if errors.Is(err, fs.ErrNotExist) {
+		// Released in the meantime.
+		return true, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	data, err := io.ReadAll(io.LimitReader(f, maxLockFileSize))
+	f.Close()
+	if err != nil {
+		return false, err
+	}
+	if expiry, err := parseLease(data); err != nil || time.Now().Before(expiry) {
+		return false, nil
+	}
+	return removeLockIn(dfd, directory, lock, data), nil
+}
+
+// removeLockIn removes the lock file lock if its content is still data, and reports whether it
+// did. The lock file is claimed by renaming it to a unique name first, so that a lock file
+// created again concurrently is never removed. Lock files only, whose absence means that the
+// lock is free, may be claimed this way.
+func removeLockIn(dfd handle, directory, lock string, data []byte) bool {
+	claim := lock + strconv.FormatUint(uint64(rand.Uint32()), 10)
+	if err := renameIn(dfd, lock, claim); err != nil {
+		return false
+	}
+	_, sum, err := hashIn(dfd, directory, claim)
+	if err == nil && sum != sha256.Sum256(data) {
+		// Taken over in the meantime: put it back, unless created again already.
+		if err := linkIn(dfd, claim, lock); err != nil && !errors.Is(err, fs.ErrExist) {
+			return false
+		}
+		removeIn(dfd, claim)
+		return false
+	}
+	removeIn(dfd, claim)
+	return err == nil
+}
+
+// publishIn creates file with data directly in the directory referred by dfd, failing with
+// ErrFileChanged if it already exists. Readers never observe a partially written file.
+func publishIn(dfd handle, directory, file string, data []byte, perm os.FileMode) error {
+	tmp, err := writeTempIn(dfd, directory, file, data, perm)
+	if err != nil {
+		return err
+	}
+	err = linkIn(dfd, tmp, file)
+	removeIn(dfd, tmp)
+	if errors.Is(err, fs.ErrExist) {
+		return &os.PathError{Op: "CompareAndSwapFileAt", Path: file, Err: ErrFileChanged}
+	}
+	if err != nil {
+		return err
+	}
+	return syncHandle(dfd)
+}
+
+func hashIn(dfd handle, directory, file string) (os.FileMode, [sha256.Size]byte, error) {
+	var sum [sha256.Size]byte
+	f, err := openFileAtIn(dfd, directory, file, os.O_RDONLY, 0)
+	if err != nil {
+		return 0, sum, err
+	}
+	defer f.Close()
+
+	fi, err := f.Stat()
+	if err != nil {
+		return 0, sum, err
+	}
+	h := sha256.New()
+	if _, err := io.Copy(h, f); err != nil {
+		return 0, sum, err
+	}
+	copy(sum[:], h.Sum(nil))
+	return fi.Mode().Perm(), sum, nil
+}
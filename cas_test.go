@@ -0,0 +1,123 @@
+// Copyright 2024 Google LLC.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package safeopen
+
+import (
+	"crypto/sha256"
+	"errors"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestCompareAndSwapFileAt(t *testing.T) {
+	filename := "counter"
+
+	tmpDir := t.TempDir()
+	if err := WriteFileAt(tmpDir, filename, []byte("0"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	stale := sha256.Sum256([]byte("stale"))
+	err := CompareAndSwapFileAt(tmpDir, filename, stale, []byte("1"))
+	if !errors.Is(err, ErrFileChanged) {
+		t.Errorf("CompareAndSwapFileAt(%q, %q) = %v, want %v", tmpDir, filename, err, ErrFileChanged)
+	}
+
+	const workers, increments = 4, 25
+	var wg sync.WaitGroup
+	for w := 0; w < workers; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := 0; i < increments; {
+				data, err := ReadFileAt(tmpDir, filename)
+				if err != nil {
+					// The file is never absent, even while a swap is in progress.
+					t.Errorf("ReadFileAt(%q, %q) error: %v", tmpDir, filename, err)
+					return
+				}
+				n, err := strconv.Atoi(string(data))
+				if err != nil {
+					t.Errorf("corrupted counter %q", data)
+					return
+				}
+				err = CompareAndSwapFileAt(tmpDir, filename, sha256.Sum256(data), []byte(strconv.Itoa(n+1)))
+				if errors.Is(err, ErrFileChanged) || errors.Is(err, ErrFileBusy) {
+					runtime.Gosched()
+					continue
+				}
+				if err != nil {
+					t.Errorf("CompareAndSwapFileAt(%q, %q) error: %v", tmpDir, filename, err)
+					return
+				}
+				i++
+			}
+		}()
+	}
+	wg.Wait()
+
+	sum, err := ContentHashAt(tmpDir, filename)
+	if err != nil {
+		t.Fatalf("ContentHashAt(%q, %q) error: %v", tmpDir, filename, err)
+	}
+	want := []byte(strconv.Itoa(workers * increments))
+	if sum != sha256.Sum256(want) {
+		data, _ := ReadFileAt(tmpDir, filename)
+		t.Errorf("counter = %q, want %q", data, want)
+	}
+}
+
+func TestCompareAndSwapFileAtStaleLock(t *testing.T) {
+	tmpDir := t.TempDir()
+	if err := WriteFileAt(tmpDir, "counter", []byte("0"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	lock := filepath.Join(tmpDir, ".counter.cas")
+	sum := sha256.Sum256([]byte("0"))
+
+	// A lock held by a running swap.
+	held := time.Now().Add(time.Minute).UTC().Format(time.RFC3339Nano) + " token\n"
+	if err := os.WriteFile(lock, []byte(held), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if err := CompareAndSwapFileAt(tmpDir, "counter", sum, []byte("1")); !errors.Is(err, ErrFileBusy) || errors.Is(err, ErrFileChanged) {
+		t.Errorf("CompareAndSwapFileAt() with a held lock error = %v, want %v", err, ErrFileBusy)
+	}
+
+	// A lock left by a crashed process.
+	expired := time.Now().Add(-time.Minute).UTC().Format(time.RFC3339Nano) + " token\n"
+	if err := os.WriteFile(lock, []byte(expired), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if err := CompareAndSwapFileAt(tmpDir, "counter", sum, []byte("1")); err != nil {
+		t.Errorf("CompareAndSwapFileAt() with an expired lock error: %v", err)
+	}
+	if data, err := ReadFileAt(tmpDir, "counter"); err != nil || string(data) != "1" {
+		t.Errorf("ReadFileAt() = %q, %v, want %q", data, err, "1")
+	}
+
+	entries, err := os.ReadDir(tmpDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 1 {
+		t.Errorf("directory entries = %v, want only the counter", entries)
+	}
+}
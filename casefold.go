@@ -0,0 +1,82 @@
+// Copyright 2024 Google LLC.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package safeopen
+
+import (
+	"errors"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// FindCaseInsensitiveBeneath returns the path of the existing file beneath the named directory
+// that matches relpath, ignoring case, with the exact case of the names on disk. This helps
+// with data coming from case-insensitive file systems, such as Windows ones, which refers to
+// files with a case that differs from the one on disk.
+//
+// The path is resolved one element at a time, by listing each directory. An exact match is
+// preferred; otherwise, an element matching several entries that only differ by their case is
+// ambiguous, and fails the lookup. Symbolic links to directories are not followed.
+// If no file matches, the returned error wraps fs.ErrNotExist.
+func FindCaseInsensitiveBeneath(directory, relpath string) (string, error) {
+	p, ok := cleanRelPath(relpath)
+	if !ok || !isRelPathBeneath(p) {
+		return "", &os.PathError{Op: "FindCaseInsensitiveBeneath", Path: relpath, Err: beneathPathError(relpath)}
+	}
+	dfd, err := openDirHandle(directory)
+	if err != nil {
+		return "", err
+	}
+	defer closeHandle(dfd)
+
+	found := "."
+	for _, elem := range splitRelPath(p) {
+		names, err := readDirNamesIn(dfd, found)
+		if err != nil {
+			return "", err
+		}
+		match, err := matchCaseInsensitive(names, elem)
+		if err != nil {
+			return "", &os.PathError{Op: "FindCaseInsensitiveBeneath", Path: relpath, Err: err}
+		}
+		found = filepath.Join(found, match)
+	}
+	return found, nil
+}
+
+// matchCaseInsensitive returns the name of names equal to name, or else the only one matching
+// name ignoring case.
+func matchCaseInsensitive(names []string, name string) (string, error) {
+	var match string
+	n := 0
+	for _, candidate := range names {
+		if candidate == name {
+			return candidate, nil
+		}
+		if strings.EqualFold(candidate, name) {
+			match = candidate
+			n++
+		}
+	}
+	switch n {
+	case 0:
+		return "", fs.ErrNotExist
+	case 1:
+		return match, nil
+	default:
+		return "", errors.New("ambiguous case-insensitive match")
+	}
+}
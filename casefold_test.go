@@ -0,0 +1,72 @@
+// Copyright 2024 Google LLC.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package safeopen
+
+import (
+	"errors"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestFindCaseInsensitiveBeneath(t *testing.T) {
+	tmpDir := t.TempDir()
+	if err := os.MkdirAll(filepath.Join(tmpDir, "Program Files", "App"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(tmpDir, "Program Files", "App", "Config.INI"), nil, 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	want := filepath.Join("Program Files", "App", "Config.INI")
+	for _, relpath := range []string{
+		"program files/app/config.ini",
+		"PROGRAM FILES/APP/CONFIG.INI",
+		"Program Files/App/Config.INI",
+		"./program files//app/config.ini",
+	} {
+		if got, err := FindCaseInsensitiveBeneath(tmpDir, relpath); err != nil || got != want {
+			t.Errorf("FindCaseInsensitiveBeneath(%q) = %q, %v, want %q, nil", relpath, got, err, want)
+		}
+	}
+	if _, err := FindCaseInsensitiveBeneath(tmpDir, "program files/app/missing.ini"); !errors.Is(err, fs.ErrNotExist) {
+		t.Errorf("FindCaseInsensitiveBeneath() of a missing file error = %v, want fs.ErrNotExist", err)
+	}
+	if _, err := FindCaseInsensitiveBeneath(tmpDir, "../etc/passwd"); err == nil {
+		t.Errorf("FindCaseInsensitiveBeneath(%q) succeeded, want error", "../etc/passwd")
+	}
+
+	// Names differing by their case only can coexist on case-sensitive file systems.
+	if err := os.WriteFile(filepath.Join(tmpDir, "readme"), nil, 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(tmpDir, "README"), nil, 0644); err != nil {
+		t.Fatal(err)
+	}
+	entries, err := os.ReadDir(tmpDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 3 {
+		t.Skip("case-insensitive file system")
+	}
+	if got, err := FindCaseInsensitiveBeneath(tmpDir, "README"); err != nil || got != "README" {
+		t.Errorf("FindCaseInsensitiveBeneath(%q) = %q, %v, want the exact match", "README", got, err)
+	}
+	if _, err := FindCaseInsensitiveBeneath(tmpDir, "ReadMe"); err == nil {
+		t.Errorf("FindCaseInsensitiveBeneath(%q) succeeded, want an ambiguity error", "ReadMe")
+	}
+}
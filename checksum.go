@@ -0,0 +1,62 @@
+// Copyright 2024 Google LLC.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package safeopen
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"errors"
+	"os"
+)
+
+// ErrChecksumMismatch is returned when the content of a file does not match the checksum stored
+// along with it.
+var ErrChecksumMismatch = errors.New("checksum mismatch")
+
+// checksumXattr is the extended attribute holding the SHA-256 hash of the content of a file.
+const checksumXattr = "user.safeopen.sha256"
+
+// WithChecksums makes d store the SHA-256 hash of the data written by WriteFile in an extended
+// attribute of the file, and ReadFile verify it, returning ErrChecksumMismatch if the file was
+// corrupted or modified by other means since. Files without the attribute are read without
+// verification.
+//
+// Checksums are only supported on Linux, macOS, FreeBSD and NetBSD, on file systems supporting
+// extended attributes (in the user namespace on Linux). Elsewhere, they are neither stored nor
+// verified.
+func WithChecksums() Option {
+	return func(o *options) {
+		o.checksums = true
+	}
+}
+
+// storeChecksum stores the checksum of data in f.
+func storeChecksum(f *os.File, data []byte) error {
+	sum := sha256.Sum256(data)
+	return setXattr(f, checksumXattr, sum[:])
+}
+
+// verifyChecksum checks data, read from f, against the checksum stored in f, if any.
+func verifyChecksum(f *os.File, file string, data []byte) error {
+	stored, err := getXattr(f, checksumXattr)
+	if err != nil || stored == nil {
+		return err
+	}
+	sum := sha256.Sum256(data)
+	if !bytes.Equal(stored, sum[:]) {
+		return &os.PathError{Op: "read", Path: file, Err: ErrChecksumMismatch}
+	}
+	return nil
+}
@@ -0,0 +1,69 @@
+// Copyright 2024 Google LLC.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package safeopen
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestChecksums(t *testing.T) {
+	tmpDir := t.TempDir()
+	d, err := OpenDir(tmpDir, WithChecksums())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer d.Close()
+
+	if err := d.WriteFile("data.txt", []byte("content"), 0644); err != nil {
+		t.Fatalf("WriteFile(%q) error: %v", "data.txt", err)
+	}
+	got, err := d.ReadFile("data.txt")
+	if err != nil {
+		t.Fatalf("ReadFile(%q) error: %v", "data.txt", err)
+	}
+	if string(got) != "content" {
+		t.Errorf("ReadFile(%q) = %q, want %q", "data.txt", got, "content")
+	}
+
+	if err := os.WriteFile(filepath.Join(tmpDir, "plain.txt"), []byte("content"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := d.ReadFile("plain.txt"); err != nil {
+		t.Errorf("ReadFile(%q) without checksum error: %v", "plain.txt", err)
+	}
+
+	f, err := os.OpenFile(filepath.Join(tmpDir, "data.txt"), os.O_WRONLY, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := f.WriteAt([]byte("C"), 0); err != nil {
+		t.Fatal(err)
+	}
+	stored, err := getXattr(f, checksumXattr)
+	f.Close()
+	if err == nil && stored == nil {
+		// Extended attributes are not supported here.
+		if _, err := d.ReadFile("data.txt"); err != nil {
+			t.Errorf("ReadFile(%q) error: %v", "data.txt", err)
+		}
+		return
+	}
+	if _, err := d.ReadFile("data.txt"); !errors.Is(err, ErrChecksumMismatch) {
+		t.Errorf("ReadFile(%q) of a modified file error = %v, want %v", "data.txt", err, ErrChecksumMismatch)
+	}
+}
@@ -0,0 +1,70 @@
+// Copyright 2024 Google LLC.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+//go:build linux || darwin || freebsd || netbsd
+// +build linux darwin freebsd netbsd
+
+package safeopen
+
+import (
+	"os"
+
+	"golang.org/x/sys/unix"
+)
+
+// setXattr sets the extended attribute name of f. It is a no-op if the file system does not
+// support extended attributes.
+func setXattr(f *os.File, name string, value []byte) error {
+	rc, err := f.SyscallConn()
+	if err != nil {
+		return err
+	}
+	var serr error
+	if err := rc.Control(func(fd uintptr) {
+		serr = unix.Fsetxattr(int(fd), name, value, 0)
+	}); err != nil {
+		return err
+	}
+	if serr == unix.ENOTSUP || serr == unix.EOPNOTSUPP {
+		return nil
+	}
+	if serr != nil {
+		return &os.PathError{Op: "setxattr", Path: f.Name(), Err: serr}
+	}
+	return nil
+}
+
+// getXattr returns the extended attribute name of f, or nil if it is not set or the file
+// system does not support extended attributes.
+func getXattr(f *os.File, name string) ([]byte, error) {
+	rc, err := f.SyscallConn()
+	if err != nil {
+		return nil, err
+	}
+	buf := make([]byte, 256)
+	var n int
+	var serr error
+	if err := rc.Control(func(fd uintptr) {
+		n, serr = unix.Fgetxattr(int(fd), name, buf)
+	}); err != nil {
+		return nil, err
+	}
+	if serr == unix.ENOTSUP || serr == unix.EOPNOTSUPP || serr == errNoXattr {
+		return nil, nil
+	}
+	if serr != nil {
+		return nil, &os.PathError{Op: "getxattr", Path: f.Name(), Err: serr}
+	}
+	return buf[:n], nil
+}
@@ -0,0 +1,116 @@
+// Copyright 2024 Google LLC.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package safeopen
+
+import (
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// ChmodAt changes the mode of the named file in the named directory to mode, as os.Chmod does.
+// file may not contain path separators. Symbolic links are not followed: changing the mode of a
+// symbolic link fails on the platforms that do not support it, such as Linux.
+// If there is an error, it will be of type *PathError.
+func ChmodAt(directory, file string, mode os.FileMode) error {
+	if !isFilename(file) {
+		return &os.PathError{Op: "ChmodAt", Path: file, Err: ErrInvalidFilename}
+	}
+	return ChmodBeneath(directory, file, mode)
+}
+
+// ChmodBeneath changes the mode of the named file in the named directory, or a subdirectory,
+// to mode, as os.Chmod does. file may not contain .. path traversal entries, and the directories
+// leading to it are resolved as by OpenBeneath. Symbolic links are not followed in the last
+// element of file: changing the mode of a symbolic link fails on the platforms that do not
+// support it, such as Linux.
+// If there is an error, it will be of type *PathError.
+func ChmodBeneath(directory, file string, mode os.FileMode) error {
+	return inParentBeneath(directory, file, "chmod", func(pfd handle, base string) error {
+		return chmodIn(pfd, base, mode)
+	})
+}
+
+// ChownAt changes the numeric uid and gid of the named file in the named directory, as os.Lchown
+// does: if the file is a symbolic link, the link itself is changed. A uid or gid of -1 means to
+// not change that value. file may not contain path separators. ChownAt is not supported on
+// Windows.
+// If there is an error, it will be of type *PathError.
+func ChownAt(directory, file string, uid, gid int) error {
+	if !isFilename(file) {
+		return &os.PathError{Op: "ChownAt", Path: file, Err: ErrInvalidFilename}
+	}
+	return ChownBeneath(directory, file, uid, gid)
+}
+
+// ChownBeneath changes the numeric uid and gid of the named file in the named directory, or a
+// subdirectory, as os.Lchown does. file may not contain .. path traversal entries, and the
+// directories leading to it are resolved as by OpenBeneath. ChownBeneath is not supported on
+// Windows.
+// If there is an error, it will be of type *PathError.
+func ChownBeneath(directory, file string, uid, gid int) error {
+	return inParentBeneath(directory, file, "chown", func(pfd handle, base string) error {
+		return chownIn(pfd, base, uid, gid)
+	})
+}
+
+// ChtimesAt changes the access and modification times of the named file in the named directory,
+// as os.Chtimes does, e.g. to restore them from a backup. A zero time.Time value leaves the
+// corresponding time unchanged. file may not contain path separators. Symbolic links are not
+// followed: their own times are changed, where the platform supports it.
+// If there is an error, it will be of type *PathError.
+func ChtimesAt(directory, file string, atime, mtime time.Time) error {
+	if !isFilename(file) {
+		return &os.PathError{Op: "ChtimesAt", Path: file, Err: ErrInvalidFilename}
+	}
+	return ChtimesBeneath(directory, file, atime, mtime)
+}
+
+// ChtimesBeneath changes the access and modification times of the named file in the named
+// directory, or a subdirectory, as os.Chtimes does. A zero time.Time value leaves the
+// corresponding time unchanged. file may not contain .. path traversal entries, and the
+// directories leading to it are resolved as by OpenBeneath. Symbolic links are not followed in
+// the last element of file.
+// If there is an error, it will be of type *PathError.
+func ChtimesBeneath(directory, file string, atime, mtime time.Time) error {
+	return inParentBeneath(directory, file, "chtimes", func(pfd handle, base string) error {
+		return chtimesIn(pfd, base, atime, mtime)
+	})
+}
+
+// inParentBeneath calls fn with the directory containing file beneath directory and the last
+// element of file, which must name a file in it. Errors of fn are reported as errors of op.
+func inParentBeneath(directory, file, op string, fn func(pfd handle, base string) error) error {
+	dfd, err := openDirHandle(directory)
+	if err != nil {
+		return err
+	}
+	defer closeHandle(dfd)
+
+	pfd, base, err := openParentIn(dfd, file)
+	if err != nil {
+		return routedError(op, err, filepath.Join(directory, file))
+	}
+	if pfd != dfd {
+		defer closeHandle(pfd)
+	}
+	if !isFilename(base) {
+		return &os.PathError{Op: op, Path: file, Err: ErrInvalidFilename}
+	}
+	if err := fn(pfd, base); err != nil {
+		return &os.PathError{Op: op, Path: filepath.Join(directory, file), Err: err}
+	}
+	return nil
+}
@@ -0,0 +1,115 @@
+// Copyright 2024 Google LLC.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package safeopen
+
+import (
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+	"time"
+)
+
+func TestChmod(t *testing.T) {
+	if runtime.GOOS == "wasip1" {
+		t.Skip("WASI has no permission bits")
+	}
+	dir := t.TempDir()
+	outside := t.TempDir()
+	if err := os.Mkdir(filepath.Join(dir, "sub"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	for _, file := range []string{filepath.Join(dir, "file"), filepath.Join(dir, "sub", "file"), filepath.Join(outside, "target")} {
+		if err := os.WriteFile(file, nil, 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// Only the read-only attribute can be changed on Windows.
+	want := os.FileMode(0600)
+	if runtime.GOOS == "windows" {
+		want = 0444
+	}
+
+	if err := ChmodAt(dir, "file", want); err != nil {
+		t.Errorf("ChmodAt(file) error: %v", err)
+	}
+	if err := ChmodBeneath(dir, filepath.Join("sub", "file"), want); err != nil {
+		t.Errorf("ChmodBeneath(sub/file) error: %v", err)
+	}
+	for _, file := range []string{"file", filepath.Join("sub", "file")} {
+		if fi, err := os.Stat(filepath.Join(dir, file)); err != nil || fi.Mode().Perm() != want {
+			t.Errorf("%s has mode %v, %v, want %v", file, fi.Mode(), err, want)
+		}
+	}
+	for _, file := range []string{filepath.Join("sub", "file"), "..", ""} {
+		if err := ChmodAt(dir, file, want); err == nil {
+			t.Errorf("ChmodAt(%q) should have been an error", file)
+		}
+	}
+	if err := ChmodBeneath(dir, filepath.Join("..", filepath.Base(outside), "target"), want); err == nil {
+		t.Error("ChmodBeneath(../target) should have been an error")
+	}
+
+	if err := os.Symlink(filepath.Join(outside, "target"), filepath.Join(dir, "link")); err != nil {
+		t.Skipf("cannot create symbolic links: %v", err)
+	}
+	ChmodAt(dir, "link", want)
+	ChmodBeneath(dir, "link", want)
+	if fi, err := os.Stat(filepath.Join(outside, "target")); err != nil || fi.Mode().Perm() == want {
+		t.Errorf("the target of a symbolic link has mode %v, %v, want it unchanged", fi.Mode(), err)
+	}
+}
+
+func TestChtimes(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.Mkdir(filepath.Join(dir, "sub"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "sub", "file"), nil, 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	mtime := time.Date(2001, 2, 3, 4, 5, 6, 0, time.UTC)
+	if err := ChtimesBeneath(dir, filepath.Join("sub", "file"), time.Time{}, mtime); err != nil {
+		t.Fatalf("ChtimesBeneath(sub/file) error: %v", err)
+	}
+	fi, err := os.Stat(filepath.Join(dir, "sub", "file"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !fi.ModTime().Equal(mtime) {
+		t.Errorf("sub/file modified at %v, want %v", fi.ModTime(), mtime)
+	}
+
+	// A zero modification time is left unchanged.
+	if err := ChtimesAt(dir, "sub", mtime, time.Time{}); err != nil {
+		t.Fatalf("ChtimesAt(sub) error: %v", err)
+	}
+	if err := ChtimesBeneath(dir, filepath.Join("sub", "file"), mtime, time.Time{}); err != nil {
+		t.Fatalf("ChtimesBeneath(sub/file) error: %v", err)
+	}
+	if fi, err := os.Stat(filepath.Join(dir, "sub", "file")); err != nil || !fi.ModTime().Equal(mtime) {
+		t.Errorf("sub/file modified at %v, %v, want %v", fi.ModTime(), err, mtime)
+	}
+
+	for _, file := range []string{filepath.Join("sub", "file"), "..", ""} {
+		if err := ChtimesAt(dir, file, mtime, mtime); err == nil {
+			t.Errorf("ChtimesAt(%q) should have been an error", file)
+		}
+	}
+	if err := ChtimesBeneath(dir, filepath.Join("..", "file"), mtime, mtime); err == nil {
+		t.Error("ChtimesBeneath(../file) should have been an error")
+	}
+}
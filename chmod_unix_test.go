@@ -0,0 +1,54 @@
+// Copyright 2024 Google LLC.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+//go:build unix
+// +build unix
+
+package safeopen
+
+import (
+	"os"
+	"path/filepath"
+	"syscall"
+	"testing"
+)
+
+func TestChown(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "file"), nil, 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Symlink("file", filepath.Join(dir, "link")); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := ChownAt(dir, "file", -1, -1); err != nil {
+		t.Errorf("ChownAt(file, -1, -1) error: %v", err)
+	}
+	if os.Geteuid() != 0 {
+		t.Skip("changing owners requires root")
+	}
+	if err := ChownBeneath(dir, "link", 65534, 65534); err != nil {
+		t.Fatalf("ChownBeneath(link) error: %v", err)
+	}
+	for file, want := range map[string]uint32{"link": 65534, "file": 0} {
+		fi, err := os.Lstat(filepath.Join(dir, file))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if st := fi.Sys().(*syscall.Stat_t); st.Uid != want || st.Gid != want {
+			t.Errorf("%s is owned by %d:%d, want %d:%d", file, st.Uid, st.Gid, want, want)
+		}
+	}
+}
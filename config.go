@@ -0,0 +1,119 @@
+// Copyright 2024 Google LLC.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package safeopen
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+)
+
+// ConfigEnv is the environment variable read by ConfigFromEnv.
+const ConfigEnv = "SAFEOPEN_CONFIG"
+
+// Config holds the program-wide defaults of the package. Its zero value is the default
+// configuration. The defaults apply to the Dirs and to the package-level Open, Create, ReadFile,
+// WriteFile and AppendFile functions; the other package-level functions are not affected.
+type Config struct {
+	// Strict enables strict mode, see SetStrictMode.
+	Strict bool
+	// SymlinkPolicy is the symlink policy of the Dirs opened without WithSymlinkPolicy, and of
+	// the Beneath functions. The At functions never follow symbolic links.
+	SymlinkPolicy SymlinkPolicy
+	// RegularFilesOnly makes all Dirs behave as if opened with WithRegularFilesOnly, and the
+	// functions opening files fail on anything else than regular files.
+	RegularFilesOnly bool
+}
+
+var (
+	configured atomic.Bool
+	config     atomic.Pointer[Config]
+)
+
+// Configure sets the program-wide configuration. It may only be called once, typically from
+// an init function of the main package, before the package is used; later calls fail.
+func Configure(c Config) error {
+	if !configured.CompareAndSwap(false, true) {
+		return errors.New("safeopen: already configured")
+	}
+	config.Store(&c)
+	SetStrictMode(c.Strict)
+	return nil
+}
+
+// ConfigFromEnv returns a Config parsed from the ConfigEnv environment variable, to be passed
+// to Configure. The variable holds a comma separated list of settings:
+//
+//	strict              enables Strict
+//	regular             enables RegularFilesOnly
+//	symlinks=samedir    sets SymlinkPolicy to AllowSameDirSymlinks()
+//	symlinks=prefix:P   sets SymlinkPolicy to AllowSymlinkPrefixes with the prefixes in the
+//	                    list P, separated by filepath.ListSeparator
+//
+// An unset or empty variable yields the zero Config.
+func ConfigFromEnv() (Config, error) {
+	var c Config
+	for _, setting := range strings.Split(os.Getenv(ConfigEnv), ",") {
+		key, value, _ := strings.Cut(strings.TrimSpace(setting), "=")
+		switch {
+		case key == "" && value == "":
+		case key == "strict" && value == "":
+			c.Strict = true
+		case key == "regular" && value == "":
+			c.RegularFilesOnly = true
+		case key == "symlinks" && value == "samedir":
+			c.SymlinkPolicy = AllowSameDirSymlinks()
+		case key == "symlinks" && strings.HasPrefix(value, "prefix:"):
+			c.SymlinkPolicy = AllowSymlinkPrefixes(filepath.SplitList(strings.TrimPrefix(value, "prefix:"))...)
+		default:
+			return Config{}, fmt.Errorf("safeopen: invalid setting %q in %s", setting, ConfigEnv)
+		}
+	}
+	return c, nil
+}
+
+// defaultOptions returns the options of a Dir before its own options are applied.
+func defaultOptions() options {
+	var o options
+	if c := config.Load(); c != nil {
+		o.symlinkPolicy = c.SymlinkPolicy
+		o.regularOnly = c.RegularFilesOnly
+	}
+	return o
+}
+
+// configuredBeneath reports whether the configuration changes how the Beneath functions open
+// files, in which case they open them through a Dir with the configured defaults.
+func configuredBeneath() bool {
+	c := config.Load()
+	return c != nil && (c.SymlinkPolicy != nil || c.RegularFilesOnly)
+}
+
+// checkConfiguredRegular closes f and returns an error if the configuration requires regular
+// files and f is not one.
+func checkConfiguredRegular(f *os.File, file string) error {
+	c := config.Load()
+	if c == nil || !c.RegularFilesOnly {
+		return nil
+	}
+	if err := checkRegular(f, file); err != nil {
+		f.Close()
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,128 @@
+// Copyright 2024 Google LLC.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package safeopen
+
+import (
+	"os"
+	"path"
+	"testing"
+)
+
+func TestConfigFromEnv(t *testing.T) {
+	tests := []struct {
+		env        string
+		wantStrict bool
+		wantReg    bool
+		wantPolicy bool
+		wantErr    bool
+	}{
+		{env: ""},
+		{env: "strict", wantStrict: true},
+		{env: "strict, regular", wantStrict: true, wantReg: true},
+		{env: "symlinks=samedir", wantPolicy: true},
+		{env: "symlinks=prefix:releases", wantPolicy: true},
+		{env: "strict=0", wantErr: true},
+		{env: "symlinks=all", wantErr: true},
+		{env: "unknown", wantErr: true},
+	}
+	for _, tc := range tests {
+		t.Setenv(ConfigEnv, tc.env)
+		c, err := ConfigFromEnv()
+		if (err != nil) != tc.wantErr {
+			t.Errorf("ConfigFromEnv() with %q error = %v, want error: %t", tc.env, err, tc.wantErr)
+			continue
+		}
+		if c.Strict != tc.wantStrict || c.RegularFilesOnly != tc.wantReg || (c.SymlinkPolicy != nil) != tc.wantPolicy {
+			t.Errorf("ConfigFromEnv() with %q = %+v", tc.env, c)
+		}
+	}
+}
+
+func TestConfigure(t *testing.T) {
+	t.Cleanup(func() {
+		configured.Store(false)
+		config.Store(nil)
+		SetStrictMode(false)
+	})
+
+	if err := Configure(Config{RegularFilesOnly: true}); err != nil {
+		t.Fatalf("Configure() error: %v", err)
+	}
+	if err := Configure(Config{}); err == nil {
+		t.Errorf("second Configure() succeeded, want error")
+	}
+
+	tmpDir := t.TempDir()
+	if err := os.Mkdir(path.Join(tmpDir, "subdir"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path.Join(tmpDir, "data.txt"), []byte("content"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	d, err := OpenDir(tmpDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer d.Close()
+
+	if _, err := d.ReadFile("data.txt"); err != nil {
+		t.Errorf("ReadFile(%q) error: %v", "data.txt", err)
+	}
+	if _, err := d.ReadFile("subdir"); err == nil {
+		t.Errorf("ReadFile(%q) succeeded with RegularFilesOnly, want error", "subdir")
+	}
+
+	// The package-level functions honor the configuration too.
+	if _, err := ReadFileAt(tmpDir, "data.txt"); err != nil {
+		t.Errorf("ReadFileAt(%q) error: %v", "data.txt", err)
+	}
+	for name, open := range map[string]func(directory, file string) (*os.File, error){"OpenAt": OpenAt, "OpenBeneath": OpenBeneath} {
+		if f, err := open(tmpDir, "subdir"); err == nil {
+			f.Close()
+			t.Errorf("%s(%q) succeeded with RegularFilesOnly, want error", name, "subdir")
+		}
+	}
+	if _, err := ReadFilesAt(tmpDir, []string{"data.txt", "subdir"}); err == nil {
+		t.Errorf("ReadFilesAt(%q) succeeded with RegularFilesOnly, want error", "subdir")
+	}
+}
+
+func TestConfigureSymlinkPolicy(t *testing.T) {
+	t.Cleanup(func() {
+		configured.Store(false)
+		config.Store(nil)
+	})
+
+	tmpDir := t.TempDir()
+	if err := os.Mkdir(path.Join(tmpDir, "subdir"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path.Join(tmpDir, "data.txt"), []byte("content"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Symlink("../data.txt", path.Join(tmpDir, "subdir", "link")); err != nil {
+		t.Skipf("os.Symlink() error: %v", err)
+	}
+	if _, err := ReadFileBeneath(tmpDir, "subdir/link"); err != nil {
+		t.Fatalf("ReadFileBeneath(%q) error: %v", "subdir/link", err)
+	}
+
+	if err := Configure(Config{SymlinkPolicy: AllowSameDirSymlinks()}); err != nil {
+		t.Fatalf("Configure() error: %v", err)
+	}
+	if _, err := ReadFileBeneath(tmpDir, "subdir/link"); err == nil {
+		t.Errorf("ReadFileBeneath(%q) succeeded with AllowSameDirSymlinks, want error", "subdir/link")
+	}
+}
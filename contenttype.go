@@ -0,0 +1,66 @@
+// Copyright 2024 Google LLC.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package safeopen
+
+import (
+	"io"
+	"mime"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// sniffLen is the number of bytes http.DetectContentType considers.
+const sniffLen = 512
+
+// DetectContentTypeAt returns the MIME type of the named file in the named directory, as
+// determined by http.DetectContentType from its first 512 bytes.
+// file may not contain path separators.
+//
+// If the content is not conclusive (i.e. it is detected as generic binary or plain text data),
+// the type registered for the file extension is returned instead, if any.
+func DetectContentTypeAt(directory, file string) (string, error) {
+	return detectContentType(directory, file, OpenFileAt)
+}
+
+// DetectContentTypeBeneath is the equivalent of DetectContentTypeAt for a file in the named
+// directory, or a subdirectory.
+// file may not contain .. path traversal entries.
+func DetectContentTypeBeneath(directory, file string) (string, error) {
+	return detectContentType(directory, file, OpenFileBeneath)
+}
+
+func detectContentType(directory, file string, opener openerFunc) (string, error) {
+	f, err := opener(directory, file, os.O_RDONLY, 0)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+
+	buf := make([]byte, sniffLen)
+	n, err := io.ReadFull(f, buf)
+	if err != nil && err != io.EOF && err != io.ErrUnexpectedEOF {
+		return "", err
+	}
+
+	ctype := http.DetectContentType(buf[:n])
+	if ctype == "application/octet-stream" || strings.HasPrefix(ctype, "text/plain") {
+		if byExt := mime.TypeByExtension(filepath.Ext(file)); byExt != "" {
+			return byExt, nil
+		}
+	}
+	return ctype, nil
+}
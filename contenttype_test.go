@@ -0,0 +1,59 @@
+// Copyright 2024 Google LLC.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package safeopen
+
+import (
+	"os"
+	"path"
+	"strings"
+	"testing"
+)
+
+func TestDetectContentType(t *testing.T) {
+	tmpDir := t.TempDir()
+	if err := os.Mkdir(path.Join(tmpDir, "subdir"), 0755); err != nil {
+		t.Fatal(err)
+	}
+
+	type testCase struct {
+		file    string
+		content string
+		want    string
+	}
+	testCases := []testCase{
+		{"page.dat", "<html><body>hello</body></html>", "text/html"},
+		{"image.txt", "\x89PNG\x0D\x0A\x1A\x0A", "image/png"},
+		{"style.css", "body { color: red; }", "text/css"},
+		{"empty", "", "text/plain"},
+		{"subdir/data", "\x00\x01\x02", "application/octet-stream"},
+	}
+	for _, tc := range testCases {
+		if err := os.WriteFile(path.Join(tmpDir, tc.file), []byte(tc.content), 0644); err != nil {
+			t.Fatal(err)
+		}
+		ctype, err := DetectContentTypeBeneath(tmpDir, tc.file)
+		if err != nil {
+			t.Errorf("DetectContentTypeBeneath(%q, %q) error: %v", tmpDir, tc.file, err)
+			continue
+		}
+		if !strings.HasPrefix(ctype, tc.want) {
+			t.Errorf("DetectContentTypeBeneath(%q, %q) = %q, want %q", tmpDir, tc.file, ctype, tc.want)
+		}
+	}
+
+	if _, err := DetectContentTypeAt(tmpDir, "subdir/data"); err == nil {
+		t.Errorf("DetectContentTypeAt(%q, %q) should have been an error", tmpDir, "subdir/data")
+	}
+}
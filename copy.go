@@ -0,0 +1,256 @@
+// Copyright 2024 Google LLC.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package safeopen
+
+import (
+	"errors"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+)
+
+// CopyFileBeneath copies the named file srcPath in the directory srcDir to dstPath in the
+// directory dstDir, or subdirectories of them. Neither path may contain .. path traversal
+// entries, and both are opened as by OpenFileBeneath, so that e.g. files can be promoted from a
+// quarantine directory without either end escaping its directory. dstPath is created with mode
+// perm (before umask) if it does not exist, and truncated otherwise.
+//
+// The data is copied by the kernel where possible, with copy_file_range or sendfile on Linux.
+// If the copy fails, dstPath may be left partially written.
+// If there is an error, it will be of type *PathError.
+func CopyFileBeneath(srcDir, srcPath, dstDir, dstPath string, perm os.FileMode) error {
+	return copyFile(srcDir, srcPath, dstDir, dstPath, perm, OpenFileBeneath)
+}
+
+func copyFile(srcDir, srcPath, dstDir, dstPath string, perm os.FileMode, opener openerFunc) error {
+	src, err := opener(srcDir, srcPath, os.O_RDONLY, 0)
+	if err != nil {
+		return err
+	}
+	defer src.Close()
+
+	dst, err := opener(dstDir, dstPath, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, perm)
+	if err != nil {
+		return err
+	}
+	// *os.File implements io.ReaderFrom with copy_file_range and sendfile where available.
+	_, err = io.Copy(dst, src)
+	if err1 := dst.Close(); err1 != nil && err == nil {
+		err = err1
+	}
+	return err
+}
+
+// CopyTreeBeneath recursively copies the directory srcPath in the directory srcDir to dstPath in
+// the directory dstDir, or subdirectories of them. Neither path may contain .. path traversal
+// entries, and symbolic links are not followed in them. dstPath must not exist, but its parent
+// must, and must not be srcPath or one of its subdirectories. The permission bits and
+// modification times of the files and directories are preserved.
+//
+// The tree is walked and created relative to the handles of the directories, never by path, so
+// that neither end escapes its directory even if the trees are modified concurrently. Symbolic
+// links are copied as is if their target stays in the copied tree, as checked by
+// SymlinkTargetBeneath with linknames relative to srcPath, and is accepted by policy. Otherwise,
+// or if policy is nil, the copy fails with an error wrapping ErrSymlinkNotAllowed. Other special
+// files, such as devices, are not supported.
+// If the copy fails, dstPath may be left partially populated.
+// If there is an error, it will be of type *PathError.
+func CopyTreeBeneath(srcDir, srcPath, dstDir, dstPath string, policy SymlinkPolicy) error {
+	sdfd, err := openDirHandle(srcDir)
+	if err != nil {
+		return err
+	}
+	defer closeHandle(sdfd)
+	ddfd, err := openDirHandle(dstDir)
+	if err != nil {
+		return err
+	}
+	defer closeHandle(ddfd)
+
+	src, err := openDirNoFollowIn(sdfd, srcDir, srcPath)
+	if err != nil {
+		return routedError("open", err, filepath.Join(srcDir, srcPath))
+	}
+	defer src.Close()
+	fi, err := src.Stat()
+	if err != nil {
+		return err
+	}
+	if !fi.IsDir() {
+		return &os.PathError{Op: "CopyTreeBeneath", Path: filepath.Join(srcDir, srcPath), Err: errors.New("not a directory")}
+	}
+
+	pfd, base, err := openParentIn(ddfd, dstPath)
+	if err != nil {
+		return routedError("mkdir", err, filepath.Join(dstDir, dstPath))
+	}
+	if pfd != ddfd {
+		defer closeHandle(pfd)
+	}
+	if !isFilename(base) {
+		return &os.PathError{Op: "CopyTreeBeneath", Path: dstPath, Err: ErrInvalidFilename}
+	}
+	if err := checkCopyDestIn(ddfd, dstDir, dstPath, fi); err != nil {
+		return err
+	}
+	return copyTreeIn(src, ".", pfd, filepath.Dir(filepath.Join(dstDir, dstPath)), base, fi, policy)
+}
+
+// checkCopyDestIn returns an error if the parent of dstPath, beneath ddfd named dstDir, is the
+// source directory described by fi or one of its subdirectories, as copying a tree into itself
+// never ends.
+func checkCopyDestIn(ddfd handle, dstDir, dstPath string, fi fs.FileInfo) error {
+	inSource := &os.PathError{Op: "CopyTreeBeneath", Path: filepath.Join(dstDir, dstPath), Err: errors.New("destination inside the source directory")}
+	for dir := filepath.Dir(filepath.Clean(dstPath)); dir != "." && dir != string(filepath.Separator); dir = filepath.Dir(dir) {
+		d, err := openDirNoFollowIn(ddfd, dstDir, dir)
+		if err != nil {
+			return routedError("open", err, filepath.Join(dstDir, dir))
+		}
+		dfi, err := d.Stat()
+		d.Close()
+		if err != nil {
+			return err
+		}
+		if os.SameFile(fi, dfi) {
+			return inSource
+		}
+	}
+	// dstDir and its ancestors are only compared to the source, so stating them by path cannot
+	// make the copy escape dstDir.
+	dir, err := filepath.Abs(dstDir)
+	if err != nil {
+		return err
+	}
+	for {
+		if dfi, err := os.Stat(dir); err == nil && os.SameFile(fi, dfi) {
+			return inSource
+		}
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			return nil
+		}
+		dir = parent
+	}
+}
+
+// copyTreeIn copies the directory src, at rel in the copied tree and described by fi, to the new
+// directory name in the directory referred by dpfd and named dpname.
+func copyTreeIn(src *os.File, rel string, dpfd handle, dpname, name string, fi fs.FileInfo, policy SymlinkPolicy) error {
+	// Read the entries before creating the destination, which must not be copied into itself.
+	entries, err := src.ReadDir(-1)
+	if err != nil {
+		return routedError("readdir", err, src.Name())
+	}
+	sort.Slice(entries, func(i, j int) bool { return entries[i].Name() < entries[j].Name() })
+
+	// Keep the directory writable until its content is copied.
+	if err := mkdirIn(dpfd, name, 0700); err != nil {
+		return &os.PathError{Op: "mkdir", Path: filepath.Join(dpname, name), Err: err}
+	}
+	dst, err := openDirNoFollowIn(dpfd, dpname, name)
+	if err != nil {
+		return err
+	}
+	defer dst.Close()
+	sfd, dfd := handle(src.Fd()), handle(dst.Fd())
+	for _, e := range entries {
+		efi, err := statIn(sfd, e.Name())
+		if err != nil {
+			return routedError("lstat", err, filepath.Join(src.Name(), e.Name()))
+		}
+		erel := filepath.Join(rel, e.Name())
+		switch mode := efi.Mode(); {
+		case mode.IsDir():
+			sub, err := openDirNoFollowIn(sfd, src.Name(), e.Name())
+			if err != nil {
+				return routedError("open", err, filepath.Join(src.Name(), e.Name()))
+			}
+			err = copyTreeIn(sub, erel, dfd, dst.Name(), e.Name(), efi, policy)
+			sub.Close()
+			if err != nil {
+				return err
+			}
+		case mode.IsRegular():
+			if err := copyFileIn(src, dst, e.Name(), efi); err != nil {
+				return err
+			}
+		case mode.Type() == fs.ModeSymlink:
+			if err := copySymlinkIn(src, dst, e.Name(), erel, policy); err != nil {
+				return err
+			}
+		default:
+			return &os.PathError{Op: "CopyTreeBeneath", Path: filepath.Join(src.Name(), e.Name()), Err: errors.New("unsupported file type")}
+		}
+	}
+	return setModeAndTimesIn(dpfd, dpname, name, fi)
+}
+
+// copyFileIn copies the regular file name, described by fi, from the directory src to the
+// directory dst.
+func copyFileIn(src, dst *os.File, name string, fi fs.FileInfo) error {
+	sf, err := openFileNoFollowIn(handle(src.Fd()), src.Name(), name, os.O_RDONLY, 0)
+	if err != nil {
+		return routedError("open", err, filepath.Join(src.Name(), name))
+	}
+	defer sf.Close()
+	df, err := openFileAtIn(handle(dst.Fd()), dst.Name(), name, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0600)
+	if err != nil {
+		return routedError("open", err, filepath.Join(dst.Name(), name))
+	}
+	_, err = io.Copy(df, sf)
+	if err1 := df.Close(); err1 != nil && err == nil {
+		err = err1
+	}
+	if err != nil {
+		return err
+	}
+	return setModeAndTimesIn(handle(dst.Fd()), dst.Name(), name, fi)
+}
+
+// copySymlinkIn copies the symbolic link name, at rel in the copied tree, from the directory src
+// to the directory dst, if its target stays in the copied tree and is accepted by policy.
+func copySymlinkIn(src, dst *os.File, name, rel string, policy SymlinkPolicy) error {
+	target, err := readlinkIn(handle(src.Fd()), name)
+	if err != nil {
+		return routedError("readlink", err, filepath.Join(src.Name(), name))
+	}
+	if policy == nil {
+		err = ErrSymlinkNotAllowed
+	} else {
+		err = checkSymlinkTarget(target, rel, policy)
+	}
+	if err != nil {
+		return &os.PathError{Op: "CopyTreeBeneath", Path: filepath.Join(src.Name(), name), Err: err}
+	}
+	if err := symlinkIn(handle(dst.Fd()), target, name); err != nil {
+		return &os.PathError{Op: "symlink", Path: filepath.Join(dst.Name(), name), Err: err}
+	}
+	return nil
+}
+
+// setModeAndTimesIn sets the permission bits and the modification time of fi on name, in the
+// directory referred by dfd and named dname.
+func setModeAndTimesIn(dfd handle, dname, name string, fi fs.FileInfo) error {
+	if err := chtimesIn(dfd, name, time.Time{}, fi.ModTime()); err != nil {
+		return &os.PathError{Op: "chtimes", Path: filepath.Join(dname, name), Err: err}
+	}
+	if err := chmodIn(dfd, name, fi.Mode().Perm()); err != nil {
+		return &os.PathError{Op: "chmod", Path: filepath.Join(dname, name), Err: err}
+	}
+	return nil
+}
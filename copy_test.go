@@ -0,0 +1,183 @@
+// Copyright 2024 Google LLC.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package safeopen
+
+import (
+	"bytes"
+	"errors"
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+	"time"
+)
+
+func TestCopyFileBeneath(t *testing.T) {
+	quarantine := t.TempDir()
+	published := t.TempDir()
+	if err := os.Mkdir(filepath.Join(published, "v1"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	content := bytes.Repeat([]byte("0123456789"), 100000)
+	if err := os.WriteFile(filepath.Join(quarantine, "upload"), content, 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(published, "v1", "artifact"), []byte("previous, longer content"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, dst := range []string{"artifact", filepath.Join("v1", "artifact")} {
+		if err := CopyFileBeneath(quarantine, "upload", published, dst, 0644); err != nil {
+			t.Fatalf("CopyFileBeneath(upload, %q) error: %v", dst, err)
+		}
+		data, err := os.ReadFile(filepath.Join(published, dst))
+		if err != nil || !bytes.Equal(data, content) {
+			t.Errorf("%s has %d bytes, %v, want a copy of upload", dst, len(data), err)
+		}
+	}
+
+	escape := filepath.Join("..", filepath.Base(published), "artifact")
+	for _, tc := range [][2]string{{escape, "copy"}, {"upload", escape}, {"missing", "copy"}, {"upload", filepath.Join("missing", "copy")}} {
+		if err := CopyFileBeneath(quarantine, tc[0], quarantine, tc[1], 0644); err == nil {
+			t.Errorf("CopyFileBeneath(%q, %q) should have been an error", tc[0], tc[1])
+		}
+	}
+	if _, err := os.Stat(filepath.Join(quarantine, "copy")); err == nil {
+		t.Error("copy should not have been created")
+	}
+}
+
+func TestCopyTreeBeneath(t *testing.T) {
+	src := t.TempDir()
+	dst := t.TempDir()
+	for _, dir := range []string{"pkg", filepath.Join("pkg", "bin"), filepath.Join("pkg", "empty")} {
+		if err := os.Mkdir(filepath.Join(src, dir), 0755); err != nil {
+			t.Fatal(err)
+		}
+	}
+	files := map[string]string{
+		"README":                    "readme",
+		filepath.Join("bin", "run"): "#!/bin/sh",
+	}
+	for file, content := range files {
+		if err := os.WriteFile(filepath.Join(src, "pkg", file), []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	mtime := time.Date(2020, 1, 2, 3, 4, 5, 0, time.UTC)
+	for _, file := range []string{filepath.Join("pkg", "README"), filepath.Join("pkg", "empty")} {
+		if err := os.Chtimes(filepath.Join(src, file), mtime, mtime); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// Only the read-only attribute can be preserved on Windows, and WASI has no permission bits.
+	if runtime.GOOS != "windows" {
+		if err := os.Chmod(filepath.Join(src, "pkg", "bin", "run"), 0755); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if err := CopyTreeBeneath(src, "pkg", dst, "installed", nil); err != nil {
+		t.Fatalf("CopyTreeBeneath error: %v", err)
+	}
+	for file, content := range files {
+		installed := filepath.Join(dst, "installed", file)
+		data, err := os.ReadFile(installed)
+		if err != nil || string(data) != content {
+			t.Errorf("%s = %q, %v, want %q", installed, data, err, content)
+		}
+	}
+	for _, tc := range []struct {
+		file  string
+		mode  os.FileMode
+		mtime bool
+	}{
+		{filepath.Join("installed", "README"), 0644, true},
+		{filepath.Join("installed", "empty"), 0755 | os.ModeDir, true},
+		{filepath.Join("installed", "bin", "run"), 0755, false},
+	} {
+		fi, err := os.Stat(filepath.Join(dst, tc.file))
+		if err != nil {
+			t.Errorf("%s is missing: %v", tc.file, err)
+			continue
+		}
+		if runtime.GOOS != "windows" && runtime.GOOS != "wasip1" && fi.Mode() != tc.mode {
+			t.Errorf("%s has mode %v, want %v", tc.file, fi.Mode(), tc.mode)
+		}
+		if tc.mtime && !fi.ModTime().Equal(mtime) {
+			t.Errorf("%s has mtime %v, want %v", tc.file, fi.ModTime(), mtime)
+		}
+	}
+
+	if err := CopyTreeBeneath(src, "pkg", dst, "installed", nil); err == nil {
+		t.Error("CopyTreeBeneath over an existing directory should have been an error")
+	}
+	for _, tc := range [][2]string{{filepath.Join("..", filepath.Base(dst)), "copy"}, {"pkg", filepath.Join("..", "copy")}, {filepath.Join("pkg", "README"), "copy"}} {
+		if err := CopyTreeBeneath(src, tc[0], dst, tc[1], nil); err == nil {
+			t.Errorf("CopyTreeBeneath(%q, %q) should have been an error", tc[0], tc[1])
+		}
+	}
+
+	switch runtime.GOOS {
+	case "windows", "aix", "dragonfly", "solaris", "illumos":
+		return
+	}
+	// The link stays in src, but not in the copied tree.
+	if err := os.Symlink(filepath.Join("..", "..", "secret"), filepath.Join(src, "pkg", "bin", "doc")); err != nil {
+		t.Fatal(err)
+	}
+	if err := CopyTreeBeneath(src, "pkg", dst, "escaping", AllowSymlinkPrefixes(".")); !errors.Is(err, ErrSymlinkNotAllowed) {
+		t.Errorf("CopyTreeBeneath with an escaping link = %v, want ErrSymlinkNotAllowed", err)
+	}
+	if err := os.Remove(filepath.Join(src, "pkg", "bin", "doc")); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Symlink(filepath.Join("..", "README"), filepath.Join(src, "pkg", "bin", "doc")); err != nil {
+		t.Fatal(err)
+	}
+	if err := CopyTreeBeneath(src, "pkg", dst, "nolinks", nil); !errors.Is(err, ErrSymlinkNotAllowed) {
+		t.Errorf("CopyTreeBeneath with a nil policy = %v, want ErrSymlinkNotAllowed", err)
+	}
+	if err := CopyTreeBeneath(src, "pkg", dst, "links", AllowSymlinkPrefixes(".")); err != nil {
+		t.Fatalf("CopyTreeBeneath with an in-tree link error: %v", err)
+	}
+	if target, err := os.Readlink(filepath.Join(dst, "links", "bin", "doc")); err != nil || target != filepath.Join("..", "README") {
+		t.Errorf("links/bin/doc points to %q, %v, want ../README", target, err)
+	}
+}
+
+func TestCopyTreeBeneathIntoItself(t *testing.T) {
+	root := t.TempDir()
+	if err := os.MkdirAll(filepath.Join(root, "a", "b"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	for _, tc := range []struct{ dstDir, dstPath string }{
+		{root, filepath.Join("a", "copy")},
+		{root, filepath.Join("a", "b", "copy")},
+		{filepath.Join(root, "a", "b"), "copy"},
+	} {
+		if err := CopyTreeBeneath(root, "a", tc.dstDir, tc.dstPath, nil); err == nil {
+			t.Errorf("CopyTreeBeneath(%q, %q) into %q succeeded, want error", "a", tc.dstPath, tc.dstDir)
+		}
+		if _, err := os.Lstat(filepath.Join(tc.dstDir, tc.dstPath)); !os.IsNotExist(err) {
+			t.Errorf("CopyTreeBeneath(%q, %q) into %q created the destination: %v", "a", tc.dstPath, tc.dstDir, err)
+		}
+	}
+
+	// A sibling of the source is fine.
+	if err := CopyTreeBeneath(root, filepath.Join("a", "b"), root, filepath.Join("a", "copy"), nil); err != nil {
+		t.Errorf("CopyTreeBeneath() to a sibling error: %v", err)
+	}
+}
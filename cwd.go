@@ -0,0 +1,58 @@
+// Copyright 2024 Google LLC.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package safeopen
+
+import "os"
+
+// OpenBeneathCwd opens the named file in the current working directory, or a subdirectory, for
+// reading. It behaves as OpenBeneath, except that the base directory is the current working
+// directory at the time of the call, and does not need to be named.
+func OpenBeneathCwd(file string) (*os.File, error) {
+	return OpenFileBeneathCwd(file, os.O_RDONLY, 0)
+}
+
+// CreateBeneathCwd creates or truncates the named file in the current working directory, or a
+// subdirectory. It behaves as CreateBeneath otherwise.
+func CreateBeneathCwd(file string) (*os.File, error) {
+	return OpenFileBeneathCwd(file, os.O_RDWR|os.O_CREATE|os.O_TRUNC, 0666)
+}
+
+// OpenFileBeneathCwd is the generalized OpenBeneathCwd call. It behaves as OpenFileBeneath,
+// with the current working directory as the base directory.
+//
+// On Unix the file is resolved relative to AT_FDCWD, without opening the working directory.
+func OpenFileBeneathCwd(file string, flag int, perm os.FileMode) (*os.File, error) {
+	dfd, release, err := cwdHandle()
+	if err != nil {
+		return nil, err
+	}
+	defer release()
+
+	return openFileBeneathIn(dfd, ".", file, flag, perm)
+}
+
+func openFileBeneathCwd(_, file string, flag int, perm os.FileMode) (*os.File, error) {
+	return OpenFileBeneathCwd(file, flag, perm)
+}
+
+// ReadFileBeneathCwd is a replacement of os.ReadFile that leverages safeopen.OpenBeneathCwd.
+func ReadFileBeneathCwd(file string) ([]byte, error) {
+	return readFile(".", file, openFileBeneathCwd)
+}
+
+// WriteFileBeneathCwd is a replacement of os.WriteFile that leverages safeopen.CreateBeneathCwd.
+func WriteFileBeneathCwd(file string, data []byte, perm os.FileMode) error {
+	return writeFile(".", file, data, perm, openFileBeneathCwd)
+}
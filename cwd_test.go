@@ -0,0 +1,58 @@
+// Copyright 2024 Google LLC.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package safeopen
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestBeneathCwd(t *testing.T) {
+	wd, err := os.Getwd()
+	if err != nil {
+		t.Fatal(err)
+	}
+	tmpDir := t.TempDir()
+	if err := os.Mkdir(filepath.Join(tmpDir, "subdir"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chdir(filepath.Join(tmpDir, "subdir")); err != nil {
+		t.Fatal(err)
+	}
+	defer os.Chdir(wd)
+
+	file := filepath.Join("nested", "data.txt")
+	if err := os.Mkdir("nested", 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := WriteFileBeneathCwd(file, []byte("content"), 0644); err != nil {
+		t.Fatalf("WriteFileBeneathCwd(%q) error: %v", file, err)
+	}
+	got, err := ReadFileBeneathCwd(file)
+	if err != nil {
+		t.Fatalf("ReadFileBeneathCwd(%q) error: %v", file, err)
+	}
+	if string(got) != "content" {
+		t.Errorf("ReadFileBeneathCwd(%q) = %q, want %q", file, got, "content")
+	}
+
+	if err := os.WriteFile(filepath.Join(tmpDir, "outside.txt"), []byte("outside"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := OpenBeneathCwd(filepath.Join("..", "outside.txt")); err == nil {
+		t.Errorf("OpenBeneathCwd(%q) succeeded, want error", filepath.Join("..", "outside.txt"))
+	}
+}
@@ -0,0 +1,178 @@
+// Copyright 2024 Google LLC.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package safeopen
+
+import (
+	"crypto/sha256"
+	"io"
+	"io/fs"
+	"os"
+	"sort"
+)
+
+// DuplicateSet is a set of distinct files with the same content.
+type DuplicateSet struct {
+	// Size is the size of each of the files.
+	Size int64
+	// Files lists the paths of the files, relative to the directory scanned, sorted.
+	Files []string
+}
+
+// FindDuplicatesBeneath scans the tree rooted at the named directory, and returns the sets of
+// regular files with the same content, sorted by their first file. Files are grouped by size
+// first, and only the files sharing their size with another one are hashed. Empty files are
+// ignored, and hard links to the same file are only reported once. Symbolic links are never
+// followed.
+//
+// If link is set, every file of each set but the first one is then atomically replaced with a
+// hard link to the first one. The files are compared byte by byte right before, and the ones
+// modified since the scan are left untouched. Note that the replaced files lose their own
+// permissions, owner and timestamps.
+func FindDuplicatesBeneath(directory string, link bool) ([]DuplicateSet, error) {
+	dfd, err := openDirHandle(directory)
+	if err != nil {
+		return nil, err
+	}
+	defer closeHandle(dfd)
+
+	sets, err := findDuplicatesIn(dfd)
+	if err != nil || !link {
+		return sets, err
+	}
+	for _, set := range sets {
+		for _, dup := range set.Files[1:] {
+			if err := linkDuplicateIn(dfd, set.Files[0], dup); err != nil {
+				return sets, err
+			}
+		}
+	}
+	return sets, nil
+}
+
+func findDuplicatesIn(dfd handle) ([]DuplicateSet, error) {
+	bySize := make(map[int64][]string)
+	err := walkIn(dfd, ".", func(file string, fi fs.FileInfo) error {
+		if fi.Mode().IsRegular() && fi.Size() > 0 {
+			bySize[fi.Size()] = append(bySize[fi.Size()], file)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	type fileID struct{ dev, ino uint64 }
+	var sets []DuplicateSet
+	for size, files := range bySize {
+		if len(files) < 2 {
+			continue
+		}
+		seen := make(map[fileID]bool)
+		byHash := make(map[[sha256.Size]byte][]string)
+		for _, file := range files {
+			xfi, err := extendedStatIn(dfd, file)
+			if err != nil {
+				return nil, err
+			}
+			id := fileID{xfi.Device, xfi.Inode}
+			if seen[id] {
+				continue
+			}
+			seen[id] = true
+			sum, err := contentHashIn(dfd, file)
+			if err != nil {
+				return nil, err
+			}
+			byHash[sum] = append(byHash[sum], file)
+		}
+		for _, group := range byHash {
+			if len(group) > 1 {
+				sort.Strings(group)
+				sets = append(sets, DuplicateSet{Size: size, Files: group})
+			}
+		}
+	}
+	sort.Slice(sets, func(i, j int) bool { return sets[i].Files[0] < sets[j].Files[0] })
+	return sets, nil
+}
+
+// contentHashIn returns the SHA-256 hash of the content of file beneath dfd.
+func contentHashIn(dfd handle, file string) ([sha256.Size]byte, error) {
+	var sum [sha256.Size]byte
+	f, err := openFileNoFollowIn(dfd, "", file, os.O_RDONLY, 0)
+	if err != nil {
+		return sum, err
+	}
+	defer f.Close()
+
+	h := sha256.New()
+	if _, err := io.Copy(h, f); err != nil {
+		return sum, err
+	}
+	copy(sum[:], h.Sum(nil))
+	return sum, nil
+}
+
+// linkDuplicateIn atomically replaces dup with a hard link to orig, both beneath dfd, if they
+// still have the same content.
+func linkDuplicateIn(dfd handle, orig, dup string) error {
+	same, err := sameContentIn(dfd, orig, dup)
+	if err != nil || !same {
+		return err
+	}
+
+	opfd, obase, err := openParentIn(dfd, orig)
+	if err != nil {
+		return err
+	}
+	if opfd != dfd {
+		defer closeHandle(opfd)
+	}
+	npfd, nbase, err := openParentIn(dfd, dup)
+	if err != nil {
+		return err
+	}
+	if npfd != dfd {
+		defer closeHandle(npfd)
+	}
+
+	tmp, err := randomName("."+nbase+".link", "")
+	if err != nil {
+		return err
+	}
+	if err := linkBetween(opfd, obase, npfd, tmp); err != nil {
+		return &os.PathError{Op: "link", Path: dup, Err: err}
+	}
+	if err := renameIn(npfd, tmp, nbase); err != nil {
+		removeIn(npfd, tmp)
+		return &os.PathError{Op: "rename", Path: dup, Err: err}
+	}
+	return syncHandle(npfd)
+}
+
+// sameContentIn reports whether the files a and b beneath dfd have the same content.
+func sameContentIn(dfd handle, a, b string) (bool, error) {
+	af, err := openFileNoFollowIn(dfd, "", a, os.O_RDONLY, 0)
+	if err != nil {
+		return false, err
+	}
+	defer af.Close()
+	bf, err := openFileNoFollowIn(dfd, "", b, os.O_RDONLY, 0)
+	if err != nil {
+		return false, err
+	}
+	defer bf.Close()
+	return equalFiles(af, bf)
+}
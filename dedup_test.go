@@ -0,0 +1,85 @@
+// Copyright 2024 Google LLC.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package safeopen
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"runtime"
+	"testing"
+)
+
+func TestFindDuplicatesBeneath(t *testing.T) {
+	tmpDir := t.TempDir()
+	if err := os.Mkdir(filepath.Join(tmpDir, "subdir"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	files := map[string]string{
+		"a.txt":        "duplicate",
+		"b.txt":        "duplicate",
+		"subdir/c.txt": "duplicate",
+		"other.txt":    "different",
+		"unique.txt":   "unique content",
+		"empty1":       "",
+		"empty2":       "",
+	}
+	for file, content := range files {
+		if err := os.WriteFile(filepath.Join(tmpDir, file), []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := os.Link(filepath.Join(tmpDir, "other.txt"), filepath.Join(tmpDir, "other.link")); err != nil {
+		t.Fatal(err)
+	}
+	if runtime.GOOS != "windows" {
+		if err := os.Symlink("a.txt", filepath.Join(tmpDir, "symlink")); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	want := []DuplicateSet{{Size: 9, Files: []string{"a.txt", "b.txt", filepath.Join("subdir", "c.txt")}}}
+	got, err := FindDuplicatesBeneath(tmpDir, false)
+	if err != nil {
+		t.Fatalf("FindDuplicatesBeneath(%q, false) error: %v", tmpDir, err)
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("FindDuplicatesBeneath(%q, false) = %+v, want %+v", tmpDir, got, want)
+	}
+
+	if _, err := FindDuplicatesBeneath(tmpDir, true); err != nil {
+		t.Fatalf("FindDuplicatesBeneath(%q, true) error: %v", tmpDir, err)
+	}
+	orig, err := os.Stat(filepath.Join(tmpDir, "a.txt"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, file := range want[0].Files[1:] {
+		fi, err := os.Stat(filepath.Join(tmpDir, file))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !os.SameFile(orig, fi) {
+			t.Errorf("%s is not a hard link to a.txt", file)
+		}
+	}
+	got, err = FindDuplicatesBeneath(tmpDir, false)
+	if err != nil {
+		t.Fatalf("FindDuplicatesBeneath(%q, false) error: %v", tmpDir, err)
+	}
+	if len(got) != 0 {
+		t.Errorf("FindDuplicatesBeneath(%q, false) after linking = %+v, want none", tmpDir, got)
+	}
+}
@@ -0,0 +1,112 @@
+// Copyright 2024 Google LLC.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+//go:build linux
+// +build linux
+
+package safeopen
+
+import (
+	"errors"
+	"os"
+	"syscall"
+
+	"golang.org/x/sys/unix"
+)
+
+// ErrContentDiffers is returned by DedupeRange if the ranges to share differ.
+var ErrContentDiffers = errors.New("content differs")
+
+const (
+	// maxDedupeChunk bounds the length of each FIDEDUPERANGE call, as file systems cap it
+	// (e.g. to 16 MiB on btrfs and XFS).
+	maxDedupeChunk = 16 << 20
+	// fileDedupeRangeDiffers is FILE_DEDUPE_RANGE_DIFFERS from linux/fs.h.
+	fileDedupeRangeDiffers = 1
+)
+
+// DedupeRange makes the length bytes of dst at dstOffset share their storage with the length
+// bytes of src at srcOffset, with the FIDEDUPERANGE ioctl, on file systems supporting it such as
+// btrfs and XFS. The kernel compares the ranges while they are locked, and the call fails with
+// an error wrapping ErrContentDiffers if they differ, so the content of dst never changes.
+// It returns the number of bytes deduplicated, which is less than length only on error.
+//
+// dst must be opened for writing, unless the process owns it or has CAP_SYS_ADMIN.
+func DedupeRange(src *os.File, srcOffset int64, dst *os.File, dstOffset, length int64) (int64, error) {
+	var done int64
+	err := fileIoctl(src, "DedupeRange", func(srcFd int) error {
+		return controlFd(dst, func(dstFd int) error {
+			for done < length {
+				chunk := length - done
+				if chunk > maxDedupeChunk {
+					chunk = maxDedupeChunk
+				}
+				info := []unix.FileDedupeRangeInfo{{Dest_fd: int64(dstFd), Dest_offset: uint64(dstOffset + done)}}
+				err := unix.IoctlFileDedupeRange(srcFd, &unix.FileDedupeRange{
+					Src_offset: uint64(srcOffset + done),
+					Src_length: uint64(chunk),
+					Info:       info,
+				})
+				if err != nil {
+					return err
+				}
+				switch status := info[0].Status; {
+				case status == fileDedupeRangeDiffers:
+					return ErrContentDiffers
+				case status < 0:
+					return syscall.Errno(-status)
+				case info[0].Bytes_deduped == 0:
+					return unix.EINVAL
+				}
+				done += int64(info[0].Bytes_deduped)
+			}
+			return nil
+		})
+	})
+	return done, err
+}
+
+// DedupeFilesBeneath makes the named file dfile beneath ddirectory share its storage with the
+// named file sfile beneath sdirectory, with DedupeRange, if both have the same content. The files
+// are opened with the same rules as OpenBeneath. If the files differ, in size or content, the
+// call fails with an error wrapping ErrContentDiffers and leaves the files as they are.
+// It returns the number of bytes deduplicated.
+func DedupeFilesBeneath(sdirectory, sfile, ddirectory, dfile string) (int64, error) {
+	src, err := OpenFileBeneath(sdirectory, sfile, os.O_RDONLY, 0)
+	if err != nil {
+		return 0, err
+	}
+	defer src.Close()
+	dst, err := OpenFileBeneath(ddirectory, dfile, os.O_WRONLY, 0)
+	if err != nil {
+		return 0, err
+	}
+	defer dst.Close()
+
+	sfi, err := src.Stat()
+	if err != nil {
+		return 0, err
+	}
+	dfi, err := dst.Stat()
+	if err != nil {
+		return 0, err
+	}
+	if !sfi.Mode().IsRegular() || !dfi.Mode().IsRegular() {
+		return 0, &os.PathError{Op: "DedupeFilesBeneath", Path: dfile, Err: ErrNotRegularFile}
+	}
+	if sfi.Size() != dfi.Size() {
+		return 0, &os.PathError{Op: "DedupeFilesBeneath", Path: dfile, Err: ErrContentDiffers}
+	}
+	return DedupeRange(src, 0, dst, 0, sfi.Size())
+}
@@ -0,0 +1,60 @@
+// Copyright 2024 Google LLC.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+//go:build linux
+// +build linux
+
+package safeopen
+
+import (
+	"bytes"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"golang.org/x/sys/unix"
+)
+
+func TestLinuxDedupeFilesBeneath(t *testing.T) {
+	tmpDir := t.TempDir()
+	data := bytes.Repeat([]byte("0123456789abcdef"), 4096)
+	for name, content := range map[string][]byte{
+		"a":       data,
+		"b":       data,
+		"differs": append(append([]byte(nil), data[:len(data)-1]...), 'x'),
+		"shorter": data[:4096],
+	} {
+		if err := os.WriteFile(filepath.Join(tmpDir, name), content, 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	n, err := DedupeFilesBeneath(tmpDir, "a", tmpDir, "b")
+	if errors.Is(err, unix.EOPNOTSUPP) || errors.Is(err, unix.EINVAL) || errors.Is(err, unix.ENOTTY) {
+		t.Skipf("FIDEDUPERANGE not supported here: %v", err)
+	}
+	if err != nil || n != int64(len(data)) {
+		t.Fatalf("DedupeFilesBeneath(a, b) = %d, %v, want %d, nil", n, err, len(data))
+	}
+	if got, err := os.ReadFile(filepath.Join(tmpDir, "b")); err != nil || !bytes.Equal(got, data) {
+		t.Errorf("content of b changed after deduplication")
+	}
+
+	for _, dst := range []string{"differs", "shorter"} {
+		if _, err := DedupeFilesBeneath(tmpDir, "a", tmpDir, dst); !errors.Is(err, ErrContentDiffers) {
+			t.Errorf("DedupeFilesBeneath(a, %s) error = %v, want ErrContentDiffers", dst, err)
+		}
+	}
+}
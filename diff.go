@@ -0,0 +1,149 @@
+// Copyright 2024 Google LLC.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package safeopen
+
+import (
+	"io/fs"
+	"os"
+	"sort"
+)
+
+// DiffBy selects how the files present in both trees are compared by TreeDiffBeneath.
+type DiffBy int
+
+const (
+	// DiffByMetadata compares the type, the permission bits, the size and the modification time
+	// of the files.
+	DiffByMetadata DiffBy = iota
+	// DiffByContent compares the type, the permission bits and the content of the files.
+	DiffByContent
+)
+
+// TreeDiff lists the differences between two directory trees. The paths are relative to the
+// roots of the trees, and sorted.
+type TreeDiff struct {
+	// Added lists the files only present in the new tree.
+	Added []string
+	// Removed lists the files only present in the old tree.
+	Removed []string
+	// Modified lists the files present in both trees, but different.
+	Modified []string
+}
+
+// TreeDiffBeneath compares the trees rooted at the named directories, and reports the files
+// added, removed and modified in newDirectory compared to oldDirectory.
+//
+// Symbolic links are compared by their target, and never followed. Directories present in both
+// trees are only reported as modified if their permission bits differ; a file replaced by a
+// directory, or the other way round, is reported as modified, and the content of the
+// directory as added or removed.
+func TreeDiffBeneath(oldDirectory, newDirectory string, by DiffBy) (*TreeDiff, error) {
+	oldDir, err := OpenDir(oldDirectory)
+	if err != nil {
+		return nil, err
+	}
+	defer oldDir.Close()
+	newDir, err := OpenDir(newDirectory)
+	if err != nil {
+		return nil, err
+	}
+	defer newDir.Close()
+
+	return DiffDirs(oldDir, newDir, by)
+}
+
+// DiffDirs is like TreeDiffBeneath, for directories already opened.
+func DiffDirs(oldDir, newDir *Dir, by DiffBy) (*TreeDiff, error) {
+	oldFiles, err := listTreeIn(oldDir.dfd)
+	if err != nil {
+		return nil, err
+	}
+	newFiles, err := listTreeIn(newDir.dfd)
+	if err != nil {
+		return nil, err
+	}
+
+	diff := &TreeDiff{}
+	for file, oldFi := range oldFiles {
+		newFi, ok := newFiles[file]
+		if !ok {
+			diff.Removed = append(diff.Removed, file)
+			continue
+		}
+		same, err := sameFileIn(oldDir.dfd, newDir.dfd, file, oldFi, newFi, by)
+		if err != nil {
+			return nil, err
+		}
+		if !same {
+			diff.Modified = append(diff.Modified, file)
+		}
+	}
+	for file := range newFiles {
+		if _, ok := oldFiles[file]; !ok {
+			diff.Added = append(diff.Added, file)
+		}
+	}
+	sort.Strings(diff.Added)
+	sort.Strings(diff.Removed)
+	sort.Strings(diff.Modified)
+	return diff, nil
+}
+
+// listTreeIn returns the FileInfo of all the files beneath dfd, by path.
+func listTreeIn(dfd handle) (map[string]fs.FileInfo, error) {
+	files := make(map[string]fs.FileInfo)
+	err := walkIn(dfd, ".", func(file string, fi fs.FileInfo) error {
+		files[file] = fi
+		return nil
+	})
+	return files, err
+}
+
+// sameFileIn reports whether file is the same beneath adfd and bdfd, given its FileInfo in
+// both directories.
+func sameFileIn(adfd, bdfd handle, file string, afi, bfi fs.FileInfo, by DiffBy) (bool, error) {
+	if afi.Mode() != bfi.Mode() {
+		return false, nil
+	}
+	switch {
+	case afi.IsDir():
+		return true, nil
+	case afi.Mode()&fs.ModeSymlink != 0:
+		atarget, err := readlinkIn(adfd, file)
+		if err != nil {
+			return false, err
+		}
+		btarget, err := readlinkIn(bdfd, file)
+		return atarget == btarget, err
+	case !afi.Mode().IsRegular():
+		return true, nil
+	case afi.Size() != bfi.Size():
+		return false, nil
+	case by == DiffByMetadata:
+		return afi.ModTime().Equal(bfi.ModTime()), nil
+	}
+
+	af, err := openFileNoFollowIn(adfd, "", file, os.O_RDONLY, 0)
+	if err != nil {
+		return false, err
+	}
+	defer af.Close()
+	bf, err := openFileNoFollowIn(bdfd, "", file, os.O_RDONLY, 0)
+	if err != nil {
+		return false, err
+	}
+	defer bf.Close()
+	return equalFiles(af, bf)
+}
@@ -0,0 +1,91 @@
+// Copyright 2024 Google LLC.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package safeopen
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestTreeDiffBeneath(t *testing.T) {
+	oldDir, newDir := t.TempDir(), t.TempDir()
+	mtime := time.Now().Add(-time.Hour).Truncate(time.Second)
+	for _, root := range []string{oldDir, newDir} {
+		for _, dir := range []string{"same", "retyped", "kept"} {
+			if err := os.Mkdir(filepath.Join(root, dir), 0755); err != nil {
+				t.Fatal(err)
+			}
+		}
+		for _, file := range []string{"same/data.txt", "touched.txt", "rewritten.txt"} {
+			if err := os.WriteFile(filepath.Join(root, file), []byte("content"), 0644); err != nil {
+				t.Fatal(err)
+			}
+			if err := os.Chtimes(filepath.Join(root, file), mtime, mtime); err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+	write := func(file, content string) {
+		if err := os.WriteFile(filepath.Join(newDir, file), []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := os.WriteFile(filepath.Join(oldDir, "removed.txt"), nil, 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(oldDir, "retyped", "child.txt"), nil, 0644); err != nil {
+		t.Fatal(err)
+	}
+	write("kept/added.txt", "")
+	write("rewritten.txt", "CONTENT")
+	if err := os.Chtimes(filepath.Join(newDir, "rewritten.txt"), mtime, mtime); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chtimes(filepath.Join(newDir, "touched.txt"), mtime.Add(time.Minute), mtime.Add(time.Minute)); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Remove(filepath.Join(newDir, "retyped")); err != nil {
+		t.Fatal(err)
+	}
+	write("retyped", "")
+
+	tests := []struct {
+		by   DiffBy
+		want *TreeDiff
+	}{
+		{DiffByMetadata, &TreeDiff{
+			Added:    []string{filepath.Join("kept", "added.txt")},
+			Removed:  []string{"removed.txt", filepath.Join("retyped", "child.txt")},
+			Modified: []string{"retyped", "touched.txt"},
+		}},
+		{DiffByContent, &TreeDiff{
+			Added:    []string{filepath.Join("kept", "added.txt")},
+			Removed:  []string{"removed.txt", filepath.Join("retyped", "child.txt")},
+			Modified: []string{"retyped", "rewritten.txt"},
+		}},
+	}
+	for _, tc := range tests {
+		got, err := TreeDiffBeneath(oldDir, newDir, tc.by)
+		if err != nil {
+			t.Fatalf("TreeDiffBeneath(%v) error: %v", tc.by, err)
+		}
+		if !reflect.DeepEqual(got, tc.want) {
+			t.Errorf("TreeDiffBeneath(%v) = %+v, want %+v", tc.by, got, tc.want)
+		}
+	}
+}
@@ -0,0 +1,513 @@
+// Copyright 2024 Google LLC.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package safeopen
+
+import (
+	"errors"
+	"io"
+	"io/fs"
+	"os"
+	"sync"
+	"time"
+)
+
+// Dir is a base directory opened once and kept open, so that subsequent operations are all
+// resolved relative to the very same directory, even if its path is renamed or replaced in the
+// meantime. Files are looked up beneath the directory with the same rules as OpenBeneath.
+//
+// The methods of Dir are safe for concurrent use.
+type Dir struct {
+	name    string
+	dfd     handle
+	opts    options
+	reads   *readGroup
+	stats   *lru[statEntry]
+	handles *lru[*cachedHandle]
+	quota   *fileQuota
+	fds     *fdBudget
+	acct    *accounting
+
+	// retentionMu serializes the enforcement of the retention policy.
+	retentionMu sync.Mutex
+}
+
+type options struct {
+	sharedReads  bool
+	statCacheLen int
+	statCacheTTL time.Duration
+
+	handleCacheLen int
+
+	symlinkPolicy SymlinkPolicy
+	resolveTrace  func(file string, steps []ResolveStep, err error)
+
+	strict      bool
+	regularOnly bool
+	readOnly    bool
+	noXDev      bool
+	portable    bool
+	permPolicy  *PermPolicy
+	accounting  bool
+	checksums   bool
+	maxFiles    int
+	retention   *Retention
+	maxOpen     int
+	waitOpen    bool
+
+	// runAs runs its argument with the credentials of the user files are accessed for.
+	runAs func(fn func() error) error
+}
+
+// addRunAs makes runAs run its argument within wrap, in addition to the existing wrappers.
+func (o *options) addRunAs(wrap func(fn func() error) error) {
+	prev := o.runAs
+	if prev == nil {
+		o.runAs = wrap
+		return
+	}
+	o.runAs = func(fn func() error) error {
+		return wrap(func() error { return prev(fn) })
+	}
+}
+
+// Option configures a Dir.
+type Option func(*options)
+
+// WithSharedReads makes concurrent ReadFile calls of the same file share a single read from
+// the disk. Each caller still receives its own copy of the data.
+func WithSharedReads() Option {
+	return func(o *options) {
+		o.sharedReads = true
+	}
+}
+
+// WithStatCache caches the results of Lstat and Exists for up to size files, for at most ttl.
+// Changes made to the files by other means than d may therefore be noticed late; use
+// Invalidate to drop stale results.
+func WithStatCache(size int, ttl time.Duration) Option {
+	return func(o *options) {
+		o.statCacheLen = size
+		o.statCacheTTL = ttl
+	}
+}
+
+// WithRegularFilesOnly makes d refuse to open files other than regular files, such as
+// directories, devices or named pipes.
+func WithRegularFilesOnly() Option {
+	return func(o *options) {
+		o.regularOnly = true
+	}
+}
+
+// WithReadOnly makes d refuse to open files for writing, or to create them, with an error
+// wrapping fs.ErrPermission.
+func WithReadOnly() Option {
+	return func(o *options) {
+		o.readOnly = true
+	}
+}
+
+// OpenDir opens the named directory for use as a Dir.
+func OpenDir(directory string, opts ...Option) (*Dir, error) {
+	dfd, err := openDirHandle(directory)
+	if err != nil {
+		return nil, err
+	}
+	return newDir(directory, dfd, opts)
+}
+
+// NewDirFromFd returns a Dir over the directory referred by the descriptor fd (a handle on
+// Windows), typically inherited from the parent process or from the systemd file descriptor
+// store, so that sandboxed processes that cannot open paths by name can still use Dir. name is
+// the name reported by Name and in errors.
+//
+// NewDirFromFd fails if fd does not refer to a directory. Otherwise, the Dir takes ownership of
+// fd, which is closed by Close, and makes it close-on-exec (non-inheritable on Windows).
+func NewDirFromFd(fd uintptr, name string, opts ...Option) (*Dir, error) {
+	dfd, err := adoptDirHandle(fd)
+	if err != nil {
+		return nil, &os.PathError{Op: "NewDirFromFd", Path: name, Err: err}
+	}
+	return newDir(name, dfd, opts)
+}
+
+// newDir returns a Dir over the directory referred by dfd, which it takes ownership of, even on
+// failure.
+func newDir(directory string, dfd handle, opts []Option) (*Dir, error) {
+	d := &Dir{name: directory, dfd: dfd, opts: defaultOptions()}
+	for _, opt := range opts {
+		opt(&d.opts)
+	}
+	if d.opts.strict {
+		if err := checkResolveBeneathIn(dfd); err != nil {
+			closeHandle(dfd)
+			return nil, &os.PathError{Op: "OpenDir", Path: directory, Err: err}
+		}
+	}
+	if d.opts.sharedReads {
+		d.reads = &readGroup{calls: make(map[string]*readCall)}
+	}
+	if d.opts.statCacheLen > 0 {
+		d.stats = newLRU[statEntry](d.opts.statCacheLen, nil)
+	}
+	if d.opts.handleCacheLen > 0 {
+		d.handles = newLRU[*cachedHandle](d.opts.handleCacheLen, (*cachedHandle).evict)
+	}
+	if d.opts.accounting {
+		d.acct = newAccounting()
+	}
+	if d.opts.maxOpen > 0 {
+		d.fds = &fdBudget{sem: make(chan struct{}, d.opts.maxOpen), wait: d.opts.waitOpen}
+	}
+	if d.opts.maxFiles > 0 {
+		d.quota = &fileQuota{max: d.opts.maxFiles}
+		if err := d.Recount(); err != nil {
+			closeHandle(dfd)
+			return nil, err
+		}
+	}
+	return d, nil
+}
+
+// Name returns the name of the directory as presented to OpenDir.
+func (d *Dir) Name() string {
+	return d.name
+}
+
+// Close closes the directory, along with the cached handles that are not in use. Files opened
+// through d remain usable.
+func (d *Dir) Close() error {
+	if d.handles != nil {
+		d.handles.purge()
+	}
+	return closeHandle(d.dfd)
+}
+
+// Open opens the named file beneath d for reading.
+// file may not contain .. path traversal entries.
+//
+// If successful, methods on the returned file can be used for reading; the associated file
+// descriptor has mode O_RDONLY.
+// If there is an error, it will be of type *PathError.
+func (d *Dir) Open(file string) (*os.File, error) {
+	return d.OpenFile(file, os.O_RDONLY, 0)
+}
+
+// Create creates or truncates the named file beneath d.
+// file may not contain .. path traversal entries.
+//
+// If the file already exists, it is truncated. If the file does not exist, it is created with
+// mode 0666 (before umask). If successful, methods on the returned File can be used for I/O; the
+// associated file descriptor has mode O_RDWR.
+// If there is an error, it will be of type *PathError.
+func (d *Dir) Create(file string) (*os.File, error) {
+	return d.OpenFile(file, os.O_RDWR|os.O_CREATE|os.O_TRUNC, 0666)
+}
+
+// OpenFile is the generalized Open call; most users will use Open or Create instead.
+//
+// It opens the named file beneath d with the same semantics as OpenFileBeneath, but without
+// opening the directory of d again, and with the options of d. The perm parameter is ignored on
+// Windows. If the file is opened for writing, the cached results about it are dropped.
+// If there is an error, it will be of type *PathError.
+func (d *Dir) OpenFile(file string, flag int, perm os.FileMode) (*os.File, error) {
+	if flag&(os.O_WRONLY|os.O_RDWR|os.O_CREATE|os.O_TRUNC|os.O_APPEND) != 0 {
+		defer d.forget(file)
+	}
+	return d.openFile(file, flag, perm)
+}
+
+// ReadFile is a replacement of os.ReadFile that reads the named file beneath d.
+// file may not contain .. path traversal entries.
+func (d *Dir) ReadFile(file string) ([]byte, error) {
+	defer d.acct.since("ReadFile", time.Now())
+	if d.reads == nil {
+		return d.readFile(file)
+	}
+	return d.reads.do(cacheKey(file), func() ([]byte, error) {
+		return d.readFile(file)
+	})
+}
+
+// WriteFile is a replacement of os.WriteFile that writes the named file beneath d.
+// file may not contain .. path traversal entries.
+func (d *Dir) WriteFile(file string, data []byte, perm os.FileMode) error {
+	defer d.acct.since("WriteFile", time.Now())
+	defer d.forget(file)
+
+	if err := d.fds.acquire(file); err != nil {
+		return err
+	}
+	defer d.fds.release()
+	f, err := d.openFile(file, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, perm)
+	if err != nil {
+		return err
+	}
+	n, err := f.Write(data)
+	d.acct.written(n)
+	if err == nil && d.opts.checksums {
+		err = storeChecksum(f, data)
+	}
+	if err1 := f.Close(); err1 != nil && err == nil {
+		err = err1
+	}
+	return err
+}
+
+// Lstat returns a FileInfo describing the named file beneath d.
+// file may not contain .. path traversal entries. If the file is a symbolic link, the returned
+// FileInfo describes the link itself.
+func (d *Dir) Lstat(file string) (fs.FileInfo, error) {
+	defer d.acct.since("Lstat", time.Now())
+	if d.stats == nil {
+		return d.lstat(file)
+	}
+
+	key := cacheKey(file)
+	if e, ok := d.stats.get(key); ok && time.Now().Before(e.expiry) {
+		return e.fi, e.err
+	}
+	fi, err := d.lstat(file)
+	if err == nil || errors.Is(err, fs.ErrNotExist) {
+		d.stats.add(key, statEntry{fi, err, time.Now().Add(d.opts.statCacheTTL)})
+	}
+	return fi, err
+}
+
+// Exists reports whether the named file exists beneath d. Symbolic links are not followed, so
+// Exists reports true for dangling ones as well.
+func (d *Dir) Exists(file string) (bool, error) {
+	_, err := d.Lstat(file)
+	if errors.Is(err, fs.ErrNotExist) {
+		return false, nil
+	}
+	return err == nil, err
+}
+
+// Invalidate drops the cached results of the named file, if any.
+func (d *Dir) Invalidate(file string) {
+	if d.stats != nil {
+		d.stats.remove(cacheKey(file))
+	}
+}
+
+// forget drops everything cached about file, after it was modified through d.
+func (d *Dir) forget(file string) {
+	d.Invalidate(file)
+	if d.handles != nil {
+		d.handles.remove(cacheKey(file))
+	}
+}
+
+type statEntry struct {
+	fi     fs.FileInfo
+	err    error
+	expiry time.Time
+}
+
+// openFile opens file beneath d, honoring the symlink policy and the file quota of d.
+func (d *Dir) openFile(file string, flag int, perm os.FileMode) (*os.File, error) {
+	if d.opts.readOnly && flag&(os.O_WRONLY|os.O_RDWR|os.O_CREATE|os.O_TRUNC|os.O_APPEND) != 0 {
+		return nil, &os.PathError{Op: "open", Path: file, Err: fs.ErrPermission}
+	}
+	if d.opts.portable && flag&os.O_CREATE != 0 {
+		if err := CheckPortablePath(file); err != nil {
+			return nil, err
+		}
+	}
+	if flag&os.O_CREATE == 0 {
+		f, err := d.openFileUncounted(file, flag, perm)
+		if err == nil {
+			d.acct.open(false)
+		}
+		return f, err
+	}
+	perm = d.opts.permPolicy.filePerm(perm)
+	var f *os.File
+	var err error
+	if d.quota != nil || d.acct != nil {
+		f, err = d.createCounted(file, flag, perm)
+	} else {
+		f, err = d.openFileUncounted(file, flag, perm)
+	}
+	if err == nil && d.opts.retention != nil && d.retentionMu.TryLock() {
+		// Best effort: a failure to expire old files does not fail the creation.
+		d.enforceRetention(file)
+		d.retentionMu.Unlock()
+	}
+	return f, err
+}
+
+func (d *Dir) openFileUncounted(file string, flag int, perm os.FileMode) (*os.File, error) {
+	var f *os.File
+	var steps []ResolveStep
+	err := d.asUser(func() error {
+		var resolved string
+		var err error
+		resolved, steps, err = d.resolve(file, true)
+		if err != nil {
+			return err
+		}
+		switch {
+		case d.opts.noXDev:
+			f, err = openFileNoXDevIn(d.dfd, d.name, resolved, flag, perm, d.opts.symlinkPolicy != nil)
+		case d.opts.symlinkPolicy == nil:
+			f, err = openFileBeneathFollowIn(d.dfd, d.name, file, flag, perm)
+		default:
+			f, err = openFileNoFollowIn(d.dfd, d.name, resolved, flag, perm)
+		}
+		return err
+	})
+	if err == nil && d.opts.regularOnly {
+		err = checkRegular(f, file)
+		if err != nil {
+			f.Close()
+			f = nil
+		}
+	}
+	if d.opts.resolveTrace != nil {
+		d.opts.resolveTrace(file, steps, err)
+	}
+	return f, err
+}
+
+// checkRegular returns an error if f is not a regular file.
+func checkRegular(f *os.File, file string) error {
+	fi, err := f.Stat()
+	if err != nil {
+		return err
+	}
+	if !fi.Mode().IsRegular() {
+		return &os.PathError{Op: "open", Path: file, Err: ErrNotRegularFile}
+	}
+	return nil
+}
+
+// lstat returns the FileInfo of file beneath d, honoring the symlink policy of d in all but
+// the last element of file.
+func (d *Dir) lstat(file string) (fs.FileInfo, error) {
+	var fi fs.FileInfo
+	var steps []ResolveStep
+	err := d.asUser(func() error {
+		var resolved string
+		var err error
+		resolved, steps, err = d.resolve(file, false)
+		if err != nil {
+			return err
+		}
+		fi, err = statIn(d.dfd, resolved)
+		return err
+	})
+	if d.opts.resolveTrace != nil {
+		d.opts.resolveTrace(file, steps, err)
+	}
+	return fi, err
+}
+
+// asUser runs fn with the credentials set up by e.g. WithImpersonation, if any.
+func (d *Dir) asUser(fn func() error) error {
+	if d.opts.runAs == nil {
+		return fn()
+	}
+	return d.opts.runAs(fn)
+}
+
+// resolve resolves the symbolic links in file with the symlink policy of d. Without a policy,
+// file is returned as is, and the lookup is only done to gather the steps if d traces them.
+func (d *Dir) resolve(file string, followLast bool) (string, []ResolveStep, error) {
+	var steps []ResolveStep
+	var trace *[]ResolveStep
+	if d.opts.resolveTrace != nil {
+		trace = &steps
+	}
+	if d.opts.symlinkPolicy == nil {
+		if trace != nil {
+			resolveIn(d.dfd, file, followLast, allowAllSymlinks, trace)
+		}
+		return file, steps, nil
+	}
+	resolved, err := resolveIn(d.dfd, file, followLast, d.opts.symlinkPolicy, trace)
+	return resolved, steps, err
+}
+
+func (d *Dir) readFile(file string) ([]byte, error) {
+	if err := d.fds.acquire(file); err != nil {
+		return nil, err
+	}
+	defer d.fds.release()
+	f, err := d.openFile(file, os.O_RDONLY, 0)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	data, err := io.ReadAll(f)
+	d.acct.read(len(data))
+	if err == nil && d.opts.checksums {
+		err = verifyChecksum(f, file, data)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return data, nil
+}
+
+// readGroup coalesces concurrent reads of the same key.
+type readGroup struct {
+	mu    sync.Mutex
+	calls map[string]*readCall
+}
+
+type readCall struct {
+	wg     sync.WaitGroup
+	dups   int
+	copies [][]byte
+	err    error
+}
+
+func (g *readGroup) do(key string, fn func() ([]byte, error)) ([]byte, error) {
+	g.mu.Lock()
+	if c, ok := g.calls[key]; ok {
+		i := c.dups
+		c.dups++
+		g.mu.Unlock()
+		c.wg.Wait()
+		if c.err != nil {
+			return nil, c.err
+		}
+		return c.copies[i], nil
+	}
+	c := &readCall{}
+	c.wg.Add(1)
+	g.calls[key] = c
+	g.mu.Unlock()
+
+	data, err := fn()
+
+	g.mu.Lock()
+	delete(g.calls, key)
+	g.mu.Unlock()
+
+	// No more waiters may join at this point. Each of them gets its own copy, prepared before
+	// data is handed over to the caller, which is free to modify it.
+	c.err = err
+	if err == nil {
+		c.copies = make([][]byte, c.dups)
+		for i := range c.copies {
+			c.copies[i] = append([]byte(nil), data...)
+		}
+	}
+	c.wg.Done()
+	return data, err
+}
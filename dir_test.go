@@ -0,0 +1,270 @@
+// Copyright 2024 Google LLC.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package safeopen
+
+import (
+	"os"
+	"path"
+	"runtime"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestDirReadFile(t *testing.T) {
+	filename := "something.txt"
+	edata := []byte("content")
+
+	tmpDir := t.TempDir()
+	if err := os.Mkdir(path.Join(tmpDir, "subdir"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	filenameInSubdir := path.Join("subdir", filename)
+	if err := os.WriteFile(path.Join(tmpDir, filenameInSubdir), edata, 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, opts := range [][]Option{nil, {WithSharedReads()}} {
+		d, err := OpenDir(tmpDir, opts...)
+		if err != nil {
+			t.Fatalf("OpenDir(%q) error: %v", tmpDir, err)
+		}
+		adata, err := d.ReadFile(filenameInSubdir)
+		if err != nil {
+			t.Fatalf("ReadFile(%q) error: %v", filenameInSubdir, err)
+		}
+		if string(adata) != string(edata) {
+			t.Errorf("ReadFile(%q) = %q, want %q", filenameInSubdir, adata, edata)
+		}
+		if _, err := d.ReadFile("../" + filename); err == nil {
+			t.Errorf("ReadFile(%q) should have been an error", "../"+filename)
+		}
+		if err := d.Close(); err != nil {
+			t.Errorf("Close() error: %v", err)
+		}
+	}
+}
+
+func TestDirOpenFile(t *testing.T) {
+	tmpDir := t.TempDir()
+	d, err := OpenDir(tmpDir, WithStatCache(8, time.Hour))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer d.Close()
+
+	if exists, err := d.Exists("file.txt"); err != nil || exists {
+		t.Fatalf("Exists(file.txt) = %v, %v, want false", exists, err)
+	}
+	f, err := d.Create("file.txt")
+	if err != nil {
+		t.Fatalf("Create(file.txt) error: %v", err)
+	}
+	if _, err := f.WriteString("hello"); err != nil {
+		t.Fatal(err)
+	}
+	f.Close()
+	// Creating the file drops the cached result of Exists.
+	if exists, err := d.Exists("file.txt"); err != nil || !exists {
+		t.Errorf("Exists(file.txt) = %v, %v, want true", exists, err)
+	}
+
+	f, err = d.OpenFile("file.txt", os.O_WRONLY|os.O_APPEND, 0)
+	if err != nil {
+		t.Fatalf("OpenFile(file.txt) error: %v", err)
+	}
+	if _, err := f.WriteString(" world"); err != nil {
+		t.Fatal(err)
+	}
+	f.Close()
+
+	f, err = d.Open("file.txt")
+	if err != nil {
+		t.Fatalf("Open(file.txt) error: %v", err)
+	}
+	buf := make([]byte, 32)
+	n, _ := f.Read(buf)
+	f.Close()
+	if got := string(buf[:n]); got != "hello world" {
+		t.Errorf("Open(file.txt) read %q, want %q", got, "hello world")
+	}
+
+	if _, err := d.Open("../file.txt"); err == nil {
+		t.Error("Open(../file.txt) should have been an error")
+	}
+	if _, err := d.Create("../file.txt"); err == nil {
+		t.Error("Create(../file.txt) should have been an error")
+	}
+}
+
+func TestReadGroup(t *testing.T) {
+	g := &readGroup{calls: make(map[string]*readCall)}
+
+	const waiters = 3
+	calls := 0
+	release := make(chan struct{})
+	fn := func() ([]byte, error) {
+		calls++
+		<-release
+		return []byte("data"), nil
+	}
+
+	results := make(chan []byte, waiters+1)
+	go func() {
+		data, _ := g.do("key", fn)
+		results <- data
+	}()
+	var wg sync.WaitGroup
+	for {
+		g.mu.Lock()
+		c := g.calls["key"]
+		g.mu.Unlock()
+		if c != nil {
+			break
+		}
+		runtime.Gosched()
+	}
+	for i := 0; i < waiters; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			data, _ := g.do("key", fn)
+			results <- data
+		}()
+	}
+	for {
+		g.mu.Lock()
+		dups := g.calls["key"].dups
+		g.mu.Unlock()
+		if dups == waiters {
+			break
+		}
+		runtime.Gosched()
+	}
+	close(release)
+	wg.Wait()
+
+	for i := 0; i < waiters+1; i++ {
+		data := <-results
+		if string(data) != "data" {
+			t.Errorf("do() = %q, want %q", data, "data")
+		}
+		// Every caller must get its own copy.
+		data[0] = 'X'
+	}
+	if calls != 1 {
+		t.Errorf("fn called %d times, want 1", calls)
+	}
+}
+
+func TestDirLstat(t *testing.T) {
+	tmpDir := t.TempDir()
+	if err := os.Mkdir(path.Join(tmpDir, "subdir"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path.Join(tmpDir, "subdir", "data.txt"), []byte("content"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	d, err := OpenDir(tmpDir)
+	if err != nil {
+		t.Fatalf("OpenDir(%q) error: %v", tmpDir, err)
+	}
+	defer d.Close()
+
+	fi, err := d.Lstat("subdir/data.txt")
+	if err != nil {
+		t.Fatalf("Lstat(%q) error: %v", "subdir/data.txt", err)
+	}
+	if fi.Name() != "data.txt" || fi.Size() != 7 || !fi.Mode().IsRegular() {
+		t.Errorf("Lstat(%q) = %v %v %v, want data.txt 7 regular", "subdir/data.txt", fi.Name(), fi.Size(), fi.Mode())
+	}
+
+	for _, dir := range []string{"subdir", "subdir/", "."} {
+		fi, err = d.Lstat(dir)
+		if err != nil {
+			t.Fatalf("Lstat(%q) error: %v", dir, err)
+		}
+		if !fi.IsDir() {
+			t.Errorf("Lstat(%q).IsDir() = false, want true", dir)
+		}
+	}
+
+	if ok, err := d.Exists("subdir/missing.txt"); ok || err != nil {
+		t.Errorf("Exists(%q) = %v, %v, want false, nil", "subdir/missing.txt", ok, err)
+	}
+	if _, err := d.Exists("../subdir"); err == nil {
+		t.Errorf("Exists(%q) should have been an error", "../subdir")
+	}
+}
+
+func TestDirStatCache(t *testing.T) {
+	tmpDir := t.TempDir()
+	filename := "data.txt"
+
+	d, err := OpenDir(tmpDir, WithStatCache(10, time.Hour))
+	if err != nil {
+		t.Fatalf("OpenDir(%q) error: %v", tmpDir, err)
+	}
+	defer d.Close()
+
+	if ok, err := d.Exists(filename); ok || err != nil {
+		t.Fatalf("Exists(%q) = %v, %v, want false, nil", filename, ok, err)
+	}
+	if err := os.WriteFile(path.Join(tmpDir, filename), []byte("content"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if ok, _ := d.Exists(filename); ok {
+		t.Errorf("Exists(%q) = true, want the cached false", filename)
+	}
+
+	d.Invalidate("./" + filename)
+	if ok, err := d.Exists(filename); !ok || err != nil {
+		t.Errorf("Exists(%q) after Invalidate = %v, %v, want true, nil", filename, ok, err)
+	}
+}
+
+func TestNewDirFromFd(t *testing.T) {
+	tmpDir := t.TempDir()
+	if err := os.WriteFile(path.Join(tmpDir, "file.txt"), []byte("data"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	f, err := os.Open(path.Join(tmpDir, "file.txt"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	if _, err := NewDirFromFd(f.Fd(), "file.txt"); err == nil {
+		t.Errorf("NewDirFromFd() of a regular file succeeded, want error")
+	}
+
+	// The Dir takes ownership of the descriptor.
+	dfd, err := openDirHandle(tmpDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	d, err := NewDirFromFd(uintptr(dfd), "inherited")
+	if err != nil {
+		t.Fatalf("NewDirFromFd() error: %v", err)
+	}
+	defer d.Close()
+	if d.Name() != "inherited" {
+		t.Errorf("Name() = %q, want %q", d.Name(), "inherited")
+	}
+	if data, err := d.ReadFile("file.txt"); err != nil || string(data) != "data" {
+		t.Errorf("ReadFile(%q) = %q, %v, want %q, nil", "file.txt", data, err, "data")
+	}
+}
@@ -0,0 +1,77 @@
+// Copyright 2024 Google LLC.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package safeopen
+
+import (
+	"io/fs"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+)
+
+// DirFS returns a file system (an fs.FS) for the tree of files rooted at the directory, as
+// os.DirFS does, but opening files with the semantics of OpenBeneath: symbolic links are not
+// allowed to escape the directory. The result can be passed to http.FS, template.ParseFS,
+// fs.WalkDir, etc.
+//
+// As with os.DirFS, the directory is opened again for each call; a Router over a single Dir
+// keeps it open instead. The result implements fs.ReadFileFS and fs.StatFS.
+func DirFS(directory string) fs.FS {
+	return dirFS(directory)
+}
+
+type dirFS string
+
+// Open implements fs.FS, opening the named file for reading.
+func (dir dirFS) Open(name string) (fs.File, error) {
+	file, err := dir.join("open", name)
+	if err != nil {
+		return nil, err
+	}
+	f, err := OpenBeneath(string(dir), file)
+	if err != nil {
+		return nil, routedError("open", err, name)
+	}
+	return f, nil
+}
+
+// ReadFile implements fs.ReadFileFS.
+func (dir dirFS) ReadFile(name string) ([]byte, error) {
+	file, err := dir.join("read", name)
+	if err != nil {
+		return nil, err
+	}
+	data, err := ReadFileBeneath(string(dir), file)
+	return data, routedError("read", err, name)
+}
+
+// Stat implements fs.StatFS.
+func (dir dirFS) Stat(name string) (fs.FileInfo, error) {
+	f, err := dir.Open(name)
+	if err != nil {
+		return nil, routedError("stat", err, name)
+	}
+	defer f.Close()
+	return f.Stat()
+}
+
+// join checks that name is a valid fs.FS path and converts it to a path beneath dir.
+func (dir dirFS) join(op, name string) (string, error) {
+	if !fs.ValidPath(name) || runtime.GOOS == "windows" && strings.ContainsAny(name, `\:`) {
+		return "", &os.PathError{Op: op, Path: name, Err: fs.ErrInvalid}
+	}
+	return filepath.FromSlash(name), nil
+}
@@ -0,0 +1,66 @@
+// Copyright 2024 Google LLC.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package safeopen
+
+import (
+	"errors"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"testing"
+	"testing/fstest"
+)
+
+func TestDirFS(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.MkdirAll(filepath.Join(dir, "a", "b"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	for _, file := range []string{"top.txt", "a/one.txt", "a/b/two.txt"} {
+		if err := os.WriteFile(filepath.Join(dir, filepath.FromSlash(file)), []byte(file), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	fsys := DirFS(dir)
+	if err := fstest.TestFS(fsys, "top.txt", "a/one.txt", "a/b/two.txt"); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, name := range []string{"../top.txt", "/top.txt", "a/../top.txt", ""} {
+		if _, err := fsys.Open(name); !errors.Is(err, fs.ErrInvalid) {
+			t.Errorf("Open(%q) = %v, want ErrInvalid", name, err)
+		}
+	}
+	_, err := fs.ReadFile(fsys, "missing")
+	var pe *fs.PathError
+	if !errors.As(err, &pe) || pe.Path != "missing" || !errors.Is(err, fs.ErrNotExist) {
+		t.Errorf("ReadFile(missing) = %v, want a PathError for missing", err)
+	}
+}
+
+func TestDirFSSymlinkEscape(t *testing.T) {
+	dir := t.TempDir()
+	outside := t.TempDir()
+	if err := os.WriteFile(filepath.Join(outside, "secret"), []byte("secret"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Symlink(filepath.Join(outside, "secret"), filepath.Join(dir, "link")); err != nil {
+		t.Skipf("cannot create symbolic links: %v", err)
+	}
+	if _, err := fs.ReadFile(DirFS(dir), "link"); err == nil {
+		t.Error("ReadFile(link) escaped the directory")
+	}
+}
@@ -0,0 +1,55 @@
+// Copyright 2024 Google LLC.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package safeopen
+
+import (
+	"errors"
+	"os"
+	"sync/atomic"
+)
+
+var symlinkEmulation atomic.Bool
+
+// SetSymlinkEmulation enables or disables symbolic link emulation for the whole program. By
+// default, where openat2 with RESOLVE_BENEATH is not available (Windows, Unix systems other
+// than Linux, and Linux kernels before 5.6), OpenBeneath, the related functions and Dir reject
+// all symbolic links, whatever the Go version. With emulation, the symbolic links staying
+// beneath the directory are followed instead, e.g. current -> releases/42, as on Linux with
+// openat2.
+//
+// Links are then resolved by this package one component at a time, checking that each hop stays
+// beneath the directory, as by a Dir configured with WithSymlinkPolicy, and the resulting path
+// is opened without following any link, so a link swapped in concurrently makes the operation
+// fail rather than escape the directory. When built with Go 1.24 or newer, OpenFileBeneath and
+// the functions built on it leave the resolution to os.Root instead, which gives the same
+// guarantees. Strict mode still refuses such resolutions. On Windows, junctions are followed as
+// well, provided their target is beneath the directory.
+func SetSymlinkEmulation(enabled bool) {
+	symlinkEmulation.Store(enabled)
+}
+
+// openFileBeneathFollowIn opens file beneath dfd as openFileBeneathIn does, resolving the
+// symbolic links in file itself if the platform rejects them and emulation is enabled.
+func openFileBeneathFollowIn(dfd handle, directory, file string, flag int, perm os.FileMode) (*os.File, error) {
+	f, err := openFileBeneathIn(dfd, directory, file, flag, perm)
+	if err == nil || !symlinkEmulation.Load() || !errors.Is(err, ErrSymlinkRejected) {
+		return f, err
+	}
+	resolved, err := resolveIn(dfd, file, true, allowAllSymlinks, nil)
+	if err != nil {
+		return nil, err
+	}
+	return openFileNoFollowIn(dfd, directory, resolved, flag, perm)
+}
@@ -0,0 +1,73 @@
+// Copyright 2024 Google LLC.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package safeopen
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// setupReleases creates a directory laid out as a deployment, with current -> releases/42 and
+// a link escaping the directory.
+func setupReleases(t *testing.T) string {
+	t.Helper()
+	tmpdir := t.TempDir()
+	root := filepath.Join(tmpdir, "root")
+	if err := os.MkdirAll(filepath.Join(root, "releases", "42"), 0777); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(root, "releases", "42", "data.txt"), []byte("hello"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(tmpdir, "secret.txt"), []byte("secret"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Symlink(filepath.Join("releases", "42"), filepath.Join(root, "current")); err != nil {
+		t.Skipf("os.Symlink() error: %v", err)
+	}
+	if err := os.Symlink(filepath.Join("..", "secret.txt"), filepath.Join(root, "escape")); err != nil {
+		t.Fatal(err)
+	}
+	return root
+}
+
+func TestSymlinkEmulation(t *testing.T) {
+	root := setupReleases(t)
+	SetSymlinkEmulation(true)
+	defer SetSymlinkEmulation(false)
+
+	if !Capabilities().FollowsSymlinks {
+		t.Error("Capabilities().FollowsSymlinks = false with symbolic link emulation")
+	}
+
+	d, err := OpenDir(root)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer d.Close()
+
+	for _, file := range []string{filepath.Join("current", "data.txt"), filepath.Join("current", "..", "current", "data.txt")} {
+		data, err := d.ReadFile(file)
+		if err != nil {
+			t.Errorf("ReadFile(%q) error: %v", file, err)
+		} else if string(data) != "hello" {
+			t.Errorf("ReadFile(%q) = %q, want %q", file, data, "hello")
+		}
+	}
+	if _, err := d.ReadFile("escape"); err == nil {
+		t.Error("ReadFile(\"escape\") succeeded, want error")
+	}
+}
@@ -0,0 +1,104 @@
+// Copyright 2024 Google LLC.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package safeopen
+
+import (
+	"errors"
+	"strings"
+)
+
+// EncodeFilename maps an arbitrary string to a name that is safe to use as a single file name
+// with OpenAt, CreateAt and the like, on all supported platforms. Distinct strings are mapped to
+// distinct names, even on case insensitive file systems, and DecodeFilename returns the
+// original string.
+//
+// Lower case ASCII letters, digits, '-', '_' and inner '.' are kept as is; all other bytes are
+// written as '%' followed by two lower case hexadecimal digits. Leading and trailing dots, and
+// the first letter of the names reserved on Windows (CON, NUL, COM1, ...) are escaped as well.
+// The empty string is encoded as "%".
+//
+// The result may be up to three times as long as s; names longer than the limit of the file
+// system (usually 255 bytes) cannot be created.
+func EncodeFilename(s string) string {
+	if s == "" {
+		return "%"
+	}
+	var b strings.Builder
+	b.Grow(len(s))
+	reserved := isReservedName(s)
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		switch {
+		case i == 0 && reserved, c == '.' && (i == 0 || i == len(s)-1), !isPlainFilenameByte(c):
+			b.WriteByte('%')
+			b.WriteByte(hexDigits[c>>4])
+			b.WriteByte(hexDigits[c&0xf])
+		default:
+			b.WriteByte(c)
+		}
+	}
+	return b.String()
+}
+
+// DecodeFilename returns the string encoded by EncodeFilename as name. It returns an error if
+// name was not produced by EncodeFilename.
+func DecodeFilename(name string) (string, error) {
+	if name == "%" {
+		return "", nil
+	}
+	var b strings.Builder
+	b.Grow(len(name))
+	for i := 0; i < len(name); i++ {
+		if name[i] != '%' {
+			b.WriteByte(name[i])
+			continue
+		}
+		if i+2 >= len(name) {
+			return "", errors.New("safeopen: truncated escape in encoded filename")
+		}
+		hi, lo := strings.IndexByte(hexDigits, name[i+1]), strings.IndexByte(hexDigits, name[i+2])
+		if hi < 0 || lo < 0 {
+			return "", errors.New("safeopen: invalid escape in encoded filename")
+		}
+		b.WriteByte(byte(hi<<4 | lo))
+		i += 2
+	}
+	s := b.String()
+	if EncodeFilename(s) != name {
+		return "", errors.New("safeopen: non canonical encoded filename")
+	}
+	return s, nil
+}
+
+const hexDigits = "0123456789abcdef"
+
+func isPlainFilenameByte(c byte) bool {
+	return 'a' <= c && c <= 'z' || '0' <= c && c <= '9' || c == '-' || c == '_' || c == '.'
+}
+
+// isReservedName reports whether s, up to its first dot, is a device name reserved on Windows,
+// regardless of case.
+func isReservedName(s string) bool {
+	base, _, _ := strings.Cut(s, ".")
+	switch strings.ToUpper(base) {
+	case "CON", "PRN", "AUX", "NUL":
+		return true
+	}
+	if len(base) == 4 {
+		prefix := strings.ToUpper(base[:3])
+		return (prefix == "COM" || prefix == "LPT") && '0' <= base[3] && base[3] <= '9'
+	}
+	return false
+}
@@ -0,0 +1,79 @@
+// Copyright 2024 Google LLC.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package safeopen
+
+import (
+	"os"
+	"testing"
+)
+
+func TestEncodeFilename(t *testing.T) {
+	tests := []struct {
+		s    string
+		want string
+	}{
+		{"", "%"},
+		{"data.txt", "data.txt"},
+		{"Data.txt", "%44ata.txt"},
+		{"a/b\\c", "a%2fb%5cc"},
+		{".", "%2e"},
+		{"..", "%2e%2e"},
+		{".hidden", "%2ehidden"},
+		{"trailing.", "trailing%2e"},
+		{"a b", "a%20b"},
+		{"100%", "100%25"},
+		{"con", "%63on"},
+		{"nul.txt", "%6eul.txt"},
+		{"com1", "%63om1"},
+		{"console", "console"},
+		{"é", "%c3%a9"},
+	}
+	for _, tc := range tests {
+		got := EncodeFilename(tc.s)
+		if got != tc.want {
+			t.Errorf("EncodeFilename(%q) = %q, want %q", tc.s, got, tc.want)
+		}
+		if !isFilename(got) {
+			t.Errorf("EncodeFilename(%q) = %q, which is not a valid file name", tc.s, got)
+		}
+		back, err := DecodeFilename(got)
+		if err != nil || back != tc.s {
+			t.Errorf("DecodeFilename(%q) = %q, %v, want %q", got, back, err, tc.s)
+		}
+	}
+
+	for _, name := range []string{"%4", "%zz", "%61", "a%2fb%2F", "%2E", "con"} {
+		if s, err := DecodeFilename(name); err == nil {
+			t.Errorf("DecodeFilename(%q) = %q, want error", name, s)
+		}
+	}
+}
+
+func TestEncodeFilenameCreate(t *testing.T) {
+	tmpDir := t.TempDir()
+	for _, s := range []string{"../etc/passwd", "a/b", "CON", "Upper", "upper", ".", " "} {
+		name := EncodeFilename(s)
+		if err := WriteFileAt(tmpDir, name, []byte(s), 0644); err != nil {
+			t.Errorf("WriteFileAt(%q) for %q error: %v", name, s, err)
+		}
+	}
+	entries, err := os.ReadDir(tmpDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 7 {
+		t.Errorf("%d files created, want 7", len(entries))
+	}
+}
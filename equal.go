@@ -0,0 +1,84 @@
+// Copyright 2024 Google LLC.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package safeopen
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"os"
+)
+
+// equalBlockSize is the size of the blocks compared by equalFiles.
+const equalBlockSize = 64 << 10
+
+// EqualFilesAt reports whether the named file in directory a and the named file in directory
+// b have the same content. The directories may be the same or not. file names may not contain
+// path separators.
+//
+// The files are compared block by block, and the comparison stops at the first difference,
+// without reading the files at all if their sizes differ.
+func EqualFilesAt(adirectory, afile, bdirectory, bfile string) (bool, error) {
+	af, err := OpenAt(adirectory, afile)
+	if err != nil {
+		return false, err
+	}
+	defer af.Close()
+	bf, err := OpenAt(bdirectory, bfile)
+	if err != nil {
+		return false, err
+	}
+	defer bf.Close()
+
+	return equalFiles(af, bf)
+}
+
+// equalFiles reports whether a and b, both at their start, have the same content.
+func equalFiles(a, b *os.File) (bool, error) {
+	afi, err := a.Stat()
+	if err != nil {
+		return false, err
+	}
+	bfi, err := b.Stat()
+	if err != nil {
+		return false, err
+	}
+	if !afi.Mode().IsRegular() || !bfi.Mode().IsRegular() {
+		return false, errors.New("safeopen: comparing files that are not regular files")
+	}
+	if afi.Size() != bfi.Size() {
+		return false, nil
+	}
+
+	abuf, bbuf := make([]byte, equalBlockSize), make([]byte, equalBlockSize)
+	for {
+		an, aerr := io.ReadFull(a, abuf)
+		bn, berr := io.ReadFull(b, bbuf)
+		if !bytes.Equal(abuf[:an], bbuf[:bn]) {
+			return false, nil
+		}
+		aeof := aerr == io.EOF || aerr == io.ErrUnexpectedEOF
+		beof := berr == io.EOF || berr == io.ErrUnexpectedEOF
+		switch {
+		case aerr != nil && !aeof:
+			return false, aerr
+		case berr != nil && !beof:
+			return false, berr
+		case aeof || beof:
+			// Both files ended at the same point, as the blocks are equal.
+			return aeof == beof, nil
+		}
+	}
+}
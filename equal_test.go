@@ -0,0 +1,66 @@
+// Copyright 2024 Google LLC.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package safeopen
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestEqualFilesAt(t *testing.T) {
+	adir, bdir := t.TempDir(), t.TempDir()
+	big := bytes.Repeat([]byte("0123456789"), equalBlockSize/5)
+	changed := append([]byte(nil), big...)
+	changed[len(changed)-1] = 'x'
+	files := map[string][]byte{
+		filepath.Join(adir, "big"):     big,
+		filepath.Join(bdir, "big"):     big,
+		filepath.Join(bdir, "changed"): changed,
+		filepath.Join(bdir, "short"):   big[:len(big)-1],
+		filepath.Join(adir, "empty"):   nil,
+		filepath.Join(bdir, "empty"):   nil,
+	}
+	for file, data := range files {
+		if err := os.WriteFile(file, data, 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	tests := []struct {
+		afile, bfile string
+		want         bool
+	}{
+		{"big", "big", true},
+		{"big", "changed", false},
+		{"big", "short", false},
+		{"empty", "empty", true},
+		{"empty", "big", false},
+	}
+	for _, tc := range tests {
+		got, err := EqualFilesAt(adir, tc.afile, bdir, tc.bfile)
+		if err != nil {
+			t.Fatalf("EqualFilesAt(%q, %q) error: %v", tc.afile, tc.bfile, err)
+		}
+		if got != tc.want {
+			t.Errorf("EqualFilesAt(%q, %q) = %t, want %t", tc.afile, tc.bfile, got, tc.want)
+		}
+	}
+
+	if _, err := EqualFilesAt(adir, "missing", bdir, "big"); err == nil {
+		t.Errorf("EqualFilesAt(%q, %q) succeeded, want error", "missing", "big")
+	}
+}
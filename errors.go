@@ -0,0 +1,80 @@
+// Copyright 2024 Google LLC.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package safeopen
+
+import (
+	"errors"
+	"path/filepath"
+	"runtime"
+	"strings"
+)
+
+var (
+	// ErrInvalidFilename is wrapped by the errors returned for malformed names, e.g. empty ones,
+	// or ones containing path separators where a single filename is expected.
+	ErrInvalidFilename = errors.New("invalid filename")
+
+	// ErrTraversal is wrapped by the errors returned for paths escaping their base directory,
+	// whether with .. path traversal entries, as absolute paths, or through symbolic links where
+	// the platform reports it.
+	ErrTraversal = errors.New("path escapes its base directory")
+
+	// ErrSymlinkRejected is wrapped by the errors returned when a symbolic link is met where it may
+	// not be followed, e.g. in the file passed to OpenAt, or when a Dir rejects it by policy, in
+	// which case ErrSymlinkNotAllowed is wrapped as well.
+	ErrSymlinkRejected = errors.New("symbolic link rejected")
+
+	// ErrNotRegularFile is wrapped by the errors returned when a regular file is required, but
+	// the file is e.g. a directory or a device, and when a special file is opened, see
+	// SetAllowSpecialFiles.
+	ErrNotRegularFile = errors.New("not a regular file")
+)
+
+// beneathPathError returns the error reported for a path rejected by the Beneath functions:
+// ErrTraversal if it escapes its base directory, and ErrInvalidFilename if it is malformed.
+func beneathPathError(path string) error {
+	if path == "" || strings.ContainsRune(path, 0) {
+		return ErrInvalidFilename
+	}
+	if runtime.GOOS == "windows" && filepath.VolumeName(path) == "" && (strings.ContainsAny(path, "?:") || hasDeviceName(path) || hasTrailingDotOrSpace(path)) {
+		return ErrInvalidFilename
+	}
+	return ErrTraversal
+}
+
+// symlinkRejected wraps err, reporting a symbolic link that may not be followed, with
+// ErrSymlinkRejected.
+func symlinkRejected(err error) error {
+	return &rejectionError{kind: ErrSymlinkRejected, err: err}
+}
+
+// rejectionError is an error err, e.g. an errno, that is also reported as kind, one of the
+// sentinel errors of this package. Multiple %w verbs are avoided for the sake of older Go
+// versions.
+type rejectionError struct {
+	kind, err error
+}
+
+func (e *rejectionError) Error() string {
+	return e.kind.Error() + ": " + e.err.Error()
+}
+
+func (e *rejectionError) Unwrap() error {
+	return e.err
+}
+
+func (e *rejectionError) Is(target error) bool {
+	return target == e.kind
+}
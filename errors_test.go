@@ -0,0 +1,86 @@
+// Copyright 2024 Google LLC.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package safeopen
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+)
+
+func TestSentinelErrors(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.Mkdir(filepath.Join(dir, "sub"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "sub", "file"), nil, 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, tc := range []struct {
+		name string
+		err  error
+		want error
+	}{
+		{"OpenAt(sub/file)", openErr(OpenAt(dir, filepath.Join("sub", "file"))), ErrInvalidFilename},
+		{"OpenAt(\"\")", openErr(OpenAt(dir, "")), ErrInvalidFilename},
+		{"OpenBeneath(\"\")", openErr(OpenBeneath(dir, "")), ErrInvalidFilename},
+		{"OpenBeneath(../file)", openErr(OpenBeneath(dir, filepath.Join("..", "file"))), ErrTraversal},
+		{"OpenBeneath(sub/../../file)", openErr(OpenBeneath(dir, filepath.Join("sub", "..", "..", "file"))), ErrTraversal},
+		{"RemoveBeneath(../file)", RemoveBeneath(dir, filepath.Join("..", "file")), ErrTraversal},
+	} {
+		var pe *os.PathError
+		if !errors.As(tc.err, &pe) || !errors.Is(tc.err, tc.want) {
+			t.Errorf("%s = %v, want a PathError wrapping %v", tc.name, tc.err, tc.want)
+		}
+	}
+
+	d, err := OpenDir(dir, WithRegularFilesOnly())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer d.Close()
+	if _, err := d.ReadFile("sub"); !errors.Is(err, ErrNotRegularFile) {
+		t.Errorf("ReadFile(sub) with WithRegularFilesOnly = %v, want ErrNotRegularFile", err)
+	}
+
+	if err := os.Symlink(filepath.Join("sub", "file"), filepath.Join(dir, "link")); err != nil {
+		t.Skipf("cannot create symbolic links: %v", err)
+	}
+	if runtime.GOOS != "windows" {
+		if _, err := OpenAt(dir, "link"); !errors.Is(err, ErrSymlinkRejected) {
+			t.Errorf("OpenAt(link) = %v, want ErrSymlinkRejected", err)
+		}
+	}
+	d, err = OpenDir(dir, WithSymlinkPolicy(AllowSymlinkPrefixes("other")))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer d.Close()
+	_, err = d.ReadFile("link")
+	if !errors.Is(err, ErrSymlinkRejected) || !errors.Is(err, ErrSymlinkNotAllowed) {
+		t.Errorf("ReadFile(link) rejected by policy = %v, want ErrSymlinkRejected and ErrSymlinkNotAllowed", err)
+	}
+}
+
+// openErr returns the error of an open, closing the file if there is none.
+func openErr(f *os.File, err error) error {
+	if f != nil {
+		f.Close()
+	}
+	return err
+}
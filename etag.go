@@ -0,0 +1,85 @@
+// Copyright 2024 Google LLC.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package safeopen
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// ETagBeneath returns a strong ETag for the named file in the named directory, or a
+// subdirectory, along with its modification time (suitable for the Last-Modified header).
+// file may not contain .. path traversal entries.
+//
+// The ETag is derived from the SHA-256 digest of the content, so computing it requires reading
+// the whole file; callers serving large files should cache it.
+func ETagBeneath(directory, file string) (string, time.Time, error) {
+	f, err := OpenBeneath(directory, file)
+	if err != nil {
+		return "", time.Time{}, err
+	}
+	defer f.Close()
+
+	fi, err := f.Stat()
+	if err != nil {
+		return "", time.Time{}, err
+	}
+	h := sha256.New()
+	if _, err := io.Copy(h, f); err != nil {
+		return "", time.Time{}, err
+	}
+	return `"` + hex.EncodeToString(h.Sum(nil)) + `"`, fi.ModTime(), nil
+}
+
+// CheckNotModified evaluates the If-None-Match and If-Modified-Since headers of r against the
+// current etag and modification time of a resource, as described by RFC 9110. It reports
+// whether a 304 (Not Modified) response should be sent instead of the content.
+// An empty etag or a zero modTime disables the respective check.
+func CheckNotModified(r *http.Request, etag string, modTime time.Time) bool {
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		return false
+	}
+	if inm := r.Header.Get("If-None-Match"); inm != "" {
+		// If-Modified-Since must be ignored when If-None-Match is present.
+		return etag != "" && etagListMatches(inm, etag)
+	}
+	ims := r.Header.Get("If-Modified-Since")
+	if ims == "" || modTime.IsZero() || modTime.Unix() == 0 {
+		return false
+	}
+	t, err := http.ParseTime(ims)
+	if err != nil {
+		return false
+	}
+	// The header has a resolution of one second only.
+	return !modTime.Truncate(time.Second).After(t)
+}
+
+// etagListMatches reports whether the If-None-Match header value list contains etag, using the
+// weak comparison function.
+func etagListMatches(list, etag string) bool {
+	etag = strings.TrimPrefix(etag, "W/")
+	for _, candidate := range strings.Split(list, ",") {
+		candidate = strings.TrimSpace(candidate)
+		if candidate == "*" || strings.TrimPrefix(candidate, "W/") == etag {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,95 @@
+// Copyright 2024 Google LLC.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package safeopen
+
+import (
+	"net/http"
+	"os"
+	"path"
+	"testing"
+	"time"
+)
+
+func TestETagBeneath(t *testing.T) {
+	tmpDir := t.TempDir()
+	if err := os.Mkdir(path.Join(tmpDir, "subdir"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	file := path.Join("subdir", "data.txt")
+	if err := os.WriteFile(path.Join(tmpDir, file), []byte("content"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	etag1, modTime, err := ETagBeneath(tmpDir, file)
+	if err != nil {
+		t.Fatalf("ETagBeneath(%q, %q) error: %v", tmpDir, file, err)
+	}
+	if modTime.IsZero() {
+		t.Errorf("ETagBeneath(%q, %q) returned a zero modification time", tmpDir, file)
+	}
+	if err := os.WriteFile(path.Join(tmpDir, file), []byte("other content"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	etag2, _, err := ETagBeneath(tmpDir, file)
+	if err != nil {
+		t.Fatalf("ETagBeneath(%q, %q) error: %v", tmpDir, file, err)
+	}
+	if etag1 == etag2 {
+		t.Errorf("ETagBeneath(%q, %q) = %s for different content", tmpDir, file, etag1)
+	}
+
+	if _, _, err := ETagBeneath(tmpDir, "../data.txt"); err == nil {
+		t.Errorf("ETagBeneath(%q, %q) should have been an error", tmpDir, "../data.txt")
+	}
+}
+
+func TestCheckNotModified(t *testing.T) {
+	etag := `"abc"`
+	modTime := time.Date(2024, 1, 2, 3, 4, 5, 600, time.UTC)
+
+	type testCase struct {
+		method string
+		inm    string
+		ims    string
+		want   bool
+	}
+	testCases := []testCase{
+		{http.MethodGet, "", "", false},
+		{http.MethodGet, `"abc"`, "", true},
+		{http.MethodHead, `W/"abc"`, "", true},
+		{http.MethodGet, `"x", "abc"`, "", true},
+		{http.MethodGet, "*", "", true},
+		{http.MethodGet, `"x"`, modTime.Format(http.TimeFormat), false},
+		{http.MethodPost, `"abc"`, "", false},
+		{http.MethodGet, "", modTime.Format(http.TimeFormat), true},
+		{http.MethodGet, "", modTime.Add(-time.Second).Format(http.TimeFormat), false},
+		{http.MethodGet, "", "garbage", false},
+	}
+	for _, tc := range testCases {
+		r, err := http.NewRequest(tc.method, "/", nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if tc.inm != "" {
+			r.Header.Set("If-None-Match", tc.inm)
+		}
+		if tc.ims != "" {
+			r.Header.Set("If-Modified-Since", tc.ims)
+		}
+		if got := CheckNotModified(r, etag, modTime); got != tc.want {
+			t.Errorf("CheckNotModified(%s, If-None-Match: %q, If-Modified-Since: %q) = %v, want %v", tc.method, tc.inm, tc.ims, got, tc.want)
+		}
+	}
+}
@@ -0,0 +1,78 @@
+// Copyright 2024 Google LLC.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package safeopen
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+)
+
+// ErrCaseMismatch is wrapped by the errors returned when exact case is enforced, see
+// SetExactCase, and the name of a file on disk differs from the requested one.
+var ErrCaseMismatch = errors.New("file name does not match the requested case")
+
+var exactCase atomic.Bool
+
+// SetExactCase enables or disables exact-case enforcement for the whole program. On
+// case-insensitive file systems, as by default on Windows and macOS, OpenAt(dir, "Config") opens
+// the file named config, which may bypass allowlists of names in the calling code. With exact
+// case, the path of each file opened by OpenAt, OpenBeneath and the related functions is queried
+// from the system, and the operation fails with an error wrapping ErrCaseMismatch unless the
+// names of the file and of the directories leading to it beneath the base directory match the
+// requested ones byte for byte.
+//
+// A file with several hard links may be reported under another of its names, and is then
+// rejected as well. SetExactCase has no effect on other platforms, where file systems are
+// normally case-sensitive.
+func SetExactCase(enabled bool) {
+	exactCase.Store(enabled)
+}
+
+// exactCaseEnforced reports whether exact case is enabled and effective on this platform.
+func exactCaseEnforced() bool {
+	return finalPathAvailable && exactCase.Load()
+}
+
+// checkExactCase returns an error if exact case is enforced and the path of f on disk does not
+// end with file, the path f was opened with relative to its base directory. f is closed on
+// error.
+func checkExactCase(f *os.File, file string) error {
+	if !exactCaseEnforced() {
+		return nil
+	}
+	actual, err := finalPath(f)
+	if err == nil && !hasPathSuffix(actual, file) {
+		err = ErrCaseMismatch
+	}
+	if err != nil {
+		f.Close()
+		return &os.PathError{Op: "open", Path: f.Name(), Err: err}
+	}
+	return nil
+}
+
+// hasPathSuffix reports whether the last elements of the absolute path actual are, byte for
+// byte, those of the relative path file once cleaned.
+func hasPathSuffix(actual, file string) bool {
+	file = strings.TrimLeft(filepath.Clean(filepath.FromSlash(file)), string(filepath.Separator))
+	if file == "" || file == "." {
+		return true
+	}
+	sep := string(filepath.Separator)
+	return strings.HasSuffix(filepath.Clean(actual), sep+file)
+}
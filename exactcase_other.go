@@ -0,0 +1,32 @@
+// Copyright 2024 Google LLC.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+//go:build !darwin && !windows
+// +build !darwin,!windows
+
+package safeopen
+
+import (
+	"os"
+	"syscall"
+)
+
+// finalPathAvailable reports whether finalPath is implemented on this platform. File systems are
+// expected to be case-sensitive here, so exact case is not enforced.
+const finalPathAvailable = false
+
+// finalPath is not implemented on this platform.
+func finalPath(f *os.File) (string, error) {
+	return "", &os.PathError{Op: "getpath", Path: f.Name(), Err: syscall.ENOTSUP}
+}
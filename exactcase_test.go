@@ -0,0 +1,88 @@
+// Copyright 2024 Google LLC.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package safeopen
+
+import (
+	"errors"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestHasPathSuffix(t *testing.T) {
+	sep := string(filepath.Separator)
+	actual := filepath.Join(sep+"base", "Sub", "config")
+	for _, tc := range []struct {
+		file string
+		want bool
+	}{
+		{"config", true},
+		{"Sub/config", true},
+		{"/Sub/config", true},
+		{"Sub/../Sub/config", true},
+		{".", true},
+		{"Config", false},
+		{"sub/config", false},
+		{"onfig", false},
+	} {
+		if got := hasPathSuffix(actual, tc.file); got != tc.want {
+			t.Errorf("hasPathSuffix(%q, %q) = %v, want %v", actual, tc.file, got, tc.want)
+		}
+	}
+}
+
+func TestExactCase(t *testing.T) {
+	tmpdir := t.TempDir()
+	if err := os.Mkdir(filepath.Join(tmpdir, "sub"), 0777); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(tmpdir, "sub", "config"), []byte("hello"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	_, err := os.Stat(filepath.Join(tmpdir, "SUB", "CONFIG"))
+	insensitive := err == nil
+
+	SetExactCase(true)
+	defer SetExactCase(false)
+	if got := Capabilities().ExactCase; got != finalPathAvailable {
+		t.Errorf("Capabilities().ExactCase = %v, want %v", got, finalPathAvailable)
+	}
+
+	if data, err := ReadFileAt(filepath.Join(tmpdir, "sub"), "config"); err != nil || string(data) != "hello" {
+		t.Errorf("ReadFileAt(\"config\") = %q, %v, want %q", data, err, "hello")
+	}
+	if data, err := ReadFileBeneath(tmpdir, "sub/config"); err != nil || string(data) != "hello" {
+		t.Errorf("ReadFileBeneath(\"sub/config\") = %q, %v, want %q", data, err, "hello")
+	}
+
+	var want error
+	switch {
+	case !insensitive:
+		want = fs.ErrNotExist
+	case finalPathAvailable:
+		want = ErrCaseMismatch
+	default:
+		t.Skip("exact case is not enforced on this platform")
+	}
+	if _, err := ReadFileAt(filepath.Join(tmpdir, "sub"), "CONFIG"); !errors.Is(err, want) {
+		t.Errorf("ReadFileAt(\"CONFIG\") error = %v, want %v", err, want)
+	}
+	for _, file := range []string{"SUB/config", "sub/Config"} {
+		if _, err := ReadFileBeneath(tmpdir, file); !errors.Is(err, want) {
+			t.Errorf("ReadFileBeneath(%q) error = %v, want %v", file, err, want)
+		}
+	}
+}
@@ -0,0 +1,41 @@
+// Copyright 2024 Google LLC.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+//go:build windows
+// +build windows
+
+package safeopen
+
+import (
+	"os"
+
+	"golang.org/x/sys/windows"
+)
+
+// finalPathAvailable reports whether finalPath is implemented on this platform.
+const finalPathAvailable = true
+
+// finalPath returns the path of f as known to the system, with the case of the names on disk.
+func finalPath(f *os.File) (string, error) {
+	buf := make([]uint16, windows.MAX_LONG_PATH)
+	// Flags 0 are FILE_NAME_NORMALIZED and VOLUME_NAME_DOS, e.g. \\?\C:\dir.
+	n, err := windows.GetFinalPathNameByHandle(windows.Handle(f.Fd()), &buf[0], uint32(len(buf)), 0)
+	if err != nil {
+		return "", err
+	}
+	if int(n) >= len(buf) {
+		return "", windows.ERROR_BUFFER_OVERFLOW
+	}
+	return winDOSPath(windows.UTF16ToString(buf[:n])), nil
+}
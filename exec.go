@@ -0,0 +1,42 @@
+// Copyright 2024 Google LLC.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package safeopen
+
+import (
+	"os"
+	"os/exec"
+)
+
+// PassFiles arranges for files, e.g. opened with OpenBeneath, to be inherited by the process cmd
+// starts, and returns the descriptors under which that process finds them, in order. This lets
+// programs hand files beneath a base directory to converters or scanners, which then never have
+// to resolve their paths.
+//
+// On Unix systems, the files are appended to cmd.ExtraFiles, and the returned descriptors are
+// their numbers in the child process, starting at 3. Only the child process gets them: they
+// are made close-on-exec in the parent, as the files opened by this package already are, so
+// that other processes started concurrently do not inherit them.
+//
+// On Windows, the handles are added to the explicit list of handles the child process inherits
+// (SysProcAttr.AdditionalInheritedHandles), so that no other handle is inherited along with
+// them, and the returned descriptors are their values, which are the same in the child process.
+//
+// In both cases, the descriptors have to be communicated to the child process, e.g. on its
+// command line.
+//
+// The files must remain open until cmd is started.
+func PassFiles(cmd *exec.Cmd, files ...*os.File) ([]uintptr, error) {
+	return passFiles(cmd, files)
+}
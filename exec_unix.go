@@ -0,0 +1,43 @@
+// Copyright 2024 Google LLC.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+//go:build unix
+// +build unix
+
+package safeopen
+
+import (
+	"os"
+	"os/exec"
+
+	"golang.org/x/sys/unix"
+)
+
+func passFiles(cmd *exec.Cmd, files []*os.File) ([]uintptr, error) {
+	fds := make([]uintptr, len(files))
+	for i, f := range files {
+		// os/exec maps ExtraFiles[i] to descriptor 3+i in the child process, duplicating it
+		// there only, so the original stays close-on-exec.
+		fds[i] = uintptr(3 + len(cmd.ExtraFiles) + i)
+		conn, err := f.SyscallConn()
+		if err == nil {
+			err = conn.Control(func(fd uintptr) { unix.CloseOnExec(int(fd)) })
+		}
+		if err != nil {
+			return nil, &os.PathError{Op: "PassFiles", Path: f.Name(), Err: err}
+		}
+	}
+	cmd.ExtraFiles = append(cmd.ExtraFiles, files...)
+	return fds, nil
+}
@@ -0,0 +1,125 @@
+// Copyright 2024 Google LLC.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+//go:build unix
+// +build unix
+
+package safeopen
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"testing"
+
+	"golang.org/x/sys/unix"
+)
+
+func TestUnixPassFiles(t *testing.T) {
+	tmpDir := t.TempDir()
+	for _, name := range []string{"a.txt", "b.txt"} {
+		if err := os.WriteFile(filepath.Join(tmpDir, name), []byte(name), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	a, err := OpenBeneath(tmpDir, "a.txt")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer a.Close()
+	b, err := OpenBeneath(tmpDir, "b.txt")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer b.Close()
+
+	sh, err := exec.LookPath("sh")
+	if err != nil {
+		t.Skip("sh not available")
+	}
+	cmd := exec.Command(sh)
+	fds, err := PassFiles(cmd, a, b)
+	if err != nil {
+		t.Fatalf("PassFiles() error: %v", err)
+	}
+	if len(fds) != 2 || fds[0] != 3 || fds[1] != 4 {
+		t.Fatalf("PassFiles() = %v, want [3 4]", fds)
+	}
+	if !isCloseOnExec(t, a.Fd()) {
+		t.Error("passed file is not close-on-exec in the parent")
+	}
+	cmd.Args = append(cmd.Args, "-c", fmt.Sprintf("cat <&%d; cat <&%d", fds[1], fds[0]))
+	out, err := cmd.Output()
+	if err != nil {
+		t.Fatalf("running %v: %v", cmd.Args, err)
+	}
+	if string(out) != "b.txta.txt" {
+		t.Errorf("child process output = %q, want %q", out, "b.txta.txt")
+	}
+}
+
+// isCloseOnExec reports whether fd has FD_CLOEXEC set.
+func isCloseOnExec(t *testing.T, fd uintptr) bool {
+	t.Helper()
+	flags, err := unix.FcntlInt(fd, unix.F_GETFD, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return flags&unix.FD_CLOEXEC != 0
+}
+
+func TestUnixCloseOnExec(t *testing.T) {
+	tmpDir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(tmpDir, "a.txt"), []byte("a"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	defer SetStrictMode(false)
+
+	d, err := OpenDir(tmpDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer d.Close()
+	if !isCloseOnExec(t, uintptr(d.dfd)) {
+		t.Error("OpenDir() descriptor is not close-on-exec")
+	}
+
+	openers := map[string]func() (*os.File, error){
+		"OpenAt":   func() (*os.File, error) { return OpenAt(tmpDir, "a.txt") },
+		"Dir.Open": func() (*os.File, error) { return d.Open("a.txt") },
+		"OpenBeneath(strict)": func() (*os.File, error) {
+			SetStrictMode(true)
+			defer SetStrictMode(false)
+			return OpenBeneath(tmpDir, "a.txt")
+		},
+	}
+	for name, open := range openers {
+		f, err := open()
+		if err != nil {
+			t.Errorf("%s error: %v", name, err)
+			continue
+		}
+		conn, err := f.SyscallConn()
+		if err != nil {
+			t.Fatal(err)
+		}
+		conn.Control(func(fd uintptr) {
+			if !isCloseOnExec(t, fd) {
+				t.Errorf("%s descriptor is not close-on-exec", name)
+			}
+		})
+		f.Close()
+	}
+}
@@ -0,0 +1,53 @@
+// Copyright 2024 Google LLC.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+//go:build windows
+// +build windows
+
+package safeopen
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"testing"
+)
+
+func TestWinPassFiles(t *testing.T) {
+	tmpDir := t.TempDir()
+	for _, name := range []string{"a.txt", "b.txt"} {
+		if err := os.WriteFile(filepath.Join(tmpDir, name), []byte(name), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	a, err := OpenBeneath(tmpDir, "a.txt")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer a.Close()
+	b, err := OpenBeneath(tmpDir, "b.txt")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer b.Close()
+
+	cmd := exec.Command("cmd.exe")
+	fds, err := PassFiles(cmd, a, b)
+	if err != nil {
+		t.Fatalf("PassFiles() error: %v", err)
+	}
+	if len(cmd.SysProcAttr.AdditionalInheritedHandles) != 2 || fds[0] != a.Fd() || fds[1] != b.Fd() {
+		t.Errorf("PassFiles() = %v with inherited handles %v, want the handles of the files", fds, cmd.SysProcAttr.AdditionalInheritedHandles)
+	}
+}
@@ -0,0 +1,152 @@
+// Copyright 2024 Google LLC.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package safeopen
+
+import (
+	"errors"
+	"io"
+	"io/fs"
+	"os"
+	"sort"
+)
+
+// FallbackFS serves files from a Dir, falling back to another file system for the files missing
+// from it. It implements the common pattern of user overrides on disk shadowing built-in
+// defaults, typically embedded in the program with embed.FS.
+//
+// Only files that do not exist in the Dir are looked up in the fallback: other errors, such as
+// permission errors or paths rejected by the symlink policy of the Dir, are returned as is.
+// FallbackFS implements fs.FS, fs.ReadFileFS, fs.StatFS and fs.ReadDirFS.
+type FallbackFS struct {
+	dir      *Dir
+	fallback fs.FS
+}
+
+// NewFallbackFS returns a FallbackFS serving the files of d, and those of fallback missing from d.
+func NewFallbackFS(d *Dir, fallback fs.FS) *FallbackFS {
+	return &FallbackFS{dir: d, fallback: fallback}
+}
+
+// Open opens the named file for reading.
+func (f *FallbackFS) Open(name string) (fs.File, error) {
+	if !fs.ValidPath(name) {
+		return nil, &fs.PathError{Op: "open", Path: name, Err: fs.ErrInvalid}
+	}
+	file, err := f.dir.openFile(name, os.O_RDONLY, 0)
+	if errors.Is(err, fs.ErrNotExist) {
+		return f.fallback.Open(name)
+	}
+	if err != nil {
+		return nil, routedError("open", err, name)
+	}
+	if fi, err := file.Stat(); err == nil && fi.IsDir() {
+		return &fallbackDir{File: file, fsys: f, name: name}, nil
+	}
+	return file, nil
+}
+
+// ReadFile reads the named file.
+func (f *FallbackFS) ReadFile(name string) ([]byte, error) {
+	if !fs.ValidPath(name) {
+		return nil, &fs.PathError{Op: "read", Path: name, Err: fs.ErrInvalid}
+	}
+	data, err := f.dir.ReadFile(name)
+	if errors.Is(err, fs.ErrNotExist) {
+		return fs.ReadFile(f.fallback, name)
+	}
+	return data, routedError("read", err, name)
+}
+
+// Stat returns a FileInfo describing the named file.
+func (f *FallbackFS) Stat(name string) (fs.FileInfo, error) {
+	file, err := f.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	return file.Stat()
+}
+
+// ReadDir reads the named directory, merging the entries of d and of the fallback. Entries of d
+// take precedence over the entries of the fallback with the same name. The entries are sorted
+// by name.
+func (f *FallbackFS) ReadDir(name string) ([]fs.DirEntry, error) {
+	if !fs.ValidPath(name) {
+		return nil, &fs.PathError{Op: "readdir", Path: name, Err: fs.ErrInvalid}
+	}
+	var entries []fs.DirEntry
+	found := false
+	dir, err := f.dir.openFile(name, os.O_RDONLY, 0)
+	if err == nil {
+		entries, err = readDirIn(dir)
+		loadDirInfo(entries)
+		dir.Close()
+		found = true
+	}
+	if err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return nil, routedError("readdir", err, name)
+	}
+
+	names := make(map[string]bool, len(entries))
+	for _, e := range entries {
+		names[e.Name()] = true
+	}
+	fallbackEntries, err := fs.ReadDir(f.fallback, name)
+	if err != nil && (!found || !errors.Is(err, fs.ErrNotExist)) {
+		return nil, err
+	}
+	for _, e := range fallbackEntries {
+		if !names[e.Name()] {
+			entries = append(entries, e)
+		}
+	}
+	sort.Slice(entries, func(i, j int) bool { return entries[i].Name() < entries[j].Name() })
+	return entries, nil
+}
+
+// fallbackDir is a directory of a Dir, listing the entries of the fallback as well.
+type fallbackDir struct {
+	*os.File
+	fsys    *FallbackFS
+	name    string
+	entries []fs.DirEntry
+	read    bool
+}
+
+// ReadDir implements fs.ReadDirFile with the merged entries of FallbackFS.ReadDir.
+func (d *fallbackDir) ReadDir(n int) ([]fs.DirEntry, error) {
+	if !d.read {
+		entries, err := d.fsys.ReadDir(d.name)
+		if err != nil {
+			return nil, err
+		}
+		d.entries = entries
+		d.read = true
+	}
+	if n <= 0 {
+		entries := d.entries
+		d.entries = nil
+		return entries, nil
+	}
+	if len(d.entries) == 0 {
+		return nil, io.EOF
+	}
+	if n > len(d.entries) {
+		n = len(d.entries)
+	}
+	entries := d.entries[:n]
+	d.entries = d.entries[n:]
+	return entries, nil
+}
@@ -0,0 +1,79 @@
+// Copyright 2024 Google LLC.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package safeopen
+
+import (
+	"errors"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"testing"
+	"testing/fstest"
+)
+
+func TestFallbackFS(t *testing.T) {
+	tmpDir := t.TempDir()
+	if err := os.Mkdir(filepath.Join(tmpDir, "templates"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(tmpDir, "templates", "page.html"), []byte("custom page"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	d, err := OpenDir(tmpDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer d.Close()
+
+	defaults := fstest.MapFS{
+		"templates/page.html": {Data: []byte("default page")},
+		"templates/base.html": {Data: []byte("default base")},
+		"config.json":         {Data: []byte("{}")},
+	}
+	fsys := NewFallbackFS(d, defaults)
+
+	for name, want := range map[string]string{
+		"templates/page.html": "custom page",
+		"templates/base.html": "default base",
+		"config.json":         "{}",
+	} {
+		data, err := fs.ReadFile(fsys, name)
+		if err != nil || string(data) != want {
+			t.Errorf("ReadFile(%q) = %q, %v, want %q, nil", name, data, err, want)
+		}
+	}
+	if _, err := fs.ReadFile(fsys, "missing"); !errors.Is(err, fs.ErrNotExist) {
+		t.Errorf("ReadFile(%q) error = %v, want fs.ErrNotExist", "missing", err)
+	}
+	if _, err := fs.ReadFile(fsys, "../etc/passwd"); !errors.Is(err, fs.ErrInvalid) {
+		t.Errorf("ReadFile(%q) error = %v, want fs.ErrInvalid", "../etc/passwd", err)
+	}
+
+	entries, err := fs.ReadDir(fsys, "templates")
+	if err != nil {
+		t.Fatalf("ReadDir(%q) error: %v", "templates", err)
+	}
+	var names []string
+	for _, e := range entries {
+		names = append(names, e.Name())
+	}
+	if want := []string{"base.html", "page.html"}; !equalStrings(names, want) {
+		t.Errorf("ReadDir(%q) = %q, want %q", "templates", names, want)
+	}
+
+	if err := fstest.TestFS(fsys, "templates/page.html", "templates/base.html", "config.json"); err != nil {
+		t.Error(err)
+	}
+}
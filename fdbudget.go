@@ -0,0 +1,68 @@
+// Copyright 2024 Google LLC.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package safeopen
+
+import (
+	"errors"
+	"os"
+)
+
+// ErrTooManyOpenFiles is returned when a Dir opened with WithMaxOpenFiles has no descriptor left.
+var ErrTooManyOpenFiles = errors.New("too many open files")
+
+// WithMaxOpenFiles limits the number of descriptors d has open at the same time to max, so that
+// a misbehaving user of a shared Dir cannot exhaust the descriptors of the process. When the
+// limit is reached, opening another file blocks until one is released if wait is true, and fails
+// with an error wrapping ErrTooManyOpenFiles otherwise.
+//
+// The limit applies to the files d opens and closes on its own, in ReadFile and WriteFile, and to
+// the handles of OpenCached, which count until they are closed, including those idle in the
+// handle cache: with wait, the cache must be smaller than max, or callers may wait forever.
+// The files returned as *os.File, such as by Open or OpenFile, are not counted, as d cannot tell
+// when they are closed.
+func WithMaxOpenFiles(max int, wait bool) Option {
+	return func(o *options) {
+		o.maxOpen = max
+		o.waitOpen = wait
+	}
+}
+
+// fdBudget is a semaphore bounding the number of open descriptors. A nil budget is unbounded.
+type fdBudget struct {
+	sem  chan struct{}
+	wait bool
+}
+
+func (b *fdBudget) acquire(file string) error {
+	if b == nil {
+		return nil
+	}
+	if b.wait {
+		b.sem <- struct{}{}
+		return nil
+	}
+	select {
+	case b.sem <- struct{}{}:
+		return nil
+	default:
+		return &os.PathError{Op: "open", Path: file, Err: ErrTooManyOpenFiles}
+	}
+}
+
+func (b *fdBudget) release() {
+	if b != nil {
+		<-b.sem
+	}
+}
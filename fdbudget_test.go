@@ -0,0 +1,81 @@
+// Copyright 2024 Google LLC.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package safeopen
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestMaxOpenFiles(t *testing.T) {
+	tmpDir := t.TempDir()
+	for _, name := range []string{"a", "b", "c"} {
+		if err := os.WriteFile(filepath.Join(tmpDir, name), []byte(name), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	d, err := OpenDir(tmpDir, WithMaxOpenFiles(2, false))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer d.Close()
+	a, err := d.OpenCached("a")
+	if err != nil {
+		t.Fatal(err)
+	}
+	b, err := d.OpenCached("b")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := d.ReadFile("c"); !errors.Is(err, ErrTooManyOpenFiles) {
+		t.Errorf("ReadFile() beyond the limit error = %v, want ErrTooManyOpenFiles", err)
+	}
+	if _, err := d.OpenCached("c"); !errors.Is(err, ErrTooManyOpenFiles) {
+		t.Errorf("OpenCached() beyond the limit error = %v, want ErrTooManyOpenFiles", err)
+	}
+	a.Close()
+	if data, err := d.ReadFile("c"); err != nil || string(data) != "c" {
+		t.Errorf("ReadFile() after a release = %q, %v, want %q, nil", data, err, "c")
+	}
+	b.Close()
+
+	wd, err := OpenDir(tmpDir, WithMaxOpenFiles(1, true))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer wd.Close()
+	a, err = wd.OpenCached("a")
+	if err != nil {
+		t.Fatal(err)
+	}
+	done := make(chan error)
+	go func() {
+		_, err := wd.ReadFile("b")
+		done <- err
+	}()
+	select {
+	case err := <-done:
+		t.Fatalf("ReadFile() beyond the limit returned %v, want it to wait", err)
+	case <-time.After(50 * time.Millisecond):
+	}
+	a.Close()
+	if err := <-done; err != nil {
+		t.Errorf("ReadFile() after a release error: %v", err)
+	}
+}
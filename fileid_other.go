@@ -0,0 +1,50 @@
+// Copyright 2024 Google LLC.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+//go:build !unix && !windows
+// +build !unix,!windows
+
+package safeopen
+
+import (
+	"os"
+	"syscall"
+)
+
+// fileIdentity returns the identity of f, reporting whether it is known.
+func fileIdentity(f *os.File) (fileID, bool) {
+	fi, err := f.Stat()
+	if err != nil {
+		return fileID{}, false
+	}
+	st, ok := fi.Sys().(*syscall.Stat_t)
+	if !ok {
+		return fileID{}, false
+	}
+	return fileID{dev: uint64(st.Dev), ino: uint64(st.Ino), size: fi.Size(), modTime: fi.ModTime().UnixNano()}, true
+}
+
+// fileIdentityIn returns the identity of file beneath dfd, following symbolic links. Without
+// descriptor-relative stat calls following links, they are resolved by this package first.
+func fileIdentityIn(dfd handle, file string) (fileID, error) {
+	resolved, err := resolveIn(dfd, file, true, allowAllSymlinks, nil)
+	if err != nil {
+		return fileID{}, err
+	}
+	xfi, err := extendedStatIn(dfd, resolved)
+	if err != nil {
+		return fileID{}, err
+	}
+	return fileID{dev: xfi.Device, ino: xfi.Inode, size: xfi.Size(), modTime: xfi.ModTime().UnixNano()}, nil
+}
@@ -0,0 +1,54 @@
+// Copyright 2024 Google LLC.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+//go:build unix
+// +build unix
+
+package safeopen
+
+import (
+	"os"
+	"syscall"
+
+	"golang.org/x/sys/unix"
+)
+
+// fileIdentity returns the identity of f, reporting whether it is known.
+func fileIdentity(f *os.File) (fileID, bool) {
+	fi, err := f.Stat()
+	if err != nil {
+		return fileID{}, false
+	}
+	st, ok := fi.Sys().(*syscall.Stat_t)
+	if !ok {
+		return fileID{}, false
+	}
+	return fileID{dev: uint64(st.Dev), ino: uint64(st.Ino), size: fi.Size(), modTime: fi.ModTime().UnixNano()}, true
+}
+
+// fileIdentityIn returns the identity of file beneath dfd, following symbolic links, with a
+// single fstatat. The links are followed by the kernel and may lead outside of dfd, which is
+// harmless as long as the identity is only compared to the one of a file opened safely.
+func fileIdentityIn(dfd handle, file string) (fileID, error) {
+	clean, ok := cleanRelPath(file)
+	if !ok {
+		return fileID{}, &os.PathError{Op: "stat", Path: file, Err: beneathPathError(file)}
+	}
+	st := &fileStat{}
+	if err := unix.Fstatat(dfd, clean, &st.sys, 0); err != nil {
+		return fileID{}, &os.PathError{Op: "stat", Path: file, Err: err}
+	}
+	st.fill()
+	return fileID{dev: uint64(st.sys.Dev), ino: uint64(st.sys.Ino), size: st.size, modTime: st.modTime.UnixNano()}, nil
+}
@@ -0,0 +1,64 @@
+// Copyright 2024 Google LLC.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+//go:build windows
+// +build windows
+
+package safeopen
+
+import (
+	"os"
+
+	"golang.org/x/sys/windows"
+)
+
+// fileIdentity returns the identity of f, reporting whether it is known.
+func fileIdentity(f *os.File) (fileID, bool) {
+	id, err := winFileIdentity(windows.Handle(f.Fd()))
+	return id, err == nil
+}
+
+// fileIdentityIn returns the identity of file beneath dfd, with a single open relative to dfd.
+// The reparse point in the last element of file, if any, is described rather than followed, so
+// the identity of a file opened through a link never matches.
+func fileIdentityIn(dfd handle, file string) (fileID, error) {
+	sanitizedFile, safe := winRelativePathDoesntTraverse(file)
+	if !safe {
+		return fileID{}, &os.PathError{Op: "stat", Path: file, Err: beneathPathError(file)}
+	}
+	fd, err := winOpenAt(dfd, sanitizedFile, windows.FILE_READ_ATTRIBUTES|windows.SYNCHRONIZE, windows.FILE_OPEN,
+		windows.FILE_SYNCHRONOUS_IO_NONALERT)
+	if err != nil {
+		return fileID{}, &os.PathError{Op: "stat", Path: file, Err: err}
+	}
+	defer windows.CloseHandle(fd)
+	id, err := winFileIdentity(fd)
+	if err != nil {
+		return fileID{}, &os.PathError{Op: "stat", Path: file, Err: err}
+	}
+	return id, nil
+}
+
+func winFileIdentity(fd windows.Handle) (fileID, error) {
+	var info windows.ByHandleFileInformation
+	if err := windows.GetFileInformationByHandle(fd, &info); err != nil {
+		return fileID{}, err
+	}
+	return fileID{
+		dev:     uint64(info.VolumeSerialNumber),
+		ino:     uint64(info.FileIndexHigh)<<32 | uint64(info.FileIndexLow),
+		size:    int64(info.FileSizeHigh)<<32 | int64(info.FileSizeLow),
+		modTime: info.LastWriteTime.Nanoseconds(),
+	}, nil
+}
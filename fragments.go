@@ -0,0 +1,113 @@
+// Copyright 2024 Google LLC.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package safeopen
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// ReadFragmentsBeneath implements the conf.d pattern: it calls fn with the content of each
+// regular file matching pattern beneath the named directory, in lexical order of the names.
+// pattern is a filepath.Match pattern in which only the last element may contain wildcards,
+// e.g. "conf.d/*.conf". Files whose name starts with a dot are skipped, unless the pattern
+// starts with a dot as well, so that hidden files such as editor backups are ignored, as are
+// directories and other files that are not regular files.
+//
+// The files are opened with the same rules as OpenBeneath, so symbolic links are followed only
+// within the directory. Dangling symbolic links are skipped if skipDangling is true, and fail the
+// call otherwise. The directory containing the files may not be a symbolic link. If fn returns an
+// error, ReadFragmentsBeneath stops and returns it.
+func ReadFragmentsBeneath(directory, pattern string, skipDangling bool, fn func(file string, r io.Reader) error) error {
+	dir, base := filepath.Split(pattern)
+	if dir == "" {
+		dir = "."
+	}
+	if _, err := filepath.Match(base, ""); err != nil || base == "" {
+		return &os.PathError{Op: "ReadFragmentsBeneath", Path: pattern, Err: filepath.ErrBadPattern}
+	}
+
+	dfd, err := openDirHandle(directory)
+	if err != nil {
+		return err
+	}
+	defer closeHandle(dfd)
+
+	names, err := readDirNamesIn(dfd, dir)
+	if err != nil {
+		return err
+	}
+	for _, name := range names {
+		if ok, _ := filepath.Match(base, name); !ok || (strings.HasPrefix(name, ".") && !strings.HasPrefix(base, ".")) {
+			continue
+		}
+		file := filepath.Join(dir, name)
+		f, err := openFragmentIn(dfd, directory, file, skipDangling)
+		if err != nil {
+			return err
+		}
+		if f == nil {
+			continue
+		}
+		err = fn(file, f)
+		f.Close()
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// ConcatFragmentsBeneath returns the concatenation of the files ReadFragmentsBeneath would pass
+// to its callback.
+func ConcatFragmentsBeneath(directory, pattern string, skipDangling bool) ([]byte, error) {
+	var buf bytes.Buffer
+	err := ReadFragmentsBeneath(directory, pattern, skipDangling, func(file string, r io.Reader) error {
+		_, err := buf.ReadFrom(r)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// openFragmentIn opens file beneath dfd, or returns nil if it is to be skipped.
+func openFragmentIn(dfd handle, directory, file string, skipDangling bool) (*os.File, error) {
+	fi, err := statIn(dfd, file)
+	if err != nil {
+		return nil, err
+	}
+	if fi.IsDir() {
+		return nil, nil
+	}
+	f, err := openFileBeneathIn(dfd, directory, file, os.O_RDONLY, 0)
+	if errors.Is(err, fs.ErrNotExist) && skipDangling && fi.Mode()&fs.ModeSymlink != 0 {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if fi, err := f.Stat(); err != nil || !fi.Mode().IsRegular() {
+		f.Close()
+		return nil, err
+	}
+	return f, nil
+}
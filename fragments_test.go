@@ -0,0 +1,81 @@
+// Copyright 2024 Google LLC.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package safeopen
+
+import (
+	"errors"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+)
+
+func TestReadFragmentsBeneath(t *testing.T) {
+	tmpDir := t.TempDir()
+	confDir := filepath.Join(tmpDir, "conf.d")
+	if err := os.Mkdir(confDir, 0755); err != nil {
+		t.Fatal(err)
+	}
+	for name, data := range map[string]string{
+		"20-net.conf":     "net\n",
+		"10-base.conf":    "base\n",
+		".30-hidden.conf": "hidden\n",
+		"40-backup.conf~": "backup\n",
+	} {
+		if err := os.WriteFile(filepath.Join(confDir, name), []byte(data), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := os.Mkdir(filepath.Join(confDir, "50-dir.conf"), 0755); err != nil {
+		t.Fatal(err)
+	}
+
+	var files []string
+	err := ReadFragmentsBeneath(tmpDir, filepath.Join("conf.d", "*.conf"), false, func(file string, r io.Reader) error {
+		files = append(files, file)
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("ReadFragmentsBeneath() error: %v", err)
+	}
+	want := []string{filepath.Join("conf.d", "10-base.conf"), filepath.Join("conf.d", "20-net.conf")}
+	if !equalStrings(files, want) {
+		t.Errorf("ReadFragmentsBeneath() files = %q, want %q", files, want)
+	}
+
+	data, err := ConcatFragmentsBeneath(tmpDir, filepath.Join("conf.d", "*.conf"), false)
+	if err != nil || string(data) != "base\nnet\n" {
+		t.Errorf("ConcatFragmentsBeneath() = %q, %v, want %q, nil", data, err, "base\nnet\n")
+	}
+
+	if _, err := ConcatFragmentsBeneath(tmpDir, filepath.Join("conf.d", "[.conf"), false); !errors.Is(err, filepath.ErrBadPattern) {
+		t.Errorf("ConcatFragmentsBeneath() with a bad pattern error = %v, want filepath.ErrBadPattern", err)
+	}
+
+	if runtime.GOOS == "windows" {
+		return
+	}
+	if err := os.Symlink("99-missing", filepath.Join(confDir, "90-dangling.conf")); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := ConcatFragmentsBeneath(tmpDir, filepath.Join("conf.d", "*.conf"), false); !errors.Is(err, fs.ErrNotExist) {
+		t.Errorf("ConcatFragmentsBeneath() with a dangling link error = %v, want fs.ErrNotExist", err)
+	}
+	if data, err := ConcatFragmentsBeneath(tmpDir, filepath.Join("conf.d", "*.conf"), true); err != nil || string(data) != "base\nnet\n" {
+		t.Errorf("ConcatFragmentsBeneath() skipping dangling links = %q, %v, want %q, nil", data, err, "base\nnet\n")
+	}
+}
@@ -0,0 +1,105 @@
+// Copyright 2024 Google LLC.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+//go:build linux
+// +build linux
+
+package safeopen
+
+import (
+	"runtime"
+	"syscall"
+
+	"golang.org/x/sys/unix"
+)
+
+// WithFilesystemUser makes d open and stat files with the file system credentials of the user
+// uid, the group gid and the supplementary groups groups, so that daemons running as root and
+// serving many users enforce the permissions of each user on top of the confinement beneath d.
+// Files created through d are owned by uid and gid.
+//
+// The credentials are switched with setfsuid, setfsgid and setgroups on the OS thread of the
+// calling goroutine for the duration of each call only, leaving the other threads of the
+// process unaffected. This requires CAP_SETUID and CAP_SETGID. The access checks happen when
+// files are opened only: the returned files remain usable by the process afterwards.
+// Maintenance done by d on its own, such as enforcing retention policies, runs as the process.
+func WithFilesystemUser(uid, gid int, groups []int) Option {
+	groups = append([]int(nil), groups...)
+	return func(o *options) {
+		o.addRunAs(func(fn func() error) error {
+			return asFilesystemUser(uid, gid, groups, fn)
+		})
+	}
+}
+
+// asFilesystemUser runs fn on the current OS thread with the given file system credentials.
+func asFilesystemUser(uid, gid int, groups []int, fn func() error) error {
+	runtime.LockOSThread()
+	restore, err := setFilesystemUser(uid, gid, groups)
+	if err != nil {
+		// The credentials may be partially switched.
+		if restore() == nil {
+			runtime.UnlockOSThread()
+		}
+		return err
+	}
+	defer func() {
+		// If the thread cannot switch back, it is left locked, so that it terminates along with
+		// the goroutine rather than serving others with the credentials of the user.
+		if restore() == nil {
+			runtime.UnlockOSThread()
+		}
+	}()
+	return fn()
+}
+
+// setFilesystemUser switches the file system credentials of the current thread, and returns a
+// function switching them back.
+func setFilesystemUser(uid, gid int, groups []int) (func() error, error) {
+	oldGroups, err := unix.Getgroups()
+	if err != nil {
+		return func() error { return nil }, err
+	}
+	// setfsuid and setfsgid always return the previous value, and report failures only by not
+	// changing it, hence the checks with -1, which does not change anything.
+	oldGID, _ := unix.SetfsgidRetGid(-1)
+	oldUID, _ := unix.SetfsuidRetUid(-1)
+	restore := func() error {
+		// The user is switched back first, as it may be required to switch the groups.
+		unix.Setfsuid(oldUID)
+		unix.Setfsgid(oldGID)
+		err := unix.Setgroups(oldGroups)
+		if cur, _ := unix.SetfsuidRetUid(-1); cur != oldUID && err == nil {
+			err = syscall.EPERM
+		}
+		if cur, _ := unix.SetfsgidRetGid(-1); cur != oldGID && err == nil {
+			err = syscall.EPERM
+		}
+		return err
+	}
+
+	// unix.Setgroups, unlike syscall.Setgroups, only affects the current thread.
+	if err := unix.Setgroups(groups); err != nil {
+		return restore, err
+	}
+	unix.Setfsgid(gid)
+	if cur, _ := unix.SetfsgidRetGid(-1); cur != gid {
+		return restore, syscall.EPERM
+	}
+	unix.Setfsuid(uid)
+	if cur, _ := unix.SetfsuidRetUid(-1); cur != uid {
+		return restore, syscall.EPERM
+	}
+	return restore, nil
+}
@@ -0,0 +1,67 @@
+// Copyright 2024 Google LLC.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+//go:build linux
+// +build linux
+
+package safeopen
+
+import (
+	"errors"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"syscall"
+	"testing"
+)
+
+func TestLinuxFilesystemUser(t *testing.T) {
+	if os.Geteuid() != 0 {
+		t.Skip("switching the file system credentials requires root")
+	}
+	const nobody = 65534
+
+	tmpDir := t.TempDir()
+	if err := os.Chmod(tmpDir, 0777); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(tmpDir, "private.txt"), []byte("secret"), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	d, err := OpenDir(tmpDir, WithFilesystemUser(nobody, nobody, nil))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer d.Close()
+
+	if _, err := d.ReadFile("private.txt"); !errors.Is(err, fs.ErrPermission) {
+		t.Errorf("ReadFile(%q) error = %v, want fs.ErrPermission", "private.txt", err)
+	}
+	if err := d.WriteFile("public.txt", []byte("data"), 0644); err != nil {
+		t.Fatalf("WriteFile(%q) error: %v", "public.txt", err)
+	}
+	fi, err := os.Stat(filepath.Join(tmpDir, "public.txt"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if st := fi.Sys().(*syscall.Stat_t); st.Uid != nobody || st.Gid != nobody {
+		t.Errorf("owner of %q = %d:%d, want %d:%d", "public.txt", st.Uid, st.Gid, nobody, nobody)
+	}
+
+	// The process itself keeps its credentials.
+	if _, err := ReadFileAt(tmpDir, "private.txt"); err != nil {
+		t.Errorf("ReadFileAt(%q) error: %v", "private.txt", err)
+	}
+}
@@ -0,0 +1,127 @@
+// Copyright 2024 Google LLC.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package safeopen
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"runtime"
+	"sort"
+	"strings"
+)
+
+// GlobBeneath returns the names of all files in the named directory, or a subdirectory, matching
+// pattern, or nil if there is no matching file. The syntax of patterns is the same as in
+// filepath.Match, and the returned names are relative to the directory.
+//
+// As with filepath.Glob, the only possible returned error is for a malformed pattern, and file
+// system errors such as I/O errors reading directories are ignored. Unlike filepath.Glob, pattern
+// must be relative and may not contain .. path traversal entries, each directory is opened
+// relative to the handle of its parent, and symbolic links are never followed: they are only
+// matched by the last element of pattern.
+func GlobBeneath(directory, pattern string) ([]string, error) {
+	if _, err := filepath.Match(pattern, ""); err != nil {
+		return nil, err
+	}
+	elems, ok := splitGlobPattern(pattern)
+	if !ok {
+		return nil, &os.PathError{Op: "GlobBeneath", Path: pattern, Err: errors.New("invalid pattern")}
+	}
+
+	dfd, err := openDirHandle(directory)
+	if err != nil {
+		return nil, nil
+	}
+	defer closeHandle(dfd)
+	root, err := openDirNoFollowIn(dfd, directory, ".")
+	if err != nil {
+		return nil, nil
+	}
+	defer root.Close()
+	return globIn(root, "", elems, nil), nil
+}
+
+// splitGlobPattern splits pattern into its elements, dropping the empty and . ones. It reports
+// false if pattern is not relative or contains .. elements.
+func splitGlobPattern(pattern string) ([]string, bool) {
+	if filepath.IsAbs(pattern) || filepath.VolumeName(pattern) != "" {
+		return nil, false
+	}
+	var elems []string
+	for _, elem := range strings.Split(filepath.ToSlash(pattern), "/") {
+		switch elem {
+		case "", ".":
+		case "..":
+			return nil, false
+		default:
+			elems = append(elems, elem)
+		}
+	}
+	return elems, len(elems) > 0
+}
+
+// globHasMeta reports whether elem contains any of the magic characters recognized by
+// filepath.Match.
+func globHasMeta(elem string) bool {
+	magic := `*?[`
+	if runtime.GOOS != "windows" {
+		magic = `*?[\`
+	}
+	return strings.ContainsAny(elem, magic)
+}
+
+// globIn appends to matches the names of the files beneath dir, reported at path, matching
+// elems, in lexical order.
+func globIn(dir *os.File, path string, elems []string, matches []string) []string {
+	elem, rest := elems[0], elems[1:]
+	var names []string
+	literal := !globHasMeta(elem)
+	if !literal {
+		all, err := dir.Readdirnames(-1)
+		if err != nil {
+			return matches
+		}
+		for _, name := range all {
+			if ok, _ := filepath.Match(elem, name); ok {
+				names = append(names, name)
+			}
+		}
+		sort.Strings(names)
+	} else {
+		names = []string{elem}
+	}
+
+	for _, name := range names {
+		file := filepath.Join(path, name)
+		if len(rest) == 0 {
+			// Literal names are not listed, check that they exist.
+			if literal {
+				if _, err := statIn(handle(dir.Fd()), name); err != nil {
+					continue
+				}
+			}
+			matches = append(matches, file)
+			continue
+		}
+		sub, err := openDirNoFollowIn(handle(dir.Fd()), dir.Name(), name)
+		if err != nil {
+			continue
+		}
+		matches = globIn(sub, file, rest, matches)
+		sub.Close()
+	}
+	return matches
+}
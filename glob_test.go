@@ -0,0 +1,74 @@
+// Copyright 2024 Google LLC.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package safeopen
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestGlobBeneath(t *testing.T) {
+	dir := t.TempDir()
+	outside := t.TempDir()
+	for _, sub := range []string{"logs", filepath.Join("logs", "app"), filepath.Join("logs", "db"), filepath.Join("logs", "empty")} {
+		if err := os.Mkdir(filepath.Join(dir, sub), 0755); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for _, file := range []string{filepath.Join("logs", "app", "current.log"), filepath.Join("logs", "db", "current.log"), filepath.Join("logs", "db", "old.log"), filepath.Join("logs", "current.log")} {
+		if err := os.WriteFile(filepath.Join(dir, file), nil, 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := os.WriteFile(filepath.Join(outside, "current.log"), nil, 0644); err != nil {
+		t.Fatal(err)
+	}
+	symlinks := os.Symlink(outside, filepath.Join(dir, "logs", "evil")) == nil
+
+	for _, tc := range []struct {
+		pattern string
+		want    []string
+	}{
+		{"logs/*/current.log", []string{filepath.Join("logs", "app", "current.log"), filepath.Join("logs", "db", "current.log")}},
+		{"logs/db/*.log", []string{filepath.Join("logs", "db", "current.log"), filepath.Join("logs", "db", "old.log")}},
+		{"./logs/app/current.log", []string{filepath.Join("logs", "app", "current.log")}},
+		{"logs/[ab]*", []string{filepath.Join("logs", "app")}},
+		{"logs/missing.log", nil},
+		{"*/*/missing", nil},
+	} {
+		got, err := GlobBeneath(dir, filepath.FromSlash(tc.pattern))
+		if err != nil || !reflect.DeepEqual(got, tc.want) {
+			t.Errorf("GlobBeneath(%q) = %v, %v, want %v", tc.pattern, got, err, tc.want)
+		}
+	}
+	if symlinks {
+		got, err := GlobBeneath(dir, filepath.FromSlash("logs/e*"))
+		if want := []string{filepath.Join("logs", "empty"), filepath.Join("logs", "evil")}; err != nil || !reflect.DeepEqual(got, want) {
+			t.Errorf("GlobBeneath(logs/e*) = %v, %v, want %v", got, err, want)
+		}
+	}
+
+	if _, err := GlobBeneath(dir, "logs/[a"); !errors.Is(err, filepath.ErrBadPattern) {
+		t.Errorf("GlobBeneath(logs/[a) = %v, want ErrBadPattern", err)
+	}
+	for _, pattern := range []string{"../*", "logs/../../*", "", ".", filepath.Join(outside, "*")} {
+		if got, err := GlobBeneath(dir, pattern); err == nil {
+			t.Errorf("GlobBeneath(%q) = %v, should have been an error", pattern, got)
+		}
+	}
+}
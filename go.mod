@@ -2,4 +2,4 @@ module github.com/google/safeopen
 
 go 1.21
 
-require golang.org/x/sys v0.10.0 // indirect
+require golang.org/x/sys v0.10.0
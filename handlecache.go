@@ -0,0 +1,164 @@
+// Copyright 2024 Google LLC.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package safeopen
+
+import (
+	"io"
+	"io/fs"
+	"os"
+	"sync"
+	"time"
+)
+
+// WithHandleCache keeps up to size files opened by OpenCached open, evicting the least recently
+// used ones. A cached handle is only reused while the name still resolves to the same file, with
+// the same modification time and size; it is dropped otherwise.
+func WithHandleCache(size int) Option {
+	return func(o *options) {
+		o.handleCacheLen = size
+	}
+}
+
+// CachedFile is a read-only view of a file, which may share its underlying handle with other
+// CachedFile instances. It only reads with ReadAt, so it is safe for concurrent use, and each
+// instance has its own offset for Read and Seek.
+type CachedFile struct {
+	*io.SectionReader
+	h    *cachedHandle
+	once sync.Once
+}
+
+// Stat returns the FileInfo of the file, as of the time it was opened.
+func (c *CachedFile) Stat() (fs.FileInfo, error) {
+	return c.h.fi, nil
+}
+
+// Close releases the file. The underlying handle is closed once it has been evicted from the
+// cache and all of its users have released it.
+func (c *CachedFile) Close() error {
+	err := os.ErrClosed
+	c.once.Do(func() {
+		err = c.h.release()
+	})
+	return err
+}
+
+// fileID identifies a file, and the version of its content by its size and modification time.
+type fileID struct {
+	dev, ino uint64
+	size     int64
+	modTime  int64
+}
+
+// cachedHandle is a reference counted open file.
+type cachedHandle struct {
+	f       *os.File
+	fds     *fdBudget
+	acct    *accounting
+	fi      fs.FileInfo
+	id      fileID
+	mu      sync.Mutex
+	refs    int
+	evicted bool
+}
+
+func (h *cachedHandle) acquire() bool {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if h.evicted {
+		return false
+	}
+	h.refs++
+	return true
+}
+
+func (h *cachedHandle) release() error {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.refs--
+	if h.evicted && h.refs == 0 {
+		defer h.fds.release()
+		return h.f.Close()
+	}
+	return nil
+}
+
+func (h *cachedHandle) evict() {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.evicted = true
+	if h.refs == 0 {
+		h.f.Close()
+		h.fds.release()
+	}
+}
+
+// OpenCached opens the named file beneath d for reading, reusing a cached handle if the Dir was
+// opened with WithHandleCache. Without the cache, a new handle is opened every time.
+// file may not contain .. path traversal entries.
+func (d *Dir) OpenCached(file string) (*CachedFile, error) {
+	defer d.acct.since("OpenCached", time.Now())
+	key := cacheKey(file)
+	if d.handles != nil {
+		if h, ok := d.handles.get(key); ok {
+			var id fileID
+			err := d.asUser(func() error {
+				var err error
+				id, err = fileIdentityIn(d.dfd, file)
+				return err
+			})
+			if err == nil && id == h.id && h.acquire() {
+				return newCachedFile(h), nil
+			}
+			d.handles.remove(key)
+		}
+	}
+
+	if err := d.fds.acquire(file); err != nil {
+		return nil, err
+	}
+	f, err := d.openFile(file, os.O_RDONLY, 0)
+	if err != nil {
+		d.fds.release()
+		return nil, err
+	}
+	fi, err := f.Stat()
+	if err != nil {
+		f.Close()
+		d.fds.release()
+		return nil, err
+	}
+	if !fi.Mode().IsRegular() {
+		f.Close()
+		d.fds.release()
+		return nil, &os.PathError{Op: "OpenCached", Path: file, Err: ErrNotRegularFile}
+	}
+	// Being evicted means not being referenced by the cache. Handles whose identity is unknown
+	// cannot be validated, so they are not cached.
+	id, ok := fileIdentity(f)
+	h := &cachedHandle{f: f, fds: d.fds, acct: d.acct, fi: fi, id: id, refs: 1, evicted: d.handles == nil || !ok}
+	if !h.evicted {
+		d.handles.add(key, h)
+	}
+	return newCachedFile(h), nil
+}
+
+func newCachedFile(h *cachedHandle) *CachedFile {
+	var r io.ReaderAt = h.f
+	if h.acct != nil {
+		r = countingReaderAt{h.f, h.acct}
+	}
+	return &CachedFile{SectionReader: io.NewSectionReader(r, 0, h.fi.Size()), h: h}
+}
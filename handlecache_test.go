@@ -0,0 +1,119 @@
+// Copyright 2024 Google LLC.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package safeopen
+
+import (
+	"io"
+	"os"
+	"path"
+	"testing"
+	"time"
+)
+
+func readCached(t *testing.T, d *Dir, file string) (*CachedFile, string) {
+	t.Helper()
+	c, err := d.OpenCached(file)
+	if err != nil {
+		t.Fatalf("OpenCached(%q) error: %v", file, err)
+	}
+	data, err := io.ReadAll(c)
+	if err != nil {
+		t.Fatalf("io.ReadAll() error: %v", err)
+	}
+	return c, string(data)
+}
+
+func TestDirOpenCached(t *testing.T) {
+	tmpDir := t.TempDir()
+	for _, name := range []string{"a.txt", "b.txt"} {
+		if err := os.WriteFile(path.Join(tmpDir, name), []byte(name), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	d, err := OpenDir(tmpDir, WithHandleCache(1))
+	if err != nil {
+		t.Fatalf("OpenDir(%q) error: %v", tmpDir, err)
+	}
+	defer d.Close()
+
+	c1, data := readCached(t, d, "a.txt")
+	if data != "a.txt" {
+		t.Errorf("read %q, want %q", data, "a.txt")
+	}
+	c2, data := readCached(t, d, "a.txt")
+	if data != "a.txt" {
+		t.Errorf("read %q, want %q", data, "a.txt")
+	}
+	if c1.h != c2.h {
+		t.Errorf("OpenCached(%q) did not reuse the cached handle", "a.txt")
+	}
+
+	// Evicting a.txt must not break its users.
+	c3, _ := readCached(t, d, "b.txt")
+	buf := make([]byte, 1)
+	if _, err := c1.ReadAt(buf, 0); err != nil {
+		t.Errorf("ReadAt() of an evicted handle error: %v", err)
+	}
+	for _, c := range []*CachedFile{c1, c2, c3} {
+		if err := c.Close(); err != nil {
+			t.Errorf("Close() error: %v", err)
+		}
+	}
+	if err := c1.Close(); err == nil {
+		t.Errorf("second Close() should have been an error")
+	}
+
+	// Modifications invalidate the cached handle.
+	c4, _ := readCached(t, d, "b.txt")
+	c4.Close()
+	future := time.Now().Add(time.Hour)
+	if err := os.WriteFile(path.Join(tmpDir, "b.txt"), []byte("modified"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chtimes(path.Join(tmpDir, "b.txt"), future, future); err != nil {
+		t.Fatal(err)
+	}
+	c5, data := readCached(t, d, "b.txt")
+	defer c5.Close()
+	if data != "modified" {
+		t.Errorf("read %q, want %q", data, "modified")
+	}
+	if c4.h == c5.h {
+		t.Errorf("OpenCached(%q) reused a stale handle", "b.txt")
+	}
+
+	// Replacing the file invalidates the cached handle, even with the same size and times.
+	c6, _ := readCached(t, d, "b.txt")
+	c6.Close()
+	if err := os.WriteFile(path.Join(tmpDir, "new.txt"), []byte("replaced"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chtimes(path.Join(tmpDir, "new.txt"), future, future); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Rename(path.Join(tmpDir, "new.txt"), path.Join(tmpDir, "b.txt")); err != nil {
+		t.Fatal(err)
+	}
+	c7, data := readCached(t, d, "b.txt")
+	defer c7.Close()
+	if data != "replaced" {
+		t.Errorf("read %q, want %q", data, "replaced")
+	}
+
+	if _, err := d.OpenCached("../b.txt"); err == nil {
+		t.Errorf("OpenCached(%q) should have been an error", "../b.txt")
+	}
+}
@@ -0,0 +1,160 @@
+// Copyright 2024 Google LLC.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package safeopen
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io"
+	"io/fs"
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+// IgnoreRules is a list of .gitignore style rules excluding files from walks, e.g. to keep
+// node_modules trees out of backups or packages.
+//
+// The syntax is the one of a single .gitignore file: blank lines and lines starting with # are
+// ignored; a leading ! negates the rule; a trailing / restricts the rule to directories; a rule
+// with a / at the beginning or in the middle is relative to the base directory of the walk,
+// while other rules match names at any level; each element is a path.Match pattern, and **
+// matches any number of directories. The last matching rule wins, and the files beneath an
+// excluded directory are excluded as well. Nested ignore files are not supported.
+type IgnoreRules struct {
+	rules []ignoreRule
+}
+
+type ignoreRule struct {
+	elems   []string
+	negate  bool
+	dirOnly bool
+}
+
+// ParseIgnoreRules parses rules, one per line, from r.
+func ParseIgnoreRules(r io.Reader) (*IgnoreRules, error) {
+	rules := &IgnoreRules{}
+	s := bufio.NewScanner(r)
+	for n := 1; s.Scan(); n++ {
+		line := strings.TrimSuffix(s.Text(), "\r")
+		// Trailing spaces are ignored, unless escaped with a backslash.
+		for strings.HasSuffix(line, " ") && !strings.HasSuffix(line, "\\ ") {
+			line = line[:len(line)-1]
+		}
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		var rule ignoreRule
+		if strings.HasPrefix(line, "!") {
+			rule.negate = true
+			line = line[1:]
+		}
+		if strings.HasSuffix(line, "/") {
+			rule.dirOnly = true
+			line = strings.TrimRight(line, "/")
+		}
+		if !strings.Contains(line, "/") {
+			// Not anchored: matches at any level.
+			line = "**/" + line
+		}
+		rule.elems = strings.Split(strings.TrimPrefix(line, "/"), "/")
+		for _, elem := range rule.elems {
+			if _, err := path.Match(elem, ""); err != nil || elem == "" {
+				return nil, fmt.Errorf("invalid ignore rule at line %d: %w", n, path.ErrBadPattern)
+			}
+		}
+		rules.rules = append(rules.rules, rule)
+	}
+	if err := s.Err(); err != nil {
+		return nil, err
+	}
+	return rules, nil
+}
+
+// LoadIgnoreRulesBeneath parses the rules of the named file beneath the named directory, e.g.
+// a .gitignore file at the root of the tree to walk.
+func LoadIgnoreRulesBeneath(directory, file string) (*IgnoreRules, error) {
+	data, err := ReadFileBeneath(directory, file)
+	if err != nil {
+		return nil, err
+	}
+	return ParseIgnoreRules(bytes.NewReader(data))
+}
+
+// Match reports whether file, relative to the base directory of the walk, is excluded by r.
+// isDir tells whether file is a directory.
+func (r *IgnoreRules) Match(file string, isDir bool) bool {
+	elems := strings.Split(filepath.ToSlash(filepath.Clean(file)), "/")
+	for i := 1; i < len(elems); i++ {
+		if r.match(elems[:i], true) {
+			return true
+		}
+	}
+	return r.match(elems, isDir)
+}
+
+// Filter returns a fs.WalkDirFunc calling fn for the files not excluded by r only, and skipping
+// the excluded directories altogether.
+func (r *IgnoreRules) Filter(fn fs.WalkDirFunc) fs.WalkDirFunc {
+	return func(file string, e fs.DirEntry, err error) error {
+		if err == nil && file != "." && r.Match(file, e.IsDir()) {
+			if e.IsDir() {
+				return fs.SkipDir
+			}
+			return nil
+		}
+		return fn(file, e, err)
+	}
+}
+
+// match reports whether the file made of elems is excluded by the rules, regardless of the
+// rules for its parent directories.
+func (r *IgnoreRules) match(elems []string, isDir bool) bool {
+	excluded := false
+	for _, rule := range r.rules {
+		if (!rule.dirOnly || isDir) && matchIgnoreElems(rule.elems, elems) {
+			excluded = !rule.negate
+		}
+	}
+	return excluded
+}
+
+// matchIgnoreElems reports whether the pattern elements pat match the path elements name.
+func matchIgnoreElems(pat, name []string) bool {
+	for len(pat) > 0 {
+		if pat[0] == "**" {
+			pat = pat[1:]
+			if len(pat) == 0 {
+				// A trailing ** matches the contents of a directory, not the directory itself.
+				return len(name) > 0
+			}
+			for i := 0; i <= len(name); i++ {
+				if matchIgnoreElems(pat, name[i:]) {
+					return true
+				}
+			}
+			return false
+		}
+		if len(name) == 0 {
+			return false
+		}
+		if ok, _ := path.Match(pat[0], name[0]); !ok {
+			return false
+		}
+		pat, name = pat[1:], name[1:]
+	}
+	return len(name) == 0
+}
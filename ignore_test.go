@@ -0,0 +1,110 @@
+// Copyright 2024 Google LLC.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package safeopen
+
+import (
+	"errors"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestIgnoreRulesMatch(t *testing.T) {
+	rules, err := ParseIgnoreRules(strings.NewReader(`
+# Dependencies
+node_modules/
+*.log
+!important.log
+/build
+docs/**/*.tmp
+cache/**
+\#literal
+trailing   
+`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, tc := range []struct {
+		file  string
+		isDir bool
+		want  bool
+	}{
+		{"node_modules", true, true},
+		{"web/node_modules", true, true},
+		{"web/node_modules/lib/index.js", false, true},
+		{"node_modules", false, false},
+		{"debug.log", false, true},
+		{"logs/debug.log", false, true},
+		{"important.log", false, false},
+		{"build", true, true},
+		{"build/out.bin", false, true},
+		{"src/build", true, false},
+		{"docs/a.tmp", false, true},
+		{"docs/a/b/c.tmp", false, true},
+		{"src/a.tmp", false, false},
+		{"cache", true, false},
+		{"cache/entry", false, true},
+		{"#literal", false, true},
+		{"trailing", false, true},
+		{"README.md", false, false},
+	} {
+		if got := rules.Match(filepath.FromSlash(tc.file), tc.isDir); got != tc.want {
+			t.Errorf("Match(%q, %v) = %v, want %v", tc.file, tc.isDir, got, tc.want)
+		}
+	}
+
+	if _, err := ParseIgnoreRules(strings.NewReader("[")); !errors.Is(err, path.ErrBadPattern) {
+		t.Errorf("ParseIgnoreRules(%q) error = %v, want path.ErrBadPattern", "[", err)
+	}
+}
+
+func TestIgnoreRulesFilter(t *testing.T) {
+	tmpDir := t.TempDir()
+	for _, file := range []string{"main.go", "node_modules/lib/index.js", "out/app.log", ".gitignore"} {
+		full := filepath.Join(tmpDir, filepath.FromSlash(file))
+		if err := os.MkdirAll(filepath.Dir(full), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(full, nil, 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := os.WriteFile(filepath.Join(tmpDir, ".gitignore"), []byte("node_modules/\n*.log\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	rules, err := LoadIgnoreRulesBeneath(tmpDir, ".gitignore")
+	if err != nil {
+		t.Fatalf("LoadIgnoreRulesBeneath() error: %v", err)
+	}
+	var files []string
+	err = fs.WalkDir(os.DirFS(tmpDir), ".", rules.Filter(func(file string, e fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		files = append(files, file)
+		return nil
+	}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []string{".", ".gitignore", "main.go", "out"}
+	if !equalStrings(files, want) {
+		t.Errorf("walked files = %q, want %q", files, want)
+	}
+}
@@ -0,0 +1,62 @@
+// Copyright 2024 Google LLC.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+//go:build windows
+// +build windows
+
+package safeopen
+
+import (
+	"runtime"
+
+	"golang.org/x/sys/windows"
+)
+
+// WithImpersonation makes d open and stat files while impersonating token, so that the access to
+// the files is checked against the rights of the user token belongs to, rather than against the
+// rights of the account the process runs as. This lets services act on behalf of their clients,
+// with the same protection against traversal.
+//
+// token must be an impersonation token, e.g. obtained with DuplicateTokenEx from the token of a
+// client, and remain valid as long as d is used; it is not closed by d. The access checks happen
+// when files are opened only: the returned files remain usable by the process afterwards.
+// Maintenance done by d on its own, such as enforcing retention policies, runs as the process.
+//
+// The impersonation is set up on the OS thread of the calling goroutine for the duration of each
+// call, and the thread then reverts to the identity of the process. Operations of d must thus
+// not be called from a thread already impersonating another user.
+func WithImpersonation(token windows.Token) Option {
+	return func(o *options) {
+		o.addRunAs(func(fn func() error) error {
+			return impersonate(token, fn)
+		})
+	}
+}
+
+// impersonate runs fn on the current OS thread while impersonating token.
+func impersonate(token windows.Token, fn func() error) error {
+	runtime.LockOSThread()
+	if err := windows.SetThreadToken(nil, token); err != nil {
+		runtime.UnlockOSThread()
+		return err
+	}
+	defer func() {
+		// If the thread cannot revert, it is left locked, so that it terminates along with the
+		// goroutine rather than serving others with the identity of the user.
+		if windows.RevertToSelf() == nil {
+			runtime.UnlockOSThread()
+		}
+	}()
+	return fn()
+}
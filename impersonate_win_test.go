@@ -0,0 +1,65 @@
+// Copyright 2024 Google LLC.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+//go:build windows
+// +build windows
+
+package safeopen
+
+import (
+	"path/filepath"
+	"testing"
+
+	"golang.org/x/sys/windows"
+)
+
+func TestWinImpersonation(t *testing.T) {
+	var process windows.Token
+	if err := windows.OpenProcessToken(windows.CurrentProcess(), windows.TOKEN_DUPLICATE|windows.TOKEN_QUERY, &process); err != nil {
+		t.Fatal(err)
+	}
+	defer process.Close()
+	var token windows.Token
+	if err := windows.DuplicateTokenEx(process, windows.TOKEN_IMPERSONATE|windows.TOKEN_QUERY, nil,
+		windows.SecurityImpersonation, windows.TokenImpersonation, &token); err != nil {
+		t.Fatal(err)
+	}
+	defer token.Close()
+
+	tmpDir := t.TempDir()
+	d, err := OpenDir(tmpDir, WithImpersonation(token))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer d.Close()
+
+	if err := d.WriteFile("file.txt", []byte("data"), 0644); err != nil {
+		t.Fatalf("WriteFile() error: %v", err)
+	}
+	data, err := d.ReadFile("file.txt")
+	if err != nil || string(data) != "data" {
+		t.Errorf("ReadFile() = %q, %v, want %q, nil", data, err, "data")
+	}
+	if _, err := d.Lstat(filepath.Join("..", "file.txt")); err == nil {
+		t.Errorf("Lstat(%q) succeeded, want error", filepath.Join("..", "file.txt"))
+	}
+
+	// The thread must have reverted to the identity of the process.
+	var thread windows.Token
+	err = windows.OpenThreadToken(windows.CurrentThread(), windows.TOKEN_QUERY, true, &thread)
+	if err == nil {
+		thread.Close()
+		t.Errorf("OpenThreadToken() succeeded after the calls, want ERROR_NO_TOKEN")
+	}
+}
@@ -0,0 +1,92 @@
+// Copyright 2024 Google LLC.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+//go:build linux
+// +build linux
+
+package safeopen
+
+import (
+	"os"
+
+	"golang.org/x/sys/unix"
+)
+
+// InodeFlags are the flags of an inode, as listed by lsattr and changed by chattr.
+type InodeFlags uint32
+
+// The values are FS_IMMUTABLE_FL and FS_APPEND_FL from linux/fs.h, which x/sys/unix lacks.
+const (
+	// InodeImmutable (chattr +i) prevents any change to the file, including its removal.
+	InodeImmutable InodeFlags = 0x10
+	// InodeAppendOnly (chattr +a) only allows appending to the file.
+	InodeAppendOnly InodeFlags = 0x20
+)
+
+// GetInodeFlags returns the inode flags of f, with the FS_IOC_GETFLAGS ioctl.
+func GetInodeFlags(f *os.File) (InodeFlags, error) {
+	var flags uint32
+	err := fileIoctl(f, "GetInodeFlags", func(fd int) (err error) {
+		flags, err = unix.IoctlGetUint32(fd, unix.FS_IOC_GETFLAGS)
+		return err
+	})
+	return InodeFlags(flags), err
+}
+
+// SetInodeFlags sets the inode flags set and clears the inode flags clear of f, leaving the
+// other flags as they are. Changing InodeImmutable and InodeAppendOnly requires
+// CAP_LINUX_IMMUTABLE. f may be opened for reading only, which is the only possibility once a
+// file is immutable.
+func SetInodeFlags(f *os.File, set, clear InodeFlags) error {
+	return fileIoctl(f, "SetInodeFlags", func(fd int) error {
+		flags, err := unix.IoctlGetUint32(fd, unix.FS_IOC_GETFLAGS)
+		if err != nil {
+			return err
+		}
+		flags = (flags | uint32(set)) &^ uint32(clear)
+		return unix.IoctlSetPointerInt(fd, unix.FS_IOC_SETFLAGS, int(flags))
+	})
+}
+
+// SetInodeFlagsBeneath is like SetInodeFlags for the named file beneath the named directory,
+// opened with the same rules as OpenBeneath, e.g. to lock down finished artifacts.
+func SetInodeFlagsBeneath(directory, file string, set, clear InodeFlags) error {
+	f, err := openForMetadataBeneath(directory, file)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	return SetInodeFlags(f, set, clear)
+}
+
+// fileIoctl calls fn with the descriptor of f, and reports its error as a *PathError.
+func fileIoctl(f *os.File, op string, fn func(fd int) error) error {
+	if err := controlFd(f, fn); err != nil {
+		return &os.PathError{Op: op, Path: f.Name(), Err: err}
+	}
+	return nil
+}
+
+// controlFd calls fn with the descriptor of f.
+func controlFd(f *os.File, fn func(fd int) error) error {
+	rc, err := f.SyscallConn()
+	if err != nil {
+		return err
+	}
+	var ferr error
+	if err := rc.Control(func(fd uintptr) { ferr = fn(int(fd)) }); err != nil {
+		return err
+	}
+	return ferr
+}
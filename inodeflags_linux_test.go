@@ -0,0 +1,69 @@
+// Copyright 2024 Google LLC.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+//go:build linux
+// +build linux
+
+package safeopen
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"golang.org/x/sys/unix"
+)
+
+func TestLinuxInodeFlags(t *testing.T) {
+	tmpDir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(tmpDir, "artifact"), []byte("data"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	err := SetInodeFlagsBeneath(tmpDir, "artifact", InodeImmutable, 0)
+	if errors.Is(err, unix.ENOTTY) || errors.Is(err, unix.EOPNOTSUPP) || errors.Is(err, unix.EPERM) {
+		t.Skipf("inode flags not supported here: %v", err)
+	}
+	if err != nil {
+		t.Fatalf("SetInodeFlagsBeneath(+i) error: %v", err)
+	}
+	defer SetInodeFlagsBeneath(tmpDir, "artifact", 0, InodeImmutable)
+
+	f, err := OpenBeneath(tmpDir, "artifact")
+	if err != nil {
+		t.Fatal(err)
+	}
+	flags, err := GetInodeFlags(f)
+	f.Close()
+	if err != nil || flags&InodeImmutable == 0 {
+		t.Errorf("GetInodeFlags() = %#x, %v, want InodeImmutable set", flags, err)
+	}
+	if err := WriteFileBeneath(tmpDir, "artifact", []byte("changed"), 0644); err == nil {
+		t.Errorf("WriteFileBeneath() of an immutable file succeeded, want error")
+	}
+
+	if err := SetInodeFlagsBeneath(tmpDir, "artifact", InodeAppendOnly, InodeImmutable); err != nil {
+		t.Fatalf("SetInodeFlagsBeneath(-i +a) error: %v", err)
+	}
+	defer SetInodeFlagsBeneath(tmpDir, "artifact", 0, InodeAppendOnly)
+	if _, err := OpenFileBeneath(tmpDir, "artifact", os.O_WRONLY|os.O_TRUNC, 0); err == nil {
+		t.Errorf("truncating an append-only file succeeded, want error")
+	}
+	f, err = OpenFileBeneath(tmpDir, "artifact", os.O_WRONLY|os.O_APPEND, 0)
+	if err != nil {
+		t.Fatalf("appending to an append-only file: %v", err)
+	}
+	f.Close()
+}
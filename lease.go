@@ -0,0 +1,191 @@
+// Copyright 2024 Google LLC.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package safeopen
+
+import (
+	"bytes"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"time"
+)
+
+var (
+	// ErrLeaseHeld is returned by LeaseAt if the lease is held by someone else and has not
+	// expired yet.
+	ErrLeaseHeld = errors.New("lease is held")
+	// ErrLeaseLost is returned by the methods of Lease if the lease has expired or has been taken
+	// over by someone else.
+	ErrLeaseLost = errors.New("lease is lost")
+)
+
+// Lease is an exclusive, time limited claim on a lease file in a directory.
+type Lease struct {
+	directory string
+	file      string
+	token     string
+	content   []byte
+	expiry    time.Time
+}
+
+// LeaseAt acquires the lease stored in the named file in the named directory for ttl.
+// file may not contain path separators.
+//
+// The lease file records the expiry time and a random token identifying the holder. If the file
+// does not exist, it is created. If it exists and the recorded expiry time has passed, the lease
+// is taken over atomically, so exactly one of the competing callers succeeds. Otherwise an error
+// wrapping ErrLeaseHeld is returned.
+//
+// Leases rely on atomic renames and hard links only, so unlike flock they work on NFS as well.
+// Expiry times are checked against the local clock: the clocks of all participants must be
+// synchronized, and ttl should be much longer than the expected skew.
+func LeaseAt(directory, file string, ttl time.Duration) (*Lease, error) {
+	if !isFilename(file) {
+		return nil, &os.PathError{Op: "LeaseAt", Path: file, Err: ErrInvalidFilename}
+	}
+
+	token := make([]byte, 16)
+	if _, err := rand.Read(token); err != nil {
+		return nil, err
+	}
+	l := &Lease{directory: directory, file: file, token: hex.EncodeToString(token)}
+
+	dfd, err := openDirHandle(directory)
+	if err != nil {
+		return nil, err
+	}
+	defer closeHandle(dfd)
+
+	expiry := time.Now().Add(ttl)
+	content := l.encode(expiry)
+	err = publishIn(dfd, directory, file, content, 0644)
+	if errors.Is(err, ErrFileChanged) {
+		// The lease file exists already, take it over if it has expired.
+		var old []byte
+		old, err = readLeaseIn(dfd, directory, file)
+		if err == nil {
+			err = compareAndSwapIn(dfd, directory, file, sha256.Sum256(old), content)
+		}
+		if errors.Is(err, fs.ErrNotExist) || errors.Is(err, ErrFileChanged) || errors.Is(err, ErrFileBusy) {
+			// Someone else is releasing, refreshing or taking over the lease right now.
+			err = &os.PathError{Op: "LeaseAt", Path: file, Err: ErrLeaseHeld}
+		}
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	l.content = content
+	l.expiry = expiry
+	return l, nil
+}
+
+// readLeaseIn returns the content of an expired lease file.
+func readLeaseIn(dfd handle, directory, file string) ([]byte, error) {
+	f, err := openFileAtIn(dfd, directory, file, os.O_RDONLY, 0)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	data, err := io.ReadAll(f)
+	if err != nil {
+		return nil, err
+	}
+	expiry, err := parseLease(data)
+	if err != nil {
+		return nil, &os.PathError{Op: "LeaseAt", Path: file, Err: err}
+	}
+	if time.Now().Before(expiry) {
+		return nil, &os.PathError{Op: "LeaseAt", Path: file, Err: ErrLeaseHeld}
+	}
+	return data, nil
+}
+
+func (l *Lease) encode(expiry time.Time) []byte {
+	return []byte(expiry.UTC().Format(time.RFC3339Nano) + " " + l.token + "\n")
+}
+
+func parseLease(data []byte) (time.Time, error) {
+	expiry, _, ok := bytes.Cut(data, []byte(" "))
+	if !ok {
+		return time.Time{}, fmt.Errorf("malformed lease file %q", data)
+	}
+	return time.Parse(time.RFC3339Nano, string(expiry))
+}
+
+// Expiry returns the time the lease expires at, unless refreshed.
+func (l *Lease) Expiry() time.Time {
+	return l.expiry
+}
+
+// Refresh extends the lease to expire ttl from now.
+// It must be called before the lease expires, otherwise an error wrapping ErrLeaseLost is
+// returned, and so it is if the lease has been taken over by someone else. If the lease file is
+// being swapped concurrently, e.g. by a takeover attempt, an error wrapping ErrFileBusy is
+// returned and Refresh may be retried.
+func (l *Lease) Refresh(ttl time.Duration) error {
+	if !time.Now().Before(l.expiry) {
+		return &os.PathError{Op: "Refresh", Path: l.file, Err: ErrLeaseLost}
+	}
+
+	dfd, err := openDirHandle(l.directory)
+	if err != nil {
+		return err
+	}
+	defer closeHandle(dfd)
+
+	// The lease may expire and be taken over right now: the swap only succeeds if the content
+	// is still ours, under the same lock as a takeover.
+	expiry := time.Now().Add(ttl)
+	content := l.encode(expiry)
+	err = compareAndSwapIn(dfd, l.directory, l.file, sha256.Sum256(l.content), content)
+	if errors.Is(err, ErrFileChanged) {
+		return &os.PathError{Op: "Refresh", Path: l.file, Err: ErrLeaseLost}
+	}
+	if err != nil {
+		return err
+	}
+	l.content = content
+	l.expiry = expiry
+	return nil
+}
+
+// Release gives up the lease by removing the lease file.
+// An error wrapping ErrLeaseLost is returned if the lease has been taken over by someone else,
+// in which case the lease file is left untouched. If the lease file is being swapped
+// concurrently, an error wrapping ErrFileBusy is returned and Release may be retried.
+func (l *Lease) Release() error {
+	dfd, err := openDirHandle(l.directory)
+	if err != nil {
+		return err
+	}
+	defer closeHandle(dfd)
+
+	err = removeIfUnchangedIn(dfd, l.directory, l.file, sha256.Sum256(l.content))
+	if errors.Is(err, ErrFileChanged) {
+		return &os.PathError{Op: "Release", Path: l.file, Err: ErrLeaseLost}
+	}
+	if err != nil {
+		return err
+	}
+	l.expiry = time.Time{}
+	return nil
+}
@@ -0,0 +1,109 @@
+// Copyright 2024 Google LLC.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package safeopen
+
+import (
+	"bytes"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestLeaseAt(t *testing.T) {
+	filename := "worker.lease"
+	tmpDir := t.TempDir()
+
+	l1, err := LeaseAt(tmpDir, filename, time.Hour)
+	if err != nil {
+		t.Fatalf("LeaseAt(%q, %q) error: %v", tmpDir, filename, err)
+	}
+	if _, err := LeaseAt(tmpDir, filename, time.Hour); !errors.Is(err, ErrLeaseHeld) {
+		t.Errorf("LeaseAt(%q, %q) = %v, want %v", tmpDir, filename, err, ErrLeaseHeld)
+	}
+
+	expiry := l1.Expiry()
+	if err := l1.Refresh(2 * time.Hour); err != nil {
+		t.Fatalf("Refresh() error: %v", err)
+	}
+	if !l1.Expiry().After(expiry) {
+		t.Errorf("Refresh() did not extend the expiry: %v, want after %v", l1.Expiry(), expiry)
+	}
+
+	if err := l1.Release(); err != nil {
+		t.Fatalf("Release() error: %v", err)
+	}
+
+	l2, err := LeaseAt(tmpDir, filename, time.Millisecond)
+	if err != nil {
+		t.Fatalf("LeaseAt(%q, %q) after Release() error: %v", tmpDir, filename, err)
+	}
+	time.Sleep(10 * time.Millisecond)
+
+	l3, err := LeaseAt(tmpDir, filename, time.Hour)
+	if err != nil {
+		t.Fatalf("LeaseAt(%q, %q) of an expired lease error: %v", tmpDir, filename, err)
+	}
+	if err := l2.Refresh(time.Hour); !errors.Is(err, ErrLeaseLost) {
+		t.Errorf("Refresh() of a stolen lease = %v, want %v", err, ErrLeaseLost)
+	}
+	if err := l2.Release(); !errors.Is(err, ErrLeaseLost) {
+		t.Errorf("Release() of a stolen lease = %v, want %v", err, ErrLeaseLost)
+	}
+	if err := l3.Release(); err != nil {
+		t.Errorf("Release() error: %v", err)
+	}
+}
+
+func TestLeaseRefreshDuringTakeover(t *testing.T) {
+	filename := "worker.lease"
+	tmpDir := t.TempDir()
+
+	l, err := LeaseAt(tmpDir, filename, time.Hour)
+	if err != nil {
+		t.Fatalf("LeaseAt(%q, %q) error: %v", tmpDir, filename, err)
+	}
+	before, err := os.ReadFile(filepath.Join(tmpDir, filename))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// A takeover holds the lock of the lease file: Refresh must not overwrite it.
+	lock := filepath.Join(tmpDir, "."+filename+".cas")
+	if err := os.WriteFile(lock, []byte(time.Now().Add(time.Hour).UTC().Format(time.RFC3339Nano)+" taker\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if err := l.Refresh(time.Hour); !errors.Is(err, ErrFileBusy) {
+		t.Errorf("Refresh() during a takeover error = %v, want %v", err, ErrFileBusy)
+	}
+	if after, err := os.ReadFile(filepath.Join(tmpDir, filename)); err != nil || !bytes.Equal(after, before) {
+		t.Errorf("Refresh() during a takeover changed the lease file to %q, %v, want %q", after, err, before)
+	}
+
+	if err := os.Remove(lock); err != nil {
+		t.Fatal(err)
+	}
+	if err := l.Refresh(time.Hour); err != nil {
+		t.Errorf("Refresh() error: %v", err)
+	}
+}
+
+func TestLeaseAtInvalidFilename(t *testing.T) {
+	tmpDir := t.TempDir()
+	if _, err := LeaseAt(tmpDir, "../worker.lease", time.Hour); err == nil {
+		t.Errorf("LeaseAt(%q, %q) should have been an error", tmpDir, "../worker.lease")
+	}
+}
@@ -0,0 +1,32 @@
+// Copyright 2024 Google LLC.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package safeopen
+
+// LinkBeneath creates newpath as a hard link to oldpath, both in the named directory or a
+// subdirectory, e.g. to deduplicate identical files in a content-addressable store. Neither may
+// contain .. path traversal entries, and the directories leading to them are resolved as by
+// OpenBeneath. If oldpath is a symbolic link, the link itself is linked, where the platform
+// supports it. If newpath exists, the returned error satisfies errors.Is(err, fs.ErrExist).
+// LinkBeneath is not supported on AIX and Solaris.
+// If there is an error, it will be of type *PathError or, if the link itself fails, *LinkError.
+func LinkBeneath(directory, oldpath, newpath string) error {
+	dfd, err := openDirHandle(directory)
+	if err != nil {
+		return err
+	}
+	defer closeHandle(dfd)
+
+	return inParentsBeneathIn(dfd, directory, oldpath, newpath, "link", linkBetween)
+}
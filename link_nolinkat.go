@@ -0,0 +1,37 @@
+// Copyright 2024 Google LLC.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+//go:build aix || solaris
+// +build aix solaris
+
+package safeopen
+
+import "golang.org/x/sys/unix"
+
+// linkIn is not supported on platforms where golang.org/x/sys/unix does not provide linkat.
+func linkIn(dfd handle, oldfile, newfile string) error {
+	return unix.ENOTSUP
+}
+
+// linkBetween is not supported on platforms where golang.org/x/sys/unix does not provide
+// linkat.
+func linkBetween(odfd handle, oldfile string, ndfd handle, newfile string) error {
+	return unix.ENOTSUP
+}
+
+// symlinkIn is not supported on platforms where golang.org/x/sys/unix does not provide
+// symlinkat.
+func symlinkIn(dfd handle, target, link string) error {
+	return unix.ENOTSUP
+}
@@ -0,0 +1,75 @@
+// Copyright 2024 Google LLC.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package safeopen
+
+import (
+	"errors"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+)
+
+func TestLinkBeneath(t *testing.T) {
+	switch runtime.GOOS {
+	case "aix", "solaris", "illumos":
+		t.Skipf("LinkBeneath is not supported on %s", runtime.GOOS)
+	}
+	dir := t.TempDir()
+	outside := t.TempDir()
+	for _, sub := range []string{"objects", "trees"} {
+		if err := os.Mkdir(filepath.Join(dir, sub), 0755); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := os.WriteFile(filepath.Join(dir, "objects", "ab12"), []byte("blob"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(outside, "secret"), []byte("secret"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	object, linked := filepath.Join("objects", "ab12"), filepath.Join("trees", "file")
+	if err := LinkBeneath(dir, object, linked); err != nil {
+		t.Fatalf("LinkBeneath(%q, %q) error: %v", object, linked, err)
+	}
+	ofi, err := os.Stat(filepath.Join(dir, object))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if lfi, err := os.Stat(filepath.Join(dir, linked)); err != nil || !os.SameFile(ofi, lfi) {
+		t.Errorf("%s is not the same file as %s: %v", linked, object, err)
+	}
+
+	err = LinkBeneath(dir, object, linked)
+	var lerr *os.LinkError
+	if !errors.As(err, &lerr) || !errors.Is(err, fs.ErrExist) {
+		t.Errorf("LinkBeneath over an existing file = %v, want a LinkError wrapping ErrExist", err)
+	}
+	if err := LinkBeneath(dir, "missing", "new"); !errors.Is(err, fs.ErrNotExist) {
+		t.Errorf("LinkBeneath(missing) = %v, want ErrNotExist", err)
+	}
+
+	escape := filepath.Join("..", filepath.Base(outside), "secret")
+	for _, tc := range [][2]string{{escape, "stolen"}, {object, escape + "2"}, {"", "new"}, {object, ""}, {object, "."}} {
+		if err := LinkBeneath(dir, tc[0], tc[1]); err == nil {
+			t.Errorf("LinkBeneath(%q, %q) should have been an error", tc[0], tc[1])
+		}
+	}
+	if _, err := os.Lstat(filepath.Join(dir, "stolen")); err == nil {
+		t.Error("stolen should not have been created")
+	}
+}
@@ -0,0 +1,37 @@
+// Copyright 2024 Google LLC.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+//go:build unix && !aix && !solaris
+// +build unix,!aix,!solaris
+
+package safeopen
+
+import "golang.org/x/sys/unix"
+
+// linkIn creates newfile as a hard link to oldfile, both directly in the directory referred
+// by dfd. It fails if newfile already exists.
+func linkIn(dfd handle, oldfile, newfile string) error {
+	return linkBetween(dfd, oldfile, dfd, newfile)
+}
+
+// linkBetween creates newfile, directly in the directory referred by ndfd, as a hard link to
+// oldfile, directly in the directory referred by odfd. It fails if newfile already exists.
+func linkBetween(odfd handle, oldfile string, ndfd handle, newfile string) error {
+	return unix.Linkat(odfd, oldfile, ndfd, newfile, 0)
+}
+
+// symlinkIn creates link, directly in the directory referred by dfd, as a symbolic link to target.
+func symlinkIn(dfd handle, target, link string) error {
+	return unix.Symlinkat(target, dfd, link)
+}
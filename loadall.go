@@ -0,0 +1,94 @@
+// Copyright 2024 Google LLC.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package safeopen
+
+import (
+	"io"
+	"os"
+)
+
+const (
+	// loadAllMaxFiles is the maximum number of files read by LoadAllAt.
+	loadAllMaxFiles = 1024
+	// loadAllMaxBytes is the maximum total size of the files read by LoadAllAt.
+	loadAllMaxBytes = 16 << 20
+)
+
+// LoadAllAt reads every regular file directly in the named directory, returning their content
+// keyed by name. Other entries, such as subdirectories and symbolic links, are skipped. It is
+// meant for directories of configuration fragments or certificates loaded at startup.
+//
+// LoadAllAt fails with an error wrapping ErrTooManyFiles if the directory has more than 1024
+// regular files, and with an error wrapping ErrFileTooLarge if they total more than 16 MiB; use
+// LoadAllLimitAt for other limits.
+func LoadAllAt(directory string) (map[string][]byte, error) {
+	return LoadAllLimitAt(directory, loadAllMaxFiles, loadAllMaxBytes)
+}
+
+// LoadAllLimitAt is like LoadAllAt, but fails with an error wrapping ErrTooManyFiles if the
+// directory has more than maxFiles regular files, and with an error wrapping ErrFileTooLarge if
+// they total more than maxBytes. A negative limit means no limit.
+func LoadAllLimitAt(directory string, maxFiles int, maxBytes int64) (map[string][]byte, error) {
+	dfd, err := openDirHandle(directory)
+	if err != nil {
+		return nil, err
+	}
+	defer closeHandle(dfd)
+
+	names, err := readDirNamesIn(dfd, ".")
+	if err != nil {
+		return nil, err
+	}
+	files := map[string][]byte{}
+	var total int64
+	for _, name := range names {
+		fi, err := statIn(dfd, name)
+		if err != nil {
+			return nil, err
+		}
+		if !fi.Mode().IsRegular() {
+			continue
+		}
+		if maxFiles >= 0 && len(files) >= maxFiles {
+			return nil, &os.PathError{Op: "LoadAllAt", Path: directory, Err: ErrTooManyFiles}
+		}
+
+		f, err := openFileAtIn(dfd, directory, name, os.O_RDONLY, 0)
+		if err != nil {
+			return nil, err
+		}
+		// The file may have been replaced since the check above.
+		if err := checkRegular(f, name); err != nil {
+			f.Close()
+			return nil, err
+		}
+		var r io.Reader = f
+		if maxBytes >= 0 {
+			// Read one byte more than allowed to detect oversized input.
+			r = io.LimitReader(f, maxBytes-total+1)
+		}
+		data, err := io.ReadAll(r)
+		f.Close()
+		if err != nil {
+			return nil, err
+		}
+		total += int64(len(data))
+		if maxBytes >= 0 && total > maxBytes {
+			return nil, &os.PathError{Op: "LoadAllAt", Path: directory, Err: ErrFileTooLarge}
+		}
+		files[name] = data
+	}
+	return files, nil
+}
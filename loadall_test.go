@@ -0,0 +1,67 @@
+// Copyright 2024 Google LLC.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package safeopen
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+)
+
+func TestLoadAllAt(t *testing.T) {
+	tmpDir := t.TempDir()
+	for name, data := range map[string]string{"a.conf": "a=1", "b.conf": "b=2", "empty.conf": ""} {
+		if err := os.WriteFile(filepath.Join(tmpDir, name), []byte(data), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := os.MkdirAll(filepath.Join(tmpDir, "subdir"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(tmpDir, "subdir", "c.conf"), []byte("c=3"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if runtime.GOOS != "windows" {
+		if err := os.Symlink("/etc/passwd", filepath.Join(tmpDir, "link.conf")); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	files, err := LoadAllAt(tmpDir)
+	if err != nil {
+		t.Fatalf("LoadAllAt() error: %v", err)
+	}
+	want := map[string]string{"a.conf": "a=1", "b.conf": "b=2", "empty.conf": ""}
+	if len(files) != len(want) {
+		t.Errorf("LoadAllAt() returned %d files, want %d", len(files), len(want))
+	}
+	for name, data := range want {
+		if got, ok := files[name]; !ok || string(got) != data {
+			t.Errorf("LoadAllAt()[%q] = %q, %v, want %q", name, got, ok, data)
+		}
+	}
+
+	if _, err := LoadAllLimitAt(tmpDir, 2, -1); !errors.Is(err, ErrTooManyFiles) {
+		t.Errorf("LoadAllLimitAt(2, -1) error = %v, want ErrTooManyFiles", err)
+	}
+	if _, err := LoadAllLimitAt(tmpDir, -1, 5); !errors.Is(err, ErrFileTooLarge) {
+		t.Errorf("LoadAllLimitAt(-1, 5) error = %v, want ErrFileTooLarge", err)
+	}
+	if _, err := LoadAllLimitAt(tmpDir, 3, 6); err != nil {
+		t.Errorf("LoadAllLimitAt(3, 6) error: %v", err)
+	}
+}
@@ -0,0 +1,136 @@
+// Copyright 2024 Google LLC.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package safeopen
+
+import (
+	"errors"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"syscall"
+)
+
+// MkdirAt creates a new directory with the specified name and permission bits (before umask) in
+// the named directory. dir may not contain path separators. The perm parameter is ignored on
+// Windows.
+// If there is an error, it will be of type *PathError.
+func MkdirAt(directory, dir string, perm os.FileMode) error {
+	if !isFilename(dir) {
+		return &os.PathError{Op: "MkdirAt", Path: dir, Err: ErrInvalidFilename}
+	}
+	dfd, err := openDirHandle(directory)
+	if err != nil {
+		return err
+	}
+	defer closeHandle(dfd)
+
+	if err := mkdirIn(dfd, dir, perm); err != nil {
+		return &os.PathError{Op: "mkdir", Path: filepath.Join(directory, dir), Err: err}
+	}
+	return nil
+}
+
+// MkdirBeneath creates a new directory with the specified name and permission bits (before
+// umask) in the named directory or a subdirectory. dir may not contain .. path traversal
+// entries, and the directories leading to it are resolved as by OpenBeneath. The perm parameter
+// is ignored on Windows.
+// If there is an error, it will be of type *PathError.
+func MkdirBeneath(directory, dir string, perm os.FileMode) error {
+	dfd, err := openDirHandle(directory)
+	if err != nil {
+		return err
+	}
+	defer closeHandle(dfd)
+
+	return mkdirBeneathIn(dfd, directory, dir, perm)
+}
+
+// mkdirBeneathIn creates the directory dir beneath dfd.
+func mkdirBeneathIn(dfd handle, directory, dir string, perm os.FileMode) error {
+	pfd, base, err := openParentIn(dfd, dir)
+	if err != nil {
+		return routedError("mkdir", err, filepath.Join(directory, dir))
+	}
+	if pfd != dfd {
+		defer closeHandle(pfd)
+	}
+	if !isFilename(base) {
+		return &os.PathError{Op: "MkdirBeneath", Path: dir, Err: ErrInvalidFilename}
+	}
+	if err := mkdirIn(pfd, base, perm); err != nil {
+		return &os.PathError{Op: "mkdir", Path: filepath.Join(directory, dir), Err: err}
+	}
+	return nil
+}
+
+// MkdirAllBeneath creates the directory dir in the named directory, along with any necessary
+// parents, with the specified permission bits (before umask), and returns nil if dir already
+// exists. dir may not contain .. path traversal entries. The perm parameter is ignored on
+// Windows.
+//
+// The directories are created and opened one at a time, each relative to the handle of its
+// parent, and existing components must be directories: symbolic links (reparse points on
+// Windows) are rejected rather than followed, so that the new directories end up beneath the
+// named directory whatever happens concurrently.
+// If there is an error, it will be of type *PathError.
+func MkdirAllBeneath(directory, dir string, perm os.FileMode) error {
+	dfd, err := openDirHandle(directory)
+	if err != nil {
+		return err
+	}
+	defer closeHandle(dfd)
+
+	return mkdirAllIn(dfd, directory, dir, perm)
+}
+
+// mkdirAllIn creates the directory dir beneath dfd, along with any necessary parents.
+func mkdirAllIn(dfd handle, directory, dir string, perm os.FileMode) error {
+	p, ok := cleanRelPath(dir)
+	if !ok || !isRelPathBeneath(dir) {
+		return &os.PathError{Op: "MkdirAllBeneath", Path: dir, Err: ErrInvalidFilename}
+	}
+
+	cur := dfd
+	var parent *os.File
+	defer func() {
+		if parent != nil {
+			parent.Close()
+		}
+	}()
+	path := directory
+	for _, elem := range splitRelPath(p) {
+		path = filepath.Join(path, elem)
+		if err := mkdirIn(cur, elem, perm); err != nil && !errors.Is(err, fs.ErrExist) {
+			return &os.PathError{Op: "mkdir", Path: path, Err: err}
+		}
+		f, err := openDirNoFollowIn(cur, "", elem)
+		if err != nil {
+			return routedError("mkdir", err, path)
+		}
+		if fi, err := f.Stat(); err != nil || fi.Mode().Type() != fs.ModeDir {
+			f.Close()
+			if err == nil {
+				err = syscall.ENOTDIR
+			}
+			return &os.PathError{Op: "mkdir", Path: path, Err: err}
+		}
+		if parent != nil {
+			parent.Close()
+		}
+		parent = f
+		cur = handle(f.Fd())
+	}
+	return nil
+}
@@ -0,0 +1,111 @@
+// Copyright 2024 Google LLC.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package safeopen
+
+import (
+	"errors"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestMkdirAt(t *testing.T) {
+	dir := t.TempDir()
+	if err := MkdirAt(dir, "sub", 0755); err != nil {
+		t.Fatalf("MkdirAt(sub) error: %v", err)
+	}
+	if fi, err := os.Stat(filepath.Join(dir, "sub")); err != nil || !fi.IsDir() {
+		t.Errorf("sub is not a directory after MkdirAt: %v", err)
+	}
+	if err := MkdirAt(dir, "sub", 0755); !errors.Is(err, fs.ErrExist) {
+		t.Errorf("MkdirAt(sub) again = %v, want ErrExist", err)
+	}
+	for _, name := range []string{filepath.Join("sub", "nested"), "..", ".", ""} {
+		if err := MkdirAt(dir, name, 0755); err == nil {
+			t.Errorf("MkdirAt(%q) should have been an error", name)
+		}
+	}
+}
+
+func TestMkdirBeneath(t *testing.T) {
+	dir := t.TempDir()
+	outside := t.TempDir()
+	if err := os.Mkdir(filepath.Join(dir, "a"), 0755); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := MkdirBeneath(dir, filepath.Join("a", "b"), 0755); err != nil {
+		t.Fatalf("MkdirBeneath(a/b) error: %v", err)
+	}
+	if fi, err := os.Stat(filepath.Join(dir, "a", "b")); err != nil || !fi.IsDir() {
+		t.Errorf("a/b is not a directory after MkdirBeneath: %v", err)
+	}
+	if err := MkdirBeneath(dir, filepath.Join("missing", "b"), 0755); !errors.Is(err, fs.ErrNotExist) {
+		t.Errorf("MkdirBeneath(missing/b) = %v, want ErrNotExist", err)
+	}
+	for _, name := range []string{filepath.Join("..", filepath.Base(outside), "escape"), ".", ""} {
+		if err := MkdirBeneath(dir, name, 0755); err == nil {
+			t.Errorf("MkdirBeneath(%q) should have been an error", name)
+		}
+	}
+
+	if err := os.Symlink(outside, filepath.Join(dir, "link")); err != nil {
+		t.Skipf("cannot create symbolic links: %v", err)
+	}
+	MkdirBeneath(dir, filepath.Join("link", "escape"), 0755)
+	if _, err := os.Stat(filepath.Join(outside, "escape")); err == nil {
+		t.Error("MkdirBeneath(link/escape) created a directory out of the directory")
+	}
+}
+
+func TestMkdirAllBeneath(t *testing.T) {
+	dir := t.TempDir()
+	outside := t.TempDir()
+	if err := os.Mkdir(filepath.Join(dir, "a"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "file"), nil, 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	nested := filepath.Join("a", "b", "c", "d")
+	if err := MkdirAllBeneath(dir, nested, 0755); err != nil {
+		t.Fatalf("MkdirAllBeneath(%q) error: %v", nested, err)
+	}
+	if fi, err := os.Stat(filepath.Join(dir, nested)); err != nil || !fi.IsDir() {
+		t.Errorf("%s is not a directory after MkdirAllBeneath: %v", nested, err)
+	}
+	for _, name := range []string{nested, "a", "."} {
+		if err := MkdirAllBeneath(dir, name, 0755); err != nil {
+			t.Errorf("MkdirAllBeneath(%q) on an existing directory error: %v", name, err)
+		}
+	}
+	for _, name := range []string{"file", filepath.Join("file", "sub"), filepath.Join("..", filepath.Base(outside), "escape"), ""} {
+		if err := MkdirAllBeneath(dir, name, 0755); err == nil {
+			t.Errorf("MkdirAllBeneath(%q) should have been an error", name)
+		}
+	}
+
+	if err := os.Symlink(outside, filepath.Join(dir, "a", "link")); err != nil {
+		t.Skipf("cannot create symbolic links: %v", err)
+	}
+	if err := MkdirAllBeneath(dir, filepath.Join("a", "link", "escape"), 0755); err == nil {
+		t.Error("MkdirAllBeneath(a/link/escape) followed a symbolic link")
+	}
+	if _, err := os.Stat(filepath.Join(outside, "escape")); err == nil {
+		t.Error("MkdirAllBeneath(a/link/escape) created a directory out of the directory")
+	}
+}
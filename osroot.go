@@ -0,0 +1,57 @@
+// Copyright 2024 Google LLC.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+//go:build go1.24
+// +build go1.24
+
+package safeopen
+
+import (
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+)
+
+// osRootAvailable reports whether OpenFileBeneath may rely on os.Root.
+const osRootAvailable = true
+
+// openFileBeneath opens file beneath directory with os.Root if symlink emulation is enabled, as
+// os.Root follows the symbolic links staying beneath directory on all platforms, as emulation
+// does. Without emulation, symbolic links are only followed where openat2 is available, so the
+// implementation of this package is used, as by the other Beneath functions and Dir. Strict mode
+// keeps requiring openat2, exact case can only be checked on paths resolved by this package, and
+// os.Root only supports the permission bits of perm, so those cases are left to it as well.
+func openFileBeneath(directory, file string, flag int, perm os.FileMode) (*os.File, error) {
+	if !symlinkEmulation.Load() || strictMode.Load() || exactCaseEnforced() || perm&^os.ModePerm != 0 {
+		return openFileBeneathHandle(directory, file, flag, perm)
+	}
+	if !isRelPathBeneath(file) {
+		return nil, &os.PathError{Op: "OpenBeneath", Path: file, Err: beneathPathError(file)}
+	}
+	if runtime.GOOS == "windows" {
+		// perm is documented as ignored, while os.Root makes files without 0200 read-only.
+		perm = 0666
+	}
+
+	root, err := os.OpenRoot(directory)
+	if err != nil {
+		return nil, err
+	}
+	defer root.Close()
+	// Leading slashes have always been accepted, as if the path was relative.
+	return openFileRegularOrDir(filepath.Join(directory, file), flag, func(flag int) (*os.File, error) {
+		return root.OpenFile(strings.TrimLeft(file, "/"), flag, perm)
+	})
+}
@@ -0,0 +1,29 @@
+// Copyright 2024 Google LLC.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+//go:build !go1.24
+// +build !go1.24
+
+package safeopen
+
+import "os"
+
+// osRootAvailable reports whether OpenFileBeneath may rely on os.Root.
+const osRootAvailable = false
+
+// openFileBeneath opens file beneath directory. Before Go 1.24 and os.Root, this is always done
+// by the implementation of this package.
+func openFileBeneath(directory, file string, flag int, perm os.FileMode) (*os.File, error) {
+	return openFileBeneathHandle(directory, file, flag, perm)
+}
@@ -0,0 +1,63 @@
+// Copyright 2024 Google LLC.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+//go:build go1.24
+// +build go1.24
+
+package safeopen
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestOpenBeneathOSRoot(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.Mkdir(filepath.Join(dir, "sub"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "sub", "target"), []byte("hello"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Symlink("sub", filepath.Join(dir, "inside")); err != nil {
+		t.Skipf("cannot create symbolic links: %v", err)
+	}
+	if err := os.Symlink(filepath.Join("..", filepath.Base(dir)), filepath.Join(dir, "sub", "outside")); err != nil {
+		t.Fatal(err)
+	}
+
+	// Without emulation, symbolic links are followed as by Dir, only where openat2 is available.
+	if _, err := ReadFileBeneath(dir, filepath.Join("inside", "target")); (err == nil) != Capabilities().KernelBeneath {
+		t.Errorf("ReadFileBeneath(inside/target) without emulation error = %v, want an error without openat2", err)
+	}
+
+	// With emulation, os.Root follows the symbolic links staying beneath the directory,
+	// whatever the platform.
+	SetSymlinkEmulation(true)
+	defer SetSymlinkEmulation(false)
+	if !Capabilities().OSRoot {
+		t.Error("Capabilities().OSRoot = false with emulation")
+	}
+	data, err := ReadFileBeneath(dir, filepath.Join("inside", "target"))
+	if err != nil || string(data) != "hello" {
+		t.Errorf("ReadFileBeneath(inside/target) = %q, %v, want %q", data, err, "hello")
+	}
+	if _, err := ReadFileBeneath(dir, filepath.Join("sub", "outside", "sub", "target")); err == nil {
+		t.Error("ReadFileBeneath(sub/outside/sub/target) escaped the directory")
+	}
+	if _, err := OpenBeneath(dir, filepath.Join("..", filepath.Base(dir), "sub", "target")); err == nil {
+		t.Error("OpenBeneath(../) escaped the directory")
+	}
+}
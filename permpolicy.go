@@ -0,0 +1,67 @@
+// Copyright 2024 Google LLC.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package safeopen
+
+import "os"
+
+// PermPolicy constrains the permission bits of the files and directories created through a Dir,
+// whatever the callers pass, e.g. to keep a single misconfigured call site from creating
+// world-readable files in a directory of secrets.
+type PermPolicy struct {
+	// FilePerm and DirPerm are the permission bits for new files and directories. Zero leaves
+	// the permission bits passed by the callers untouched.
+	FilePerm os.FileMode
+	DirPerm  os.FileMode
+	// Override makes FilePerm and DirPerm replace the permission bits passed by the callers.
+	// Otherwise, they are a cap: the bits they do not have are cleared.
+	Override bool
+}
+
+// WithPermPolicy makes d apply p to the permission bits of the files and directories it creates.
+// As with os.OpenFile, the resulting bits are still subject to the umask; the permissions of
+// existing files are left untouched.
+func WithPermPolicy(p PermPolicy) Option {
+	return func(o *options) {
+		o.permPolicy = &p
+	}
+}
+
+// filePerm returns the permission bits to create a file with, given the bits perm asked for.
+func (p *PermPolicy) filePerm(perm os.FileMode) os.FileMode {
+	if p == nil {
+		return perm
+	}
+	return p.apply(perm, p.FilePerm)
+}
+
+// dirPerm returns the permission bits to create a directory with, given the bits perm asked for.
+func (p *PermPolicy) dirPerm(perm os.FileMode) os.FileMode {
+	if p == nil {
+		return perm
+	}
+	return p.apply(perm, p.DirPerm)
+}
+
+func (p *PermPolicy) apply(perm, policy os.FileMode) os.FileMode {
+	policy &= os.ModePerm
+	switch {
+	case policy == 0:
+		return perm
+	case p.Override:
+		return perm&^os.ModePerm | policy
+	default:
+		return perm &^ (os.ModePerm &^ policy)
+	}
+}
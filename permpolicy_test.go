@@ -0,0 +1,69 @@
+// Copyright 2024 Google LLC.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package safeopen
+
+import (
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+)
+
+func TestPermPolicy(t *testing.T) {
+	for _, tc := range []struct {
+		policy    *PermPolicy
+		perm      os.FileMode
+		file, dir os.FileMode
+	}{
+		{nil, 0666, 0666, 0666},
+		{&PermPolicy{}, 0644, 0644, 0644},
+		{&PermPolicy{FilePerm: 0600, DirPerm: 0700}, 0666, 0600, 0600},
+		{&PermPolicy{FilePerm: 0600, DirPerm: 0700}, 0777, 0600, 0700},
+		{&PermPolicy{FilePerm: 0600, DirPerm: 0700}, 0400, 0400, 0400},
+		{&PermPolicy{FilePerm: 0600, DirPerm: 0700, Override: true}, 0444, 0600, 0700},
+		{&PermPolicy{FilePerm: 0640, Override: true}, 0755, 0640, 0755},
+		{&PermPolicy{FilePerm: 0600}, os.ModeSticky | 0777, os.ModeSticky | 0600, os.ModeSticky | 0777},
+	} {
+		if got := tc.policy.filePerm(tc.perm); got != tc.file {
+			t.Errorf("%+v.filePerm(%v) = %v, want %v", tc.policy, tc.perm, got, tc.file)
+		}
+		if got := tc.policy.dirPerm(tc.perm); got != tc.dir {
+			t.Errorf("%+v.dirPerm(%v) = %v, want %v", tc.policy, tc.perm, got, tc.dir)
+		}
+	}
+}
+
+func TestWithPermPolicy(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("permission bits are ignored on Windows")
+	}
+	dir := t.TempDir()
+	d, err := OpenDir(dir, WithPermPolicy(PermPolicy{FilePerm: 0600, DirPerm: 0700}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer d.Close()
+
+	if err := d.WriteFile("secret", []byte("data"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	fi, err := os.Stat(filepath.Join(dir, "secret"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if perm := fi.Mode().Perm(); perm&0077 != 0 {
+		t.Errorf("secret created with mode %v, want no group or other bits", perm)
+	}
+}
@@ -0,0 +1,97 @@
+// Copyright 2024 Google LLC.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package safeopen
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"unicode/utf8"
+)
+
+// ErrNotPortable is returned for paths that cannot be used on all the supported platforms.
+var ErrNotPortable = errors.New("not a portable path")
+
+const (
+	// maxPortableNameLen is the maximum length in bytes of an element of a portable path, which
+	// most file systems cap at 255 bytes (ext4) or 255 UTF-16 code units (NTFS).
+	maxPortableNameLen = 255
+	// maxPortablePathLen is the maximum length in bytes of a portable path.
+	maxPortablePathLen = 4095
+)
+
+// CheckPortablePath returns an error wrapping ErrNotPortable if the relative path relpath is not
+// usable as is on all the supported platforms, Windows included, so that data written on one
+// platform can be served from any other. Each element of a portable path is valid UTF-8, at most
+// 255 bytes long, does not end with a dot or a space, is not a device name reserved on Windows
+// (CON, NUL, COM1, ...) with or without extension, and has neither control characters nor any
+// of the characters < > : " / \ | ? *. The whole path is at most 4095 bytes long.
+func CheckPortablePath(relpath string) error {
+	p, ok := cleanRelPath(relpath)
+	if !ok || p == "." {
+		return &os.PathError{Op: "CheckPortablePath", Path: relpath, Err: ErrInvalidFilename}
+	}
+	if len(p) > maxPortablePathLen {
+		return &os.PathError{Op: "CheckPortablePath", Path: relpath, Err: fmt.Errorf("%w: too long", ErrNotPortable)}
+	}
+	for _, elem := range splitRelPath(p) {
+		if reason := nonPortableReason(elem); reason != "" {
+			return &os.PathError{Op: "CheckPortablePath", Path: relpath, Err: fmt.Errorf("%w: %q %s", ErrNotPortable, elem, reason)}
+		}
+	}
+	return nil
+}
+
+// WithPortableNames makes d refuse to create files whose path is not portable, as checked by
+// CheckPortablePath, on every platform.
+func WithPortableNames() Option {
+	return func(o *options) {
+		o.portable = true
+	}
+}
+
+// nonPortableReason returns why the path element name is not portable, or "" if it is.
+func nonPortableReason(name string) string {
+	switch {
+	case !utf8.ValidString(name):
+		return "is not valid UTF-8"
+	case len(name) > maxPortableNameLen:
+		return "is too long"
+	case strings.HasSuffix(name, ".") || strings.HasSuffix(name, " "):
+		return "ends with a dot or a space"
+	case strings.ContainsFunc(name, func(r rune) bool { return r < 0x20 || strings.ContainsRune(`<>:"/\|?*`, r) }):
+		return "has a forbidden character"
+	case isReservedPortableName(name):
+		return "is a reserved name"
+	}
+	return ""
+}
+
+// isReservedPortableName reports whether name is a device name reserved on Windows. Unlike
+// isReservedName, spaces before the extension and the console and superscript digit variants
+// are taken into account.
+func isReservedPortableName(name string) bool {
+	base, _, _ := strings.Cut(name, ".")
+	base = strings.ToUpper(strings.TrimRight(base, " "))
+	if isReservedName(base) {
+		return true
+	}
+	switch base {
+	case "CONIN$", "CONOUT$", "COM¹", "COM²", "COM³", "LPT¹", "LPT²", "LPT³":
+		return true
+	}
+	return false
+}
@@ -0,0 +1,96 @@
+// Copyright 2024 Google LLC.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package safeopen
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestCheckPortablePath(t *testing.T) {
+	for _, tc := range []struct {
+		path string
+		ok   bool
+	}{
+		{"file.txt", true},
+		{"dir/sub/file.txt", true},
+		{"./dir/file", true},
+		{"héllo wörld", true},
+		{".hidden", true},
+		{"CONSOLE", true},
+		{"COM10", true},
+		{strings.Repeat("a", 255), true},
+		{"con", false},
+		{"dir/Nul.txt", false},
+		{"aux .tar.gz", false},
+		{"com1", false},
+		{"LPT²", false},
+		{"conin$", false},
+		{"trailing.", false},
+		{"trailing ", false},
+		{"dir./file", false},
+		{"a:b", false},
+		{"a\\b", false},
+		{"a*", false},
+		{"a\tb", false},
+		{"\xff", false},
+		{strings.Repeat("a", 256), false},
+		{strings.Repeat("a/", 2048) + "a", false},
+	} {
+		err := CheckPortablePath(tc.path)
+		if tc.ok && err != nil {
+			t.Errorf("CheckPortablePath(%q) = %v, want nil", tc.path, err)
+		}
+		if !tc.ok && !errors.Is(err, ErrNotPortable) {
+			t.Errorf("CheckPortablePath(%q) = %v, want ErrNotPortable", tc.path, err)
+		}
+	}
+
+	for _, path := range []string{"", ".", "../file"} {
+		if err := CheckPortablePath(path); err == nil {
+			t.Errorf("CheckPortablePath(%q) = nil, want error", path)
+		}
+	}
+}
+
+func TestWithPortableNames(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "existing "), []byte("data"), 0600); err != nil {
+		t.Skipf("cannot create a non-portable file on this platform: %v", err)
+	}
+
+	d, err := OpenDir(dir, WithPortableNames())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer d.Close()
+
+	if err := d.WriteFile("good.txt", []byte("data"), 0600); err != nil {
+		t.Errorf("WriteFile(good.txt) = %v", err)
+	}
+	if err := d.WriteFile("bad?.txt", []byte("data"), 0600); !errors.Is(err, ErrNotPortable) {
+		t.Errorf("WriteFile(bad?.txt) = %v, want ErrNotPortable", err)
+	}
+	if _, err := os.Stat(filepath.Join(dir, "bad?.txt")); err == nil {
+		t.Error("non-portable file was created")
+	}
+	// Existing files can still be read.
+	if _, err := d.ReadFile("existing "); err != nil {
+		t.Errorf("ReadFile(existing) = %v", err)
+	}
+}
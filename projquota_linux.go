@@ -0,0 +1,147 @@
+// Copyright 2024 Google LLC.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+//go:build linux
+// +build linux
+
+package safeopen
+
+import (
+	"io/fs"
+	"os"
+	"unsafe"
+
+	"golang.org/x/sys/unix"
+)
+
+// fsxattr is struct fsxattr from linux/fs.h.
+type fsxattr struct {
+	xflags     uint32
+	extsize    uint32
+	nextents   uint32
+	projid     uint32
+	cowextsize uint32
+	pad        [8]byte
+}
+
+// fsXflagProjInherit is FS_XFLAG_PROJINHERIT from linux/fs.h.
+const fsXflagProjInherit = 0x200
+
+// The ioctl requests FS_IOC_FSGETXATTR and FS_IOC_FSSETXATTR, which x/sys/unix lacks. The
+// direction bits are architecture dependent, so they are taken from FS_IOC_GETFLAGS and
+// FS_IOC_SETFLAGS, which read and write a value as well.
+var (
+	fsIocFsgetxattr = uint(unix.FS_IOC_GETFLAGS)&^0x1fffffff | uint(unsafe.Sizeof(fsxattr{}))<<16 | 'X'<<8 | 31
+	fsIocFssetxattr = uint(unix.FS_IOC_SETFLAGS)&^0x1fffffff | uint(unsafe.Sizeof(fsxattr{}))<<16 | 'X'<<8 | 32
+)
+
+// GetProjectID returns the project ID of f, which XFS and ext4 project quotas account it to.
+func GetProjectID(f *os.File) (uint32, error) {
+	var attr fsxattr
+	err := fileIoctl(f, "GetProjectID", func(fd int) error {
+		return fsxattrIoctl(fd, fsIocFsgetxattr, &attr)
+	})
+	return attr.projid, err
+}
+
+// SetProjectID sets the project ID of f to id. If f is a directory, it also makes the files
+// created in it inherit id. This requires the file system to be mounted with project quotas
+// enabled, and the process to own f in the initial user namespace, or to have CAP_FOWNER.
+func SetProjectID(f *os.File, id uint32) error {
+	fi, err := f.Stat()
+	if err != nil {
+		return err
+	}
+	return fileIoctl(f, "SetProjectID", func(fd int) error {
+		var attr fsxattr
+		if err := fsxattrIoctl(fd, fsIocFsgetxattr, &attr); err != nil {
+			return err
+		}
+		attr.projid = id
+		if fi.IsDir() {
+			attr.xflags |= fsXflagProjInherit
+		}
+		return fsxattrIoctl(fd, fsIocFssetxattr, &attr)
+	})
+}
+
+// GetProjectIDBeneath is like GetProjectID for the named file beneath the named directory,
+// opened with the same rules as OpenBeneath.
+func GetProjectIDBeneath(directory, file string) (uint32, error) {
+	f, err := openForMetadataBeneath(directory, file)
+	if err != nil {
+		return 0, err
+	}
+	defer f.Close()
+	return GetProjectID(f)
+}
+
+// SetProjectIDBeneath sets the project ID of the named file beneath the named directory to id
+// with SetProjectID, as well as, if it is a directory, the project ID of the whole tree rooted at
+// it, e.g. to attach a project quota to the subtree of a tenant. The file is opened with the same
+// rules as OpenBeneath, but symbolic links within the tree are neither followed nor changed, as
+// they have no project ID.
+func SetProjectIDBeneath(directory, file string, id uint32) error {
+	dfd, err := openDirHandle(directory)
+	if err != nil {
+		return err
+	}
+	defer closeHandle(dfd)
+
+	f, err := openFileBeneathIn(dfd, directory, file, os.O_RDONLY|unix.O_NONBLOCK, 0)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	if err := SetProjectID(f, id); err != nil {
+		return err
+	}
+	fi, err := f.Stat()
+	if err != nil || !fi.IsDir() {
+		return err
+	}
+
+	// Walk from the directory itself, so that the tree is the one just changed even if file has
+	// symbolic links in its path.
+	rc, err := f.SyscallConn()
+	if err != nil {
+		return err
+	}
+	var walkErr error
+	err = rc.Control(func(fd uintptr) {
+		walkErr = walkIn(int(fd), ".", func(name string, fi fs.FileInfo) error {
+			if !fi.IsDir() && !fi.Mode().IsRegular() {
+				return nil
+			}
+			entry, err := openFileNoFollowIn(int(fd), f.Name(), name, os.O_RDONLY|unix.O_NONBLOCK, 0)
+			if err != nil {
+				return err
+			}
+			defer entry.Close()
+			return SetProjectID(entry, id)
+		})
+	})
+	if err != nil {
+		return err
+	}
+	return walkErr
+}
+
+func fsxattrIoctl(fd int, req uint, attr *fsxattr) error {
+	_, _, errno := unix.Syscall(unix.SYS_IOCTL, uintptr(fd), uintptr(req), uintptr(unsafe.Pointer(attr)))
+	if errno != 0 {
+		return errno
+	}
+	return nil
+}
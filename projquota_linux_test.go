@@ -0,0 +1,65 @@
+// Copyright 2024 Google LLC.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+//go:build linux
+// +build linux
+
+package safeopen
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"golang.org/x/sys/unix"
+)
+
+func TestLinuxProjectID(t *testing.T) {
+	// With the generic encoding of the direction bits, as on x86 and arm.
+	if uint(unix.FS_IOC_GETFLAGS)&^0x1fffffff == 0x80000000 && (fsIocFsgetxattr != 0x801c581f || fsIocFssetxattr != 0x401c5820) {
+		t.Errorf("FS_IOC_FSGETXATTR, FS_IOC_FSSETXATTR = %#x, %#x, want 0x801c581f, 0x401c5820", fsIocFsgetxattr, fsIocFssetxattr)
+	}
+
+	tmpDir := t.TempDir()
+	tenant := filepath.Join("tenants", "a")
+	if err := os.MkdirAll(filepath.Join(tmpDir, tenant, "sub"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(tmpDir, tenant, "sub", "file"), nil, 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := GetProjectIDBeneath(tmpDir, tenant); err != nil {
+		t.Skipf("project IDs not supported here: %v", err)
+	}
+	err := SetProjectIDBeneath(tmpDir, tenant, 42)
+	if errors.Is(err, unix.EOPNOTSUPP) || errors.Is(err, unix.ENOTTY) || errors.Is(err, unix.EINVAL) {
+		t.Skipf("project quotas not enabled here: %v", err)
+	}
+	if err != nil {
+		t.Fatalf("SetProjectIDBeneath() error: %v", err)
+	}
+	for _, file := range []string{tenant, filepath.Join(tenant, "sub"), filepath.Join(tenant, "sub", "file")} {
+		if id, err := GetProjectIDBeneath(tmpDir, file); err != nil || id != 42 {
+			t.Errorf("GetProjectIDBeneath(%q) = %d, %v, want 42, nil", file, id, err)
+		}
+	}
+	if err := os.WriteFile(filepath.Join(tmpDir, tenant, "new"), nil, 0644); err != nil {
+		t.Fatal(err)
+	}
+	if id, err := GetProjectIDBeneath(tmpDir, filepath.Join(tenant, "new")); err != nil || id != 42 {
+		t.Errorf("project ID of a new file = %d, %v, want 42 (inherited), nil", id, err)
+	}
+}
@@ -0,0 +1,123 @@
+// Copyright 2024 Google LLC.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package safeopen
+
+import (
+	"errors"
+	"io/fs"
+	"os"
+	"sync"
+)
+
+// ErrTooManyFiles is returned when creating a file would exceed the maximum number of files of
+// a Dir.
+var ErrTooManyFiles = errors.New("too many files")
+
+// WithMaxFiles caps the number of entries (files, directories, symbolic links...) in the tree
+// rooted at d to max: creating a new file through d fails with ErrTooManyFiles once the cap is
+// reached, while existing files can still be opened and truncated.
+//
+// The entries are counted when d is opened, and the count is then kept up to date with the
+// files created through d. Entries created or removed by other means are only accounted for by
+// Recount, which may be called periodically.
+func WithMaxFiles(max int) Option {
+	return func(o *options) {
+		o.maxFiles = max
+	}
+}
+
+// fileQuota tracks the number of entries beneath a Dir.
+type fileQuota struct {
+	mu    sync.Mutex
+	max   int
+	count int
+}
+
+// reserve accounts for a new entry, unless the maximum is reached. A nil quota is unbounded.
+func (q *fileQuota) reserve() error {
+	if q == nil {
+		return nil
+	}
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	if q.count >= q.max {
+		return ErrTooManyFiles
+	}
+	q.count++
+	return nil
+}
+
+// release gives back an entry reserved but not created.
+func (q *fileQuota) release() {
+	if q == nil {
+		return
+	}
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	q.count--
+}
+
+// Recount counts the entries in the tree rooted at d again, to account for the entries
+// created or removed by other means than d. It is a no-op unless d was opened with
+// WithMaxFiles.
+func (d *Dir) Recount() error {
+	if d.quota == nil {
+		return nil
+	}
+	n := 0
+	err := walkIn(d.dfd, ".", func(string, fs.FileInfo) error {
+		n++
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	d.quota.mu.Lock()
+	defer d.quota.mu.Unlock()
+	d.quota.count = n
+	return nil
+}
+
+// createCounted opens file beneath d with flag, which includes O_CREATE, accounting for the
+// file in the quota and the I/O accounting of d if it is created.
+func (d *Dir) createCounted(file string, flag int, perm os.FileMode) (*os.File, error) {
+	for {
+		if flag&os.O_EXCL == 0 {
+			// Opening an existing file does not count.
+			f, err := d.openFileUncounted(file, flag&^os.O_CREATE, perm)
+			if err == nil {
+				d.acct.open(false)
+			}
+			if !errors.Is(err, fs.ErrNotExist) {
+				return f, err
+			}
+		}
+
+		if err := d.quota.reserve(); err != nil {
+			return nil, &os.PathError{Op: "open", Path: file, Err: err}
+		}
+		// The new file is empty anyway, and O_TRUNC would not be exclusive on Windows.
+		f, err := d.openFileUncounted(file, (flag|os.O_EXCL)&^os.O_TRUNC, perm)
+		if err == nil {
+			d.acct.open(true)
+			return f, nil
+		}
+		d.quota.release()
+		if flag&os.O_EXCL != 0 || !errors.Is(err, fs.ErrExist) {
+			return nil, err
+		}
+		// Created concurrently: open it as an existing file.
+	}
+}
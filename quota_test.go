@@ -0,0 +1,63 @@
+// Copyright 2024 Google LLC.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package safeopen
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestMaxFiles(t *testing.T) {
+	tmpDir := t.TempDir()
+	if err := os.Mkdir(filepath.Join(tmpDir, "subdir"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(tmpDir, "subdir", "existing.txt"), nil, 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	d, err := OpenDir(tmpDir, WithMaxFiles(4))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer d.Close()
+
+	for _, file := range []string{"a.txt", filepath.Join("subdir", "b.txt")} {
+		if err := d.WriteFile(file, []byte("data"), 0644); err != nil {
+			t.Fatalf("WriteFile(%q) error: %v", file, err)
+		}
+	}
+	if err := d.WriteFile("c.txt", []byte("data"), 0644); !errors.Is(err, ErrTooManyFiles) {
+		t.Errorf("WriteFile(%q) over the quota error = %v, want %v", "c.txt", err, ErrTooManyFiles)
+	}
+	if err := d.WriteFile("a.txt", []byte("rewritten"), 0644); err != nil {
+		t.Errorf("WriteFile(%q) of an existing file error: %v", "a.txt", err)
+	}
+	if _, err := os.Stat(filepath.Join(tmpDir, "c.txt")); !os.IsNotExist(err) {
+		t.Errorf("c.txt was created over the quota: %v", err)
+	}
+
+	if err := os.Remove(filepath.Join(tmpDir, "a.txt")); err != nil {
+		t.Fatal(err)
+	}
+	if err := d.Recount(); err != nil {
+		t.Fatalf("Recount() error: %v", err)
+	}
+	if err := d.WriteFile("c.txt", []byte("data"), 0644); err != nil {
+		t.Errorf("WriteFile(%q) after Recount error: %v", "c.txt", err)
+	}
+}
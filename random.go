@@ -0,0 +1,94 @@
+// Copyright 2024 Google LLC.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package safeopen
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"io/fs"
+	"os"
+)
+
+// maxRandomCreateTries bounds the number of names tried by createRandomIn.
+const maxRandomCreateTries = 100
+
+// RandomCreateAt creates a new file with a random name in the named directory, opens it for
+// reading and writing, and returns it along with its name. The name is made of prefix, 32
+// random hexadecimal digits and suffix; prefix and suffix may not contain path separators.
+// The file is created with mode 0600 (before umask), and never replaces an existing file.
+//
+// The name is drawn from a cryptographic random source, so it can neither be predicted nor
+// collide in practice, which makes RandomCreateAt suitable for staging uploads or spooling in
+// shared directories. The caller is responsible for removing the file; see CreateScratchAt for
+// a file removed by the system, even if the process crashes.
+func RandomCreateAt(directory, prefix, suffix string) (*os.File, string, error) {
+	if !isFilename(prefix + "x" + suffix) {
+		return nil, "", &os.PathError{Op: "RandomCreateAt", Path: prefix + "*" + suffix, Err: ErrInvalidFilename}
+	}
+	dfd, err := openDirHandle(directory)
+	if err != nil {
+		return nil, "", err
+	}
+	defer closeHandle(dfd)
+
+	return createRandomIn(dfd, directory, prefix, suffix, 0600)
+}
+
+// CreateScratchAt creates an anonymous scratch file in the named directory, opened for reading
+// and writing, which is removed by the system when closed. Unlike with RandomCreateAt, the file
+// does not outlive the process even if it crashes or is killed, so no temporary file is ever left
+// behind in the directory.
+//
+// On Linux, the file is created with O_TMPFILE and never has a name; on file systems lacking
+// O_TMPFILE support and on other Unix systems, it is removed right after its creation. On
+// Windows, it is created with a random name and FILE_DELETE_ON_CLOSE, so it remains visible in
+// the directory while open, and is removed when its last handle is closed, which the system does
+// for a process that crashes or is killed as well.
+func CreateScratchAt(directory string) (*os.File, error) {
+	dfd, err := openDirHandle(directory)
+	if err != nil {
+		return nil, err
+	}
+	defer closeHandle(dfd)
+
+	return createScratchIn(dfd, directory)
+}
+
+// createRandomIn creates a new file named prefix, random hexadecimal digits and suffix directly
+// in the directory referred by dfd, retrying with another name if the file exists.
+func createRandomIn(dfd handle, directory, prefix, suffix string, perm os.FileMode) (*os.File, string, error) {
+	for try := 0; try < maxRandomCreateTries; try++ {
+		name, err := randomName(prefix, suffix)
+		if err != nil {
+			return nil, "", err
+		}
+		f, err := openFileAtIn(dfd, directory, name, os.O_RDWR|os.O_CREATE|os.O_EXCL, perm)
+		if errors.Is(err, fs.ErrExist) {
+			continue
+		}
+		return f, name, err
+	}
+	return nil, "", &os.PathError{Op: "CreateTemp", Path: prefix + "*" + suffix, Err: fs.ErrExist}
+}
+
+// randomName returns a name made of prefix, 32 random hexadecimal digits and suffix.
+func randomName(prefix, suffix string) (string, error) {
+	var buf [16]byte
+	if _, err := rand.Read(buf[:]); err != nil {
+		return "", err
+	}
+	return prefix + hex.EncodeToString(buf[:]) + suffix, nil
+}
@@ -0,0 +1,148 @@
+// Copyright 2024 Google LLC.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package safeopen
+
+import (
+	"bufio"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestRandomCreateAt(t *testing.T) {
+	tmpDir := t.TempDir()
+
+	names := map[string]bool{}
+	for i := 0; i < 10; i++ {
+		f, name, err := RandomCreateAt(tmpDir, "upload-", ".part")
+		if err != nil {
+			t.Fatalf("RandomCreateAt(%q) error: %v", tmpDir, err)
+		}
+		if _, err := f.WriteString(name); err != nil {
+			t.Fatal(err)
+		}
+		f.Close()
+		if !strings.HasPrefix(name, "upload-") || !strings.HasSuffix(name, ".part") || len(name) != len("upload-.part")+32 {
+			t.Errorf("RandomCreateAt(%q) = %q, want upload-<32 digits>.part", tmpDir, name)
+		}
+		if names[name] {
+			t.Errorf("RandomCreateAt(%q) returned %q twice", tmpDir, name)
+		}
+		names[name] = true
+		data, err := os.ReadFile(filepath.Join(tmpDir, name))
+		if err != nil || string(data) != name {
+			t.Errorf("os.ReadFile(%q) = %q, %v, want %q", name, data, err, name)
+		}
+	}
+
+	for _, prefix := range []string{"sub/", "../"} {
+		if _, _, err := RandomCreateAt(tmpDir, prefix, ""); err == nil {
+			t.Errorf("RandomCreateAt(%q, %q) succeeded, want error", tmpDir, prefix)
+		}
+	}
+}
+
+func TestCreateScratchAt(t *testing.T) {
+	tmpDir := t.TempDir()
+
+	f, err := CreateScratchAt(tmpDir)
+	if err != nil {
+		t.Fatalf("CreateScratchAt() error: %v", err)
+	}
+	if _, err := f.WriteString("scratch"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := f.Seek(0, io.SeekStart); err != nil {
+		t.Fatal(err)
+	}
+	data, err := io.ReadAll(f)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(data) != "scratch" {
+		t.Errorf("scratch file content = %q, want %q", data, "scratch")
+	}
+	if err := f.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	entries, err := os.ReadDir(tmpDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 0 {
+		t.Errorf("directory entries after Close() = %v, want none", entries)
+	}
+}
+
+// scratchDirEnv names the environment variable making TestCreateScratchAtKilled create a scratch
+// file in the given directory and wait to be killed.
+const scratchDirEnv = "SAFEOPEN_TEST_SCRATCH_DIR"
+
+func TestCreateScratchAtKilled(t *testing.T) {
+	if dir := os.Getenv(scratchDirEnv); dir != "" {
+		f, err := CreateScratchAt(dir)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := f.WriteString("scratch"); err != nil {
+			t.Fatal(err)
+		}
+		os.Stdout.WriteString("ready\n")
+		time.Sleep(time.Minute)
+		t.Fatal("not killed")
+	}
+	if runtime.GOOS == "js" || runtime.GOOS == "wasip1" {
+		t.Skipf("subprocesses are not supported on %s", runtime.GOOS)
+	}
+
+	tmpDir := t.TempDir()
+	cmd := exec.Command(os.Args[0], "-test.run=^TestCreateScratchAtKilled$")
+	cmd.Env = append(os.Environ(), scratchDirEnv+"="+tmpDir)
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := cmd.Start(); err != nil {
+		t.Fatal(err)
+	}
+	line, err := bufio.NewReader(stdout).ReadString('\n')
+	cmd.Process.Kill()
+	cmd.Wait()
+	if line != "ready\n" {
+		t.Fatalf("subprocess output = %q, %v, want %q", line, err, "ready\n")
+	}
+
+	// The system removes the file once the handles of the killed process are closed, which may
+	// take a moment on Windows.
+	var entries []os.DirEntry
+	for try := 0; try < 50; try++ {
+		if entries, err = os.ReadDir(tmpDir); err != nil || len(entries) == 0 {
+			break
+		}
+		time.Sleep(100 * time.Millisecond)
+	}
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 0 {
+		t.Errorf("directory entries after the process was killed = %v, want none", entries)
+	}
+}
@@ -0,0 +1,141 @@
+// Copyright 2024 Google LLC.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package safeopen
+
+import (
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+)
+
+// ReadDirAt reads the named subdirectory of the named directory and returns all its entries,
+// sorted by filename, as os.ReadDir does. subdir may not contain path separators, and may not be
+// a symbolic link.
+// If there is an error, it will be of type *PathError.
+func ReadDirAt(directory, subdir string) ([]fs.DirEntry, error) {
+	if !isFilename(subdir) {
+		return nil, &os.PathError{Op: "ReadDirAt", Path: subdir, Err: ErrInvalidFilename}
+	}
+	return ReadDirBeneath(directory, subdir)
+}
+
+// OpenDirAt opens the named subdirectory of the named directory for reading its entries, e.g.
+// with ReadDir or Readdirnames. subdir may not contain path separators, and may not be a symbolic
+// link.
+// If there is an error, it will be of type *PathError.
+func OpenDirAt(directory, subdir string) (*os.File, error) {
+	if !isFilename(subdir) {
+		return nil, &os.PathError{Op: "OpenDirAt", Path: subdir, Err: ErrInvalidFilename}
+	}
+	return OpenDirBeneath(directory, subdir)
+}
+
+// OpenDirBeneath opens the named subdirectory of the named directory, or of a subdirectory, for
+// reading its entries. subdir may not contain .. path traversal entries, and symbolic links are
+// not followed at any level. The file refers to the directory opened even if it is later
+// renamed or replaced, which makes it the building block for listing directories safely. Note
+// that the Info method of the entries returned by its ReadDir method stats them by path, unlike
+// those returned by ReadDirBeneath.
+// If there is an error, it will be of type *PathError.
+func OpenDirBeneath(directory, subdir string) (*os.File, error) {
+	dfd, err := openDirHandle(directory)
+	if err != nil {
+		return nil, err
+	}
+	defer closeHandle(dfd)
+
+	f, err := openDirNoFollowIn(dfd, directory, subdir)
+	if err != nil {
+		return nil, routedError("open", err, filepath.Join(directory, subdir))
+	}
+	return f, nil
+}
+
+// ReadDirBeneath reads the named subdirectory of the named directory, or of a subdirectory, and
+// returns all its entries, sorted by filename, as os.ReadDir does. subdir may not contain ..
+// path traversal entries, and symbolic links are not followed at any level. The FileInfo of the
+// entries is read relative to the handle of the directory, never by path.
+// If there is an error, it will be of type *PathError.
+func ReadDirBeneath(directory, subdir string) ([]fs.DirEntry, error) {
+	f, err := OpenDirBeneath(directory, subdir)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	entries, err := readDirIn(f)
+	loadDirInfo(entries)
+	if err != nil {
+		return entries, routedError("readdir", err, filepath.Join(directory, subdir))
+	}
+	return entries, nil
+}
+
+// readDirIn returns the entries of the directory dir, sorted by filename. Unlike the entries
+// returned by dir.ReadDir, whose Info method stats them by path, theirs stats them relative to
+// the handle of dir, so that it cannot be redirected outside of it once dir is replaced by a
+// symbolic link. It fails once dir is closed, unless loadDirInfo was called before.
+func readDirIn(dir *os.File) ([]fs.DirEntry, error) {
+	entries, err := dir.ReadDir(-1)
+	for i, e := range entries {
+		entries[i] = &dirEntry{DirEntry: e, dir: dir}
+	}
+	sort.Slice(entries, func(i, j int) bool { return entries[i].Name() < entries[j].Name() })
+	return entries, err
+}
+
+// loadDirInfo loads the FileInfo of entries returned by readDirIn, so that their Info method
+// keeps working once their directory is closed.
+func loadDirInfo(entries []fs.DirEntry) {
+	for _, e := range entries {
+		e := e.(*dirEntry)
+		e.fi, e.err = e.Info()
+		e.dir = nil
+	}
+}
+
+// dirEntry is an entry of the directory dir, whose FileInfo is loaded relative to the handle of
+// dir. Once dir is nil, the FileInfo has been loaded into fi and err.
+type dirEntry struct {
+	fs.DirEntry
+	dir *os.File
+	fi  fs.FileInfo
+	err error
+}
+
+func (e *dirEntry) Info() (fs.FileInfo, error) {
+	if e.dir == nil {
+		return e.fi, e.err
+	}
+	conn, err := e.dir.SyscallConn()
+	if err != nil {
+		return nil, &os.PathError{Op: "lstat", Path: filepath.Join(e.dir.Name(), e.Name()), Err: err}
+	}
+	var fi fs.FileInfo
+	if cerr := conn.Control(func(fd uintptr) {
+		fi, err = statIn(handle(fd), e.Name())
+	}); cerr != nil {
+		return nil, &os.PathError{Op: "lstat", Path: filepath.Join(e.dir.Name(), e.Name()), Err: cerr}
+	}
+	if err != nil {
+		return nil, routedError("lstat", err, filepath.Join(e.dir.Name(), e.Name()))
+	}
+	return fi, nil
+}
+
+func (e *dirEntry) String() string {
+	return fs.FormatDirEntry(e)
+}
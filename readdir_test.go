@@ -0,0 +1,161 @@
+// Copyright 2024 Google LLC.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package safeopen
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestReadDir(t *testing.T) {
+	dir := t.TempDir()
+	outside := t.TempDir()
+	for _, sub := range []string{"sub", filepath.Join("sub", "nested"), filepath.Join("sub", "c")} {
+		if err := os.Mkdir(filepath.Join(dir, sub), 0755); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for _, file := range []string{filepath.Join("sub", "b"), filepath.Join("sub", "a"), filepath.Join("sub", "nested", "x")} {
+		if err := os.WriteFile(filepath.Join(dir, file), []byte("data"), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := os.WriteFile(filepath.Join(outside, "secret"), nil, 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	entries, err := ReadDirAt(dir, "sub")
+	if err != nil {
+		t.Fatalf("ReadDirAt(sub) error: %v", err)
+	}
+	var names []string
+	for _, e := range entries {
+		names = append(names, e.Name())
+	}
+	if got, want := filepath.Join(names...), filepath.Join("a", "b", "c", "nested"); got != want {
+		t.Errorf("ReadDirAt(sub) = %v, want [a b c nested]", names)
+	}
+	if len(entries) == 4 && (entries[0].IsDir() || !entries[2].IsDir()) {
+		t.Errorf("ReadDirAt(sub) reported wrong types: %v", entries)
+	}
+	if len(entries) > 0 {
+		if fi, err := entries[0].Info(); err != nil || fi.Size() != 4 {
+			t.Errorf("entries[0].Info() = %v, %v, want a 4 bytes file", fi, err)
+		}
+	}
+
+	entries, err = ReadDirBeneath(dir, filepath.Join("sub", "nested"))
+	if err != nil || len(entries) != 1 || entries[0].Name() != "x" {
+		t.Errorf("ReadDirBeneath(sub/nested) = %v, %v, want [x]", entries, err)
+	}
+
+	for _, subdir := range []string{filepath.Join("sub", "nested"), "..", "", "missing", filepath.Join("sub", "a")} {
+		if _, err := ReadDirAt(dir, subdir); err == nil {
+			t.Errorf("ReadDirAt(%q) should have been an error", subdir)
+		}
+	}
+	if _, err := ReadDirBeneath(dir, filepath.Join("..", filepath.Base(outside))); err == nil {
+		t.Error("ReadDirBeneath(../outside) should have been an error")
+	}
+
+	if err := os.Symlink(outside, filepath.Join(dir, "link")); err != nil {
+		t.Skipf("cannot create symbolic links: %v", err)
+	}
+	if _, err := ReadDirBeneath(dir, "link"); err == nil {
+		t.Error("ReadDirBeneath(link) should not follow the symbolic link")
+	}
+}
+
+func TestOpenDir(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.MkdirAll(filepath.Join(dir, "sub", "nested"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "sub", "nested", "x"), []byte("data"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	f, err := OpenDirAt(dir, "sub")
+	if err != nil {
+		t.Fatalf("OpenDirAt(sub) error: %v", err)
+	}
+	names, err := f.Readdirnames(-1)
+	f.Close()
+	if err != nil || len(names) != 1 || names[0] != "nested" {
+		t.Errorf("Readdirnames() = %v, %v, want [nested]", names, err)
+	}
+
+	f, err = OpenDirBeneath(dir, filepath.Join("sub", "nested"))
+	if err != nil {
+		t.Fatalf("OpenDirBeneath(sub/nested) error: %v", err)
+	}
+	if fi, err := f.Stat(); err != nil || !fi.IsDir() {
+		t.Errorf("Stat() = %v, %v, want a directory", fi, err)
+	}
+	entries, err := f.ReadDir(-1)
+	f.Close()
+	if err != nil || len(entries) != 1 || entries[0].Name() != "x" {
+		t.Errorf("ReadDir() = %v, %v, want [x]", entries, err)
+	}
+
+	for _, subdir := range []string{filepath.Join("sub", "nested"), "..", "", "missing"} {
+		if f, err := OpenDirAt(dir, subdir); err == nil {
+			f.Close()
+			t.Errorf("OpenDirAt(%q) should have been an error", subdir)
+		}
+	}
+	for _, subdir := range []string{"..", filepath.Join("sub", "nested", "x")} {
+		if f, err := OpenDirBeneath(dir, subdir); err == nil {
+			f.Close()
+			t.Errorf("OpenDirBeneath(%q) should have been an error", subdir)
+		}
+	}
+}
+
+func TestReadDirInfoAfterSwap(t *testing.T) {
+	tmpDir := t.TempDir()
+	root, outside := filepath.Join(tmpDir, "root"), filepath.Join(tmpDir, "outside")
+	for _, dir := range []string{filepath.Join(root, "sub"), outside} {
+		if err := os.MkdirAll(dir, 0755); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := os.WriteFile(filepath.Join(root, "sub", "file"), []byte("x"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(outside, "file"), []byte("outside the root"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	entries, err := ReadDirBeneath(root, "sub")
+	if err != nil {
+		t.Fatalf("ReadDirBeneath() error: %v", err)
+	}
+	// Replace sub with a link to a directory outside the root before reading the entries.
+	if err := os.Rename(filepath.Join(root, "sub"), filepath.Join(root, "old")); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Symlink(outside, filepath.Join(root, "sub")); err != nil {
+		t.Skipf("os.Symlink() error: %v", err)
+	}
+	fi, err := entries[0].Info()
+	if err != nil {
+		t.Fatalf("Info() error: %v", err)
+	}
+	if fi.Size() != 1 {
+		t.Errorf("Info().Size() = %d, want 1", fi.Size())
+	}
+}
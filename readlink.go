@@ -0,0 +1,47 @@
+// Copyright 2024 Google LLC.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package safeopen
+
+import "path/filepath"
+
+// ReadlinkBeneath returns the target of the named symbolic link in the named directory, or a
+// subdirectory, without resolving it. path may not contain .. path traversal entries, and the
+// directories leading to it are resolved as by OpenBeneath. Use SymlinkTargetBeneath to check
+// whether the target stays beneath the directory. On Windows, junctions are read as symbolic
+// links, and absolute targets are returned as DOS paths, e.g. C:\dir. ReadlinkBeneath is not
+// supported on AIX, DragonFly BSD and Solaris.
+// If there is an error, it will be of type *PathError.
+func ReadlinkBeneath(directory, path string) (string, error) {
+	dfd, err := openDirHandle(directory)
+	if err != nil {
+		return "", err
+	}
+	defer closeHandle(dfd)
+
+	target, err := readlinkIn(dfd, path)
+	if err != nil {
+		return "", routedError("readlink", err, filepath.Join(directory, path))
+	}
+	return target, nil
+}
+
+// SymlinkTargetBeneath reports whether a symbolic link linkname to target, with linkname relative
+// to a directory, points beneath the directory: target must be relative, and resolving it from
+// the directory containing linkname must not escape the directory with .. path traversal
+// entries. This is checked lexically, as by SymlinkBeneath: the symbolic links target goes
+// through, if any, are not accounted for.
+func SymlinkTargetBeneath(linkname, target string) bool {
+	return checkSymlinkTarget(target, linkname, nil) == nil
+}
@@ -0,0 +1,83 @@
+// Copyright 2024 Google LLC.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package safeopen
+
+import (
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+)
+
+func TestReadlinkBeneath(t *testing.T) {
+	switch runtime.GOOS {
+	case "windows", "aix", "dragonfly", "solaris", "illumos":
+		t.Skipf("ReadlinkBeneath is not supported on %s", runtime.GOOS)
+	}
+	dir := t.TempDir()
+	if err := os.Mkdir(filepath.Join(dir, "sub"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "file"), nil, 0644); err != nil {
+		t.Fatal(err)
+	}
+	links := map[string]string{
+		"in":                            "file",
+		filepath.Join("sub", "up"):      "../file",
+		filepath.Join("sub", "out"):     "../../etc/passwd",
+		"abs":                           "/etc/passwd",
+		filepath.Join("sub", "missing"): "nowhere",
+	}
+	for link, target := range links {
+		if err := os.Symlink(target, filepath.Join(dir, link)); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	for link, want := range links {
+		got, err := ReadlinkBeneath(dir, link)
+		if err != nil || got != want {
+			t.Errorf("ReadlinkBeneath(%q) = %q, %v, want %q", link, got, err, want)
+		}
+	}
+	for _, path := range []string{"file", "missing", filepath.Join("..", filepath.Base(dir), "in"), ""} {
+		if got, err := ReadlinkBeneath(dir, path); err == nil {
+			t.Errorf("ReadlinkBeneath(%q) = %q, should have been an error", path, got)
+		}
+	}
+}
+
+func TestSymlinkTargetBeneath(t *testing.T) {
+	for _, tc := range []struct {
+		link, target string
+		want         bool
+	}{
+		{"link", "file", true},
+		{"link", "sub/file", true},
+		{"sub/link", "../file", true},
+		{"sub/link", "./file", true},
+		{"link", ".", true},
+		{"link", "../file", false},
+		{"sub/link", "../../file", false},
+		{"link", "sub/../../file", false},
+		{"link", "/etc/passwd", false},
+		{"link", "", false},
+		{"../link", "file", false},
+	} {
+		if got := SymlinkTargetBeneath(filepath.FromSlash(tc.link), filepath.FromSlash(tc.target)); got != tc.want {
+			t.Errorf("SymlinkTargetBeneath(%q, %q) = %v, want %v", tc.link, tc.target, got, tc.want)
+		}
+	}
+}
@@ -0,0 +1,46 @@
+// Copyright 2024 Google LLC.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+//go:build unix && !aix && !dragonfly && !solaris
+// +build unix,!aix,!dragonfly,!solaris
+
+package safeopen
+
+import (
+	"os"
+
+	"golang.org/x/sys/unix"
+)
+
+// readlinkIn returns the target of the symbolic link file beneath dfd.
+func readlinkIn(dfd handle, file string) (string, error) {
+	pfd, base, err := openParentIn(dfd, file)
+	if err != nil {
+		return "", err
+	}
+	if pfd != dfd {
+		defer unix.Close(pfd)
+	}
+
+	for size := 128; ; size *= 2 {
+		buf := make([]byte, size)
+		n, err := unix.Readlinkat(pfd, base, buf)
+		if err != nil {
+			return "", &os.PathError{Op: "readlink", Path: file, Err: err}
+		}
+		if n < size {
+			return string(buf[:n]), nil
+		}
+	}
+}
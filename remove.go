@@ -0,0 +1,139 @@
+// Copyright 2024 Google LLC.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package safeopen
+
+import (
+	"errors"
+	"io/fs"
+	"os"
+	"path/filepath"
+)
+
+// RemoveAt removes the named file, or empty directory, in the named directory.
+// file may not contain path separators. If file is a symbolic link, the link itself is removed.
+// If there is an error, it will be of type *PathError.
+func RemoveAt(directory, file string) error {
+	if !isFilename(file) {
+		return &os.PathError{Op: "RemoveAt", Path: file, Err: ErrInvalidFilename}
+	}
+	dfd, err := openDirHandle(directory)
+	if err != nil {
+		return err
+	}
+	defer closeHandle(dfd)
+
+	if err := removeIn(dfd, file); err != nil {
+		return &os.PathError{Op: "remove", Path: filepath.Join(directory, file), Err: err}
+	}
+	return nil
+}
+
+// RemoveBeneath removes the named file, or empty directory, in the named directory or a
+// subdirectory. file may not contain .. path traversal entries, and the directories leading to
+// it are resolved as by OpenBeneath. If file is a symbolic link, the link itself is removed.
+// If there is an error, it will be of type *PathError.
+func RemoveBeneath(directory, file string) error {
+	dfd, err := openDirHandle(directory)
+	if err != nil {
+		return err
+	}
+	defer closeHandle(dfd)
+
+	if err := removeBeneathIn(dfd, file); err != nil {
+		return routedError("remove", err, filepath.Join(directory, file))
+	}
+	return nil
+}
+
+// RemoveAllBeneath removes the named file in the named directory or a subdirectory, along with
+// everything it contains if it is a directory. file may not contain .. path traversal entries.
+// If file does not exist, RemoveAllBeneath returns nil.
+//
+// Unlike os.RemoveAll on a joined path, RemoveAllBeneath never resolves a path from the top
+// again: each directory is opened relative to the handle of its parent, without following
+// symbolic links, so replacing a directory with a symbolic link while it is being removed cannot
+// redirect the removal out of the directory. Symbolic links are removed, not followed.
+// If there is an error, it will be of type *PathError.
+func RemoveAllBeneath(directory, file string) error {
+	dfd, err := openDirHandle(directory)
+	if err != nil {
+		return err
+	}
+	defer closeHandle(dfd)
+
+	pfd, base, err := openParentIn(dfd, file)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return routedError("RemoveAll", err, filepath.Join(directory, file))
+	}
+	if pfd != dfd {
+		defer closeHandle(pfd)
+	}
+	if !isFilename(base) {
+		return &os.PathError{Op: "RemoveAllBeneath", Path: file, Err: ErrInvalidFilename}
+	}
+	return removeAllIn(pfd, base, filepath.Join(directory, file))
+}
+
+// removeAllIn removes file directly in the directory referred by dfd, along with its content if
+// it is a directory. Missing files are ignored. Errors refer to path, the name of file to report.
+func removeAllIn(dfd handle, file, path string) error {
+	err := removeIn(dfd, file)
+	if err == nil || errors.Is(err, fs.ErrNotExist) {
+		return nil
+	}
+	// Only directories are worth a second try, once emptied.
+	dir, err1 := openDirNoFollowIn(dfd, "", file)
+	if errors.Is(err1, fs.ErrNotExist) {
+		return nil
+	}
+	if err1 != nil {
+		return &os.PathError{Op: "remove", Path: path, Err: err}
+	}
+	names, err1 := dir.Readdirnames(-1)
+	if err1 != nil {
+		dir.Close()
+		return &os.PathError{Op: "remove", Path: path, Err: err}
+	}
+	for _, name := range names {
+		if err := removeAllIn(handle(dir.Fd()), name, filepath.Join(path, name)); err != nil {
+			dir.Close()
+			return err
+		}
+	}
+	dir.Close()
+	err = removeIn(dfd, file)
+	if err == nil || errors.Is(err, fs.ErrNotExist) {
+		return nil
+	}
+	return &os.PathError{Op: "remove", Path: path, Err: err}
+}
+
+// removeBeneathIn removes file beneath dfd.
+func removeBeneathIn(dfd handle, file string) error {
+	pfd, base, err := openParentIn(dfd, file)
+	if err != nil {
+		return err
+	}
+	if pfd != dfd {
+		defer closeHandle(pfd)
+	}
+	if !isFilename(base) {
+		return &os.PathError{Op: "RemoveBeneath", Path: file, Err: ErrInvalidFilename}
+	}
+	return removeIn(pfd, base)
+}
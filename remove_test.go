@@ -0,0 +1,148 @@
+// Copyright 2024 Google LLC.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package safeopen
+
+import (
+	"errors"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestRemoveAt(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "file"), nil, 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.MkdirAll(filepath.Join(dir, "full", "sub"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Mkdir(filepath.Join(dir, "empty"), 0755); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, file := range []string{"file", "empty"} {
+		if err := RemoveAt(dir, file); err != nil {
+			t.Errorf("RemoveAt(%q) error: %v", file, err)
+		}
+		if _, err := os.Lstat(filepath.Join(dir, file)); !errors.Is(err, fs.ErrNotExist) {
+			t.Errorf("%s still exists after RemoveAt", file)
+		}
+	}
+	if err := RemoveAt(dir, "file"); !errors.Is(err, fs.ErrNotExist) {
+		t.Errorf("RemoveAt(missing) = %v, want ErrNotExist", err)
+	}
+	if err := RemoveAt(dir, "full"); err == nil {
+		t.Error("RemoveAt(full) removed a non-empty directory")
+	}
+	for _, file := range []string{"full/sub", "../file", ".", ""} {
+		if err := RemoveAt(dir, file); err == nil {
+			t.Errorf("RemoveAt(%q) should have been an error", file)
+		}
+	}
+}
+
+func TestRemoveBeneath(t *testing.T) {
+	dir := t.TempDir()
+	outside := t.TempDir()
+	if err := os.MkdirAll(filepath.Join(dir, "a", "b"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	for _, file := range []string{filepath.Join(dir, "a", "b", "file"), filepath.Join(outside, "target")} {
+		if err := os.WriteFile(file, nil, 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if err := RemoveBeneath(dir, filepath.Join("a", "b", "file")); err != nil {
+		t.Errorf("RemoveBeneath(a/b/file) error: %v", err)
+	}
+	if err := RemoveBeneath(dir, filepath.Join("a", "b")); err != nil {
+		t.Errorf("RemoveBeneath(a/b) error: %v", err)
+	}
+	if _, err := os.Lstat(filepath.Join(dir, "a", "b")); !errors.Is(err, fs.ErrNotExist) {
+		t.Error("a/b still exists after RemoveBeneath")
+	}
+	for _, file := range []string{filepath.Join("..", filepath.Base(outside), "target"), ".", ""} {
+		if err := RemoveBeneath(dir, file); err == nil {
+			t.Errorf("RemoveBeneath(%q) should have been an error", file)
+		}
+	}
+	if _, err := os.Lstat(filepath.Join(dir, "a")); err != nil {
+		t.Errorf("a was removed: %v", err)
+	}
+
+	if err := os.Symlink(outside, filepath.Join(dir, "link")); err != nil {
+		t.Skipf("cannot create symbolic links: %v", err)
+	}
+	if err := RemoveBeneath(dir, filepath.Join("link", "target")); err == nil {
+		t.Error("RemoveBeneath(link/target) followed a symbolic link out of the directory")
+	}
+	// The link itself is removed, not its target.
+	if err := RemoveBeneath(dir, "link"); err != nil {
+		t.Errorf("RemoveBeneath(link) error: %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(outside, "target")); err != nil {
+		t.Errorf("target was removed: %v", err)
+	}
+}
+
+func TestRemoveAllBeneath(t *testing.T) {
+	dir := t.TempDir()
+	outside := t.TempDir()
+	if err := os.MkdirAll(filepath.Join(dir, "uploads", "a", "b", "c"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	for _, file := range []string{
+		filepath.Join(dir, "uploads", "top"),
+		filepath.Join(dir, "uploads", "a", "b", "file"),
+		filepath.Join(dir, "keep"),
+		filepath.Join(outside, "target"),
+	} {
+		if err := os.WriteFile(file, nil, 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	symlinks := os.Symlink(outside, filepath.Join(dir, "uploads", "a", "link")) == nil
+
+	if err := RemoveAllBeneath(dir, "uploads"); err != nil {
+		t.Fatalf("RemoveAllBeneath(uploads) error: %v", err)
+	}
+	if _, err := os.Lstat(filepath.Join(dir, "uploads")); !errors.Is(err, fs.ErrNotExist) {
+		t.Error("uploads still exists after RemoveAllBeneath")
+	}
+	if _, err := os.Stat(filepath.Join(dir, "keep")); err != nil {
+		t.Errorf("keep was removed: %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(outside, "target")); symlinks && err != nil {
+		t.Errorf("the target of a symbolic link was removed: %v", err)
+	}
+
+	if err := RemoveAllBeneath(dir, filepath.Join("missing", "file")); err != nil {
+		t.Errorf("RemoveAllBeneath(missing/file) = %v, want nil", err)
+	}
+	if err := RemoveAllBeneath(dir, "keep"); err != nil {
+		t.Errorf("RemoveAllBeneath(keep) error: %v", err)
+	}
+	for _, file := range []string{filepath.Join("..", filepath.Base(outside)), ".", ""} {
+		if err := RemoveAllBeneath(dir, file); err == nil {
+			t.Errorf("RemoveAllBeneath(%q) should have been an error", file)
+		}
+	}
+	if _, err := os.Stat(dir); err != nil {
+		t.Errorf("the directory was removed: %v", err)
+	}
+}
@@ -0,0 +1,104 @@
+// Copyright 2024 Google LLC.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package safeopen
+
+import (
+	"os"
+	"path/filepath"
+)
+
+// RenameBeneath renames (moves) oldpath to newpath, both in the named directory or a
+// subdirectory. Neither may contain .. path traversal entries, and the directories leading to
+// them are resolved as by OpenBeneath. If oldpath is a symbolic link, the link itself is renamed.
+//
+// Unlike os.Rename, RenameBeneath never replaces an existing file: if newpath exists, the returned
+// error satisfies errors.Is(err, fs.ErrExist). This relies on renameat2 with RENAME_NOREPLACE on
+// Linux, and on the native rename on Windows. Elsewhere, and on Linux file systems without
+// RENAME_NOREPLACE, files are hard linked to newpath and then unlinked from oldpath, while
+// directories are renamed after checking that newpath does not exist, which is racy.
+// If there is an error, it will be of type *PathError or, if the rename itself fails, *LinkError.
+func RenameBeneath(directory, oldpath, newpath string) error {
+	dfd, err := openDirHandle(directory)
+	if err != nil {
+		return err
+	}
+	defer closeHandle(dfd)
+
+	return renameBeneathIn(dfd, directory, oldpath, newpath)
+}
+
+// ExchangeAt atomically exchanges the named files a and b in the named directory: each name
+// then refers to the file the other one referred to, and no observer can see either name
+// missing. This allows e.g. rolling over a "current" configuration with a "staging" one. Both
+// files must exist, and they may be of different types, such as a file and a directory. a and b
+// may not contain path separators.
+//
+// ExchangeAt relies on renameat2 with RENAME_EXCHANGE, and is only supported on Linux 3.15 and
+// newer, on file systems supporting it. Elsewhere, the error wraps an error satisfying
+// errors.Is(err, errors.ErrUnsupported).
+// If there is an error, it will be of type *PathError or, if the exchange itself fails,
+// *LinkError.
+func ExchangeAt(directory, a, b string) error {
+	for _, file := range []string{a, b} {
+		if !isFilename(file) {
+			return &os.PathError{Op: "ExchangeAt", Path: file, Err: ErrInvalidFilename}
+		}
+	}
+	dfd, err := openDirHandle(directory)
+	if err != nil {
+		return err
+	}
+	defer closeHandle(dfd)
+
+	if err := exchangeIn(dfd, a, b); err != nil {
+		return &os.LinkError{Op: "exchange", Old: filepath.Join(directory, a), New: filepath.Join(directory, b), Err: err}
+	}
+	return nil
+}
+
+// renameBeneathIn renames oldpath to newpath, both beneath dfd, failing if newpath exists.
+func renameBeneathIn(dfd handle, directory, oldpath, newpath string) error {
+	return inParentsBeneathIn(dfd, directory, oldpath, newpath, "rename", renameNoReplaceIn)
+}
+
+// inParentsBeneathIn calls fn with the directories containing oldpath and newpath beneath dfd
+// and their last elements, which must name files in them. Errors of fn are reported as
+// *LinkError of op.
+func inParentsBeneathIn(dfd handle, directory, oldpath, newpath, op string, fn func(opfd handle, obase string, npfd handle, nbase string) error) error {
+	opfd, obase, err := openParentIn(dfd, oldpath)
+	if err != nil {
+		return routedError(op, err, filepath.Join(directory, oldpath))
+	}
+	if opfd != dfd {
+		defer closeHandle(opfd)
+	}
+	npfd, nbase, err := openParentIn(dfd, newpath)
+	if err != nil {
+		return routedError(op, err, filepath.Join(directory, newpath))
+	}
+	if npfd != dfd {
+		defer closeHandle(npfd)
+	}
+	for _, p := range [][2]string{{oldpath, obase}, {newpath, nbase}} {
+		if !isFilename(p[1]) {
+			return &os.PathError{Op: op, Path: p[0], Err: ErrInvalidFilename}
+		}
+	}
+
+	if err := fn(opfd, obase, npfd, nbase); err != nil {
+		return &os.LinkError{Op: op, Old: filepath.Join(directory, oldpath), New: filepath.Join(directory, newpath), Err: err}
+	}
+	return nil
+}
@@ -0,0 +1,107 @@
+// Copyright 2024 Google LLC.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package safeopen
+
+import (
+	"errors"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+)
+
+func TestRenameBeneath(t *testing.T) {
+	dir := t.TempDir()
+	outside := t.TempDir()
+	if err := os.MkdirAll(filepath.Join(dir, "a", "b"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	for file, content := range map[string]string{"old": "old", "existing": "existing"} {
+		if err := os.WriteFile(filepath.Join(dir, file), []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	moved := filepath.Join("a", "b", "new")
+	if err := RenameBeneath(dir, "old", moved); err != nil {
+		t.Fatalf("RenameBeneath(old, %q) error: %v", moved, err)
+	}
+	if data, err := os.ReadFile(filepath.Join(dir, moved)); err != nil || string(data) != "old" {
+		t.Errorf("%s = %q, %v after RenameBeneath, want %q", moved, data, err, "old")
+	}
+	if _, err := os.Lstat(filepath.Join(dir, "old")); !errors.Is(err, fs.ErrNotExist) {
+		t.Error("old still exists after RenameBeneath")
+	}
+
+	err := RenameBeneath(dir, moved, "existing")
+	var le *os.LinkError
+	if !errors.Is(err, fs.ErrExist) || !errors.As(err, &le) {
+		t.Errorf("RenameBeneath(%q, existing) = %v, want a LinkError wrapping ErrExist", moved, err)
+	}
+	if data, err := os.ReadFile(filepath.Join(dir, "existing")); err != nil || string(data) != "existing" {
+		t.Errorf("existing = %q, %v, want it untouched", data, err)
+	}
+
+	if err := RenameBeneath(dir, filepath.Join("a", "b"), "c"); err != nil {
+		t.Errorf("RenameBeneath(a/b, c) error: %v", err)
+	}
+	if err := RenameBeneath(dir, "c", "a"); !errors.Is(err, fs.ErrExist) {
+		t.Errorf("RenameBeneath(c, a) = %v, want ErrExist", err)
+	}
+
+	escape := filepath.Join("..", filepath.Base(outside), "escape")
+	for _, tc := range [][2]string{{"existing", escape}, {escape, "x"}, {"existing", "."}, {".", "x"}, {"existing", ""}} {
+		if err := RenameBeneath(dir, tc[0], tc[1]); err == nil {
+			t.Errorf("RenameBeneath(%q, %q) should have been an error", tc[0], tc[1])
+		}
+	}
+	if _, err := os.Stat(filepath.Join(dir, "existing")); err != nil {
+		t.Errorf("existing was moved: %v", err)
+	}
+}
+
+func TestExchangeAt(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.Mkdir(filepath.Join(dir, "staging"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "current"), []byte("v1"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := ExchangeAt(dir, "current", "missing"); err == nil {
+		t.Error("ExchangeAt(current, missing) should have been an error")
+	}
+	for _, tc := range [][2]string{{"current", filepath.Join("staging", "x")}, {"..", "current"}, {"current", ""}} {
+		if err := ExchangeAt(dir, tc[0], tc[1]); err == nil {
+			t.Errorf("ExchangeAt(%q, %q) should have been an error", tc[0], tc[1])
+		}
+	}
+
+	err := ExchangeAt(dir, "current", "staging")
+	if runtime.GOOS != "linux" && err != nil {
+		t.Skipf("ExchangeAt is not supported: %v", err)
+	}
+	if err != nil {
+		t.Fatalf("ExchangeAt(current, staging) error: %v", err)
+	}
+	if fi, err := os.Stat(filepath.Join(dir, "current")); err != nil || !fi.IsDir() {
+		t.Errorf("current is not the directory after ExchangeAt: %v", err)
+	}
+	if data, err := os.ReadFile(filepath.Join(dir, "staging")); err != nil || string(data) != "v1" {
+		t.Errorf("staging = %q, %v after ExchangeAt, want %q", data, err, "v1")
+	}
+}
@@ -0,0 +1,68 @@
+// Copyright 2024 Google LLC.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+//go:build unix
+// +build unix
+
+package safeopen
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"golang.org/x/sys/unix"
+)
+
+func TestRenameNoReplaceLegacy(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.Mkdir(filepath.Join(dir, "dir"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	for _, file := range []string{"file", "existing"} {
+		if err := os.WriteFile(filepath.Join(dir, file), nil, 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	dfd, err := openDirHandle(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer closeHandle(dfd)
+
+	for _, tc := range []struct {
+		old, new string
+		want     error
+	}{
+		{"file", "existing", unix.EEXIST},
+		{"dir", "existing", unix.EEXIST},
+		{"missing", "new", unix.ENOENT},
+		{"file", "renamed", nil},
+		{"dir", "renamed-dir", nil},
+	} {
+		if err := renameNoReplaceLegacy(dfd, tc.old, dfd, tc.new); err != tc.want {
+			t.Errorf("renameNoReplaceLegacy(%q, %q) = %v, want %v", tc.old, tc.new, err, tc.want)
+		}
+	}
+	for _, file := range []string{"existing", "renamed", "renamed-dir"} {
+		if _, err := os.Lstat(filepath.Join(dir, file)); err != nil {
+			t.Errorf("%s is missing: %v", file, err)
+		}
+	}
+	for _, file := range []string{"file", "dir"} {
+		if _, err := os.Lstat(filepath.Join(dir, file)); err == nil {
+			t.Errorf("%s still exists", file)
+		}
+	}
+}
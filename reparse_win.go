@@ -0,0 +1,199 @@
+// Copyright 2024 Google LLC.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+//go:build windows
+// +build windows
+
+package safeopen
+
+import (
+	"encoding/binary"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"unicode/utf16"
+	"unsafe"
+
+	"golang.org/x/sys/windows"
+)
+
+// winSymlinkFlagRelative is SYMLINK_FLAG_RELATIVE, set in the reparse data of symbolic links
+// whose target is relative to the directory containing them.
+const winSymlinkFlagRelative = 1
+
+// fileAttributeTagInfo mirrors FILE_ATTRIBUTE_TAG_INFO.
+type fileAttributeTagInfo struct {
+	FileAttributes uint32
+	ReparseTag     uint32
+}
+
+// winReparseTag returns the reparse tag of the file opened as h, or 0 if it is not a reparse
+// point.
+func winReparseTag(h windows.Handle) (uint32, error) {
+	var info fileAttributeTagInfo
+	if err := windows.GetFileInformationByHandleEx(h, windows.FileAttributeTagInfo, (*byte)(unsafe.Pointer(&info)), uint32(unsafe.Sizeof(info))); err != nil {
+		return 0, err
+	}
+	if info.FileAttributes&windows.FILE_ATTRIBUTE_REPARSE_POINT == 0 {
+		return 0, nil
+	}
+	return info.ReparseTag, nil
+}
+
+// winIsLinkTag reports whether tag is the tag of a reparse point redirecting lookups to another
+// path: a symbolic link, or a mount point. Junctions are mount points targeting a directory
+// rather than the root of a volume.
+func winIsLinkTag(tag uint32) bool {
+	return tag == windows.IO_REPARSE_TAG_SYMLINK || tag == windows.IO_REPARSE_TAG_MOUNT_POINT
+}
+
+// winRejectLink returns an error wrapping ErrSymlinkRejected if h, opened without following
+// reparse points, is a symbolic link or a junction. h is closed on error. Other reparse points,
+// e.g. deduplicated or cloud files, are regular files as far as lookups are concerned.
+func winRejectLink(h windows.Handle) error {
+	tag, err := winReparseTag(h)
+	if err == nil && winIsLinkTag(tag) {
+		err = symlinkRejected(windows.ERROR_CANT_RESOLVE_FILENAME)
+	}
+	if err != nil {
+		windows.CloseHandle(h)
+	}
+	return err
+}
+
+// winLstat returns the FileInfo of f, opened as h without following reparse points. Junctions
+// are reported as symbolic links, as they are resolved the same way.
+func winLstat(f *os.File, h windows.Handle) (fs.FileInfo, error) {
+	fi, err := f.Stat()
+	if err != nil || fi.Mode()&fs.ModeSymlink != 0 {
+		return fi, err
+	}
+	if tag, err := winReparseTag(h); err == nil && winIsLinkTag(tag) {
+		return linkFileInfo{fi}, nil
+	}
+	return fi, nil
+}
+
+// linkFileInfo is the FileInfo of a junction, reported as a symbolic link.
+type linkFileInfo struct {
+	fs.FileInfo
+}
+
+func (fi linkFileInfo) Mode() fs.FileMode {
+	return fi.FileInfo.Mode().Perm() | fs.ModeSymlink
+}
+
+func (fi linkFileInfo) IsDir() bool {
+	return false
+}
+
+// readlinkIn returns the target of the symbolic link or junction file beneath dfd. Absolute
+// targets are returned as DOS paths, e.g. C:\dir or \\server\share\dir.
+func readlinkIn(dfd handle, file string) (string, error) {
+	fd, _, err := winOpenNoFollow(dfd, file, windows.FILE_READ_ATTRIBUTES, 0)
+	if err != nil {
+		return "", err
+	}
+	defer windows.CloseHandle(fd)
+
+	buf := make([]byte, windows.MAXIMUM_REPARSE_DATA_BUFFER_SIZE)
+	var n uint32
+	if err := windows.DeviceIoControl(fd, windows.FSCTL_GET_REPARSE_POINT, nil, 0, &buf[0], uint32(len(buf)), &n, nil); err != nil {
+		return "", &os.PathError{Op: "readlink", Path: file, Err: err}
+	}
+	target, err := winParseReparseData(buf[:n])
+	if err != nil {
+		return "", &os.PathError{Op: "readlink", Path: file, Err: err}
+	}
+	return target, nil
+}
+
+// winParseReparseData returns the target held by the REPARSE_DATA_BUFFER b of a symbolic link
+// or a mount point.
+func winParseReparseData(b []byte) (string, error) {
+	if len(b) < 16 {
+		return "", windows.ERROR_INVALID_REPARSE_DATA
+	}
+	le := binary.LittleEndian
+	var pathBuffer int
+	relative := false
+	switch le.Uint32(b) {
+	case windows.IO_REPARSE_TAG_SYMLINK:
+		if len(b) < 20 {
+			return "", windows.ERROR_INVALID_REPARSE_DATA
+		}
+		pathBuffer = 20
+		relative = le.Uint32(b[16:])&winSymlinkFlagRelative != 0
+	case windows.IO_REPARSE_TAG_MOUNT_POINT:
+		pathBuffer = 16
+	default:
+		return "", windows.ERROR_NOT_A_REPARSE_POINT
+	}
+	off, size := pathBuffer+int(le.Uint16(b[8:])), int(le.Uint16(b[10:]))
+	if size%2 != 0 || off+size > len(b) {
+		return "", windows.ERROR_INVALID_REPARSE_DATA
+	}
+	name := make([]uint16, size/2)
+	for i := range name {
+		name[i] = le.Uint16(b[off+2*i:])
+	}
+	target := string(utf16.Decode(name))
+	if relative {
+		return target, nil
+	}
+	return winDOSPath(target), nil
+}
+
+// winDOSPath converts the NT or verbatim path p, e.g. \??\C:\dir or \\?\UNC\server\share, to a
+// DOS path. Paths of volumes without a drive letter are kept verbatim.
+func winDOSPath(p string) string {
+	rest := p
+	switch {
+	case strings.HasPrefix(p, `\??\`), strings.HasPrefix(p, `\\?\`):
+		rest = p[4:]
+	default:
+		return p
+	}
+	switch {
+	case len(rest) >= 2 && rest[1] == ':':
+		return rest
+	case len(rest) >= 4 && strings.EqualFold(rest[:4], `UNC\`):
+		return `\\` + rest[4:]
+	default:
+		return `\\?\` + rest
+	}
+}
+
+// rootRelativeIn maps the absolute target of a link beneath dfd to a path relative to dfd,
+// reporting whether it lies beneath dfd at all. Junctions only hold absolute targets, so this is
+// the only way to follow them. The comparison is case-insensitive, as lookups are by default.
+func rootRelativeIn(dfd handle, target string) (string, bool) {
+	buf := make([]uint16, windows.MAX_LONG_PATH)
+	// Flags 0 are FILE_NAME_NORMALIZED and VOLUME_NAME_DOS, e.g. \\?\C:\dir.
+	n, err := windows.GetFinalPathNameByHandle(dfd, &buf[0], uint32(len(buf)), 0)
+	if err != nil || int(n) >= len(buf) {
+		return "", false
+	}
+	root := strings.TrimRight(winDOSPath(windows.UTF16ToString(buf[:n])), `\`)
+	target = filepath.Clean(winDOSPath(target))
+	if len(target) < len(root) || !strings.EqualFold(target[:len(root)], root) {
+		return "", false
+	}
+	rest := target[len(root):]
+	if rest != "" && rest[0] != '\\' {
+		return "", false
+	}
+	return cleanRelPath("." + rest)
+}
@@ -0,0 +1,110 @@
+// Copyright 2024 Google LLC.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+//go:build windows
+// +build windows
+
+package safeopen
+
+import (
+	"errors"
+	"io/fs"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"testing"
+)
+
+func mklinkJunction(t *testing.T, link, target string) {
+	t.Helper()
+	if out, err := exec.Command("cmd", "/c", "mklink", "/J", link, target).CombinedOutput(); err != nil {
+		t.Skipf("mklink /J error: %v: %s", err, out)
+	}
+}
+
+func TestWinJunctions(t *testing.T) {
+	tmpdir := t.TempDir()
+	root := filepath.Join(tmpdir, "root")
+	if err := os.MkdirAll(filepath.Join(root, "releases", "42"), 0777); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(root, "releases", "42", "data.txt"), []byte("hello"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(tmpdir, "data.txt"), []byte("secret"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	mklinkJunction(t, filepath.Join(root, "current"), filepath.Join(root, "releases", "42"))
+	mklinkJunction(t, filepath.Join(root, "escape"), tmpdir)
+
+	d, err := OpenDir(root)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer d.Close()
+
+	if fi, err := statIn(d.dfd, "current"); err != nil || fi.Mode().Type() != fs.ModeSymlink {
+		t.Errorf("statIn(\"current\") = %v, %v, want a symbolic link", fi, err)
+	}
+	if target, err := readlinkIn(d.dfd, "current"); err != nil || !sameFile(t, target, filepath.Join(root, "releases", "42")) {
+		t.Errorf("readlinkIn(\"current\") = %q, %v, want %q", target, err, filepath.Join(root, "releases", "42"))
+	}
+
+	file := filepath.Join("current", "data.txt")
+	if _, err := d.ReadFile(file); !errors.Is(err, ErrSymlinkRejected) {
+		t.Errorf("ReadFile(%q) error = %v, want %v", file, err, ErrSymlinkRejected)
+	}
+
+	SetSymlinkEmulation(true)
+	defer SetSymlinkEmulation(false)
+	if data, err := d.ReadFile(file); err != nil || string(data) != "hello" {
+		t.Errorf("ReadFile(%q) with emulation = %q, %v, want %q", file, data, err, "hello")
+	}
+	file = filepath.Join("escape", "data.txt")
+	if _, err := d.ReadFile(file); !errors.Is(err, ErrSymlinkRejected) {
+		t.Errorf("ReadFile(%q) with emulation error = %v, want %v", file, err, ErrSymlinkRejected)
+	}
+}
+
+// sameFile reports whether the paths a and b name the same existing file.
+func sameFile(t *testing.T, a, b string) bool {
+	t.Helper()
+	fa, err := os.Stat(a)
+	if err != nil {
+		return false
+	}
+	fb, err := os.Stat(b)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return os.SameFile(fa, fb)
+}
+
+func TestWinDOSPath(t *testing.T) {
+	for _, tc := range []struct {
+		path, want string
+	}{
+		{`\??\C:\data`, `C:\data`},
+		{`\\?\C:\data`, `C:\data`},
+		{`\??\UNC\server\share\dir`, `\\server\share\dir`},
+		{`\\?\UNC\server\share\dir`, `\\server\share\dir`},
+		{`\??\Volume{01234567-89ab-cdef-0123-456789abcdef}\data`, `\\?\Volume{01234567-89ab-cdef-0123-456789abcdef}\data`},
+		{`C:\data`, `C:\data`},
+		{`..\data`, `..\data`},
+	} {
+		if got := winDOSPath(tc.path); got != tc.want {
+			t.Errorf("winDOSPath(%q) = %q, want %q", tc.path, got, tc.want)
+		}
+	}
+}
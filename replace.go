@@ -0,0 +1,135 @@
+// Copyright 2024 Google LLC.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package safeopen
+
+import (
+	"io"
+	"os"
+	"path/filepath"
+)
+
+// ReplaceFileAt reads the named file in the named directory, passes its content to fn and
+// atomically replaces the file with the data returned by fn.
+// file may not contain path separators.
+//
+// The new content is written to a temporary file in the same directory, synced to disk and
+// renamed over the original one, so concurrent readers observe either the old or the new
+// content, never a partially written file. The permission bits of the original file are kept.
+// If fn returns an error, the file is left untouched and the error is returned as is.
+func ReplaceFileAt(directory, file string, fn func(old []byte) ([]byte, error)) error {
+	dfd, err := openDirHandle(directory)
+	if err != nil {
+		return err
+	}
+	defer closeHandle(dfd)
+
+	f, err := openFileAtIn(dfd, directory, file, os.O_RDONLY, 0)
+	if err != nil {
+		return err
+	}
+	fi, err := f.Stat()
+	if err != nil {
+		f.Close()
+		return err
+	}
+	old, err := io.ReadAll(f)
+	f.Close()
+	if err != nil {
+		return err
+	}
+
+	data, err := fn(old)
+	if err != nil {
+		return err
+	}
+	return replaceIn(dfd, directory, file, data, fi.Mode().Perm())
+}
+
+// WriteFileAtomicBeneath writes data to the named file in the named directory, or a
+// subdirectory, replacing it atomically if it exists. file may not contain .. path traversal
+// entries, and the directories leading to it are resolved as by OpenBeneath.
+//
+// As with ReplaceFileAt, the data is written to a temporary file in the same directory, synced
+// to disk and renamed over file, so that after a crash file holds either the old or the new
+// content. The file gets mode perm, regardless of umask.
+func WriteFileAtomicBeneath(directory, file string, data []byte, perm os.FileMode) error {
+	dfd, err := openDirHandle(directory)
+	if err != nil {
+		return err
+	}
+	defer closeHandle(dfd)
+
+	pfd, base, err := openParentIn(dfd, file)
+	if err != nil {
+		return routedError("open", err, filepath.Join(directory, file))
+	}
+	if pfd != dfd {
+		defer closeHandle(pfd)
+	}
+	if !isFilename(base) {
+		return &os.PathError{Op: "WriteFileAtomicBeneath", Path: file, Err: ErrInvalidFilename}
+	}
+	return replaceIn(pfd, filepath.Dir(filepath.Join(directory, file)), base, data, perm)
+}
+
+// createTempIn creates a new file with a random name starting with prefix directly in the
+// directory referred by dfd. The caller is responsible for removing the file.
+func createTempIn(dfd handle, directory, prefix string, perm os.FileMode) (*os.File, string, error) {
+	return createRandomIn(dfd, directory, prefix, "", perm)
+}
+
+// writeTempIn writes data to a new temporary file created next to file, in the directory
+// referred by dfd, and returns its name. The data is synced to disk before returning.
+func writeTempIn(dfd handle, directory, file string, data []byte, perm os.FileMode) (string, error) {
+	f, tmp, err := createTempIn(dfd, directory, "."+file+".tmp", perm)
+	if err != nil {
+		return "", err
+	}
+	// Chmod explicitly, as the mode passed at creation time is subject to umask.
+	err = f.Chmod(perm)
+	if err == nil {
+		_, err = f.Write(data)
+	}
+	if err == nil {
+		err = f.Sync()
+	}
+	if err1 := f.Close(); err1 != nil && err == nil {
+		err = err1
+	}
+	if err != nil {
+		removeIn(dfd, tmp)
+		return "", err
+	}
+	return tmp, nil
+}
+
+// replaceIn atomically replaces file directly in the directory referred by dfd with data.
+func replaceIn(dfd handle, directory, file string, data []byte, perm os.FileMode) error {
+	tmp, err := writeTempIn(dfd, directory, file, data, perm)
+	if err != nil {
+		return err
+	}
+	return commitTempIn(dfd, tmp, file)
+}
+
+// commitTempIn renames the temporary file tmp over file, directly in the directory referred by
+// dfd, and syncs the directory so that the rename survives a crash. tmp is removed on failure.
+func commitTempIn(dfd handle, tmp, file string) error {
+	if err := renameIn(dfd, tmp, file); err != nil {
+		removeIn(dfd, tmp)
+		return err
+	}
+	return syncHandle(dfd)
+}
@@ -0,0 +1,108 @@
+// Copyright 2024 Google LLC.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package safeopen
+
+import (
+	"bytes"
+	"errors"
+	"os"
+	"path"
+	"testing"
+)
+
+func TestReplaceFileAt(t *testing.T) {
+	filename := "something.txt"
+
+	tmpDir := t.TempDir()
+	if err := WriteFileAt(tmpDir, filename, []byte("content"), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	err := ReplaceFileAt(tmpDir, filename, func(old []byte) ([]byte, error) {
+		return bytes.ToUpper(old), nil
+	})
+	if err != nil {
+		t.Fatalf("ReplaceFileAt(%q, %q) error: %v", tmpDir, filename, err)
+	}
+
+	adata, err := os.ReadFile(path.Join(tmpDir, filename))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(adata) != "CONTENT" {
+		t.Errorf("ReplaceFileAt(%q, %q) wrote %q, want %q", tmpDir, filename, adata, "CONTENT")
+	}
+
+	entries, err := os.ReadDir(tmpDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 1 {
+		t.Errorf("ReplaceFileAt(%q, %q) left %d entries behind, want 1", tmpDir, filename, len(entries))
+	}
+
+	errFn := errors.New("transform failed")
+	err = ReplaceFileAt(tmpDir, filename, func(old []byte) ([]byte, error) {
+		return nil, errFn
+	})
+	if err != errFn {
+		t.Errorf("ReplaceFileAt(%q, %q) = %v, want %v", tmpDir, filename, err, errFn)
+	}
+	adata, err = os.ReadFile(path.Join(tmpDir, filename))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(adata) != "CONTENT" {
+		t.Errorf("failed ReplaceFileAt(%q, %q) modified the file to %q", tmpDir, filename, adata)
+	}
+
+	if err = os.Mkdir(path.Join(tmpDir, "subdir"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	filenameInSubdir := path.Join("subdir", filename)
+	err = ReplaceFileAt(tmpDir, filenameInSubdir, func(old []byte) ([]byte, error) {
+		return old, nil
+	})
+	if err == nil {
+		t.Errorf("ReplaceFileAt(%q, %q) should have been an error", tmpDir, filenameInSubdir)
+	}
+}
+
+func TestWriteFileAtomicBeneath(t *testing.T) {
+	tmpDir := t.TempDir()
+	if err := os.Mkdir(path.Join(tmpDir, "conf"), 0755); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, content := range []string{"first", "second"} {
+		if err := WriteFileAtomicBeneath(tmpDir, "conf/app.conf", []byte(content), 0600); err != nil {
+			t.Fatalf("WriteFileAtomicBeneath(%q) error: %v", content, err)
+		}
+		data, err := os.ReadFile(path.Join(tmpDir, "conf", "app.conf"))
+		if err != nil || string(data) != content {
+			t.Errorf("conf/app.conf = %q, %v, want %q", data, err, content)
+		}
+	}
+	entries, err := os.ReadDir(path.Join(tmpDir, "conf"))
+	if err != nil || len(entries) != 1 {
+		t.Errorf("conf holds %v, %v, want only app.conf", entries, err)
+	}
+
+	for _, file := range []string{"../app.conf", "missing/app.conf", "conf/"} {
+		if err := WriteFileAtomicBeneath(tmpDir, file, []byte("data"), 0600); err == nil {
+			t.Errorf("WriteFileAtomicBeneath(%q) succeeded, want error", file)
+		}
+	}
+}
@@ -0,0 +1,66 @@
+// Copyright 2024 Google LLC.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package safeopen
+
+import (
+	"strings"
+	"sync/atomic"
+)
+
+var allowDeviceNames atomic.Bool
+
+// SetAllowDeviceNames allows or refuses, for the whole program, the names reserved for devices
+// on Windows: CON, PRN, AUX, NUL, COM1 to COM9 and LPT1 to LPT9, in any case and with or without
+// an extension, e.g. nul.txt. By default, the functions of this package reject paths with such
+// an element on Windows, with an error wrapping ErrInvalidFilename. Files are opened relative to
+// a directory handle with the native API, where these names are ordinary file names, but other
+// programs given the full path of such a file would open the device instead.
+//
+// Allowing them is meant for programs accessing these files through the native API only.
+// SetAllowDeviceNames has no effect on other platforms.
+func SetAllowDeviceNames(allow bool) {
+	allowDeviceNames.Store(allow)
+}
+
+// hasDeviceName reports whether an element of the Windows path path is a name reserved for a
+// device, unless they are allowed with SetAllowDeviceNames.
+func hasDeviceName(path string) bool {
+	if allowDeviceNames.Load() {
+		return false
+	}
+	for _, elem := range winPathElems(path) {
+		if isReservedPortableName(elem) {
+			return true
+		}
+	}
+	return false
+}
+
+// hasTrailingDotOrSpace reports whether an element of the Windows path path, other than . and
+// .., ends with a dot or a space. Win32 path handling strips them, so that such a file could
+// not be opened by other programs, or another file would be opened instead.
+func hasTrailingDotOrSpace(path string) bool {
+	for _, elem := range winPathElems(path) {
+		if elem != "." && elem != ".." && (strings.HasSuffix(elem, ".") || strings.HasSuffix(elem, " ")) {
+			return true
+		}
+	}
+	return false
+}
+
+// winPathElems returns the non-empty elements of the Windows path path.
+func winPathElems(path string) []string {
+	return strings.FieldsFunc(path, func(r rune) bool { return r == '/' || r == '\\' })
+}
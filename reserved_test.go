@@ -0,0 +1,120 @@
+// Copyright 2024 Google LLC.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package safeopen
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+)
+
+func TestHasDeviceName(t *testing.T) {
+	for _, tc := range []struct {
+		path string
+		want bool
+	}{
+		{"CON", true},
+		{"nul.txt", true},
+		{"Com1.tar.gz", true},
+		{"LPT9", true},
+		{"AUX ", true},
+		{"conin$", true},
+		{"logs/prn", true},
+		{`logs\prn.log`, true},
+		{"console", false},
+		{"COM10", false},
+		{"LPT", false},
+		{"logs/nullable.txt", false},
+	} {
+		if got := hasDeviceName(tc.path); got != tc.want {
+			t.Errorf("hasDeviceName(%q) = %v, want %v", tc.path, got, tc.want)
+		}
+	}
+
+	SetAllowDeviceNames(true)
+	defer SetAllowDeviceNames(false)
+	if hasDeviceName("CON") {
+		t.Error("hasDeviceName(\"CON\") = true with SetAllowDeviceNames(true)")
+	}
+}
+
+func TestDeviceNames(t *testing.T) {
+	if runtime.GOOS != "windows" {
+		t.Skip("device names are only reserved on Windows")
+	}
+	tmpDir := t.TempDir()
+	if err := os.Mkdir(filepath.Join(tmpDir, "logs"), 0755); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, file := range []string{"nul.txt", filepath.Join("logs", "COM1")} {
+		if err := WriteFileBeneath(tmpDir, file, []byte("data"), 0644); !errors.Is(err, ErrInvalidFilename) {
+			t.Errorf("WriteFileBeneath(%q) error = %v, want %v", file, err, ErrInvalidFilename)
+		}
+	}
+	if err := WriteFileAt(tmpDir, "aux", []byte("data"), 0644); !errors.Is(err, ErrInvalidFilename) {
+		t.Errorf("WriteFileAt(%q) error = %v, want %v", "aux", err, ErrInvalidFilename)
+	}
+
+	SetAllowDeviceNames(true)
+	defer SetAllowDeviceNames(false)
+	if err := WriteFileAt(tmpDir, "aux", []byte("data"), 0644); err != nil {
+		t.Errorf("WriteFileAt(%q) with device names allowed error: %v", "aux", err)
+	}
+}
+
+func TestHasTrailingDotOrSpace(t *testing.T) {
+	for _, tc := range []struct {
+		path string
+		want bool
+	}{
+		{"data.", true},
+		{"data ", true},
+		{"logs./data", true},
+		{`logs \data`, true},
+		{"data.txt", false},
+		{"./data", false},
+		{"logs/../data", false},
+		{".hidden", false},
+	} {
+		if got := hasTrailingDotOrSpace(tc.path); got != tc.want {
+			t.Errorf("hasTrailingDotOrSpace(%q) = %v, want %v", tc.path, got, tc.want)
+		}
+	}
+}
+
+func TestTrailingDotsAndSpaces(t *testing.T) {
+	if runtime.GOOS != "windows" {
+		t.Skip("trailing dots and spaces are only stripped on Windows")
+	}
+	tmpDir := t.TempDir()
+	if err := os.Mkdir(filepath.Join(tmpDir, "logs"), 0755); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, file := range []string{"data.", "data ", filepath.Join("logs.", "data")} {
+		if err := WriteFileBeneath(tmpDir, file, []byte("data"), 0644); !errors.Is(err, ErrInvalidFilename) {
+			t.Errorf("WriteFileBeneath(%q) error = %v, want %v", file, err, ErrInvalidFilename)
+		}
+		if err := WriteFileAt(tmpDir, file, []byte("data"), 0644); !errors.Is(err, ErrInvalidFilename) {
+			t.Errorf("WriteFileAt(%q) error = %v, want %v", file, err, ErrInvalidFilename)
+		}
+	}
+	if _, err := os.Stat(filepath.Join(tmpDir, "data")); !errors.Is(err, os.ErrNotExist) {
+		t.Errorf("data was created: %v", err)
+	}
+}
@@ -0,0 +1,102 @@
+// Copyright 2024 Google LLC.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package safeopen
+
+import (
+	"io/fs"
+	"sort"
+	"time"
+)
+
+// Retention limits the files kept beneath a Dir. The zero value of each field means no limit.
+type Retention struct {
+	// MaxFiles is the maximum number of regular files.
+	MaxFiles int
+	// MaxBytes is the maximum total size of the regular files.
+	MaxBytes int64
+	// MaxAge is the maximum time since the last modification of a file.
+	MaxAge time.Duration
+}
+
+// WithRetention makes d enforce r each time a file is created through d: the files older than
+// r.MaxAge are removed, and then the least recently modified files until the others fit in
+// r.MaxFiles and r.MaxBytes. The file just created is never removed. Only regular files are
+// considered, in the whole tree rooted at d; directories are left in place, even if empty.
+//
+// This gives cache directories a self-limiting behavior. As each creation walks the tree, it is
+// meant for directories of moderate size. The policy is enforced on a best effort basis:
+// failures are ignored, and creations concurrent with an enforcement do not start another one.
+func WithRetention(r Retention) Option {
+	return func(o *options) {
+		o.retention = &r
+	}
+}
+
+// EnforceRetention enforces the retention policy of d right away, as done after each creation,
+// but reporting failures. It is a no-op unless d was opened with WithRetention.
+func (d *Dir) EnforceRetention() error {
+	if d.opts.retention == nil {
+		return nil
+	}
+	d.retentionMu.Lock()
+	defer d.retentionMu.Unlock()
+	return d.enforceRetention("")
+}
+
+// enforceRetention removes the files beyond the retention policy of d, except keep.
+func (d *Dir) enforceRetention(keep string) error {
+	type entry struct {
+		file    string
+		size    int64
+		modTime time.Time
+	}
+	var entries []entry
+	var total int64
+	err := walkIn(d.dfd, ".", func(file string, fi fs.FileInfo) error {
+		if fi.Mode().IsRegular() {
+			entries = append(entries, entry{file, fi.Size(), fi.ModTime()})
+			total += fi.Size()
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	// Most recently modified first.
+	sort.Slice(entries, func(i, j int) bool { return entries[i].modTime.After(entries[j].modTime) })
+
+	r := d.opts.retention
+	cutoff := time.Now().Add(-r.MaxAge)
+	n := len(entries)
+	for i := len(entries) - 1; i >= 0; i-- {
+		e := entries[i]
+		expired := r.MaxAge > 0 && e.modTime.Before(cutoff)
+		if !expired && (r.MaxFiles <= 0 || n <= r.MaxFiles) && (r.MaxBytes <= 0 || total <= r.MaxBytes) {
+			break
+		}
+		if cacheKey(e.file) == cacheKey(keep) {
+			continue
+		}
+		if err := removeBeneathIn(d.dfd, e.file); err != nil {
+			return err
+		}
+		d.forget(e.file)
+		d.quota.release()
+		d.acct.remove()
+		n--
+		total -= e.size
+	}
+	return nil
+}
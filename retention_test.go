@@ -0,0 +1,103 @@
+// Copyright 2024 Google LLC.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package safeopen
+
+import (
+	"os"
+	"path/filepath"
+	"sort"
+	"testing"
+	"time"
+)
+
+func TestRetention(t *testing.T) {
+	tmpDir := t.TempDir()
+	if err := os.Mkdir(filepath.Join(tmpDir, "subdir"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	now := time.Now()
+	files := []struct {
+		file string
+		age  time.Duration
+	}{
+		{"ancient.txt", 48 * time.Hour},
+		{filepath.Join("subdir", "old.txt"), 3 * time.Hour},
+		{"older.txt", 2 * time.Hour},
+		{"recent.txt", time.Hour},
+	}
+	for _, f := range files {
+		full := filepath.Join(tmpDir, f.file)
+		if err := os.WriteFile(full, []byte("0123456789"), 0644); err != nil {
+			t.Fatal(err)
+		}
+		mtime := now.Add(-f.age)
+		if err := os.Chtimes(full, mtime, mtime); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	d, err := OpenDir(tmpDir, WithRetention(Retention{MaxFiles: 3, MaxBytes: 1000, MaxAge: 24 * time.Hour}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer d.Close()
+
+	if err := d.WriteFile("new.txt", []byte("0123456789"), 0644); err != nil {
+		t.Fatalf("WriteFile(%q) error: %v", "new.txt", err)
+	}
+	want := []string{"new.txt", "older.txt", "recent.txt"}
+	if got := listFiles(t, tmpDir); !equalStrings(got, want) {
+		t.Errorf("files after WriteFile(%q) = %q, want %q", "new.txt", got, want)
+	}
+
+	d.opts.retention.MaxBytes = 15
+	if err := d.EnforceRetention(); err != nil {
+		t.Fatalf("EnforceRetention() error: %v", err)
+	}
+	want = []string{"new.txt"}
+	if got := listFiles(t, tmpDir); !equalStrings(got, want) {
+		t.Errorf("files after EnforceRetention() = %q, want %q", got, want)
+	}
+}
+
+// listFiles returns the regular files in the tree rooted at dir, sorted.
+func listFiles(t *testing.T, dir string) []string {
+	t.Helper()
+	var files []string
+	err := filepath.WalkDir(dir, func(path string, e os.DirEntry, err error) error {
+		if err == nil && e.Type().IsRegular() {
+			rel, _ := filepath.Rel(dir, path)
+			files = append(files, rel)
+		}
+		return err
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	sort.Strings(files)
+	return files
+}
+
+func equalStrings(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,40 @@
+// Copyright 2024 Google LLC.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package safeopen
+
+import (
+	"sync/atomic"
+	"time"
+)
+
+const (
+	minOpenRetryBackoff = time.Millisecond
+	maxOpenRetryBackoff = 100 * time.Millisecond
+)
+
+// openRetryMax is the maximum time spent retrying an open failing with EMFILE or ENFILE.
+var openRetryMax atomic.Int64
+
+// SetOpenRetry makes the functions of this package retry the opens failing because the process
+// (EMFILE) or the system (ENFILE) is out of file descriptors, with an exponential backoff from
+// 1ms to 100ms between attempts, until max has been spent waiting. Transient descriptor
+// pressure, e.g. during a burst of requests, then slows callers down instead of failing them.
+// A max of 0, the default, disables these retries.
+//
+// Opens interrupted by signals (EINTR) are always retried, regardless of SetOpenRetry.
+// SetOpenRetry has no effect on Windows.
+func SetOpenRetry(max time.Duration) {
+	openRetryMax.Store(int64(max))
+}
@@ -0,0 +1,64 @@
+// Copyright 2024 Google LLC.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+//go:build unix
+// +build unix
+
+package safeopen
+
+import (
+	"testing"
+	"time"
+
+	"golang.org/x/sys/unix"
+)
+
+func TestOpenRetry(t *testing.T) {
+	failing := func(errs ...error) func() (int, error) {
+		return func() (int, error) {
+			if len(errs) == 0 {
+				return 42, nil
+			}
+			err := errs[0]
+			errs = errs[1:]
+			return -1, err
+		}
+	}
+
+	if fd, err := openRetry(failing(unix.EINTR, unix.EINTR)); err != nil || fd != 42 {
+		t.Errorf("openRetry() with EINTR = %d, %v, want 42, nil", fd, err)
+	}
+	if _, err := openRetry(failing(unix.EMFILE)); err != unix.EMFILE {
+		t.Errorf("openRetry() with EMFILE and no retries error = %v, want EMFILE", err)
+	}
+
+	SetOpenRetry(time.Second)
+	defer SetOpenRetry(0)
+	if fd, err := openRetry(failing(unix.EMFILE, unix.ENFILE, unix.EINTR)); err != nil || fd != 42 {
+		t.Errorf("openRetry() with EMFILE and ENFILE = %d, %v, want 42, nil", fd, err)
+	}
+
+	SetOpenRetry(5 * time.Millisecond)
+	errs := make([]error, 100)
+	for i := range errs {
+		errs[i] = unix.EMFILE
+	}
+	start := time.Now()
+	if _, err := openRetry(failing(errs...)); err != unix.EMFILE {
+		t.Errorf("openRetry() with persistent EMFILE error = %v, want EMFILE", err)
+	}
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("openRetry() with persistent EMFILE took %v, want about 5ms", elapsed)
+	}
+}
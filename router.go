@@ -0,0 +1,144 @@
+// Copyright 2024 Google LLC.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package safeopen
+
+import (
+	"errors"
+	"io/fs"
+	"os"
+	"sort"
+	"strings"
+)
+
+// Router maps virtual path prefixes to Dirs, e.g. "static" to one directory and "uploads" to
+// another, so that servers with several base directories resolve all their paths through a
+// single entry point. Each path is routed to the Dir of its longest matching prefix and looked
+// up beneath it with the rules of that Dir.
+//
+// Router implements fs.FS. Its methods are safe for concurrent use, as long as the Dirs are not
+// closed.
+type Router struct {
+	// routes is sorted by decreasing prefix length, so that the first match is the longest.
+	routes []route
+}
+
+type route struct {
+	prefix string
+	dir    *Dir
+}
+
+// NewRouter returns a Router serving the paths beneath each prefix of routes from the
+// associated Dir. Prefixes are slash-separated, and leading and trailing slashes are ignored;
+// the empty prefix (or "/") matches all paths, making its Dir the default one. Paths that no
+// prefix matches do not exist.
+func NewRouter(routes map[string]*Dir) (*Router, error) {
+	r := &Router{}
+	seen := map[string]bool{}
+	for prefix, d := range routes {
+		p := strings.Trim(prefix, "/")
+		if p == "" {
+			p = "."
+		}
+		if !fs.ValidPath(p) {
+			return nil, &os.PathError{Op: "NewRouter", Path: prefix, Err: errors.New("invalid prefix")}
+		}
+		if seen[p] {
+			return nil, &os.PathError{Op: "NewRouter", Path: prefix, Err: errors.New("duplicate prefix")}
+		}
+		seen[p] = true
+		r.routes = append(r.routes, route{p, d})
+	}
+	sort.Slice(r.routes, func(i, j int) bool {
+		if len(r.routes[i].prefix) != len(r.routes[j].prefix) {
+			return len(r.routes[i].prefix) > len(r.routes[j].prefix)
+		}
+		return r.routes[i].prefix < r.routes[j].prefix
+	})
+	return r, nil
+}
+
+// Route returns the Dir serving name, along with the path of name beneath it.
+// A leading slash in name is ignored; name must otherwise be a valid fs.FS path.
+func (r *Router) Route(name string) (*Dir, string, error) {
+	p := strings.TrimPrefix(name, "/")
+	if p == "" {
+		p = "."
+	}
+	if !fs.ValidPath(p) {
+		return nil, "", &os.PathError{Op: "route", Path: name, Err: fs.ErrInvalid}
+	}
+	for _, rt := range r.routes {
+		switch {
+		case rt.prefix == ".":
+			return rt.dir, p, nil
+		case p == rt.prefix:
+			return rt.dir, ".", nil
+		case strings.HasPrefix(p, rt.prefix) && p[len(rt.prefix)] == '/':
+			return rt.dir, p[len(rt.prefix)+1:], nil
+		}
+	}
+	return nil, "", &os.PathError{Op: "route", Path: name, Err: fs.ErrNotExist}
+}
+
+// Open implements fs.FS, opening the named file for reading.
+func (r *Router) Open(name string) (fs.File, error) {
+	if !fs.ValidPath(name) {
+		return nil, &fs.PathError{Op: "open", Path: name, Err: fs.ErrInvalid}
+	}
+	return r.OpenFile(name, os.O_RDONLY, 0)
+}
+
+// OpenFile opens the named file with the Dir it is routed to, in the same way as
+// OpenFileBeneath. Errors refer to name rather than to the path beneath the Dir.
+func (r *Router) OpenFile(name string, flag int, perm os.FileMode) (*os.File, error) {
+	d, rest, err := r.Route(name)
+	if err != nil {
+		return nil, err
+	}
+	f, err := d.OpenFile(rest, flag, perm)
+	return f, routedError("open", err, name)
+}
+
+// ReadFile reads the named file with the Dir it is routed to.
+func (r *Router) ReadFile(name string) ([]byte, error) {
+	d, rest, err := r.Route(name)
+	if err != nil {
+		return nil, err
+	}
+	data, err := d.ReadFile(rest)
+	return data, routedError("read", err, name)
+}
+
+// Stat returns a FileInfo describing the named file. Symbolic links are followed.
+func (r *Router) Stat(name string) (fs.FileInfo, error) {
+	f, err := r.OpenFile(name, os.O_RDONLY, 0)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return f.Stat()
+}
+
+// routedError returns err as a *PathError for name, the path the Router reports errors for.
+func routedError(op string, err error, name string) error {
+	if err == nil {
+		return nil
+	}
+	var pe *os.PathError
+	if errors.As(err, &pe) {
+		return &os.PathError{Op: pe.Op, Path: name, Err: pe.Err}
+	}
+	return &os.PathError{Op: op, Path: name, Err: err}
+}
@@ -0,0 +1,93 @@
+// Copyright 2024 Google LLC.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package safeopen
+
+import (
+	"errors"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestRouter(t *testing.T) {
+	static, uploads, images := t.TempDir(), t.TempDir(), t.TempDir()
+	for dir, file := range map[string]string{static: "index.html", uploads: "report.pdf", images: "logo.png"} {
+		if err := os.WriteFile(filepath.Join(dir, file), []byte(file), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	dirs := map[string]*Dir{}
+	for prefix, dir := range map[string]string{"/static": static, "uploads/": uploads, "static/images": images} {
+		d, err := OpenDir(dir)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer d.Close()
+		dirs[prefix] = d
+	}
+	r, err := NewRouter(dirs)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for name, want := range map[string]string{
+		"static/index.html":            "index.html",
+		"/uploads/report.pdf":          "report.pdf",
+		"static/images/logo.png":       "logo.png",
+		"static/images/../../etc":      "",
+		"staticx/index.html":           "",
+		"uploads/../static/index.html": "",
+		"other/report.pdf":             "",
+	} {
+		data, err := r.ReadFile(name)
+		if want == "" {
+			if err == nil {
+				t.Errorf("ReadFile(%q) succeeded, want error", name)
+			}
+			continue
+		}
+		if err != nil || string(data) != want {
+			t.Errorf("ReadFile(%q) = %q, %v, want %q, nil", name, data, err, want)
+		}
+	}
+
+	f, err := r.Open("static/index.html")
+	if err != nil {
+		t.Fatalf("Open() error: %v", err)
+	}
+	data, err := io.ReadAll(f)
+	f.Close()
+	if err != nil || string(data) != "index.html" {
+		t.Errorf("content of Open(%q) = %q, %v, want %q, nil", "static/index.html", data, err, "index.html")
+	}
+	if _, err := r.Open("/static/index.html"); !errors.Is(err, fs.ErrInvalid) {
+		t.Errorf("Open(%q) error = %v, want fs.ErrInvalid", "/static/index.html", err)
+	}
+
+	var pe *os.PathError
+	if _, err := r.Stat("uploads/missing"); !errors.As(err, &pe) || pe.Path != "uploads/missing" || !errors.Is(err, fs.ErrNotExist) {
+		t.Errorf("Stat(%q) error = %v, want fs.ErrNotExist for the routed path", "uploads/missing", err)
+	}
+	entries, err := fs.ReadDir(r, "uploads")
+	if err != nil || len(entries) != 1 || entries[0].Name() != "report.pdf" {
+		t.Errorf("ReadDir(%q) = %v, %v, want [report.pdf]", "uploads", entries, err)
+	}
+
+	if _, err := NewRouter(map[string]*Dir{"static": dirs["/static"], "/static/": dirs["/static"]}); err == nil {
+		t.Errorf("NewRouter() with duplicate prefixes succeeded, want error")
+	}
+}
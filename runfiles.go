@@ -0,0 +1,67 @@
+// Copyright 2024 Google LLC.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package safeopen
+
+import (
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// OpenRunfilesDir opens the runfiles directory of the running Bazel-built binary as a read-only
+// Dir, so that data dependencies are accessed with the protection of Dir. The paths beneath it
+// are runfiles paths, e.g. "_main/data/file.txt" or "my_workspace/data/file.txt".
+//
+// The directory is located from, in order: the RUNFILES_DIR environment variable; the
+// RUNFILES_MANIFEST_FILE environment variable, if the manifest is next to the directory; the
+// TEST_SRCDIR environment variable set by bazel test; and the <executable>.runfiles directory.
+// Runfiles that only exist in a manifest, as usual on Windows, are not supported.
+// opts are applied in addition to WithReadOnly.
+func OpenRunfilesDir(opts ...Option) (*Dir, error) {
+	dir, err := findRunfilesDir()
+	if err != nil {
+		return nil, err
+	}
+	return OpenDir(dir, append(opts[:len(opts):len(opts)], WithReadOnly())...)
+}
+
+// findRunfilesDir returns the path of the runfiles directory of the running binary.
+func findRunfilesDir() (string, error) {
+	if dir := os.Getenv("RUNFILES_DIR"); dir != "" {
+		return dir, nil
+	}
+	var candidates []string
+	if manifest := os.Getenv("RUNFILES_MANIFEST_FILE"); manifest != "" {
+		// The manifest is either <dir>/MANIFEST or <dir>_manifest.
+		if filepath.Base(manifest) == "MANIFEST" {
+			candidates = append(candidates, filepath.Dir(manifest))
+		} else if dir := strings.TrimSuffix(manifest, "_manifest"); dir != manifest {
+			candidates = append(candidates, dir)
+		}
+	}
+	if dir := os.Getenv("TEST_SRCDIR"); dir != "" {
+		candidates = append(candidates, dir)
+	}
+	if exe, err := os.Executable(); err == nil {
+		candidates = append(candidates, exe+".runfiles")
+	}
+	for _, dir := range candidates {
+		if fi, err := os.Stat(dir); err == nil && fi.IsDir() {
+			return dir, nil
+		}
+	}
+	return "", &os.PathError{Op: "OpenRunfilesDir", Path: "runfiles", Err: fs.ErrNotExist}
+}
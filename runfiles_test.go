@@ -0,0 +1,57 @@
+// Copyright 2024 Google LLC.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package safeopen
+
+import (
+	"errors"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestOpenRunfilesDir(t *testing.T) {
+	runfiles := filepath.Join(t.TempDir(), "app.runfiles")
+	if err := os.MkdirAll(filepath.Join(runfiles, "_main", "data"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	file := filepath.Join("_main", "data", "file.txt")
+	if err := os.WriteFile(filepath.Join(runfiles, file), []byte("data"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, env := range []map[string]string{
+		{"RUNFILES_DIR": runfiles},
+		{"RUNFILES_MANIFEST_FILE": filepath.Join(runfiles, "MANIFEST")},
+		{"RUNFILES_MANIFEST_FILE": runfiles + "_manifest"},
+		{"TEST_SRCDIR": runfiles},
+	} {
+		for _, name := range []string{"RUNFILES_DIR", "RUNFILES_MANIFEST_FILE", "TEST_SRCDIR"} {
+			t.Setenv(name, env[name])
+		}
+		d, err := OpenRunfilesDir()
+		if err != nil {
+			t.Errorf("OpenRunfilesDir() with %v error: %v", env, err)
+			continue
+		}
+		if data, err := d.ReadFile(file); err != nil || string(data) != "data" {
+			t.Errorf("ReadFile(%q) with %v = %q, %v, want %q, nil", file, env, data, err, "data")
+		}
+		if err := d.WriteFile(file, []byte("new"), 0644); !errors.Is(err, fs.ErrPermission) {
+			t.Errorf("WriteFile(%q) with %v error = %v, want fs.ErrPermission", file, env, err)
+		}
+		d.Close()
+	}
+}
@@ -22,6 +22,8 @@ package safeopen
 import (
 	"io"
 	"os"
+	"strings"
+	"sync"
 )
 
 // OpenAt opens the named file in the named directory for reading.
@@ -47,6 +49,18 @@ func CreateAt(directory, file string) (*os.File, error) {
 	return OpenFileAt(directory, file, os.O_RDWR|os.O_CREATE|os.O_TRUNC, 0666)
 }
 
+// CreateExclusiveAt creates the named file in the named directory, failing if it already exists.
+// file may not contain path separators.
+//
+// The file is opened with O_CREATE|O_EXCL (FILE_CREATE on Windows), so that when several callers
+// race to create the same file, e.g. to claim a lease, exactly one of them succeeds. If the file
+// already exists, the returned error satisfies errors.Is(err, fs.ErrExist). Otherwise, the file
+// is created with mode 0666 (before umask), and the associated file descriptor has mode O_RDWR.
+// If there is an error, it will be of type *PathError.
+func CreateExclusiveAt(directory, file string) (*os.File, error) {
+	return OpenFileAt(directory, file, os.O_RDWR|os.O_CREATE|os.O_EXCL, 0666)
+}
+
 // OpenFileAt is the generalized OpenAt call; most users will use OpenAt
 // or CreateAt instead.
 //
@@ -56,7 +70,14 @@ func CreateAt(directory, file string) (*os.File, error) {
 // If successful, methods on the returned File can be used for I/O.
 // If there is an error, it will be of type *PathError.
 func OpenFileAt(directory, file string, flag int, perm os.FileMode) (*os.File, error) {
-	return openFileAt(directory, file, flag, perm)
+	f, err := openFileAt(directory, file, flag, perm)
+	if err != nil {
+		return nil, err
+	}
+	if err := checkConfiguredRegular(f, file); err != nil {
+		return nil, err
+	}
+	return f, nil
 }
 
 // OpenBeneath opens the named file in the named directory, or a subdirectory, for reading.
@@ -82,6 +103,14 @@ func CreateBeneath(directory, file string) (*os.File, error) {
 	return OpenFileBeneath(directory, file, os.O_RDWR|os.O_CREATE|os.O_TRUNC, 0666)
 }
 
+// CreateExclusiveBeneath creates the named file in the named directory, or a subdirectory,
+// failing if it already exists, as CreateExclusiveAt does.
+// file may not contain .. path traversal entries.
+// If there is an error, it will be of type *PathError.
+func CreateExclusiveBeneath(directory, file string) (*os.File, error) {
+	return OpenFileBeneath(directory, file, os.O_RDWR|os.O_CREATE|os.O_EXCL, 0666)
+}
+
 // OpenFileBeneath is the generalized OpenBeneath call; most users will use OpenBeneath
 // or CreateBeneath instead.
 //
@@ -91,10 +120,54 @@ func CreateBeneath(directory, file string) (*os.File, error) {
 // is passed, it is created with mode perm (before umask). The perm parameter is ignored on Windows.
 // If successful, methods on the returned File can be used for I/O.
 // If there is an error, it will be of type *PathError.
+//
+// Symbolic links are followed as by the other Beneath functions: where openat2 is available, or
+// with symlink emulation, see SetSymlinkEmulation. When built with Go 1.24 or newer, emulation
+// is then left to os.Root. If the Config passed to Configure sets
+// a SymlinkPolicy or RegularFilesOnly, the file is opened as Dir.OpenFile does instead.
 func OpenFileBeneath(directory, file string, flag int, perm os.FileMode) (*os.File, error) {
+	if configuredBeneath() {
+		d, err := OpenDir(directory)
+		if err != nil {
+			return nil, err
+		}
+		defer d.Close()
+		return d.OpenFile(file, flag, perm)
+	}
 	return openFileBeneath(directory, file, flag, perm)
 }
 
+// WithinBase reports whether the relative path relpath stays beneath its base directory, as
+// checked lexically by the Beneath functions: it is not empty, and does not escape the base
+// directory with .. path traversal entries. WithinBase does not access the file system, so it
+// does not account for symbolic links; it is meant to reject obviously bad input early, e.g.
+// in request handlers, not to replace the Beneath functions.
+func WithinBase(relpath string) bool {
+	return !strings.ContainsRune(relpath, 0) && isRelPathBeneath(relpath)
+}
+
+func openFileAt(directory, file string, flag int, perm os.FileMode) (*os.File, error) {
+	dfd, err := openDirHandle(directory)
+	if err != nil {
+		return nil, err
+	}
+	defer closeHandle(dfd)
+
+	return openFileAtIn(dfd, directory, file, flag, perm)
+}
+
+// openFileBeneathHandle opens file beneath directory with the implementation of this package,
+// rather than with os.Root.
+func openFileBeneathHandle(directory, file string, flag int, perm os.FileMode) (*os.File, error) {
+	dfd, err := openDirHandle(directory)
+	if err != nil {
+		return nil, err
+	}
+	defer closeHandle(dfd)
+
+	return openFileBeneathFollowIn(dfd, directory, file, flag, perm)
+}
+
 type openerFunc func(dir, file string, flag int, perm os.FileMode) (*os.File, error)
 
 func readFile(directory, file string, opener openerFunc) ([]byte, error) {
@@ -118,16 +191,143 @@ func writeFile(directory, file string, data []byte, perm os.FileMode, creator op
 	return err
 }
 
+func appendFile(directory, file string, data []byte, perm os.FileMode, opener openerFunc) error {
+	f, err := opener(directory, file, os.O_WRONLY|os.O_CREATE|os.O_APPEND, perm)
+	if err != nil {
+		return err
+	}
+	_, err = f.Write(data)
+	if err1 := f.Close(); err1 != nil && err == nil {
+		err = err1
+	}
+	return err
+}
+
+func writeFileBuffers(directory, file string, bufs [][]byte, perm os.FileMode, creator openerFunc) error {
+	f, err := creator(directory, file, os.O_RDWR|os.O_CREATE|os.O_TRUNC, perm)
+	if err != nil {
+		return err
+	}
+	err = writeBuffers(f, bufs)
+	if err1 := f.Close(); err1 != nil && err == nil {
+		err = err1
+	}
+	return err
+}
+
+// writeBuffersLoop writes bufs to f one after the other.
+func writeBuffersLoop(f *os.File, bufs [][]byte) error {
+	for _, buf := range bufs {
+		if _, err := f.Write(buf); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
 // ReadFileAt is a replacement of os.ReadFile that leverages safeopen.OpenAt.
 func ReadFileAt(directory, file string) ([]byte, error) {
 	return readFile(directory, file, OpenFileAt)
 }
 
+// readFilesAtWorkers is the maximum number of files ReadFilesAt reads concurrently.
+const readFilesAtWorkers = 8
+
+// ReadFilesAt reads the named files in the named directory, returning their content keyed by
+// name. The directory is opened only once and the files are read concurrently.
+// The names may not contain path separators.
+// If any of the files cannot be read, the error of the first such name is returned.
+func ReadFilesAt(directory string, names []string) (map[string][]byte, error) {
+	dfd, err := openDirHandle(directory)
+	if err != nil {
+		return nil, err
+	}
+	defer closeHandle(dfd)
+
+	datas := make([][]byte, len(names))
+	errs := make([]error, len(names))
+	next := make(chan int)
+	var wg sync.WaitGroup
+	for w := 0; w < readFilesAtWorkers && w < len(names); w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range next {
+				datas[i], errs[i] = readFile(directory, names[i], func(dir, file string, flag int, perm os.FileMode) (*os.File, error) {
+					f, err := openFileAtIn(dfd, dir, file, flag, perm)
+					if err != nil {
+						return nil, err
+					}
+					if err := checkConfiguredRegular(f, file); err != nil {
+						return nil, err
+					}
+					return f, nil
+				})
+			}
+		}()
+	}
+	for i := range names {
+		next <- i
+	}
+	close(next)
+	wg.Wait()
+
+	files := make(map[string][]byte, len(names))
+	for i, name := range names {
+		if errs[i] != nil {
+			return nil, errs[i]
+		}
+		files[name] = datas[i]
+	}
+	return files, nil
+}
+
 // WriteFileAt is a replacement of os.WriteFile that leverages safeopen.CreateAt.
 func WriteFileAt(directory, file string, data []byte, perm os.FileMode) error {
 	return writeFile(directory, file, data, perm, OpenFileAt)
 }
 
+// WriteFileNewAt is a variant of WriteFileAt that never overwrites an existing file: the file is
+// created with O_CREATE|O_EXCL, and if it already exists, the returned error satisfies
+// errors.Is(err, fs.ErrExist).
+// If writing the data fails, the newly created file is removed, so that the call can be
+// retried.
+func WriteFileNewAt(directory, file string, data []byte, perm os.FileMode) error {
+	dfd, err := openDirHandle(directory)
+	if err != nil {
+		return err
+	}
+	defer closeHandle(dfd)
+
+	f, err := openFileAtIn(dfd, directory, file, os.O_WRONLY|os.O_CREATE|os.O_EXCL, perm)
+	if err != nil {
+		return err
+	}
+	_, err = f.Write(data)
+	if err1 := f.Close(); err1 != nil && err == nil {
+		err = err1
+	}
+	if err != nil {
+		removeIn(dfd, file)
+	}
+	return err
+}
+
+// WriteFileBuffersAt is a variant of WriteFileAt that writes the concatenation of bufs,
+// without copying them into a single buffer first. Where available (e.g. Linux), the buffers are
+// written with vectored I/O.
+func WriteFileBuffersAt(directory, file string, bufs [][]byte, perm os.FileMode) error {
+	return writeFileBuffers(directory, file, bufs, perm, OpenFileAt)
+}
+
+// AppendFileAt appends data to the named file in the named directory, creating it with mode perm
+// (before umask) if it does not exist. file may not contain path separators.
+// The file is opened with O_APPEND, so that the data is written at the end of the file even if
+// other writers append to it concurrently. On Windows, this maps to FILE_APPEND_DATA access.
+func AppendFileAt(directory, file string, data []byte, perm os.FileMode) error {
+	return appendFile(directory, file, data, perm, OpenFileAt)
+}
+
 // ReadFileBeneath is a replacement of os.ReadFile that leverages safeopen.OpenBeneath.
 func ReadFileBeneath(directory, file string) ([]byte, error) {
 	return readFile(directory, file, OpenFileBeneath)
@@ -137,3 +337,15 @@ func ReadFileBeneath(directory, file string) ([]byte, error) {
 func WriteFileBeneath(directory, file string, data []byte, perm os.FileMode) error {
 	return writeFile(directory, file, data, perm, OpenFileBeneath)
 }
+
+// AppendFileBeneath is a variant of AppendFileAt that leverages safeopen.OpenFileBeneath.
+func AppendFileBeneath(directory, file string, data []byte, perm os.FileMode) error {
+	return appendFile(directory, file, data, perm, OpenFileBeneath)
+}
+
+// WriteFileBuffersBeneath is a variant of WriteFileBeneath that writes the concatenation of bufs,
+// without copying them into a single buffer first. Where available (e.g. Linux), the buffers are
+// written with vectored I/O.
+func WriteFileBuffersBeneath(directory, file string, bufs [][]byte, perm os.FileMode) error {
+	return writeFileBuffers(directory, file, bufs, perm, OpenFileBeneath)
+}
@@ -0,0 +1,63 @@
+// Copyright 2024 Google LLC.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+//go:build darwin
+// +build darwin
+
+package safeopen
+
+import (
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+
+	"golang.org/x/sys/unix"
+)
+
+// noFollowAnyRelease is the first Darwin release, that of macOS 11, supporting O_NOFOLLOW_ANY.
+// Older kernels silently ignore the flag, so it must not be relied on there.
+const noFollowAnyRelease = 20
+
+var noFollowAnySupported = sync.OnceValue(func() bool {
+	var uts unix.Utsname
+	if err := unix.Uname(&uts); err != nil {
+		return false
+	}
+	release, _, _ := strings.Cut(unix.ByteSliceToString(uts.Release[:]), ".")
+	major, err := strconv.Atoi(release)
+	return err == nil && major >= noFollowAnyRelease
+})
+
+// openFileImplNix opens file beneath dfd in a single openat call with O_NOFOLLOW_ANY, which
+// refuses symbolic links in any component of the path. file must not traverse out of dfd: it is
+// cleaned first, so that no .. component remains to be resolved by the kernel, and leading
+// slashes are stripped, as an absolute path would make openat ignore dfd. Older kernels fall
+// back to the legacy implementation.
+func openFileImplNix(dfd int, file string, flag int, perm os.FileMode) (int, error) {
+	if !noFollowAnySupported() {
+		return openFileImplLegacy(dfd, file, flag, perm)
+	}
+
+	file = strings.TrimLeft(filepath.Clean(file), "/")
+	if file == "" {
+		file = "."
+	}
+	fd, err := openatRetry(dfd, file, flag|unix.O_NOFOLLOW_ANY, syscallMode(perm))
+	if err == unix.ELOOP {
+		return 0, symlinkRejected(err)
+	}
+	return fd, err
+}
@@ -0,0 +1,136 @@
+// Copyright 2024 Google LLC.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+//go:build darwin
+// +build darwin
+
+package safeopen
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestDarwinNoFollowAny(t *testing.T) {
+	if !noFollowAnySupported() {
+		t.Skip("O_NOFOLLOW_ANY is not supported by this kernel")
+	}
+	defer SetStrictMode(false)
+
+	tmpdir := t.TempDir()
+	if err := os.MkdirAll(filepath.Join(tmpdir, "a", "b"), 0777); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(tmpdir, "a", "b", "data.txt"), []byte("hello"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Symlink("a", filepath.Join(tmpdir, "link")); err != nil {
+		t.Fatal(err)
+	}
+
+	// Multi-component paths are opened in a single call, which strict mode does not refuse.
+	SetStrictMode(true)
+	for _, file := range []string{filepath.Join("a", "b", "data.txt"), filepath.Join("a", "..", "a", "b", "data.txt")} {
+		data, err := ReadFileBeneath(tmpdir, file)
+		if err != nil {
+			t.Errorf("ReadFileBeneath(%q) error: %v", file, err)
+		} else if string(data) != "hello" {
+			t.Errorf("ReadFileBeneath(%q) = %q, want %q", file, data, "hello")
+		}
+	}
+
+	file := filepath.Join("link", "b", "data.txt")
+	if _, err := ReadFileBeneath(tmpdir, file); !errors.Is(err, ErrSymlinkRejected) {
+		t.Errorf("ReadFileBeneath(%q) error = %v, want %v", file, err, ErrSymlinkRejected)
+	}
+}
+
+func TestDarwinNoFollowAnyLeadingSlash(t *testing.T) {
+	if !noFollowAnySupported() {
+		t.Skip("O_NOFOLLOW_ANY is not supported by this kernel")
+	}
+	defer SetStrictMode(false)
+
+	tmpdir := t.TempDir()
+	outside := t.TempDir()
+	if err := os.WriteFile(filepath.Join(outside, "data.txt"), []byte("outside"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	// The same absolute path, recreated beneath tmpdir.
+	if err := os.MkdirAll(filepath.Join(tmpdir, outside), 0777); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(tmpdir, outside, "data.txt"), []byte("beneath"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	file := filepath.Join(outside, "data.txt")
+
+	SetStrictMode(true)
+	if data, err := ReadFileBeneath(tmpdir, file); err != nil || string(data) != "beneath" {
+		t.Errorf("ReadFileBeneath(%q) = %q, %v, want %q", file, data, err, "beneath")
+	}
+	SetStrictMode(false)
+
+	d, err := OpenDir(tmpdir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer d.Close()
+	if data, err := d.ReadFile(file); err != nil || string(data) != "beneath" {
+		t.Errorf("Dir.ReadFile(%q) = %q, %v, want %q", file, data, err, "beneath")
+	}
+}
+
+func TestDarwinOpenFileBeneath(t *testing.T) {
+	if !noFollowAnySupported() {
+		t.Skip("O_NOFOLLOW_ANY is not supported by this kernel")
+	}
+
+	tmpdir := t.TempDir()
+	if err := os.MkdirAll(filepath.Join(tmpdir, "a", "b"), 0777); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(tmpdir, "a", "b", "data.txt"), []byte("hello"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Symlink("a", filepath.Join(tmpdir, "link")); err != nil {
+		t.Fatal(err)
+	}
+
+	// Without emulation, OpenFileBeneath goes through O_NOFOLLOW_ANY, whatever the Go version.
+	for _, file := range []string{filepath.Join("a", "b", "data.txt"), "/" + filepath.Join("a", "b", "data.txt")} {
+		f, err := OpenFileBeneath(tmpdir, file, os.O_RDONLY, 0)
+		if err != nil {
+			t.Errorf("OpenFileBeneath(%q) error: %v", file, err)
+			continue
+		}
+		f.Close()
+	}
+	file := filepath.Join("link", "b", "data.txt")
+	if _, err := OpenFileBeneath(tmpdir, file, os.O_RDONLY, 0); !errors.Is(err, ErrSymlinkRejected) {
+		t.Errorf("OpenFileBeneath(%q) error = %v, want %v", file, err, ErrSymlinkRejected)
+	}
+
+	file = filepath.Join("a", "b", "new.txt")
+	f, err := OpenFileBeneath(tmpdir, file, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0644)
+	if err != nil {
+		t.Fatalf("OpenFileBeneath(%q, O_CREATE) error: %v", file, err)
+	}
+	f.Close()
+	if _, err := os.Stat(filepath.Join(tmpdir, file)); err != nil {
+		t.Errorf("OpenFileBeneath(%q, O_CREATE) did not create the file: %v", file, err)
+	}
+}
@@ -0,0 +1,171 @@
+// Copyright 2024 Google LLC.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+//go:build js
+// +build js
+
+package safeopen
+
+import (
+	"io/fs"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"syscall"
+	"time"
+)
+
+// js/wasm has no descriptor-relative system calls, and no file system at all in browsers. This
+// package only compiles there, so that packages importing it can be built for the browser: the
+// functions relying on handles fail at run time with an error wrapping syscall.ENOTSUP, while
+// OpenFileBeneath and the related functions behave as os.Root does with symlink emulation.
+
+// handle is never valid on this platform.
+type handle = int
+
+func openDirHandle(directory string) (handle, error) {
+	return -1, &os.PathError{Op: "open", Path: directory, Err: syscall.ENOTSUP}
+}
+
+func closeHandle(dfd handle) error {
+	return syscall.ENOTSUP
+}
+
+func syncHandle(dfd handle) error {
+	return syscall.ENOTSUP
+}
+
+func cwdHandle() (handle, func(), error) {
+	return -1, nil, &os.PathError{Op: "open", Path: ".", Err: syscall.ENOTSUP}
+}
+
+func adoptDirHandle(fd uintptr) (handle, error) {
+	return -1, syscall.ENOTSUP
+}
+
+// processExists reports true, so that the owner of a lock is never presumed dead.
+func processExists(pid int) bool {
+	return true
+}
+
+func passFiles(cmd *exec.Cmd, files []*os.File) ([]uintptr, error) {
+	return nil, syscall.ENOTSUP
+}
+
+// isFilename reports whether path names a file directly in a directory.
+func isFilename(path string) bool {
+	return path != "" && path != "." && path != ".." && !strings.Contains(path, "/")
+}
+
+// isRelPathBeneath reports whether path is accepted by the Beneath functions.
+func isRelPathBeneath(path string) bool {
+	return filepath.IsLocal(path)
+}
+
+func openParentIn(dfd handle, file string) (int, string, error) {
+	return -1, "", &os.PathError{Op: "OpenBeneath", Path: file, Err: syscall.ENOTSUP}
+}
+
+func openFileAtIn(dfd handle, directory, file string, flag int, perm os.FileMode) (*os.File, error) {
+	return nil, &os.PathError{Op: "OpenAt", Path: file, Err: syscall.ENOTSUP}
+}
+
+func openFileBeneathIn(dfd handle, directory, file string, flag int, perm os.FileMode) (*os.File, error) {
+	return nil, &os.PathError{Op: "OpenBeneath", Path: file, Err: syscall.ENOTSUP}
+}
+
+func openFileNoFollowIn(dfd handle, directory, file string, flag int, perm os.FileMode) (*os.File, error) {
+	return openFileBeneathIn(dfd, directory, file, flag, perm)
+}
+
+func openFileNoXDevIn(dfd handle, directory, file string, flag int, perm os.FileMode, noFollow bool) (*os.File, error) {
+	return openFileBeneathIn(dfd, directory, file, flag, perm)
+}
+
+func openDirNoFollowIn(dfd handle, directory, dir string) (*os.File, error) {
+	return openFileBeneathIn(dfd, directory, dir, os.O_RDONLY, 0)
+}
+
+func createScratchIn(dfd handle, directory string) (*os.File, error) {
+	return nil, &os.PathError{Op: "CreateTemp", Path: directory, Err: syscall.ENOTSUP}
+}
+
+func checkResolveBeneathIn(dfd handle) error {
+	return ErrLegacyResolution
+}
+
+func kernelBeneathSupported() bool {
+	return false
+}
+
+func writeBuffers(f *os.File, bufs [][]byte) error {
+	return writeBuffersLoop(f, bufs)
+}
+
+func renameIn(dfd handle, oldfile, newfile string) error {
+	return syscall.ENOTSUP
+}
+
+func renameNoReplaceIn(odfd handle, oldfile string, ndfd handle, newfile string) error {
+	return syscall.ENOTSUP
+}
+
+func exchangeIn(dfd handle, a, b string) error {
+	return syscall.ENOTSUP
+}
+
+func removeIn(dfd handle, file string) error {
+	return syscall.ENOTSUP
+}
+
+func mkdirIn(dfd handle, dir string, perm os.FileMode) error {
+	return syscall.ENOTSUP
+}
+
+func chmodIn(dfd handle, file string, mode os.FileMode) error {
+	return syscall.ENOTSUP
+}
+
+func chownIn(dfd handle, file string, uid, gid int) error {
+	return syscall.ENOTSUP
+}
+
+func chtimesIn(dfd handle, file string, atime, mtime time.Time) error {
+	return syscall.ENOTSUP
+}
+
+func linkIn(dfd handle, oldfile, newfile string) error {
+	return syscall.ENOTSUP
+}
+
+func linkBetween(odfd handle, oldfile string, ndfd handle, newfile string) error {
+	return syscall.ENOTSUP
+}
+
+func symlinkIn(dfd handle, target, link string) error {
+	return syscall.ENOTSUP
+}
+
+func readlinkIn(dfd handle, file string) (string, error) {
+	return "", &os.PathError{Op: "readlink", Path: file, Err: syscall.ENOTSUP}
+}
+
+func statIn(dfd handle, file string) (fs.FileInfo, error) {
+	return nil, &os.PathError{Op: "lstat", Path: file, Err: syscall.ENOTSUP}
+}
+
+func extendedStatIn(dfd handle, file string) (*ExtendedFileInfo, error) {
+	return nil, &os.PathError{Op: "lstat", Path: file, Err: syscall.ENOTSUP}
+}
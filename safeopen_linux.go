@@ -18,9 +18,10 @@
 package safeopen
 
 import (
-	"errors"
+	"io"
 	"os"
 	"path/filepath"
+	"strconv"
 	"strings"
 	"syscall"
 
@@ -59,33 +60,99 @@ func canTraverseUnixRelPath(path string) (string, bool) {
 	return path, true
 }
 
-func openFileAt(directory, file string, flag int, perm os.FileMode) (*os.File, error) {
-	if !unixIsFilename(file) {
-		return nil, &os.PathError{Op: "OpenAt", Path: file, Err: errors.New("invalid filename")}
+func openFileAtIn(dfd handle, directory, file string, flag int, perm os.FileMode) (*os.File, error) {
+	if !isFilename(file) {
+		return nil, &os.PathError{Op: "OpenAt", Path: file, Err: ErrInvalidFilename}
 	}
 
-	return openFileImpl(directory, file, flag, perm, unix.RESOLVE_NO_SYMLINKS)
+	return openFileImpl(dfd, directory, file, flag, perm, unix.RESOLVE_NO_SYMLINKS)
 }
 
-func openFileBeneath(directory, file string, flag int, perm os.FileMode) (*os.File, error) {
-	file, safe := canTraverseUnixRelPath(file)
+func openFileBeneathIn(dfd handle, directory, file string, flag int, perm os.FileMode) (*os.File, error) {
+	path, safe := canTraverseUnixRelPath(file)
 	if !safe {
-		return nil, &os.PathError{Op: "OpenBeneath", Path: file, Err: errors.New("invalid filename")}
+		return nil, &os.PathError{Op: "OpenBeneath", Path: file, Err: beneathPathError(file)}
 	}
+	file = path
 
-	return openFileImpl(directory, file, flag, perm, 0)
+	return openFileImpl(dfd, directory, file, flag, perm, 0)
 }
 
-func openFileImpl(directory, file string, flag int, perm os.FileMode, resolveHow uint64) (*os.File, error) {
-	dfd, err := unix.Open(directory, os.O_RDONLY|unix.O_DIRECTORY, 0)
+// isRelPathBeneath reports whether path is accepted by the Beneath functions.
+func isRelPathBeneath(path string) bool {
+	_, ok := canTraverseUnixRelPath(path)
+	return ok
+}
+
+// openFileNoFollowIn opens file beneath dfd, refusing to follow symbolic links at any level.
+func openFileNoFollowIn(dfd handle, directory, file string, flag int, perm os.FileMode) (*os.File, error) {
+	path, safe := canTraverseUnixRelPath(file)
+	if !safe {
+		return nil, &os.PathError{Op: "OpenBeneath", Path: file, Err: beneathPathError(file)}
+	}
+	file = path
+
+	return openFileImpl(dfd, directory, file, flag, perm, unix.RESOLVE_NO_SYMLINKS)
+}
+
+// openFileNoXDevIn opens file beneath dfd, refusing to cross mount points, and to follow
+// symbolic links at any level if noFollow is set. It relies on RESOLVE_NO_XDEV, and falls back
+// to checking the device of each directory on the way if openat2 is not available.
+func openFileNoXDevIn(dfd handle, directory, file string, flag int, perm os.FileMode, noFollow bool) (*os.File, error) {
+	path, safe := canTraverseUnixRelPath(file)
+	if !safe {
+		return nil, &os.PathError{Op: "OpenBeneath", Path: file, Err: beneathPathError(file)}
+	}
+
+	var resolveHow uint64 = unix.RESOLVE_NO_XDEV
+	if noFollow {
+		resolveHow |= unix.RESOLVE_NO_SYMLINKS
+	}
+	fd, err := openRegularOrDir(flag, func(flag int) (int, error) {
+		if !forceLegacyMode {
+			if fd, supported, err := openFileImplBeneath(dfd, path, flag, perm, resolveHow); supported {
+				return fd, err
+			}
+		}
+		return openFileNoXDevLegacy(dfd, path, flag, perm)
+	})
 	if err != nil {
-		return nil, err
+		return nil, &os.PathError{Op: "openat", Path: filepath.Join(directory, path), Err: err}
 	}
-	defer unix.Close(dfd)
+	return os.NewFile(uintptr(fd), filepath.Join(directory, path)), nil
+}
+
+// openDirNoFollowIn opens the directory dir beneath dfd for reading its entries, refusing to
+// follow symbolic links at any level.
+func openDirNoFollowIn(dfd handle, directory, dir string) (*os.File, error) {
+	return openFileNoFollowIn(dfd, directory, dir, os.O_RDONLY|unix.O_DIRECTORY, 0)
+}
 
-	fd, err := openFileImplBeneathFirst(dfd, file, flag, perm, resolveHow)
+// openParentIn opens the directory containing file beneath dfd, with the same rules as
+// OpenBeneath. It returns the descriptor of the directory (which is dfd itself if file is
+// directly in it) and the last element of file.
+func openParentIn(dfd handle, file string) (int, string, error) {
+	path, safe := canTraverseUnixRelPath(file)
+	if !safe {
+		return 0, "", &os.PathError{Op: "OpenBeneath", Path: file, Err: beneathPathError(file)}
+	}
+	file = path
+
+	dir, base := filepath.Split(file)
+	dir = strings.TrimRight(dir, "/")
+	if dir == "" {
+		return dfd, base, nil
+	}
+	fd, err := openFileImplBeneathFirst(dfd, dir, os.O_RDONLY|unix.O_DIRECTORY, 0, 0)
+	return fd, base, err
+}
+
+func openFileImpl(dfd handle, directory, file string, flag int, perm os.FileMode, resolveHow uint64) (*os.File, error) {
+	fd, err := openRegularOrDir(flag, func(flag int) (int, error) {
+		return openFileImplBeneathFirst(dfd, file, flag, perm, resolveHow)
+	})
 	if err != nil {
-		return nil, err
+		return nil, &os.PathError{Op: "openat", Path: filepath.Join(directory, file), Err: err}
 	}
 
 	return os.NewFile(uintptr(fd), filepath.Join(directory, file)), nil
@@ -104,10 +171,12 @@ func openFileImplBeneathFirst(dfd int, file string, flag int, perm os.FileMode,
 }
 
 func openFileImplBeneath(dfd int, file string, flag int, perm os.FileMode, resolveHow uint64) (int, bool, error) {
-	fd, err := unix.Openat2(dfd, file, &unix.OpenHow{
-		Flags:   uint64(flag),
-		Mode:    uint64(syscallMode(perm)),
-		Resolve: unix.RESOLVE_BENEATH | resolveHow,
+	fd, err := openRetry(func() (int, error) {
+		return unix.Openat2(dfd, file, &unix.OpenHow{
+			Flags:   uint64(flag | unix.O_CLOEXEC),
+			Mode:    uint64(syscallMode(perm)),
+			Resolve: unix.RESOLVE_BENEATH | resolveHow,
+		})
 	})
 	supported := true
 	if err != nil {
@@ -119,51 +188,150 @@ func openFileImplBeneath(dfd int, file string, flag int, perm os.FileMode, resol
 			// Falling back to legacy impl.
 			supported = false
 		}
+		switch {
+		case err == unix.EXDEV && resolveHow&unix.RESOLVE_NO_XDEV != 0:
+			err = &rejectionError{kind: ErrCrossDevice, err: err}
+		case err == unix.EXDEV:
+			err = &rejectionError{kind: ErrTraversal, err: err}
+		case err == unix.ELOOP && resolveHow&unix.RESOLVE_NO_SYMLINKS != 0:
+			err = symlinkRejected(err)
+		}
 		return 0, supported, err
 	}
 	return fd, supported, nil
 }
 
-func openFileImplLegacy(dfd int, file string, flag int, perm os.FileMode) (int, error) {
-	segs := strings.Split(file, string(filepath.Separator))
+// createScratchIn creates an anonymous file in the directory referred by dfd with O_TMPFILE,
+// falling back to removing a named file right after its creation if the file system does not
+// support it.
+func createScratchIn(dfd handle, directory string) (*os.File, error) {
+	fd, err := openatRetry(dfd, ".", unix.O_RDWR|unix.O_TMPFILE|unix.O_CLOEXEC, 0600)
+	// Kernels older than 3.11 see O_DIRECTORY only and fail with EISDIR.
+	if err == unix.EOPNOTSUPP || err == unix.EISDIR {
+		return createUnlinkedIn(dfd, directory)
+	}
+	if err != nil {
+		return nil, &os.PathError{Op: "CreateScratchAt", Path: directory, Err: err}
+	}
+	return os.NewFile(uintptr(fd), directory), nil
+}
 
-	adfd := dfd
-	var err error
-	if len(segs) > 1 {
-		for _, seg := range segs[:len(segs)-1] {
-			// Ignore empty segments
-			if seg == "" {
-				continue
-			}
+// renameNoReplaceIn renames oldfile, directly in the directory referred by odfd, to newfile,
+// directly in the directory referred by ndfd, failing if newfile exists.
+func renameNoReplaceIn(odfd handle, oldfile string, ndfd handle, newfile string) error {
+	err := unix.Renameat2(odfd, oldfile, ndfd, newfile, unix.RENAME_NOREPLACE)
+	// Kernels before 3.15 lack renameat2, and some file systems do not support the flag.
+	if err == unix.ENOSYS || err == unix.EINVAL {
+		return renameNoReplaceLegacy(odfd, oldfile, ndfd, newfile)
+	}
+	return err
+}
 
-			odfd := adfd
+// exchangeIn atomically exchanges a and b, both directly in the directory referred by dfd.
+func exchangeIn(dfd handle, a, b string) error {
+	err := unix.Renameat2(dfd, a, dfd, b, unix.RENAME_EXCHANGE)
+	// Kernels before 3.15 lack renameat2, and some file systems do not support the flag.
+	if err == unix.ENOSYS || err == unix.EINVAL {
+		return unix.ENOTSUP
+	}
+	return err
+}
 
-			adfd, err = unix.Openat(adfd, seg, os.O_RDONLY|unix.O_NOFOLLOW|unix.O_DIRECTORY, 0)
+// chmodIn changes the mode of file directly in the directory referred by dfd. Symbolic links are
+// not followed, and their mode cannot be changed. As fchmodat ignores AT_SYMLINK_NOFOLLOW on
+// Linux, the file is opened with O_PATH and changed through /proc, as glibc does.
+func chmodIn(dfd handle, file string, mode os.FileMode) error {
+	fd, err := openatRetry(dfd, file, unix.O_PATH|unix.O_NOFOLLOW|unix.O_CLOEXEC, 0)
+	if err != nil {
+		return err
+	}
+	defer unix.Close(fd)
 
-			// odfd (the previous adfd) is not needed any longer. Closing it right now.
-			if odfd != dfd {
-				if cerr := unix.Close(odfd); cerr != nil {
-					return 0, cerr
-				}
-			}
+	var st unix.Stat_t
+	if err := unix.Fstat(fd, &st); err != nil {
+		return err
+	}
+	if st.Mode&unix.S_IFMT == unix.S_IFLNK {
+		return unix.EOPNOTSUPP
+	}
+	return unix.Fchmodat(unix.AT_FDCWD, "/proc/self/fd/"+strconv.Itoa(fd), syscallMode(mode), 0)
+}
+
+// maxIovecs is the maximum number of buffers passed to a single writev call (IOV_MAX).
+const maxIovecs = 1024
 
+// writeBuffers writes bufs to f using writev.
+func writeBuffers(f *os.File, bufs [][]byte) error {
+	rc, err := f.SyscallConn()
+	if err != nil {
+		return err
+	}
+	// Copy the slice, as the partially written buffer is resliced in place.
+	bufs = append([][]byte(nil), bufs...)
+	var werr error
+	err = rc.Write(func(fd uintptr) bool {
+		for len(bufs) > 0 {
+			iovs := bufs
+			if len(iovs) > maxIovecs {
+				iovs = iovs[:maxIovecs]
+			}
+			n, err := unix.Writev(int(fd), iovs)
+			if err == unix.EINTR {
+				continue
+			}
 			if err != nil {
-				return 0, err
+				werr = err
+				return true
+			}
+			if n == 0 && len(bufs[0]) > 0 {
+				werr = io.ErrShortWrite
+				return true
+			}
+			for len(bufs) > 0 && n >= len(bufs[0]) {
+				n -= len(bufs[0])
+				bufs = bufs[1:]
+			}
+			if n > 0 {
+				bufs[0] = bufs[0][n:]
 			}
-
 		}
+		return true
+	})
+	if err != nil {
+		return err
 	}
+	return werr
+}
 
-	fd, err := unix.Openat(adfd, segs[len(segs)-1], flag|syscall.O_NOFOLLOW, syscallMode(perm))
-	if adfd != dfd {
-		err = unix.Close(adfd)
+// checkResolveBeneathIn returns ErrLegacyResolution if openat2 with RESOLVE_BENEATH cannot be
+// used beneath dfd.
+func checkResolveBeneathIn(dfd handle) error {
+	if forceLegacyMode {
+		return ErrLegacyResolution
 	}
-	return fd, err
+	fd, supported, err := openFileImplBeneath(dfd, ".", os.O_RDONLY|unix.O_DIRECTORY, 0, 0)
+	if !supported {
+		return ErrLegacyResolution
+	}
+	if err != nil {
+		return err
+	}
+	return unix.Close(fd)
+}
+
+// kernelBeneathSupported reports whether openat2 with RESOLVE_BENEATH is available.
+func kernelBeneathSupported() bool {
+	dfd, err := unix.Open("/", os.O_RDONLY|unix.O_DIRECTORY|unix.O_CLOEXEC, 0)
+	if err != nil {
+		return false
+	}
+	defer unix.Close(dfd)
+	return checkResolveBeneathIn(dfd) == nil
 }
 
 // isOpenat2WithResolveBeneathSupported is a helper function for unit tests only.
 func isOpenat2WithResolveBeneathSupported() bool {
-	dfd, err := unix.Open("/etc", os.O_RDONLY|unix.O_DIRECTORY, 0)
+	dfd, err := unix.Open("/etc", os.O_RDONLY|unix.O_DIRECTORY|unix.O_CLOEXEC, 0)
 	if err != nil {
 		return false
 	}
@@ -18,6 +18,7 @@
 package safeopen
 
 import (
+	"errors"
 	"fmt"
 	"io"
 	"os"
@@ -161,3 +162,122 @@ func TestLinuxDirTraversal(t *testing.T) {
 		})
 	}
 }
+
+func TestLinuxStrictMode(t *testing.T) {
+	origForceLegacyMode := forceLegacyMode
+	defer func() { forceLegacyMode = origForceLegacyMode }()
+	defer SetStrictMode(false)
+
+	tmpdir := t.TempDir()
+	if err := os.Mkdir(path.Join(tmpdir, "subdir"), 0777); err != nil {
+		t.Fatal(err)
+	}
+	for _, file := range []string{"data.txt", path.Join("subdir", "data.txt")} {
+		if err := os.WriteFile(path.Join(tmpdir, file), []byte("hello"), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	forceLegacyMode = true
+	SetStrictMode(true)
+	if _, err := ReadFileBeneath(tmpdir, path.Join("subdir", "data.txt")); !errors.Is(err, ErrLegacyResolution) {
+		t.Errorf("ReadFileBeneath(%q) in strict legacy mode error = %v, want %v", path.Join("subdir", "data.txt"), err, ErrLegacyResolution)
+	}
+	if _, err := ReadFileBeneath(tmpdir, "data.txt"); err != nil {
+		t.Errorf("ReadFileBeneath(%q) in strict legacy mode error: %v", "data.txt", err)
+	}
+	if _, err := OpenDir(tmpdir, WithStrictResolution()); !errors.Is(err, ErrLegacyResolution) {
+		t.Errorf("OpenDir(WithStrictResolution()) in legacy mode error = %v, want %v", err, ErrLegacyResolution)
+	}
+
+	SetStrictMode(false)
+	if _, err := ReadFileBeneath(tmpdir, path.Join("subdir", "data.txt")); err != nil {
+		t.Errorf("ReadFileBeneath(%q) in legacy mode error: %v", path.Join("subdir", "data.txt"), err)
+	}
+
+	if !isOpenat2WithResolveBeneathSupported() {
+		return
+	}
+	forceLegacyMode = false
+	SetStrictMode(true)
+	if _, err := ReadFileBeneath(tmpdir, path.Join("subdir", "data.txt")); err != nil {
+		t.Errorf("ReadFileBeneath(%q) in strict mode error: %v", path.Join("subdir", "data.txt"), err)
+	}
+	d, err := OpenDir(tmpdir, WithStrictResolution())
+	if err != nil {
+		t.Fatalf("OpenDir(WithStrictResolution()) error: %v", err)
+	}
+	d.Close()
+}
+
+func TestLinuxSymlinkRejected(t *testing.T) {
+	origForceLegacyMode := forceLegacyMode
+	defer func() { forceLegacyMode = origForceLegacyMode }()
+
+	tmpdir := t.TempDir()
+	if err := os.Mkdir(path.Join(tmpdir, "subdir"), 0777); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path.Join(tmpdir, "subdir", "data.txt"), []byte("hello"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Symlink("subdir", path.Join(tmpdir, "link")); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, legacy := range []bool{false, true} {
+		forceLegacyMode = legacy
+		if _, err := OpenAt(tmpdir, "link"); !errors.Is(err, ErrSymlinkRejected) {
+			t.Errorf("OpenAt(link) with forceLegacyMode=%v error = %v, want %v", legacy, err, ErrSymlinkRejected)
+		}
+		if _, err := ReadDirBeneath(tmpdir, "link"); !errors.Is(err, ErrSymlinkRejected) {
+			t.Errorf("ReadDirBeneath(link) with forceLegacyMode=%v error = %v, want %v", legacy, err, ErrSymlinkRejected)
+		}
+		if _, err := LstatBeneath(tmpdir, path.Join("link", "data.txt")); legacy && !errors.Is(err, ErrSymlinkRejected) {
+			t.Errorf("LstatBeneath(link/data.txt) with forceLegacyMode=%v error = %v, want %v", legacy, err, ErrSymlinkRejected)
+		}
+	}
+}
+
+func TestLinuxCapabilities(t *testing.T) {
+	origForceLegacyMode := forceLegacyMode
+	defer func() { forceLegacyMode = origForceLegacyMode }()
+
+	if got, want := Capabilities().KernelBeneath, isOpenat2WithResolveBeneathSupported(); got != want {
+		t.Errorf("Capabilities().KernelBeneath = %v, want %v", got, want)
+	}
+	forceLegacyMode = true
+	if c := Capabilities(); c.KernelBeneath || c.KernelNoCrossDevice {
+		t.Errorf("Capabilities() in legacy mode = %+v, want neither KernelBeneath nor KernelNoCrossDevice", c)
+	}
+}
+
+func TestLinuxSymlinkEmulation(t *testing.T) {
+	origForceLegacyMode := forceLegacyMode
+	defer func() { forceLegacyMode = origForceLegacyMode }()
+	forceLegacyMode = true
+
+	root := setupReleases(t)
+	file := path.Join("current", "data.txt")
+	d, err := OpenDir(root)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer d.Close()
+
+	if _, err := d.ReadFile(file); !errors.Is(err, ErrSymlinkRejected) {
+		t.Errorf("ReadFile(%q) in legacy mode error = %v, want %v", file, err, ErrSymlinkRejected)
+	}
+
+	SetSymlinkEmulation(true)
+	defer SetSymlinkEmulation(false)
+	if data, err := d.ReadFile(file); err != nil || string(data) != "hello" {
+		t.Errorf("ReadFile(%q) with emulation = %q, %v, want %q", file, data, err, "hello")
+	}
+	if _, err := d.ReadFile("escape"); !errors.Is(err, ErrTraversal) && !errors.Is(err, ErrSymlinkRejected) {
+		t.Errorf("ReadFile(\"escape\") with emulation error = %v, want traversal", err)
+	}
+	if c := Capabilities(); !c.SymlinkEmulation || !c.FollowsSymlinks {
+		t.Errorf("Capabilities() with emulation in legacy mode = %+v, want SymlinkEmulation and FollowsSymlinks", c)
+	}
+}
@@ -18,7 +18,6 @@
 package safeopen
 
 import (
-	"errors"
 	"os"
 	"path/filepath"
 	"strings"
@@ -49,62 +48,123 @@ func unixRelativePathDoesntTraverse(path string) bool {
 	return true
 }
 
-func openFileAt(directory, file string, flag int, perm os.FileMode) (*os.File, error) {
-	if !unixIsFilename(file) {
-		return nil, &os.PathError{"OpenAt", file, errors.New("invalid filename")}
+func openFileAtIn(dfd handle, directory, file string, flag int, perm os.FileMode) (*os.File, error) {
+	if !isFilename(file) {
+		return nil, &os.PathError{Op: "OpenAt", Path: file, Err: ErrInvalidFilename}
 	}
 
-	dfd, err := unix.Open(directory, os.O_RDONLY|unix.O_DIRECTORY, 0)
+	fd, err := openRegularOrDir(flag, func(flag int) (int, error) {
+		return openatRetry(dfd, file, flag|syscall.O_NOFOLLOW, syscallMode(perm))
+	})
 	if err != nil {
+		return nil, &os.PathError{Op: "openat", Path: filepath.Join(directory, file), Err: noFollowError(dfd, file, err)}
+	}
+
+	f := os.NewFile(uintptr(fd), filepath.Join(directory, file))
+	if err := checkExactCase(f, file); err != nil {
 		return nil, err
 	}
+	return f, nil
+}
 
-	fd, err := unix.Openat(dfd, file, flag|syscall.O_NOFOLLOW, syscallMode(perm))
-	unix.Close(dfd)
+func openFileBeneathIn(dfd handle, directory, file string, flag int, perm os.FileMode) (*os.File, error) {
+	if !unixRelativePathDoesntTraverse(file) {
+		return nil, &os.PathError{Op: "OpenBeneath", Path: file, Err: beneathPathError(file)}
+	}
 
+	fd, err := openRegularOrDir(flag, func(flag int) (int, error) {
+		return openFileImplNix(dfd, file, flag, perm)
+	})
 	if err != nil {
+		return nil, &os.PathError{Op: "openat", Path: filepath.Join(directory, file), Err: err}
+	}
+
+	f := os.NewFile(uintptr(fd), filepath.Join(directory, file))
+	if err := checkExactCase(f, file); err != nil {
 		return nil, err
 	}
+	return f, nil
+}
 
-	return os.NewFile(uintptr(fd), filepath.Join(directory, file)), nil
+// isRelPathBeneath reports whether path is accepted by the Beneath functions.
+func isRelPathBeneath(path string) bool {
+	return unixRelativePathDoesntTraverse(path)
+}
+
+// openFileNoFollowIn opens file beneath dfd, refusing to follow symbolic links at any level.
+// openFileBeneathIn already behaves this way on this platform.
+func openFileNoFollowIn(dfd handle, directory, file string, flag int, perm os.FileMode) (*os.File, error) {
+	return openFileBeneathIn(dfd, directory, file, flag, perm)
 }
 
-func openFileBeneath(directory, file string, flag int, perm os.FileMode) (*os.File, error) {
+// openFileNoXDevIn opens file beneath dfd, refusing to cross mount points. Symbolic links are
+// never followed on this platform, whatever noFollow.
+func openFileNoXDevIn(dfd handle, directory, file string, flag int, perm os.FileMode, noFollow bool) (*os.File, error) {
 	if !unixRelativePathDoesntTraverse(file) {
-		return nil, &os.PathError{"OpenBeneath", file, errors.New("invalid filename")}
+		return nil, &os.PathError{Op: "OpenBeneath", Path: file, Err: beneathPathError(file)}
 	}
 
-	dfd, err := unix.Open(directory, os.O_RDONLY|unix.O_DIRECTORY, 0)
+	fd, err := openRegularOrDir(flag, func(flag int) (int, error) {
+		return openFileNoXDevLegacy(dfd, file, flag, perm)
+	})
 	if err != nil {
-		return nil, err
+		return nil, &os.PathError{Op: "openat", Path: filepath.Join(directory, file), Err: err}
+	}
+	return os.NewFile(uintptr(fd), filepath.Join(directory, file)), nil
+}
+
+// openDirNoFollowIn opens the directory dir beneath dfd for reading its entries, refusing to
+// follow symbolic links at any level.
+func openDirNoFollowIn(dfd handle, directory, dir string) (*os.File, error) {
+	return openFileNoFollowIn(dfd, directory, dir, os.O_RDONLY|unix.O_DIRECTORY, 0)
+}
+
+// openParentIn opens the directory containing file beneath dfd, with the same rules as
+// OpenBeneath. It returns the descriptor of the directory (which is dfd itself if file is
+// directly in it) and the last element of file.
+func openParentIn(dfd handle, file string) (int, string, error) {
+	if !unixRelativePathDoesntTraverse(file) {
+		return 0, "", &os.PathError{Op: "OpenBeneath", Path: file, Err: beneathPathError(file)}
 	}
 
-	segs := strings.Split(file, string(filepath.Separator))
+	return openParentLegacy(dfd, file)
+}
 
-	if len(segs) > 1 {
-		for _, seg := range segs[:len(segs)-1] {
-			// Ignore empty segments
-			if seg == "" {
-				continue
-			}
+// createScratchIn creates an anonymous file in the directory referred by dfd. O_TMPFILE is not
+// available on this platform, so the file is removed right after its creation.
+func createScratchIn(dfd handle, directory string) (*os.File, error) {
+	return createUnlinkedIn(dfd, directory)
+}
 
-			odfd := dfd
+// checkResolveBeneathIn returns ErrLegacyResolution, as openat2 is not available on this
+// platform.
+func checkResolveBeneathIn(dfd handle) error {
+	return ErrLegacyResolution
+}
 
-			dfd, err = unix.Openat(dfd, seg, os.O_RDONLY|unix.O_NOFOLLOW|unix.O_DIRECTORY, 0)
-			unix.Close(odfd)
+// kernelBeneathSupported reports false, as openat2 is not available on this platform.
+func kernelBeneathSupported() bool {
+	return false
+}
 
-			if err != nil {
-				return nil, err
-			}
-		}
-	}
+// writeBuffers writes bufs to f. Vectored I/O is not used on this platform.
+func writeBuffers(f *os.File, bufs [][]byte) error {
+	return writeBuffersLoop(f, bufs)
+}
 
-	fd, err := unix.Openat(dfd, segs[len(segs)-1], flag|syscall.O_NOFOLLOW, syscallMode(perm))
-	unix.Close(dfd)
+// renameNoReplaceIn renames oldfile, directly in the directory referred by odfd, to newfile,
+// directly in the directory referred by ndfd, failing if newfile exists.
+func renameNoReplaceIn(odfd handle, oldfile string, ndfd handle, newfile string) error {
+	return renameNoReplaceLegacy(odfd, oldfile, ndfd, newfile)
+}
 
-	if err != nil {
-		return nil, err
-	}
+// exchangeIn is not supported on this platform.
+func exchangeIn(dfd handle, a, b string) error {
+	return unix.ENOTSUP
+}
 
-	return os.NewFile(uintptr(fd), filepath.Join(directory, file)), nil
+// chmodIn changes the mode of file directly in the directory referred by dfd. Symbolic links are
+// not followed.
+func chmodIn(dfd handle, file string, mode os.FileMode) error {
+	return unix.Fchmodat(dfd, file, syscallMode(mode), unix.AT_SYMLINK_NOFOLLOW)
 }
@@ -18,15 +18,196 @@
 package safeopen
 
 import (
+	"io/fs"
 	"os"
+	"path/filepath"
+	"strconv"
 	"strings"
 	"syscall"
+	"time"
+
+	"golang.org/x/sys/unix"
 )
 
+// handle is a file descriptor of an opened base directory.
+type handle = int
+
+func openDirHandle(directory string) (handle, error) {
+	return openRetry(func() (int, error) {
+		return unix.Open(directory, os.O_RDONLY|unix.O_DIRECTORY|unix.O_CLOEXEC, 0)
+	})
+}
+
+// openRetry calls open until it does not fail with EINTR, which opening named pipes or files on
+// network file systems may return when interrupted by a signal, such as the preemption signal
+// of the Go runtime. It also retries on EMFILE and ENFILE, as configured with SetOpenRetry.
+func openRetry(open func() (int, error)) (int, error) {
+	var waited time.Duration
+	backoff := minOpenRetryBackoff
+	for {
+		fd, err := open()
+		if err == unix.EINTR {
+			continue
+		}
+		if (err == unix.EMFILE || err == unix.ENFILE) && waited < time.Duration(openRetryMax.Load()) {
+			time.Sleep(backoff)
+			waited += backoff
+			if backoff < maxOpenRetryBackoff {
+				backoff *= 2
+			}
+			continue
+		}
+		return fd, err
+	}
+}
+
+// openatRetry is unix.Openat with the retries of openRetry. The descriptor is always opened
+// with O_CLOEXEC, so that it does not leak into child processes.
+func openatRetry(dfd int, path string, flags int, mode uint32) (int, error) {
+	return openRetry(func() (int, error) {
+		return unix.Openat(dfd, path, flags|unix.O_CLOEXEC, mode)
+	})
+}
+
+func closeHandle(dfd handle) error {
+	return unix.Close(dfd)
+}
+
+func syncHandle(dfd handle) error {
+	return unix.Fsync(dfd)
+}
+
+// cwdHandle returns a handle referring to the current working directory, and a function
+// releasing it.
+func cwdHandle() (handle, func(), error) {
+	return unix.AT_FDCWD, func() {}, nil
+}
+
+// adoptDirHandle checks that the inherited descriptor fd refers to a directory, and marks it
+// close-on-exec, so that it is not leaked further.
+func adoptDirHandle(fd uintptr) (handle, error) {
+	var st unix.Stat_t
+	if err := unix.Fstat(int(fd), &st); err != nil {
+		return 0, err
+	}
+	if st.Mode&unix.S_IFMT != unix.S_IFDIR {
+		return 0, unix.ENOTDIR
+	}
+	unix.CloseOnExec(int(fd))
+	return int(fd), nil
+}
+
+// processExists reports whether a process with the given id is running.
+func processExists(pid int) bool {
+	err := unix.Kill(pid, 0)
+	return err == nil || err == unix.EPERM
+}
+
+// renameIn renames oldfile to newfile, both directly in the directory referred by dfd.
+func renameIn(dfd handle, oldfile, newfile string) error {
+	return unix.Renameat(dfd, oldfile, dfd, newfile)
+}
+
+// renameNoReplaceLegacy renames oldfile, directly in the directory referred by odfd, to newfile,
+// directly in the directory referred by ndfd, failing if newfile exists, without renameat2.
+// Files are hard linked to their new name and then unlinked, which fails atomically if newfile
+// exists; other files, such as directories, are renamed after checking that newfile does not
+// exist, which is racy.
+func renameNoReplaceLegacy(odfd handle, oldfile string, ndfd handle, newfile string) error {
+	err := linkBetween(odfd, oldfile, ndfd, newfile)
+	if err == nil {
+		if err := unix.Unlinkat(odfd, oldfile, 0); err != nil {
+			unix.Unlinkat(ndfd, newfile, 0)
+			return err
+		}
+		return nil
+	}
+	if err == unix.EEXIST || err == unix.ENOENT {
+		return err
+	}
+	var st unix.Stat_t
+	err = unix.Fstatat(ndfd, newfile, &st, unix.AT_SYMLINK_NOFOLLOW)
+	if err == nil {
+		return unix.EEXIST
+	}
+	if err != unix.ENOENT {
+		return err
+	}
+	return unix.Renameat(odfd, oldfile, ndfd, newfile)
+}
+
+// removeIn removes file directly in the directory referred by dfd. As with os.Remove, file may
+// be an empty directory.
+func removeIn(dfd handle, file string) error {
+	err := unix.Unlinkat(dfd, file, 0)
+	if err == nil || err == unix.ENOENT {
+		return err
+	}
+	// Linux fails with EISDIR for directories, and other systems with EPERM.
+	err1 := unix.Unlinkat(dfd, file, unix.AT_REMOVEDIR)
+	if err1 == nil {
+		return nil
+	}
+	if err1 != unix.ENOTDIR {
+		err = err1
+	}
+	return err
+}
+
+// mkdirIn creates the directory dir directly in the directory referred by dfd.
+func mkdirIn(dfd handle, dir string, perm os.FileMode) error {
+	return unix.Mkdirat(dfd, dir, syscallMode(perm))
+}
+
+// chownIn changes the owner and group of file directly in the directory referred by dfd. Symbolic
+// links are not followed.
+func chownIn(dfd handle, file string, uid, gid int) error {
+	return unix.Fchownat(dfd, file, uid, gid, unix.AT_SYMLINK_NOFOLLOW)
+}
+
+// chtimesIn changes the access and modification times of file directly in the directory referred
+// by dfd. Zero times are left unchanged. Symbolic links are not followed.
+func chtimesIn(dfd handle, file string, atime, mtime time.Time) error {
+	ts := []unix.Timespec{unix.NsecToTimespec(atime.UnixNano()), unix.NsecToTimespec(mtime.UnixNano())}
+	if atime.IsZero() || mtime.IsZero() {
+		// UTIME_OMIT is not available everywhere.
+		var st unix.Stat_t
+		if err := unix.Fstatat(dfd, file, &st, unix.AT_SYMLINK_NOFOLLOW); err != nil {
+			return err
+		}
+		if atime.IsZero() {
+			ts[0] = unix.NsecToTimespec(time.Unix(st.Atim.Unix()).UnixNano())
+		}
+		if mtime.IsZero() {
+			ts[1] = unix.NsecToTimespec(time.Unix(st.Mtim.Unix()).UnixNano())
+		}
+	}
+	return unix.UtimesNanoAt(dfd, file, ts, unix.AT_SYMLINK_NOFOLLOW)
+}
+
+// createUnlinkedIn creates a new file directly in the directory referred by dfd and removes it
+// right away, leaving it reachable only through the returned file.
+func createUnlinkedIn(dfd handle, directory string) (*os.File, error) {
+	f, name, err := createRandomIn(dfd, directory, ".scratch", "", 0600)
+	if err != nil {
+		return nil, err
+	}
+	if err := removeIn(dfd, name); err != nil {
+		f.Close()
+		return nil, err
+	}
+	return f, nil
+}
+
 func unixIsFilename(path string) bool {
 	return !(strings.Contains(path, "/") || path == "." || path == "..")
 }
 
+// isFilename reports whether path names a file directly in a directory.
+func isFilename(path string) bool {
+	return path != "" && unixIsFilename(path)
+}
+
 // syscallMode returns the syscall-specific mode bits from Go's portable mode bits.
 func syscallMode(i os.FileMode) (o uint32) {
 	o |= uint32(i.Perm())
@@ -42,3 +223,238 @@ func syscallMode(i os.FileMode) (o uint32) {
 	// No mapping for Go's ModeTemporary (plan9 only).
 	return
 }
+
+// openParentLegacy opens the directory containing file beneath dfd by walking the path one
+// segment at a time, refusing to follow symbolic links at any level. It returns the descriptor of
+// the directory (which is dfd itself if file consists of a single segment) and the last segment
+// of file.
+func openParentLegacy(dfd int, file string) (int, string, error) {
+	segs := strings.Split(file, string(filepath.Separator))
+	if len(segs) > 1 && strictMode.Load() {
+		return 0, "", &os.PathError{Op: "OpenBeneath", Path: file, Err: ErrLegacyResolution}
+	}
+
+	adfd := dfd
+	var err error
+	if len(segs) > 1 {
+		for _, seg := range segs[:len(segs)-1] {
+			// Ignore empty segments
+			if seg == "" {
+				continue
+			}
+
+			odfd := adfd
+
+			adfd, err = openatRetry(adfd, seg, os.O_RDONLY|unix.O_NOFOLLOW|unix.O_DIRECTORY, 0)
+			err = noFollowError(odfd, seg, err)
+
+			// odfd (the previous adfd) is not needed any longer. Closing it right now.
+			if odfd != dfd {
+				if cerr := unix.Close(odfd); cerr != nil {
+					if err == nil {
+						unix.Close(adfd)
+					}
+					return 0, "", cerr
+				}
+			}
+
+			if err != nil {
+				return 0, "", err
+			}
+
+		}
+	}
+	return adfd, segs[len(segs)-1], nil
+}
+
+// openFileNoXDevLegacy opens file beneath dfd as openFileImplLegacy does, failing with an error
+// wrapping ErrCrossDevice if any directory on the way, or the file itself, is on another device
+// than dfd, that is, if the lookup crosses a mount point.
+func openFileNoXDevLegacy(dfd int, file string, flag int, perm os.FileMode) (int, error) {
+	var st unix.Stat_t
+	if err := unix.Fstat(dfd, &st); err != nil {
+		return 0, err
+	}
+	dev := st.Dev
+	// sameDevice checks st, filled by a stat call that returned err.
+	sameDevice := func(err error) error {
+		if err != nil {
+			return err
+		}
+		if st.Dev != dev {
+			return &rejectionError{kind: ErrCrossDevice, err: unix.EXDEV}
+		}
+		return nil
+	}
+
+	segs := strings.Split(file, string(filepath.Separator))
+	if len(segs) > 1 && strictMode.Load() {
+		return 0, ErrLegacyResolution
+	}
+	adfd := dfd
+	defer func() {
+		if adfd != dfd {
+			unix.Close(adfd)
+		}
+	}()
+	for _, seg := range segs[:len(segs)-1] {
+		if seg == "" {
+			continue
+		}
+		fd, err := openatRetry(adfd, seg, os.O_RDONLY|unix.O_NOFOLLOW|unix.O_DIRECTORY, 0)
+		err = noFollowError(adfd, seg, err)
+		if err != nil {
+			return 0, err
+		}
+		if adfd != dfd {
+			unix.Close(adfd)
+		}
+		adfd = fd
+		if err := sameDevice(unix.Fstat(adfd, &st)); err != nil {
+			return 0, err
+		}
+	}
+
+	// Check the file before opening it as well, so that e.g. O_TRUNC does not apply to a file
+	// mounted over it.
+	base := segs[len(segs)-1]
+	if err := sameDevice(unix.Fstatat(adfd, base, &st, unix.AT_SYMLINK_NOFOLLOW)); err != nil && err != unix.ENOENT {
+		return 0, err
+	}
+	fd, err := openatRetry(adfd, base, flag|syscall.O_NOFOLLOW, syscallMode(perm))
+	if err != nil {
+		return 0, noFollowError(adfd, base, err)
+	}
+	if err := sameDevice(unix.Fstat(fd, &st)); err != nil {
+		unix.Close(fd)
+		return 0, err
+	}
+	return fd, nil
+}
+
+// noFollowError returns err, returned by openat with O_NOFOLLOW for name in the directory
+// referred by dfd, wrapped with ErrSymlinkRejected if name is a symbolic link. Platforms report
+// this with different errors (ELOOP, or ENOTDIR with O_DIRECTORY, or EMLINK on FreeBSD), so the
+// file is checked instead.
+func noFollowError(dfd int, name string, err error) error {
+	if err == nil || err == unix.ENOENT {
+		return err
+	}
+	var st unix.Stat_t
+	if unix.Fstatat(dfd, name, &st, unix.AT_SYMLINK_NOFOLLOW) == nil && st.Mode&unix.S_IFMT == unix.S_IFLNK {
+		return symlinkRejected(err)
+	}
+	return err
+}
+
+// openFileImplLegacy opens file beneath dfd by walking the path one segment at a time, refusing
+// to follow symbolic links at any level.
+func openFileImplLegacy(dfd int, file string, flag int, perm os.FileMode) (int, error) {
+	adfd, base, err := openParentLegacy(dfd, file)
+	if err != nil {
+		return 0, err
+	}
+
+	fd, err := openatRetry(adfd, base, flag|syscall.O_NOFOLLOW, syscallMode(perm))
+	err = noFollowError(adfd, base, err)
+	if adfd != dfd {
+		if cerr := unix.Close(adfd); cerr != nil && err == nil {
+			unix.Close(fd)
+			return 0, cerr
+		}
+	}
+	return fd, err
+}
+
+// statIn returns the FileInfo of file beneath dfd. If file is a symbolic link, the link itself
+// is described.
+func statIn(dfd handle, file string) (fs.FileInfo, error) {
+	pfd, base, err := openParentIn(dfd, file)
+	if err != nil {
+		return nil, err
+	}
+	if pfd != dfd {
+		defer unix.Close(pfd)
+	}
+	if base == "" {
+		base = "."
+	}
+
+	st := &fileStat{name: filepath.Base(file)}
+	if err := unix.Fstatat(pfd, base, &st.sys, unix.AT_SYMLINK_NOFOLLOW); err != nil {
+		return nil, &os.PathError{Op: "lstat", Path: file, Err: err}
+	}
+	st.fill()
+	return st, nil
+}
+
+func extendedStatIn(dfd handle, file string) (*ExtendedFileInfo, error) {
+	fi, err := statIn(dfd, file)
+	if err != nil {
+		return nil, err
+	}
+	st := &fi.(*fileStat).sys
+	return &ExtendedFileInfo{
+		FileInfo:   fi,
+		Owner:      strconv.FormatUint(uint64(st.Uid), 10),
+		Group:      strconv.FormatUint(uint64(st.Gid), 10),
+		Device:     uint64(st.Dev),
+		Inode:      uint64(st.Ino),
+		Links:      uint64(st.Nlink),
+		AccessTime: time.Unix(st.Atim.Unix()),
+		ChangeTime: time.Unix(st.Ctim.Unix()),
+		BirthTime:  birthTime(st),
+	}, nil
+}
+
+// fileStat implements fs.FileInfo on top of unix.Stat_t.
+type fileStat struct {
+	name    string
+	size    int64
+	mode    fs.FileMode
+	modTime time.Time
+	sys     unix.Stat_t
+}
+
+func (st *fileStat) Name() string       { return st.name }
+func (st *fileStat) Size() int64        { return st.size }
+func (st *fileStat) Mode() fs.FileMode  { return st.mode }
+func (st *fileStat) ModTime() time.Time { return st.modTime }
+func (st *fileStat) IsDir() bool        { return st.mode.IsDir() }
+func (st *fileStat) Sys() any           { return &st.sys }
+
+// fill converts the raw stat structure, the same way package os does.
+func (st *fileStat) fill() {
+	st.size = st.sys.Size
+	st.modTime = time.Unix(st.sys.Mtim.Unix())
+	st.mode = unixFileMode(uint32(st.sys.Mode))
+}
+
+// unixFileMode converts a raw st_mode to an fs.FileMode, the same way package os does.
+func unixFileMode(mode uint32) fs.FileMode {
+	fm := fs.FileMode(mode & 0777)
+	switch mode & unix.S_IFMT {
+	case unix.S_IFBLK:
+		fm |= fs.ModeDevice
+	case unix.S_IFCHR:
+		fm |= fs.ModeDevice | fs.ModeCharDevice
+	case unix.S_IFDIR:
+		fm |= fs.ModeDir
+	case unix.S_IFIFO:
+		fm |= fs.ModeNamedPipe
+	case unix.S_IFLNK:
+		fm |= fs.ModeSymlink
+	case unix.S_IFSOCK:
+		fm |= fs.ModeSocket
+	}
+	if mode&unix.S_ISGID != 0 {
+		fm |= fs.ModeSetgid
+	}
+	if mode&unix.S_ISUID != 0 {
+		fm |= fs.ModeSetuid
+	}
+	if mode&unix.S_ISVTX != 0 {
+		fm |= fs.ModeSticky
+	}
+	return fm
+}
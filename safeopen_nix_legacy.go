@@ -0,0 +1,28 @@
+// Copyright 2024 Google LLC.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+//go:build unix && !linux && !darwin
+// +build unix,!linux,!darwin
+
+package safeopen
+
+import (
+	"os"
+)
+
+// openFileImplNix opens file beneath dfd with the legacy implementation, the only one available
+// on this platform.
+func openFileImplNix(dfd int, file string, flag int, perm os.FileMode) (int, error) {
+	return openFileImplLegacy(dfd, file, flag, perm)
+}
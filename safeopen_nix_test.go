@@ -167,3 +167,25 @@ func TestUnixDirTraversal(t *testing.T) {
 		t.Errorf("io.ReadAll() = %v, want = %v", string(actualData), fileContent)
 	}
 }
+
+func TestUnixDirLstat(t *testing.T) {
+	basedir := prepareUnixStructure(t)
+
+	d, err := OpenDir(basedir)
+	if err != nil {
+		t.Fatalf("OpenDir(%q) error: %v", basedir, err)
+	}
+	defer d.Close()
+
+	fi, err := d.Lstat("safeopensym")
+	if err != nil {
+		t.Fatalf("Lstat(%q) error: %v", "safeopensym", err)
+	}
+	if fi.Mode()&os.ModeSymlink == 0 {
+		t.Errorf("Lstat(%q).Mode() = %v, want a symlink", "safeopensym", fi.Mode())
+	}
+
+	if _, err := d.Lstat("safeopensym/safeopentarget"); err == nil {
+		t.Errorf("Lstat(%q) should have been an error", "safeopensym/safeopentarget")
+	}
+}
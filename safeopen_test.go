@@ -15,6 +15,9 @@
 package safeopen
 
 import (
+	"errors"
+	"fmt"
+	"io/fs"
 	"os"
 	"path"
 	"testing"
@@ -77,3 +80,223 @@ func TestBeneath(t *testing.T) {
 		t.Errorf("ReadFileAt(%q, %q) = %q, want %q", tmpDir, filenameInSubdir, adata, edata)
 	}
 }
+
+func TestReadFilesAt(t *testing.T) {
+	tmpDir := t.TempDir()
+	names := make([]string, 20)
+	for i := range names {
+		names[i] = fmt.Sprintf("fragment%02d.conf", i)
+		if err := os.WriteFile(path.Join(tmpDir, names[i]), []byte(names[i]), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	files, err := ReadFilesAt(tmpDir, names)
+	if err != nil {
+		t.Fatalf("ReadFilesAt(%q) error: %v", tmpDir, err)
+	}
+	if len(files) != len(names) {
+		t.Errorf("ReadFilesAt(%q) returned %d files, want %d", tmpDir, len(files), len(names))
+	}
+	for _, name := range names {
+		if string(files[name]) != name {
+			t.Errorf("ReadFilesAt(%q)[%q] = %q, want %q", tmpDir, name, files[name], name)
+		}
+	}
+
+	for _, bad := range []string{"missing.conf", "../" + names[0]} {
+		files, err = ReadFilesAt(tmpDir, append(names, bad))
+		if err == nil || files != nil {
+			t.Errorf("ReadFilesAt(%q) with %q = %v, %v, want an error", tmpDir, bad, files, err)
+		}
+	}
+}
+
+func TestWriteFileBuffers(t *testing.T) {
+	tmpDir := t.TempDir()
+	if err := os.Mkdir(path.Join(tmpDir, "subdir"), 0755); err != nil {
+		t.Fatal(err)
+	}
+
+	var bufs [][]byte
+	var want []byte
+	for i := 0; i < 2000; i++ {
+		buf := []byte(fmt.Sprintf("chunk %d;", i))
+		if i%7 == 0 {
+			buf = nil
+		}
+		bufs = append(bufs, buf)
+		want = append(want, buf...)
+	}
+	orig := append([][]byte(nil), bufs...)
+
+	if err := WriteFileBuffersAt(tmpDir, "data.txt", bufs, 0644); err != nil {
+		t.Fatalf("WriteFileBuffersAt() error: %v", err)
+	}
+	if err := WriteFileBuffersBeneath(tmpDir, "subdir/data.txt", bufs, 0644); err != nil {
+		t.Fatalf("WriteFileBuffersBeneath() error: %v", err)
+	}
+	for _, file := range []string{"data.txt", "subdir/data.txt"} {
+		adata, err := os.ReadFile(path.Join(tmpDir, file))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(adata) != string(want) {
+			t.Errorf("%s has %d bytes, want %d", file, len(adata), len(want))
+		}
+	}
+	for i := range bufs {
+		if string(bufs[i]) != string(orig[i]) {
+			t.Fatalf("the buffers passed to WriteFileBuffersAt have been modified")
+		}
+	}
+
+	if err := WriteFileBuffersAt(tmpDir, "subdir/data.txt", bufs, 0644); err == nil {
+		t.Errorf("WriteFileBuffersAt(%q, %q) should have been an error", tmpDir, "subdir/data.txt")
+	}
+}
+
+func TestWriteFileNewAt(t *testing.T) {
+	tmpDir := t.TempDir()
+
+	if err := WriteFileNewAt(tmpDir, "receipt.txt", []byte("first"), 0644); err != nil {
+		t.Fatalf("WriteFileNewAt(%q, %q) error: %v", tmpDir, "receipt.txt", err)
+	}
+	if err := WriteFileNewAt(tmpDir, "receipt.txt", []byte("second"), 0644); !errors.Is(err, fs.ErrExist) {
+		t.Errorf("WriteFileNewAt(%q, %q) of an existing file error = %v, want %v", tmpDir, "receipt.txt", err, fs.ErrExist)
+	}
+	data, err := os.ReadFile(path.Join(tmpDir, "receipt.txt"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(data) != "first" {
+		t.Errorf("receipt.txt = %q, want %q", data, "first")
+	}
+
+	if err := WriteFileNewAt(tmpDir, "subdir/receipt.txt", []byte("first"), 0644); err == nil {
+		t.Errorf("WriteFileNewAt(%q, %q) succeeded, want error", tmpDir, "subdir/receipt.txt")
+	}
+}
+
+func TestCreateExclusive(t *testing.T) {
+	tmpDir := t.TempDir()
+	if err := os.Mkdir(path.Join(tmpDir, "leases"), 0755); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, create := range []struct {
+		name string
+		fn   func(directory, file string) (*os.File, error)
+		file string
+	}{
+		{"CreateExclusiveAt", CreateExclusiveAt, "job.lease"},
+		{"CreateExclusiveBeneath", CreateExclusiveBeneath, "leases/job.lease"},
+	} {
+		f, err := create.fn(tmpDir, create.file)
+		if err != nil {
+			t.Fatalf("%s(%q) error: %v", create.name, create.file, err)
+		}
+		if _, err := f.WriteString("owner"); err != nil {
+			t.Errorf("%s(%q).WriteString error: %v", create.name, create.file, err)
+		}
+		f.Close()
+
+		if f, err := create.fn(tmpDir, create.file); !errors.Is(err, fs.ErrExist) {
+			if err == nil {
+				f.Close()
+			}
+			t.Errorf("%s(%q) of an existing file error = %v, want %v", create.name, create.file, err, fs.ErrExist)
+		}
+		data, err := os.ReadFile(path.Join(tmpDir, create.file))
+		if err != nil || string(data) != "owner" {
+			t.Errorf("%s = %q, %v, want %q", create.file, data, err, "owner")
+		}
+	}
+
+	if _, err := CreateExclusiveAt(tmpDir, "leases/other.lease"); err == nil {
+		t.Errorf("CreateExclusiveAt(%q) succeeded, want error", "leases/other.lease")
+	}
+	if _, err := CreateExclusiveBeneath(tmpDir, "../other.lease"); err == nil {
+		t.Errorf("CreateExclusiveBeneath(%q) succeeded, want error", "../other.lease")
+	}
+}
+
+func TestOpenFileExclusive(t *testing.T) {
+	tmpDir := t.TempDir()
+	if err := os.WriteFile(path.Join(tmpDir, "data.txt"), []byte("hello"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	// O_EXCL takes precedence over O_TRUNC: the existing file must be left untouched.
+	if f, err := OpenFileAt(tmpDir, "data.txt", os.O_RDWR|os.O_CREATE|os.O_EXCL|os.O_TRUNC, 0644); !errors.Is(err, fs.ErrExist) {
+		if err == nil {
+			f.Close()
+		}
+		t.Errorf("OpenFileAt(O_CREATE|O_EXCL|O_TRUNC) of an existing file error = %v, want %v", err, fs.ErrExist)
+	}
+	if data, err := os.ReadFile(path.Join(tmpDir, "data.txt")); err != nil || string(data) != "hello" {
+		t.Errorf("data.txt = %q, %v, want %q", data, err, "hello")
+	}
+
+	// O_TRUNC alone does not create the file.
+	if f, err := OpenFileAt(tmpDir, "missing.txt", os.O_WRONLY|os.O_TRUNC, 0644); !errors.Is(err, fs.ErrNotExist) {
+		if err == nil {
+			f.Close()
+		}
+		t.Errorf("OpenFileAt(O_TRUNC) of a missing file error = %v, want %v", err, fs.ErrNotExist)
+	}
+}
+
+func TestAppendFile(t *testing.T) {
+	tmpDir := t.TempDir()
+	if err := os.Mkdir(path.Join(tmpDir, "logs"), 0755); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, line := range []string{"first\n", "second\n"} {
+		if err := AppendFileAt(tmpDir, "app.log", []byte(line), 0644); err != nil {
+			t.Fatalf("AppendFileAt(%q, %q) error: %v", tmpDir, "app.log", err)
+		}
+		if err := AppendFileBeneath(tmpDir, "logs/app.log", []byte(line), 0644); err != nil {
+			t.Fatalf("AppendFileBeneath(%q, %q) error: %v", tmpDir, "logs/app.log", err)
+		}
+	}
+	for _, file := range []string{"app.log", "logs/app.log"} {
+		data, err := os.ReadFile(path.Join(tmpDir, file))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if want := "first\nsecond\n"; string(data) != want {
+			t.Errorf("%s = %q, want %q", file, data, want)
+		}
+	}
+
+	if err := AppendFileAt(tmpDir, "logs/app.log", []byte("third\n"), 0644); err == nil {
+		t.Errorf("AppendFileAt(%q, %q) succeeded, want error", tmpDir, "logs/app.log")
+	}
+	if err := AppendFileBeneath(tmpDir, "../app.log", []byte("third\n"), 0644); err == nil {
+		t.Errorf("AppendFileBeneath(%q, %q) succeeded, want error", tmpDir, "../app.log")
+	}
+}
+
+func TestWithinBase(t *testing.T) {
+	tests := []struct {
+		relpath string
+		want    bool
+	}{
+		{"data.txt", true},
+		{"subdir/data.txt", true},
+		{"subdir/../data.txt", true},
+		{"./data.txt", true},
+		{"", false},
+		{"..", false},
+		{"../data.txt", false},
+		{"subdir/../../data.txt", false},
+		{"data\x00.txt", false},
+	}
+	for _, tc := range tests {
+		if got := WithinBase(tc.relpath); got != tc.want {
+			t.Errorf("WithinBase(%q) = %t, want %t", tc.relpath, got, tc.want)
+		}
+	}
+}
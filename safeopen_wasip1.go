@@ -0,0 +1,650 @@
+// Copyright 2024 Google LLC.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+//go:build wasip1
+// +build wasip1
+
+package safeopen
+
+import (
+	"io/fs"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"syscall"
+	"time"
+	"unsafe"
+)
+
+// handle is a WASI file descriptor of an opened base directory.
+type handle = int
+
+// WASI preview 1 constants, see
+// https://github.com/WebAssembly/WASI/blob/main/legacy/preview1/docs.md.
+const (
+	wasiLookupSymlinkFollow = 0x1
+
+	wasiOflagCreate    = 0x1
+	wasiOflagDirectory = 0x2
+	wasiOflagExcl      = 0x4
+	wasiOflagTrunc     = 0x8
+
+	wasiFdflagAppend = 0x1
+	wasiFdflagSync   = 0x10
+
+	wasiFstflagAtim = 0x1
+	wasiFstflagMtim = 0x4
+
+	wasiFiletypeBlockDevice     = 1
+	wasiFiletypeCharacterDevice = 2
+	wasiFiletypeDirectory       = 3
+	wasiFiletypeSymbolicLink    = 7
+
+	// wasiFileRights are all the rights applying to files, bits 0 (fd_datasync) to 27
+	// (poll_fd_readwrite). Runtimes refuse to open directories with rights beyond
+	// wasiDirRights, and files opened for reading or writing only are requested without
+	// wasiWriteRights or wasiReadRights, as done by package syscall.
+	wasiFileRights  = 1<<28 - 1
+	wasiDirRights   = wasiFileRights &^ (1<<0 | 1<<1 | 1<<5 | 1<<6 | 1<<7 | 1<<8 | 1<<22 | 1<<27)
+	wasiReadRights  = 1<<1 | 1<<14
+	wasiWriteRights = 1<<0 | 1<<6 | 1<<8 | 1<<19
+)
+
+//go:wasmimport wasi_snapshot_preview1 path_open
+//go:noescape
+func wasiPathOpen(fd int32, dirflags uint32, path unsafe.Pointer, pathLen uint32, oflags uint32, rightsBase uint64, rightsInheriting uint64, fdflags uint32, result unsafe.Pointer) uint32
+
+//go:wasmimport wasi_snapshot_preview1 path_filestat_get
+//go:noescape
+func wasiPathFilestatGet(fd int32, flags uint32, path unsafe.Pointer, pathLen uint32, result unsafe.Pointer) uint32
+
+//go:wasmimport wasi_snapshot_preview1 path_filestat_set_times
+//go:noescape
+func wasiPathFilestatSetTimes(fd int32, flags uint32, path unsafe.Pointer, pathLen uint32, atim uint64, mtim uint64, fstflags uint32) uint32
+
+//go:wasmimport wasi_snapshot_preview1 path_readlink
+//go:noescape
+func wasiPathReadlink(fd int32, path unsafe.Pointer, pathLen uint32, buf unsafe.Pointer, bufLen uint32, result unsafe.Pointer) uint32
+
+//go:wasmimport wasi_snapshot_preview1 path_create_directory
+//go:noescape
+func wasiPathCreateDirectory(fd int32, path unsafe.Pointer, pathLen uint32) uint32
+
+//go:wasmimport wasi_snapshot_preview1 path_remove_directory
+//go:noescape
+func wasiPathRemoveDirectory(fd int32, path unsafe.Pointer, pathLen uint32) uint32
+
+//go:wasmimport wasi_snapshot_preview1 path_unlink_file
+//go:noescape
+func wasiPathUnlinkFile(fd int32, path unsafe.Pointer, pathLen uint32) uint32
+
+//go:wasmimport wasi_snapshot_preview1 path_rename
+//go:noescape
+func wasiPathRename(oldFd int32, oldPath unsafe.Pointer, oldPathLen uint32, newFd int32, newPath unsafe.Pointer, newPathLen uint32) uint32
+
+//go:wasmimport wasi_snapshot_preview1 path_link
+//go:noescape
+func wasiPathLink(oldFd int32, oldFlags uint32, oldPath unsafe.Pointer, oldPathLen uint32, newFd int32, newPath unsafe.Pointer, newPathLen uint32) uint32
+
+//go:wasmimport wasi_snapshot_preview1 path_symlink
+//go:noescape
+func wasiPathSymlink(oldPath unsafe.Pointer, oldPathLen uint32, fd int32, newPath unsafe.Pointer, newPathLen uint32) uint32
+
+// wasiString returns a pointer to the bytes of s and its length, as WASI expects strings.
+func wasiString(s string) (unsafe.Pointer, uint32) {
+	return unsafe.Pointer(unsafe.StringData(s)), uint32(len(s))
+}
+
+// wasiError converts a WASI errno to an error.
+func wasiError(errno uint32) error {
+	if errno == 0 {
+		return nil
+	}
+	return syscall.Errno(errno)
+}
+
+// openIn opens path relative to the directory referred by dfd with path_open. Symbolic links
+// are only followed in the last element of path if follow is set, and path must be a directory
+// if directory is set.
+func openIn(dfd int, path string, flag int, follow, directory bool) (int, error) {
+	var oflags, fdflags, lookup uint32
+	if flag&os.O_CREATE != 0 {
+		oflags |= wasiOflagCreate
+	}
+	if flag&os.O_TRUNC != 0 {
+		oflags |= wasiOflagTrunc
+	}
+	if flag&os.O_EXCL != 0 {
+		oflags |= wasiOflagExcl
+	}
+	if flag&os.O_APPEND != 0 {
+		fdflags |= wasiFdflagAppend
+	}
+	if flag&os.O_SYNC != 0 {
+		fdflags |= wasiFdflagSync
+	}
+	if follow {
+		lookup = wasiLookupSymlinkFollow
+	}
+
+	var rights uint64
+	switch flag & (os.O_RDONLY | os.O_WRONLY | os.O_RDWR) {
+	case os.O_RDONLY:
+		rights = wasiFileRights &^ wasiWriteRights
+	case os.O_WRONLY:
+		rights = wasiFileRights &^ wasiReadRights
+	default:
+		rights = wasiFileRights
+	}
+	if directory {
+		oflags |= wasiOflagDirectory
+		rights = wasiDirRights
+	}
+
+	p, n := wasiString(path)
+	var fd int32
+	errno := wasiPathOpen(int32(dfd), lookup, p, n, oflags, rights, wasiFileRights, fdflags, unsafe.Pointer(&fd))
+	if syscall.Errno(errno) == syscall.EISDIR && !directory && oflags == 0 && fdflags == 0 && rights&wasiWriteRights == 0 {
+		// As in package syscall, retry with the rights of a directory, which runtimes require.
+		errno = wasiPathOpen(int32(dfd), lookup, p, n, oflags|wasiOflagDirectory, rights&wasiDirRights, wasiFileRights, fdflags, unsafe.Pointer(&fd))
+	}
+	if err := wasiError(errno); err != nil {
+		return -1, err
+	}
+	return int(fd), nil
+}
+
+// openNoFollowIn opens name directly in the directory referred by dfd, refusing to follow a
+// symbolic link. Some runtimes do not honor the lookup flags of path_open, so name is checked
+// before opening it, and the opened file is checked against it afterwards.
+func openNoFollowIn(dfd int, name string, flag int, directory bool) (int, error) {
+	var before syscall.Stat_t
+	err := lstatIn(dfd, name, &before)
+	if err == nil && before.Filetype == wasiFiletypeSymbolicLink {
+		return -1, symlinkRejected(syscall.ELOOP)
+	}
+	if err != nil && err != syscall.ENOENT {
+		return -1, err
+	}
+	exists := err == nil
+
+	fd, err := openIn(dfd, name, flag, false, directory)
+	if err != nil {
+		return -1, noFollowError(dfd, name, err)
+	}
+	if exists {
+		var after syscall.Stat_t
+		if err := syscall.Fstat(fd, &after); err != nil {
+			syscall.Close(fd)
+			return -1, err
+		}
+		if after.Dev != before.Dev || after.Ino != before.Ino {
+			// name was replaced while being opened, possibly by a symbolic link.
+			syscall.Close(fd)
+			return -1, noFollowError(dfd, name, syscall.EAGAIN)
+		}
+	}
+	return fd, nil
+}
+
+// lstatIn fills st with the status of path relative to the directory referred by dfd, without
+// following symbolic links.
+func lstatIn(dfd int, path string, st *syscall.Stat_t) error {
+	p, n := wasiString(path)
+	return wasiError(wasiPathFilestatGet(int32(dfd), 0, p, n, unsafe.Pointer(st)))
+}
+
+// openDirHandle opens directory, resolved by package syscall against the preopened directories.
+// The descriptor it returns lacks some of the rights needed on a directory, such as truncating
+// files in it, so the directory is opened again through it, with all of them.
+func openDirHandle(directory string) (handle, error) {
+	fd, err := syscall.Open(directory, os.O_RDONLY, 0)
+	if err != nil {
+		return -1, err
+	}
+	defer syscall.Close(fd)
+	return openIn(fd, ".", os.O_RDONLY, false, true)
+}
+
+func closeHandle(dfd handle) error {
+	return syscall.Close(dfd)
+}
+
+func syncHandle(dfd handle) error {
+	return syscall.Fsync(dfd)
+}
+
+// cwdHandle returns a handle referring to the current working directory, and a function
+// releasing it. WASI has no descriptor for the current directory, so it is opened.
+func cwdHandle() (handle, func(), error) {
+	fd, err := openDirHandle(".")
+	if err != nil {
+		return -1, nil, err
+	}
+	return fd, func() { syscall.Close(fd) }, nil
+}
+
+// adoptDirHandle checks that the inherited descriptor fd refers to a directory.
+func adoptDirHandle(fd uintptr) (handle, error) {
+	var st syscall.Stat_t
+	if err := syscall.Fstat(int(fd), &st); err != nil {
+		return -1, err
+	}
+	if st.Filetype != wasiFiletypeDirectory {
+		return -1, syscall.ENOTDIR
+	}
+	return int(fd), nil
+}
+
+// processExists reports true, as WASI has no notion of other processes: the owner of a lock is
+// never presumed dead.
+func processExists(pid int) bool {
+	return true
+}
+
+// passFiles is not supported on this platform, which cannot start processes.
+func passFiles(cmd *exec.Cmd, files []*os.File) ([]uintptr, error) {
+	return nil, syscall.ENOSYS
+}
+
+// isFilename reports whether path names a file directly in a directory.
+func isFilename(path string) bool {
+	return path != "" && path != "." && path != ".." && !strings.Contains(path, "/")
+}
+
+// isRelPathBeneath reports whether path is accepted by the Beneath functions.
+func isRelPathBeneath(path string) bool {
+	return filepath.IsLocal(path)
+}
+
+// noFollowError returns err, returned by path_open without following symbolic links for name in
+// the directory referred by dfd, wrapped with ErrSymlinkRejected if name is a symbolic link.
+func noFollowError(dfd int, name string, err error) error {
+	if err == nil || err == syscall.ENOENT {
+		return err
+	}
+	var st syscall.Stat_t
+	if lstatIn(dfd, name, &st) == nil && st.Filetype == wasiFiletypeSymbolicLink {
+		return symlinkRejected(err)
+	}
+	return err
+}
+
+// openParentIn opens the directory containing file beneath dfd, with the same rules as
+// OpenBeneath. It returns the descriptor of the directory (which is dfd itself if file is
+// directly in it) and the last element of file.
+func openParentIn(dfd handle, file string) (int, string, error) {
+	return openParentWalk(dfd, file, nil)
+}
+
+// openParentWalk opens the directory containing file beneath dfd by walking the cleaned path one
+// element at a time, refusing to follow symbolic links at any level. If dev is not nil, each
+// directory on the way must be on device *dev.
+func openParentWalk(dfd int, file string, dev *uint64) (int, string, error) {
+	if !isRelPathBeneath(file) {
+		return -1, "", &os.PathError{Op: "OpenBeneath", Path: file, Err: beneathPathError(file)}
+	}
+	segs := strings.Split(filepath.Clean(file), "/")
+	if len(segs) > 1 && strictMode.Load() {
+		return -1, "", &os.PathError{Op: "OpenBeneath", Path: file, Err: ErrLegacyResolution}
+	}
+
+	adfd := dfd
+	for _, seg := range segs[:len(segs)-1] {
+		fd, err := openNoFollowIn(adfd, seg, os.O_RDONLY, true)
+		if err == nil && dev != nil {
+			err = checkDeviceIn(fd, *dev)
+			if err != nil {
+				syscall.Close(fd)
+			}
+		}
+		if adfd != dfd {
+			syscall.Close(adfd)
+		}
+		if err != nil {
+			return -1, "", err
+		}
+		adfd = fd
+	}
+	return adfd, segs[len(segs)-1], nil
+}
+
+// checkDeviceIn returns an error wrapping ErrCrossDevice if fd is not on device dev.
+func checkDeviceIn(fd int, dev uint64) error {
+	var st syscall.Stat_t
+	if err := syscall.Fstat(fd, &st); err != nil {
+		return err
+	}
+	if st.Dev != dev {
+		return &rejectionError{kind: ErrCrossDevice, err: syscall.EXDEV}
+	}
+	return nil
+}
+
+// openBeneathIn opens file beneath dfd, refusing to follow symbolic links at any level, and to
+// cross devices if dev is not nil.
+func openBeneathIn(dfd handle, directory, file string, flag int, perm os.FileMode, dev *uint64) (*os.File, error) {
+	pfd, base, err := openParentWalk(dfd, file, dev)
+	if err != nil {
+		if _, ok := err.(*os.PathError); ok {
+			return nil, err
+		}
+		return nil, &os.PathError{Op: "openat", Path: filepath.Join(directory, file), Err: err}
+	}
+	if pfd != dfd {
+		defer syscall.Close(pfd)
+	}
+
+	fd, err := openNoFollowIn(pfd, base, flag, false)
+	if err == nil && dev != nil {
+		if err = checkDeviceIn(fd, *dev); err != nil {
+			syscall.Close(fd)
+		}
+	}
+	if err != nil {
+		return nil, &os.PathError{Op: "openat", Path: filepath.Join(directory, file), Err: err}
+	}
+	return os.NewFile(uintptr(fd), filepath.Join(directory, file)), nil
+}
+
+func openFileAtIn(dfd handle, directory, file string, flag int, perm os.FileMode) (*os.File, error) {
+	if !isFilename(file) {
+		return nil, &os.PathError{Op: "OpenAt", Path: file, Err: ErrInvalidFilename}
+	}
+	return openBeneathIn(dfd, directory, file, flag, perm, nil)
+}
+
+// openFileBeneathIn opens file beneath dfd. As WASI runtimes differ in how strictly they confine
+// the resolution of paths to the directory, it is walked one element at a time and symbolic
+// links are never followed, as with the legacy implementation on Unix.
+func openFileBeneathIn(dfd handle, directory, file string, flag int, perm os.FileMode) (*os.File, error) {
+	return openBeneathIn(dfd, directory, file, flag, perm, nil)
+}
+
+// openFileNoFollowIn opens file beneath dfd, refusing to follow symbolic links at any level.
+// openFileBeneathIn already behaves this way on this platform.
+func openFileNoFollowIn(dfd handle, directory, file string, flag int, perm os.FileMode) (*os.File, error) {
+	return openFileBeneathIn(dfd, directory, file, flag, perm)
+}
+
+// openFileNoXDevIn opens file beneath dfd, refusing to cross mount points. Symbolic links are
+// never followed on this platform, whatever noFollow.
+func openFileNoXDevIn(dfd handle, directory, file string, flag int, perm os.FileMode, noFollow bool) (*os.File, error) {
+	var st syscall.Stat_t
+	if err := syscall.Fstat(dfd, &st); err != nil {
+		return nil, &os.PathError{Op: "fstat", Path: directory, Err: err}
+	}
+	return openBeneathIn(dfd, directory, file, flag, perm, &st.Dev)
+}
+
+// openDirNoFollowIn opens the directory dir beneath dfd for reading its entries, refusing to
+// follow symbolic links at any level.
+func openDirNoFollowIn(dfd handle, directory, dir string) (*os.File, error) {
+	pfd, base, err := openParentIn(dfd, dir)
+	if err != nil {
+		return nil, routedError("openat", err, filepath.Join(directory, dir))
+	}
+	if pfd != dfd {
+		defer syscall.Close(pfd)
+	}
+	fd, err := openNoFollowIn(pfd, base, os.O_RDONLY, true)
+	if err != nil {
+		return nil, &os.PathError{Op: "openat", Path: filepath.Join(directory, dir), Err: err}
+	}
+	return os.NewFile(uintptr(fd), filepath.Join(directory, dir)), nil
+}
+
+// createScratchIn creates an anonymous file in the directory referred by dfd. WASI has no
+// O_TMPFILE, so the file is removed right after its creation.
+func createScratchIn(dfd handle, directory string) (*os.File, error) {
+	f, name, err := createRandomIn(dfd, directory, ".scratch", "", 0600)
+	if err != nil {
+		return nil, err
+	}
+	if err := removeIn(dfd, name); err != nil {
+		f.Close()
+		return nil, err
+	}
+	return f, nil
+}
+
+// checkResolveBeneathIn returns ErrLegacyResolution, as paths are walked one element at a time
+// on this platform.
+func checkResolveBeneathIn(dfd handle) error {
+	return ErrLegacyResolution
+}
+
+// kernelBeneathSupported reports false, as paths are walked one element at a time on this
+// platform.
+func kernelBeneathSupported() bool {
+	return false
+}
+
+// writeBuffers writes bufs to f. Vectored I/O is not used on this platform.
+func writeBuffers(f *os.File, bufs [][]byte) error {
+	return writeBuffersLoop(f, bufs)
+}
+
+// renameIn renames oldfile to newfile, both directly in the directory referred by dfd.
+func renameIn(dfd handle, oldfile, newfile string) error {
+	op, on := wasiString(oldfile)
+	np, nn := wasiString(newfile)
+	return wasiError(wasiPathRename(int32(dfd), op, on, int32(dfd), np, nn))
+}
+
+// renameNoReplaceIn renames oldfile, directly in the directory referred by odfd, to newfile,
+// directly in the directory referred by ndfd, failing if newfile exists. As on Unix systems
+// without renameat2, files are hard linked to their new name and then unlinked, and other
+// files are renamed after checking that newfile does not exist, which is racy.
+func renameNoReplaceIn(odfd handle, oldfile string, ndfd handle, newfile string) error {
+	err := linkBetween(odfd, oldfile, ndfd, newfile)
+	if err == nil {
+		if err := removeIn(odfd, oldfile); err != nil {
+			removeIn(ndfd, newfile)
+			return err
+		}
+		return nil
+	}
+	if err == syscall.EEXIST || err == syscall.ENOENT {
+		return err
+	}
+	var st syscall.Stat_t
+	err = lstatIn(ndfd, newfile, &st)
+	if err == nil {
+		return syscall.EEXIST
+	}
+	if err != syscall.ENOENT {
+		return err
+	}
+	op, on := wasiString(oldfile)
+	np, nn := wasiString(newfile)
+	return wasiError(wasiPathRename(int32(odfd), op, on, int32(ndfd), np, nn))
+}
+
+// exchangeIn is not supported on this platform.
+func exchangeIn(dfd handle, a, b string) error {
+	return syscall.ENOTSUP
+}
+
+// removeIn removes file directly in the directory referred by dfd. As with os.Remove, file may
+// be an empty directory.
+func removeIn(dfd handle, file string) error {
+	p, n := wasiString(file)
+	err := wasiError(wasiPathUnlinkFile(int32(dfd), p, n))
+	if err == nil || err == syscall.ENOENT {
+		return err
+	}
+	err1 := wasiError(wasiPathRemoveDirectory(int32(dfd), p, n))
+	if err1 == nil {
+		return nil
+	}
+	if err1 != syscall.ENOTDIR {
+		err = err1
+	}
+	return err
+}
+
+// mkdirIn creates the directory dir directly in the directory referred by dfd. WASI has no
+// permission bits, so perm is ignored.
+func mkdirIn(dfd handle, dir string, perm os.FileMode) error {
+	p, n := wasiString(dir)
+	return wasiError(wasiPathCreateDirectory(int32(dfd), p, n))
+}
+
+// chmodIn only checks that file exists directly in the directory referred by dfd, as WASI has
+// no permission bits, like os.Chmod does.
+func chmodIn(dfd handle, file string, mode os.FileMode) error {
+	var st syscall.Stat_t
+	return lstatIn(dfd, file, &st)
+}
+
+// chownIn is not supported on this platform, as with os.Chown.
+func chownIn(dfd handle, file string, uid, gid int) error {
+	return syscall.ENOSYS
+}
+
+// chtimesIn changes the access and modification times of file directly in the directory referred
+// by dfd. Zero times are left unchanged. Symbolic links are not followed.
+func chtimesIn(dfd handle, file string, atime, mtime time.Time) error {
+	var flags uint32
+	var atim, mtim uint64
+	if !atime.IsZero() {
+		flags |= wasiFstflagAtim
+		atim = uint64(atime.UnixNano())
+	}
+	if !mtime.IsZero() {
+		flags |= wasiFstflagMtim
+		mtim = uint64(mtime.UnixNano())
+	}
+	p, n := wasiString(file)
+	return wasiError(wasiPathFilestatSetTimes(int32(dfd), 0, p, n, atim, mtim, flags))
+}
+
+// linkIn creates newfile as a hard link to oldfile, both directly in the directory referred by
+// dfd.
+func linkIn(dfd handle, oldfile, newfile string) error {
+	return linkBetween(dfd, oldfile, dfd, newfile)
+}
+
+// linkBetween creates newfile, directly in the directory referred by ndfd, as a hard link to
+// oldfile, directly in the directory referred by odfd. It fails if newfile already exists.
+func linkBetween(odfd handle, oldfile string, ndfd handle, newfile string) error {
+	op, on := wasiString(oldfile)
+	np, nn := wasiString(newfile)
+	return wasiError(wasiPathLink(int32(odfd), 0, op, on, int32(ndfd), np, nn))
+}
+
+// symlinkIn creates link, directly in the directory referred by dfd, as a symbolic link to target.
+func symlinkIn(dfd handle, target, link string) error {
+	tp, tn := wasiString(target)
+	lp, ln := wasiString(link)
+	return wasiError(wasiPathSymlink(tp, tn, int32(dfd), lp, ln))
+}
+
+// readlinkIn returns the target of the symbolic link file beneath dfd. The directories leading
+// to file are resolved as by OpenBeneath.
+func readlinkIn(dfd handle, file string) (string, error) {
+	pfd, base, err := openParentIn(dfd, file)
+	if err != nil {
+		return "", err
+	}
+	if pfd != dfd {
+		defer syscall.Close(pfd)
+	}
+
+	p, n := wasiString(base)
+	for size := 128; ; size *= 2 {
+		buf := make([]byte, size)
+		var nwritten uint32
+		if err := wasiError(wasiPathReadlink(int32(pfd), p, n, unsafe.Pointer(&buf[0]), uint32(size), unsafe.Pointer(&nwritten))); err != nil {
+			return "", &os.PathError{Op: "readlink", Path: file, Err: err}
+		}
+		if int(nwritten) < size {
+			return string(buf[:nwritten]), nil
+		}
+	}
+}
+
+// statIn returns the FileInfo of file beneath dfd. If file is a symbolic link, the link itself
+// is described.
+func statIn(dfd handle, file string) (fs.FileInfo, error) {
+	pfd, base, err := openParentIn(dfd, file)
+	if err != nil {
+		return nil, err
+	}
+	if pfd != dfd {
+		defer syscall.Close(pfd)
+	}
+
+	st := &fileStat{name: filepath.Base(file)}
+	if err := lstatIn(pfd, base, &st.sys); err != nil {
+		return nil, &os.PathError{Op: "lstat", Path: file, Err: err}
+	}
+	st.fill()
+	return st, nil
+}
+
+// extendedStatIn returns the ExtendedFileInfo of file beneath dfd. WASI has no owners, so Owner
+// and Group are empty, nor birth times.
+func extendedStatIn(dfd handle, file string) (*ExtendedFileInfo, error) {
+	fi, err := statIn(dfd, file)
+	if err != nil {
+		return nil, err
+	}
+	st := &fi.(*fileStat).sys
+	return &ExtendedFileInfo{
+		FileInfo:   fi,
+		Device:     st.Dev,
+		Inode:      st.Ino,
+		Links:      st.Nlink,
+		AccessTime: time.Unix(0, int64(st.Atime)),
+		ChangeTime: time.Unix(0, int64(st.Ctime)),
+	}, nil
+}
+
+// fileStat implements fs.FileInfo on top of syscall.Stat_t.
+type fileStat struct {
+	name    string
+	size    int64
+	mode    fs.FileMode
+	modTime time.Time
+	sys     syscall.Stat_t
+}
+
+func (st *fileStat) Name() string       { return st.name }
+func (st *fileStat) Size() int64        { return st.size }
+func (st *fileStat) Mode() fs.FileMode  { return st.mode }
+func (st *fileStat) ModTime() time.Time { return st.modTime }
+func (st *fileStat) IsDir() bool        { return st.mode.IsDir() }
+func (st *fileStat) Sys() any           { return &st.sys }
+
+// fill converts the raw stat structure, the same way package os does: WASI has no permission
+// bits, so directories get 0700 and other files 0600.
+func (st *fileStat) fill() {
+	st.size = int64(st.sys.Size)
+	st.modTime = time.Unix(0, int64(st.sys.Mtime))
+	switch st.sys.Filetype {
+	case wasiFiletypeDirectory:
+		st.mode = fs.ModeDir | 0700
+	case wasiFiletypeSymbolicLink:
+		st.mode = fs.ModeSymlink | 0600
+	case wasiFiletypeBlockDevice:
+		st.mode = fs.ModeDevice | 0600
+	case wasiFiletypeCharacterDevice:
+		st.mode = fs.ModeDevice | fs.ModeCharDevice | 0600
+	default:
+		st.mode = 0600
+	}
+}
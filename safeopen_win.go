@@ -19,16 +19,23 @@ package safeopen
 
 import (
 	"errors"
+	"io/fs"
 	"os"
 	"path/filepath"
 	"strings"
+	"time"
 	"unsafe"
 
 	"golang.org/x/sys/windows"
 )
 
 func winIsSimpleFilename(path string) bool {
-	return !(strings.Contains(path, "/") || strings.Contains(path, `\`) || path == "." || path == "..")
+	return !(strings.Contains(path, "/") || strings.Contains(path, `\`) || path == "." || path == ".." || hasDeviceName(path) || hasTrailingDotOrSpace(path))
+}
+
+// isFilename reports whether path names a file directly in a directory.
+func isFilename(path string) bool {
+	return path != "" && winIsSimpleFilename(path)
 }
 
 func winRelativePathDoesntTraverse(path string) (string, bool) {
@@ -40,6 +47,11 @@ func winRelativePathDoesntTraverse(path string) (string, bool) {
 	if strings.Contains(path, "?") {
 		return "", false
 	}
+	// Names reserved for devices, or ending with a dot or a space, are only ordinary names
+	// relative to a handle.
+	if hasDeviceName(path) || hasTrailingDotOrSpace(path) {
+		return "", false
+	}
 
 	hasDots := false
 	// Normalizing directory separator as Windows accepts both:
@@ -61,6 +73,13 @@ func winRelativePathDoesntTraverse(path string) (string, bool) {
 	return path, true
 }
 
+// isRelPathBeneath reports whether path is accepted by the Beneath functions. Paths with a
+// volume name or an alternate data stream are rejected as well.
+func isRelPathBeneath(path string) bool {
+	_, ok := winRelativePathDoesntTraverse(path)
+	return ok && filepath.VolumeName(path) == "" && !strings.Contains(path, ":")
+}
+
 func winOpenAt(dfd windows.Handle, file string, access, disposition, options uint32) (windows.Handle, error) {
 	var allocSize int64 = 0
 	var iosb windows.IO_STATUS_BLOCK
@@ -85,48 +104,439 @@ func winOpenAt(dfd windows.Handle, file string, access, disposition, options uin
 		disposition,
 		options|windows.FILE_OPEN_REPARSE_POINT,
 		0, 0)
-	return fileHandle, err
+	return fileHandle, winError(err)
+}
+
+// winError maps NTSTATUS codes to Win32 errors, so callers can check them with errors.Is
+// (e.g. against fs.ErrNotExist).
+func winError(err error) error {
+	if status, ok := err.(windows.NTStatus); ok {
+		return status.Errno()
+	}
+	return err
+}
+
+// handle is a handle of an opened base directory.
+type handle = windows.Handle
+
+func openDirHandle(directory string) (handle, error) {
+	ntPath, err := winNTPath(directory)
+	if err != nil {
+		return windows.InvalidHandle, err
+	}
+	return winOpenAt(windows.InvalidHandle, ntPath,
+		windows.FILE_GENERIC_READ,
+		windows.FILE_OPEN,
+		windows.FILE_DIRECTORY_FILE)
+}
+
+// winNTPath converts directory to an NT path, which is not limited to MAX_PATH characters. The
+// native API takes neither relative paths nor forward slashes, nor . and .. elements, so
+// directory is made absolute and cleaned first, unless it is a verbatim \\?\ path already. UNC
+// paths, \\server\share\dir, map to \??\UNC\server\share\dir. Paths in the device namespace,
+// such as \\.\PhysicalDrive0, are rejected, as they do not name directories of a file system.
+func winNTPath(directory string) (string, error) {
+	if strings.HasPrefix(directory, `\\?\`) || strings.HasPrefix(directory, `\??\`) {
+		if !winIsVerbatimVolumePath(directory[4:]) {
+			return "", &os.PathError{Op: "open", Path: directory, Err: ErrInvalidFilename}
+		}
+		return `\??\` + directory[4:], nil
+	}
+	if p := strings.ReplaceAll(directory, "/", `\`); strings.HasPrefix(p, `\\.\`) || strings.HasPrefix(p, `\\?\`) {
+		return "", &os.PathError{Op: "open", Path: directory, Err: ErrInvalidFilename}
+	}
+	abs, err := filepath.Abs(directory)
+	if err != nil {
+		return "", err
+	}
+	if strings.HasPrefix(abs, `\\`) {
+		return `\??\UNC\` + abs[2:], nil
+	}
+	return `\??\` + abs, nil
 }
 
-func openFileAt(directory, file string, flag int, perm os.FileMode) (*os.File, error) {
+// winIsVerbatimVolumePath reports whether path, following a \\?\ prefix, starts with a drive
+// letter, a UNC share or a volume GUID, rather than naming a device.
+func winIsVerbatimVolumePath(path string) bool {
+	switch {
+	case len(path) >= 2 && path[1] == ':' && (len(path) == 2 || path[2] == '\\'):
+		c := path[0] | 0x20
+		return 'a' <= c && c <= 'z'
+	case len(path) > 4 && strings.EqualFold(path[:4], `UNC\`):
+		return true
+	case len(path) > 7 && strings.EqualFold(path[:7], "Volume{"):
+		return true
+	}
+	return false
+}
+
+func closeHandle(dfd handle) error {
+	return windows.CloseHandle(dfd)
+}
+
+// syncHandle is a no-op on Windows: flushing a directory handle requires write access to the
+// directory, and NTFS commits metadata changes (e.g. renames) through its journal anyway.
+func syncHandle(dfd handle) error {
+	return nil
+}
+
+// fileLinkInformation is the FILE_INFORMATION_CLASS of FILE_LINK_INFORMATION.
+const fileLinkInformation = 11
+
+// fileRenameInformation mirrors FILE_RENAME_INFORMATION and FILE_LINK_INFORMATION, which share
+// the same layout; FileName is variable length.
+type fileRenameInformation struct {
+	ReplaceIfExists uint32
+	RootDirectory   windows.Handle
+	FileNameLength  uint32
+	FileName        [1]uint16
+}
+
+// setFileNameIn renames (or links, depending on class) oldfile, directly in the directory
+// referred by dfd, to newfile, directly in the directory referred by ndfd.
+func setFileNameIn(dfd handle, oldfile string, ndfd handle, newfile string, class uint32, replace bool) error {
+	var access uint32 = windows.DELETE
+	if class == fileLinkInformation {
+		access = windows.FILE_WRITE_ATTRIBUTES
+	}
+	fd, err := winOpenAt(dfd, oldfile, access|windows.SYNCHRONIZE, windows.FILE_OPEN,
+		windows.FILE_SYNCHRONOUS_IO_NONALERT)
+	if err != nil {
+		return err
+	}
+	defer windows.CloseHandle(fd)
+
+	name, err := windows.UTF16FromString(newfile)
+	if err != nil {
+		return err
+	}
+	name = name[:len(name)-1]
+
+	// The buffer holds at least a whole fileRenameInformation, as pinfo is used as one, even
+	// when the name is shorter than the FileName array.
+	var info fileRenameInformation
+	buf := make([]byte, max(int(unsafe.Sizeof(info)), int(unsafe.Offsetof(info.FileName))+len(name)*2))
+	pinfo := (*fileRenameInformation)(unsafe.Pointer(&buf[0]))
+	if replace {
+		pinfo.ReplaceIfExists = 1
+	}
+	pinfo.RootDirectory = ndfd
+	pinfo.FileNameLength = uint32(len(name) * 2)
+	copy(unsafe.Slice(&pinfo.FileName[0], len(name)), name)
+
+	var iosb windows.IO_STATUS_BLOCK
+	return winError(windows.NtSetInformationFile(fd, &iosb, &buf[0], uint32(len(buf)), class))
+}
+
+// cwdHandle returns a handle referring to the current working directory, and a function
+// releasing it.
+func cwdHandle() (handle, func(), error) {
+	dfd, err := openDirHandle(".")
+	if err != nil {
+		return windows.InvalidHandle, nil, err
+	}
+	return dfd, func() { closeHandle(dfd) }, nil
+}
+
+// adoptDirHandle checks that the inherited handle fd refers to a directory, and makes it
+// non-inheritable, so that it is not leaked further.
+func adoptDirHandle(fd uintptr) (handle, error) {
+	h := windows.Handle(fd)
+	var info windows.ByHandleFileInformation
+	if err := windows.GetFileInformationByHandle(h, &info); err != nil {
+		return windows.InvalidHandle, err
+	}
+	if info.FileAttributes&windows.FILE_ATTRIBUTE_DIRECTORY == 0 {
+		return windows.InvalidHandle, windows.ERROR_DIRECTORY
+	}
+	if err := windows.SetHandleInformation(h, windows.HANDLE_FLAG_INHERIT, 0); err != nil {
+		return windows.InvalidHandle, err
+	}
+	return h, nil
+}
+
+// processExists reports whether a process with the given id is running.
+func processExists(pid int) bool {
+	h, err := windows.OpenProcess(windows.PROCESS_QUERY_LIMITED_INFORMATION, false, uint32(pid))
+	if err != nil {
+		// Access may be denied to a process that does exist.
+		return err != windows.ERROR_INVALID_PARAMETER
+	}
+	defer windows.CloseHandle(h)
+
+	var code uint32
+	if err := windows.GetExitCodeProcess(h, &code); err != nil {
+		return true
+	}
+	const stillActive = 259
+	return code == stillActive
+}
+
+// renameIn renames oldfile to newfile, both directly in the directory referred by dfd.
+func renameIn(dfd handle, oldfile, newfile string) error {
+	return setFileNameIn(dfd, oldfile, dfd, newfile, windows.FileRenameInformation, true)
+}
+
+// linkIn creates newfile as a hard link to oldfile, both directly in the directory referred
+// by dfd. It fails if newfile already exists.
+func linkIn(dfd handle, oldfile, newfile string) error {
+	return linkBetween(dfd, oldfile, dfd, newfile)
+}
+
+// linkBetween creates newfile, directly in the directory referred by ndfd, as a hard link to
+// oldfile, directly in the directory referred by odfd. It fails if newfile already exists.
+func linkBetween(odfd handle, oldfile string, ndfd handle, newfile string) error {
+	return setFileNameIn(odfd, oldfile, ndfd, newfile, fileLinkInformation, false)
+}
+
+// renameNoReplaceIn renames oldfile, directly in the directory referred by odfd, to newfile,
+// directly in the directory referred by ndfd, failing if newfile exists.
+func renameNoReplaceIn(odfd handle, oldfile string, ndfd handle, newfile string) error {
+	return setFileNameIn(odfd, oldfile, ndfd, newfile, windows.FileRenameInformation, false)
+}
+
+// exchangeIn is not supported on this platform.
+func exchangeIn(dfd handle, a, b string) error {
+	return windows.ERROR_NOT_SUPPORTED
+}
+
+// removeIn removes file directly in the directory referred by dfd.
+func removeIn(dfd handle, file string) error {
+	fd, err := winOpenAt(dfd, file, windows.DELETE|windows.SYNCHRONIZE, windows.FILE_OPEN,
+		windows.FILE_SYNCHRONOUS_IO_NONALERT)
+	if err != nil {
+		return err
+	}
+	defer windows.CloseHandle(fd)
+
+	// FILE_DISPOSITION_INFORMATION consists of a single BOOLEAN DeleteFile field.
+	deleteFile := byte(1)
+	var iosb windows.IO_STATUS_BLOCK
+	return winError(windows.NtSetInformationFile(fd, &iosb, &deleteFile, 1, windows.FileDispositionInformation))
+}
+
+// mkdirIn creates the directory dir directly in the directory referred by dfd. perm is ignored.
+func mkdirIn(dfd handle, dir string, _ os.FileMode) error {
+	fd, err := winOpenAt(dfd, dir, windows.FILE_LIST_DIRECTORY|windows.SYNCHRONIZE, windows.FILE_CREATE,
+		windows.FILE_DIRECTORY_FILE|windows.FILE_SYNCHRONOUS_IO_NONALERT)
+	if err != nil {
+		return err
+	}
+	return windows.CloseHandle(fd)
+}
+
+// chmodIn changes the mode of file directly in the directory referred by dfd. As with os.Chmod,
+// only the 0200 bit is used, to clear or set the read-only attribute. Reparse points are not
+// followed.
+func chmodIn(dfd handle, file string, mode os.FileMode) error {
+	fd, err := winOpenAt(dfd, file, windows.FILE_READ_ATTRIBUTES|windows.FILE_WRITE_ATTRIBUTES|windows.SYNCHRONIZE,
+		windows.FILE_OPEN, windows.FILE_SYNCHRONOUS_IO_NONALERT)
+	if err != nil {
+		return err
+	}
+	defer windows.CloseHandle(fd)
+
+	var basic fileBasicInfo
+	if err := windows.GetFileInformationByHandleEx(fd, windows.FileBasicInfo, (*byte)(unsafe.Pointer(&basic)), uint32(unsafe.Sizeof(basic))); err != nil {
+		return err
+	}
+	attrs := basic.FileAttributes
+	if mode&0200 == 0 {
+		attrs |= windows.FILE_ATTRIBUTE_READONLY
+	} else {
+		attrs &^= windows.FILE_ATTRIBUTE_READONLY
+	}
+	if attrs == basic.FileAttributes {
+		return nil
+	}
+	// Zero times are left unchanged.
+	basic = fileBasicInfo{FileAttributes: attrs}
+	return windows.SetFileInformationByHandle(fd, windows.FileBasicInfo, (*byte)(unsafe.Pointer(&basic)), uint32(unsafe.Sizeof(basic)))
+}
+
+// chtimesIn changes the access and modification times of file directly in the directory referred
+// by dfd. Zero times are left unchanged. Reparse points are not followed.
+func chtimesIn(dfd handle, file string, atime, mtime time.Time) error {
+	fd, err := winOpenAt(dfd, file, windows.FILE_WRITE_ATTRIBUTES|windows.SYNCHRONIZE,
+		windows.FILE_OPEN, windows.FILE_SYNCHRONOUS_IO_NONALERT)
+	if err != nil {
+		return err
+	}
+	defer windows.CloseHandle(fd)
+
+	var a, m *windows.Filetime
+	if !atime.IsZero() {
+		ft := windows.NsecToFiletime(atime.UnixNano())
+		a = &ft
+	}
+	if !mtime.IsZero() {
+		ft := windows.NsecToFiletime(mtime.UnixNano())
+		m = &ft
+	}
+	return windows.SetFileTime(fd, nil, a, m)
+}
+
+// chownIn is not supported on this platform, as with os.Chown.
+func chownIn(dfd handle, file string, uid, gid int) error {
+	return windows.ERROR_NOT_SUPPORTED
+}
+
+// createScratchIn creates a new file with a random name directly in the directory referred by
+// dfd, which is deleted when its last handle is closed, including when the process terminates.
+func createScratchIn(dfd handle, directory string) (*os.File, error) {
+	for try := 0; try < maxRandomCreateTries; try++ {
+		name, err := randomName(".scratch", "")
+		if err != nil {
+			return nil, err
+		}
+		fd, err := winOpenAt(dfd, name,
+			windows.FILE_GENERIC_READ|windows.FILE_GENERIC_WRITE|windows.DELETE,
+			windows.FILE_CREATE,
+			windows.FILE_RANDOM_ACCESS|windows.FILE_NON_DIRECTORY_FILE|windows.FILE_SYNCHRONOUS_IO_NONALERT|windows.FILE_DELETE_ON_CLOSE)
+		if errors.Is(err, fs.ErrExist) {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		return os.NewFile(uintptr(fd), filepath.Join(directory, name)), nil
+	}
+	return nil, &os.PathError{Op: "CreateScratchAt", Path: directory, Err: fs.ErrExist}
+}
+
+func openFileAtIn(dfd handle, directory, file string, flag int, perm os.FileMode) (*os.File, error) {
 	if !winIsSimpleFilename(file) {
-		return nil, &os.PathError{"OpenAt", file, errors.New("invalid filename")}
+		return nil, &os.PathError{Op: "OpenAt", Path: file, Err: ErrInvalidFilename}
 	}
 
-	return openFileBeneath(directory, file, flag, perm)
+	return openFileBeneathIn(dfd, directory, file, flag, perm)
 }
 
-func openFileBeneath(directory, file string, flag int, _ os.FileMode) (*os.File, error) {
-	var winPerm uint32 = windows.FILE_GENERIC_READ
-	if flag != os.O_RDONLY {
+func openFileBeneathIn(dfd handle, directory, file string, flag int, _ os.FileMode) (*os.File, error) {
+	var winPerm uint32
+	switch flag & (os.O_RDONLY | os.O_WRONLY | os.O_RDWR) {
+	case os.O_RDONLY:
+		winPerm = windows.FILE_GENERIC_READ
+	case os.O_WRONLY:
+		// FILE_READ_ATTRIBUTES keeps Stat working on write-only files.
+		winPerm = windows.FILE_GENERIC_WRITE | windows.FILE_READ_ATTRIBUTES
+	default:
+		winPerm = windows.FILE_GENERIC_READ | windows.FILE_GENERIC_WRITE
+	}
+	if flag&os.O_CREATE > 0 {
 		winPerm |= windows.FILE_GENERIC_WRITE
 	}
+	// Without FILE_WRITE_DATA, but with FILE_APPEND_DATA, the system writes at the end of the
+	// file whatever the offset, as with O_APPEND on POSIX systems. Truncating the file needs it,
+	// though, as with os.OpenFile.
+	if flag&os.O_APPEND > 0 && flag&os.O_TRUNC == 0 {
+		winPerm &^= windows.FILE_WRITE_DATA
+	}
 
 	// Note, on Windows the semantics of disposition options are different compared to posix,
-	// os.O_CREATE|os.O_TRUNC => FILE_CREATE|FILE_OVERWRITE is invalid
-	var disposition uint32 = windows.FILE_OPEN
-	if flag&os.O_CREATE > 0 {
+	// os.O_CREATE|os.O_TRUNC => FILE_CREATE|FILE_OVERWRITE is invalid. O_EXCL takes precedence,
+	// so that an existing file is neither opened nor truncated.
+	var disposition uint32
+	switch {
+	case flag&(os.O_CREATE|os.O_EXCL) == os.O_CREATE|os.O_EXCL:
 		disposition = windows.FILE_CREATE
-	}
-	if flag&os.O_TRUNC > 0 {
+	case flag&(os.O_CREATE|os.O_TRUNC) == os.O_CREATE|os.O_TRUNC:
 		disposition = windows.FILE_OVERWRITE_IF
+	case flag&os.O_CREATE > 0:
+		disposition = windows.FILE_OPEN_IF
+	case flag&os.O_TRUNC > 0:
+		disposition = windows.FILE_OVERWRITE
+	default:
+		disposition = windows.FILE_OPEN
 	}
 
 	sanitizedFile, safe := winRelativePathDoesntTraverse(file)
 	if !safe {
-		return nil, &os.PathError{"OpenAt", file, errors.New("invalid filename")}
+		return nil, &os.PathError{Op: "OpenBeneath", Path: file, Err: beneathPathError(file)}
 	}
 
-	dfd, err := winOpenAt(windows.InvalidHandle, `\??\`+directory,
-		winPerm,
-		windows.FILE_OPEN,
-		windows.FILE_DIRECTORY_FILE)
+	adfd, base, err := winOpenParent(dfd, sanitizedFile, winPerm)
+	if err != nil {
+		return nil, err
+	}
+
+	// Note: windows.FILE_SYNCHRONOUS_IO_NONALERT is important here, without that regular file IO
+	// would be rejected with the error message "The parameter is incorrect".
+	fd, err := winOpenAt(adfd, base, winPerm, disposition,
+		windows.FILE_RANDOM_ACCESS|windows.FILE_NON_DIRECTORY_FILE|windows.FILE_SYNCHRONOUS_IO_NONALERT)
+	if adfd != dfd {
+		windows.CloseHandle(adfd)
+	}
+
+	if err != nil {
+		return nil, err
+	}
+	if err := winRejectLink(fd); err != nil {
+		return nil, err
+	}
+
+	f := os.NewFile(uintptr(fd), filepath.Join(directory, sanitizedFile))
+	if err := checkExactCase(f, sanitizedFile); err != nil {
+		return nil, err
+	}
+	return f, nil
+}
+
+// openFileNoFollowIn opens file beneath dfd, refusing to follow reparse points at any level.
+// openFileBeneathIn already behaves this way on this platform.
+func openFileNoFollowIn(dfd handle, directory, file string, flag int, perm os.FileMode) (*os.File, error) {
+	return openFileBeneathIn(dfd, directory, file, flag, perm)
+}
+
+// kernelBeneathSupported reports false: paths are walked one component at a time on this
+// platform.
+func kernelBeneathSupported() bool {
+	return false
+}
+
+// openFileNoXDevIn opens file beneath dfd. Reparse points, mount points included, are never
+// followed on this platform, so the lookup cannot cross them.
+func openFileNoXDevIn(dfd handle, directory, file string, flag int, perm os.FileMode, noFollow bool) (*os.File, error) {
+	return openFileBeneathIn(dfd, directory, file, flag, perm)
+}
+
+// openDirNoFollowIn opens the directory dir beneath dfd for reading its entries, refusing to
+// follow reparse points at any level.
+func openDirNoFollowIn(dfd handle, directory, dir string) (*os.File, error) {
+	fd, name, err := winOpenNoFollow(dfd, dir, windows.FILE_LIST_DIRECTORY|windows.FILE_READ_ATTRIBUTES, windows.FILE_DIRECTORY_FILE)
 	if err != nil {
 		return nil, err
 	}
+	if err := winRejectLink(fd); err != nil {
+		return nil, &os.PathError{Op: "open", Path: dir, Err: err}
+	}
+	return os.NewFile(uintptr(fd), filepath.Join(directory, name)), nil
+}
+
+// symlinkIn is not supported on this platform yet.
+func symlinkIn(dfd handle, target, link string) error {
+	return windows.ERROR_NOT_SUPPORTED
+}
+
+// openParentIn opens the directory containing file beneath dfd, with the same rules as
+// OpenBeneath. It returns the handle of the directory (which is dfd itself if file is directly
+// in it) and the last element of file.
+func openParentIn(dfd handle, file string) (handle, string, error) {
+	sanitizedFile, safe := winRelativePathDoesntTraverse(file)
+	if !safe {
+		return windows.InvalidHandle, "", &os.PathError{Op: "OpenBeneath", Path: file, Err: beneathPathError(file)}
+	}
+	return winOpenParent(dfd, sanitizedFile, windows.FILE_GENERIC_READ)
+}
 
+// winOpenParent opens the directory containing the sanitized file beneath dfd, one segment at a
+// time. It returns the handle of the directory (which is dfd itself if file is directly in it)
+// and the last segment of file.
+func winOpenParent(dfd handle, sanitizedFile string, access uint32) (handle, string, error) {
 	segs := strings.Split(sanitizedFile, `\`)
 
+	adfd := dfd
+	var err error
 	if len(segs) > 1 {
 		for _, seg := range segs[:len(segs)-1] {
 			// Ignore empty segments
@@ -134,25 +544,131 @@ func openFileBeneath(directory, file string, flag int, _ os.FileMode) (*os.File,
 				continue
 			}
 
-			odfd := dfd
-			dfd, err = winOpenAt(dfd, seg, winPerm, windows.FILE_OPEN, windows.FILE_DIRECTORY_FILE)
-			windows.CloseHandle(odfd)
+			odfd := adfd
+			adfd, err = winOpenAt(adfd, seg, access, windows.FILE_OPEN, windows.FILE_DIRECTORY_FILE)
+			if odfd != dfd {
+				windows.CloseHandle(odfd)
+			}
 
 			if err != nil {
-				return nil, err
+				return windows.InvalidHandle, "", err
+			}
+			if err := winRejectLink(adfd); err != nil {
+				return windows.InvalidHandle, "", err
 			}
 		}
 	}
+	return adfd, segs[len(segs)-1], nil
+}
 
-	// Note: windows.FILE_SYNCHRONOUS_IO_NONALERT is important here, without that regular file IO
-	// would be rejected with the error message "The parameter is incorrect".
-	fd, err := winOpenAt(dfd, segs[len(segs)-1], winPerm, disposition,
-		windows.FILE_RANDOM_ACCESS|windows.FILE_NON_DIRECTORY_FILE|windows.FILE_SYNCHRONOUS_IO_NONALERT)
-	windows.CloseHandle(dfd)
+// winOpenNoFollow opens file beneath dfd with the given access and create options, without
+// following a reparse point in its last element. It returns the handle and the sanitized file
+// name.
+func winOpenNoFollow(dfd handle, file string, access, options uint32) (windows.Handle, string, error) {
+	sanitizedFile, safe := winRelativePathDoesntTraverse(file)
+	if !safe {
+		return windows.InvalidHandle, "", &os.PathError{Op: "OpenBeneath", Path: file, Err: beneathPathError(file)}
+	}
+
+	adfd, base, err := winOpenParent(dfd, sanitizedFile, windows.FILE_GENERIC_READ)
+	if err != nil {
+		return windows.InvalidHandle, "", err
+	}
+	if base == "." {
+		// An empty name opens the parent directory itself.
+		base = ""
+	}
+	fd, err := winOpenAt(adfd, base, access|windows.SYNCHRONIZE, windows.FILE_OPEN,
+		options|windows.FILE_SYNCHRONOUS_IO_NONALERT)
+	if adfd != dfd {
+		windows.CloseHandle(adfd)
+	}
+	if err != nil {
+		return windows.InvalidHandle, "", &os.PathError{Op: "lstat", Path: file, Err: err}
+	}
+	return fd, sanitizedFile, nil
+}
+
+// statIn returns the FileInfo of file beneath dfd. If file is a reparse point (e.g. a symbolic
+// link), the reparse point itself is described, and junctions are reported as symbolic links.
+func statIn(dfd handle, file string) (fs.FileInfo, error) {
+	fd, name, err := winOpenNoFollow(dfd, file, windows.FILE_READ_ATTRIBUTES, 0)
+	if err != nil {
+		return nil, err
+	}
 
+	f := os.NewFile(uintptr(fd), filepath.Base(name))
+	defer f.Close()
+	return winLstat(f, fd)
+}
+
+// fileBasicInfo mirrors FILE_BASIC_INFO.
+type fileBasicInfo struct {
+	CreationTime   int64
+	LastAccessTime int64
+	LastWriteTime  int64
+	ChangeTime     int64
+	FileAttributes uint32
+	_              uint32
+}
+
+func filetimeToTime(ft int64) time.Time {
+	return time.Unix(0, (&windows.Filetime{LowDateTime: uint32(ft), HighDateTime: uint32(ft >> 32)}).Nanoseconds())
+}
+
+func extendedStatIn(dfd handle, file string) (*ExtendedFileInfo, error) {
+	fd, name, err := winOpenNoFollow(dfd, file, windows.FILE_READ_ATTRIBUTES|windows.READ_CONTROL, 0)
+	if err != nil {
+		return nil, err
+	}
+	f := os.NewFile(uintptr(fd), filepath.Base(name))
+	defer f.Close()
+
+	fi, err := winLstat(f, fd)
+	if err != nil {
+		return nil, err
+	}
+	var info windows.ByHandleFileInformation
+	if err := windows.GetFileInformationByHandle(fd, &info); err != nil {
+		return nil, &os.PathError{Op: "GetFileInformationByHandle", Path: file, Err: err}
+	}
+	var basic fileBasicInfo
+	if err := windows.GetFileInformationByHandleEx(fd, windows.FileBasicInfo, (*byte)(unsafe.Pointer(&basic)), uint32(unsafe.Sizeof(basic))); err != nil {
+		return nil, &os.PathError{Op: "GetFileInformationByHandleEx", Path: file, Err: err}
+	}
+	sd, err := windows.GetSecurityInfo(fd, windows.SE_FILE_OBJECT, windows.OWNER_SECURITY_INFORMATION|windows.GROUP_SECURITY_INFORMATION)
+	if err != nil {
+		return nil, &os.PathError{Op: "GetSecurityInfo", Path: file, Err: err}
+	}
+	owner, _, err := sd.Owner()
+	if err != nil {
+		return nil, err
+	}
+	group, _, err := sd.Group()
 	if err != nil {
 		return nil, err
 	}
 
-	return os.NewFile(uintptr(fd), filepath.Join(directory, sanitizedFile)), nil
+	return &ExtendedFileInfo{
+		FileInfo:   fi,
+		Owner:      owner.String(),
+		Group:      group.String(),
+		Device:     uint64(info.VolumeSerialNumber),
+		Inode:      uint64(info.FileIndexHigh)<<32 | uint64(info.FileIndexLow),
+		Links:      uint64(info.NumberOfLinks),
+		AccessTime: filetimeToTime(basic.LastAccessTime),
+		ChangeTime: filetimeToTime(basic.ChangeTime),
+		BirthTime:  filetimeToTime(basic.CreationTime),
+	}, nil
+}
+
+// checkResolveBeneathIn returns nil, as paths are always resolved one component at a time
+// relative to the handle of the parent directory on this platform.
+func checkResolveBeneathIn(dfd handle) error {
+	return nil
+}
+
+// writeBuffers writes bufs to f. Vectored I/O is not used on this platform.
+func writeBuffers(f *os.File, bufs [][]byte) error {
+	return writeBuffersLoop(f, bufs)
 }
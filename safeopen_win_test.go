@@ -18,9 +18,13 @@
 package safeopen
 
 import (
+	"errors"
 	"fmt"
+	"io"
 	"os"
 	"path"
+	"path/filepath"
+	"strings"
 
 	"testing"
 )
@@ -185,3 +189,108 @@ func TestWinIO(t *testing.T) {
 		t.Errorf("Read() = %q, want %q", aRead, content)
 	}
 }
+
+func TestWinAccessModes(t *testing.T) {
+	tmpdir := t.TempDir()
+	file := path.Join(tmpdir, "data.txt")
+	if err := os.WriteFile(file, []byte("hello"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	f, err := OpenFileAt(tmpdir, "data.txt", os.O_WRONLY|os.O_APPEND, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := f.Read(make([]byte, 1)); err == nil {
+		t.Error("Read() on a write-only file succeeded")
+	}
+	if _, err := f.Stat(); err != nil {
+		t.Errorf("Stat() on a write-only file error: %v", err)
+	}
+	// The offset is ignored when appending.
+	if _, err := f.Seek(0, io.SeekStart); err != nil {
+		t.Error(err)
+	}
+	if _, err := f.WriteString(" world"); err != nil {
+		t.Error(err)
+	}
+	f.Close()
+
+	if data, err := os.ReadFile(file); err != nil || string(data) != "hello world" {
+		t.Errorf("ReadFile() = %q, %v, want %q", data, err, "hello world")
+	}
+}
+
+func TestWinLongPaths(t *testing.T) {
+	tmpdir := t.TempDir()
+	elem := strings.Repeat("d", 200)
+	var rel string
+	for len(rel) < 4200 {
+		rel = filepath.Join(rel, elem)
+	}
+
+	if err := MkdirAllBeneath(tmpdir, rel, 0755); err != nil {
+		t.Fatalf("MkdirAllBeneath(%d characters) error: %v", len(rel), err)
+	}
+	file := filepath.Join(rel, "data.txt")
+	if err := WriteFileBeneath(tmpdir, file, []byte("deep"), 0644); err != nil {
+		t.Fatalf("WriteFileBeneath(%d characters) error: %v", len(file), err)
+	}
+	if data, err := ReadFileBeneath(tmpdir, file); err != nil || string(data) != "deep" {
+		t.Errorf("ReadFileBeneath(%d characters) = %q, %v, want %q", len(file), data, err, "deep")
+	}
+
+	// The long directory itself as the base directory, in all the forms it may be given.
+	deep := filepath.Join(tmpdir, rel)
+	for _, dir := range []string{deep, filepath.ToSlash(deep), `\\?\` + deep, deep + `\` + elem + `\..`} {
+		if data, err := ReadFileAt(dir, "data.txt"); err != nil || string(data) != "deep" {
+			t.Errorf("ReadFileAt(%d characters) = %q, %v, want %q", len(dir), data, err, "deep")
+		}
+	}
+}
+
+func TestWinRelativeDirectory(t *testing.T) {
+	tmpdir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(tmpdir, "data.txt"), []byte("hello"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	wd, err := os.Getwd()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chdir(tmpdir); err != nil {
+		t.Fatal(err)
+	}
+	defer os.Chdir(wd)
+
+	if data, err := ReadFileAt(".", "data.txt"); err != nil || string(data) != "hello" {
+		t.Errorf("ReadFileAt(\".\") = %q, %v, want %q", data, err, "hello")
+	}
+	if data, err := ReadFileBeneathCwd("data.txt"); err != nil || string(data) != "hello" {
+		t.Errorf("ReadFileBeneathCwd() = %q, %v, want %q", data, err, "hello")
+	}
+}
+
+func TestWinNTPath(t *testing.T) {
+	for _, tc := range []struct {
+		directory, want string
+	}{
+		{`C:\data\logs`, `\??\C:\data\logs`},
+		{`C:/data/./logs/../logs`, `\??\C:\data\logs`},
+		{`\\server\share\dir`, `\??\UNC\server\share\dir`},
+		{`//server/share/dir`, `\??\UNC\server\share\dir`},
+		{`\\?\C:\data`, `\??\C:\data`},
+		{`\\?\UNC\server\share\dir`, `\??\UNC\server\share\dir`},
+		{`\\?\Volume{01234567-89ab-cdef-0123-456789abcdef}\data`, `\??\Volume{01234567-89ab-cdef-0123-456789abcdef}\data`},
+	} {
+		if got, err := winNTPath(tc.directory); err != nil || got != tc.want {
+			t.Errorf("winNTPath(%q) = %q, %v, want %q", tc.directory, got, err, tc.want)
+		}
+	}
+
+	for _, directory := range []string{`\\.\PhysicalDrive0`, `//./C:/data`, `\\.\C:\data`, `\\?\GLOBALROOT\Device\HarddiskVolume1`, `\??\PhysicalDrive0`} {
+		if got, err := winNTPath(directory); !errors.Is(err, ErrInvalidFilename) {
+			t.Errorf("winNTPath(%q) = %q, %v, want %v", directory, got, err, ErrInvalidFilename)
+		}
+	}
+}
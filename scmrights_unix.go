@@ -0,0 +1,81 @@
+// Copyright 2024 Google LLC.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+//go:build unix
+// +build unix
+
+package safeopen
+
+import (
+	"errors"
+	"net"
+	"os"
+
+	"golang.org/x/sys/unix"
+)
+
+const (
+	// maxDirNameLen is the maximum length of the name of a Dir received by ReceiveDir.
+	maxDirNameLen = 4096
+	// maxReceivedFds is the number of descriptors ReceiveDir makes room for, so that the
+	// unexpected ones are received and closed rather than truncated.
+	maxReceivedFds = 8
+)
+
+// SendDir sends the directory of d, along with its name, over conn with SCM_RIGHTS, so that the
+// receiving process can use it with ReceiveDir. This lets a privileged broker open a directory
+// and hand it to sandboxed workers, which cannot open it on their own. d remains usable.
+//
+// The name is sent as the data of the message; conn should not be used concurrently for other
+// messages, and if it is a stream socket, the receiver should read nothing else from it at the
+// same time.
+func SendDir(conn *net.UnixConn, d *Dir) error {
+	_, _, err := conn.WriteMsgUnix([]byte(d.name), unix.UnixRights(d.dfd), nil)
+	return err
+}
+
+// ReceiveDir receives a directory sent with SendDir over conn, and returns it as a Dir with the
+// options opts. Messages carrying anything else than exactly one directory descriptor are
+// rejected, and the descriptors they carry are closed.
+func ReceiveDir(conn *net.UnixConn, opts ...Option) (*Dir, error) {
+	buf := make([]byte, maxDirNameLen)
+	oob := make([]byte, unix.CmsgSpace(maxReceivedFds*4))
+	n, oobn, flags, _, err := conn.ReadMsgUnix(buf, oob)
+	if err != nil {
+		return nil, err
+	}
+	var fds []int
+	msgs, err := unix.ParseSocketControlMessage(oob[:oobn])
+	if err == nil {
+		for _, msg := range msgs {
+			rights, err := unix.ParseUnixRights(&msg)
+			if err == nil {
+				fds = append(fds, rights...)
+			}
+		}
+	}
+	name := string(buf[:n])
+	if len(fds) != 1 || flags&unix.MSG_CTRUNC != 0 {
+		for _, fd := range fds {
+			unix.Close(fd)
+		}
+		return nil, &os.PathError{Op: "ReceiveDir", Path: name, Err: errors.New("expected one directory descriptor")}
+	}
+	fd, err := adoptDirHandle(uintptr(fds[0]))
+	if err != nil {
+		unix.Close(fds[0])
+		return nil, &os.PathError{Op: "ReceiveDir", Path: name, Err: err}
+	}
+	return newDir(name, fd, opts)
+}
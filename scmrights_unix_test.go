@@ -0,0 +1,103 @@
+// Copyright 2024 Google LLC.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+//go:build unix
+// +build unix
+
+package safeopen
+
+import (
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"golang.org/x/sys/unix"
+)
+
+// unixConnPair returns a pair of connected Unix sockets.
+func unixConnPair(t *testing.T) (*net.UnixConn, *net.UnixConn) {
+	t.Helper()
+	fds, err := unix.Socketpair(unix.AF_UNIX, unix.SOCK_STREAM, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var conns [2]*net.UnixConn
+	for i, fd := range fds {
+		f := os.NewFile(uintptr(fd), "socket")
+		c, err := net.FileConn(f)
+		f.Close()
+		if err != nil {
+			t.Fatal(err)
+		}
+		t.Cleanup(func() { c.Close() })
+		conns[i] = c.(*net.UnixConn)
+	}
+	return conns[0], conns[1]
+}
+
+func TestSendReceiveDir(t *testing.T) {
+	tmpDir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(tmpDir, "file.txt"), []byte("data"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	d, err := OpenDir(tmpDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer d.Close()
+
+	broker, worker := unixConnPair(t)
+	if err := SendDir(broker, d); err != nil {
+		t.Fatalf("SendDir() error: %v", err)
+	}
+	received, err := ReceiveDir(worker, WithReadOnly())
+	if err != nil {
+		t.Fatalf("ReceiveDir() error: %v", err)
+	}
+	defer received.Close()
+
+	if received.Name() != tmpDir {
+		t.Errorf("Name() = %q, want %q", received.Name(), tmpDir)
+	}
+	if data, err := received.ReadFile("file.txt"); err != nil || string(data) != "data" {
+		t.Errorf("ReadFile(%q) = %q, %v, want %q, nil", "file.txt", data, err, "data")
+	}
+	if _, err := received.ReadFile("../file.txt"); err == nil {
+		t.Errorf("ReadFile(%q) succeeded, want error", "../file.txt")
+	}
+
+	// A regular file is rejected.
+	f, err := os.Open(filepath.Join(tmpDir, "file.txt"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	if _, _, err := broker.WriteMsgUnix([]byte("file.txt"), unix.UnixRights(int(f.Fd())), nil); err != nil {
+		t.Fatal(err)
+	}
+	if d, err := ReceiveDir(worker); err == nil {
+		d.Close()
+		t.Errorf("ReceiveDir() of a regular file succeeded, want error")
+	}
+
+	// So is a message without descriptor.
+	if _, err := broker.Write([]byte("nothing")); err != nil {
+		t.Fatal(err)
+	}
+	if d, err := ReceiveDir(worker); err == nil {
+		d.Close()
+		t.Errorf("ReceiveDir() without descriptor succeeded, want error")
+	}
+}
@@ -0,0 +1,66 @@
+// Copyright 2024 Google LLC.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+//go:build linux
+// +build linux
+
+package safeopen
+
+import (
+	"errors"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"golang.org/x/sys/unix"
+)
+
+func TestLinuxSELinuxCreateContext(t *testing.T) {
+	tmpDir := t.TempDir()
+	if !selinuxEnabled() {
+		d, err := OpenDir(tmpDir, WithSELinuxCreateContext("system_u:object_r:tmp_t:s0"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer d.Close()
+		if err := d.WriteFile("file", nil, 0644); !errors.Is(err, ErrSELinuxDisabled) {
+			t.Errorf("WriteFile() without SELinux error = %v, want ErrSELinuxDisabled", err)
+		}
+		return
+	}
+
+	// Use the context of the directory, which is valid for files in it.
+	buf := make([]byte, 256)
+	n, err := unix.Getxattr(tmpDir, "security.selinux", buf)
+	if err != nil {
+		t.Skipf("cannot get the context of %s: %v", tmpDir, err)
+	}
+	context := strings.TrimRight(string(buf[:n]), "\x00")
+
+	d, err := OpenDir(tmpDir, WithSELinuxCreateContext(context))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer d.Close()
+	if err := d.WriteFile("file", nil, 0644); err != nil {
+		t.Fatalf("WriteFile() error: %v", err)
+	}
+	n, err = unix.Getxattr(filepath.Join(tmpDir, "file"), "security.selinux", buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := strings.TrimRight(string(buf[:n]), "\x00"); got != context {
+		t.Errorf("context of the created file = %q, want %q", got, context)
+	}
+}
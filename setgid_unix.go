@@ -0,0 +1,146 @@
+// Copyright 2024 Google LLC.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+//go:build unix
+// +build unix
+
+package safeopen
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"syscall"
+
+	"golang.org/x/sys/unix"
+)
+
+// MkdirSharedBeneath creates the directory dir beneath directory for sharing files among the
+// members of the group gid: the directory belongs to gid and has the setgid bit, so that the
+// files and directories later created in it belong to gid too. The permission bits of the new
+// directory are set to perm, regardless of the umask. dir may not contain .. path traversal
+// entries, and its parent must exist.
+//
+// The caller must be a member of gid, or be privileged. If the setgid bit cannot be set, the
+// directory is removed and an error is returned.
+func MkdirSharedBeneath(directory, dir string, gid int, perm os.FileMode) error {
+	dfd, err := openDirHandle(directory)
+	if err != nil {
+		return err
+	}
+	defer closeHandle(dfd)
+
+	pfd, base, err := openParentIn(dfd, dir)
+	if err != nil {
+		return err
+	}
+	if pfd != dfd {
+		defer closeHandle(pfd)
+	}
+
+	if err := mkdirIn(pfd, base, perm.Perm()); err != nil {
+		return &os.PathError{Op: "mkdir", Path: filepath.Join(directory, dir), Err: err}
+	}
+	if err := shareDirIn(pfd, base, gid, perm); err != nil {
+		unix.Unlinkat(pfd, base, unix.AT_REMOVEDIR)
+		return &os.PathError{Op: "MkdirSharedBeneath", Path: filepath.Join(directory, dir), Err: err}
+	}
+	return nil
+}
+
+// shareDirIn gives the directory dir, directly in the directory referred by dfd, to the group gid
+// and sets its mode to perm with the setgid bit.
+func shareDirIn(dfd handle, dir string, gid int, perm os.FileMode) error {
+	fd, err := openatRetry(dfd, dir, unix.O_RDONLY|unix.O_DIRECTORY|unix.O_NOFOLLOW|unix.O_CLOEXEC, 0)
+	if err != nil {
+		return err
+	}
+	defer unix.Close(fd)
+
+	if err := unix.Fchown(fd, -1, gid); err != nil {
+		return err
+	}
+	if err := unix.Fchmod(fd, syscallMode(perm.Perm()|os.ModeSetgid)); err != nil {
+		return err
+	}
+	// Without privileges, the setgid bit is silently dropped for groups the caller is not in.
+	var st unix.Stat_t
+	if err := unix.Fstat(fd, &st); err != nil {
+		return err
+	}
+	if st.Mode&unix.S_ISGID == 0 {
+		return unix.EPERM
+	}
+	return nil
+}
+
+// OpenFileSharedBeneath is a variant of OpenFileBeneath for the directories shared among the
+// members of a group, such as those created by MkdirSharedBeneath. The last element of file must
+// not be a symbolic link.
+//
+// If the file is created, it is given to the group of its directory, which not all platforms do
+// for directories without the setgid bit, and its permission bits are set to perm regardless of
+// the umask, so that group permissions are not lost. Existing files are left untouched.
+func OpenFileSharedBeneath(directory, file string, flag int, perm os.FileMode) (*os.File, error) {
+	dfd, err := openDirHandle(directory)
+	if err != nil {
+		return nil, err
+	}
+	defer closeHandle(dfd)
+
+	pfd, base, err := openParentIn(dfd, file)
+	if err != nil {
+		return nil, err
+	}
+	if pfd != dfd {
+		defer closeHandle(pfd)
+	}
+	pdir := filepath.Join(directory, filepath.Dir(file))
+
+	if flag&os.O_CREATE == 0 {
+		return openFileAtIn(pfd, pdir, base, flag, perm)
+	}
+	f, err := openFileAtIn(pfd, pdir, base, flag|os.O_EXCL, perm)
+	if errors.Is(err, os.ErrExist) && flag&os.O_EXCL == 0 {
+		return openFileAtIn(pfd, pdir, base, flag&^os.O_CREATE, perm)
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := shareFileIn(pfd, f, perm); err != nil {
+		f.Close()
+		removeIn(pfd, base)
+		return nil, &os.PathError{Op: "OpenFileSharedBeneath", Path: filepath.Join(directory, file), Err: err}
+	}
+	return f, nil
+}
+
+// shareFileIn gives f, newly created in the directory referred by dfd, to the group of the
+// directory and sets its permission bits to perm.
+func shareFileIn(dfd handle, f *os.File, perm os.FileMode) error {
+	var dst unix.Stat_t
+	if err := unix.Fstat(dfd, &dst); err != nil {
+		return err
+	}
+	fi, err := f.Stat()
+	if err != nil {
+		return err
+	}
+	if st, ok := fi.Sys().(*syscall.Stat_t); !ok || st.Gid != dst.Gid {
+		if err := f.Chown(-1, int(dst.Gid)); err != nil {
+			return err
+		}
+	}
+	return f.Chmod(perm.Perm())
+}
@@ -0,0 +1,86 @@
+// Copyright 2024 Google LLC.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+//go:build unix
+// +build unix
+
+package safeopen
+
+import (
+	"os"
+	"path/filepath"
+	"syscall"
+	"testing"
+)
+
+func TestMkdirSharedBeneath(t *testing.T) {
+	gid := os.Getgid()
+	if os.Geteuid() == 0 {
+		gid = 65534
+	}
+	dir := t.TempDir()
+	if err := MkdirSharedBeneath(dir, "shared", gid, 0770); err != nil {
+		t.Fatal(err)
+	}
+	fi, err := os.Stat(filepath.Join(dir, "shared"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if fi.Mode()&os.ModeSetgid == 0 || fi.Mode().Perm() != 0770 {
+		t.Errorf("shared has mode %v, want setgid and 0770", fi.Mode())
+	}
+	if got := fi.Sys().(*syscall.Stat_t).Gid; int(got) != gid {
+		t.Errorf("shared belongs to group %d, want %d", got, gid)
+	}
+
+	f, err := OpenFileSharedBeneath(dir, "shared/file", os.O_RDWR|os.O_CREATE, 0660)
+	if err != nil {
+		t.Fatal(err)
+	}
+	fi, err = f.Stat()
+	f.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if fi.Mode().Perm() != 0660 {
+		t.Errorf("shared/file has mode %v, want 0660", fi.Mode())
+	}
+	if got := fi.Sys().(*syscall.Stat_t).Gid; int(got) != gid {
+		t.Errorf("shared/file belongs to group %d, want %d", got, gid)
+	}
+
+	// Existing files are opened as is.
+	if err := os.Chmod(filepath.Join(dir, "shared/file"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	f, err = OpenFileSharedBeneath(dir, "shared/file", os.O_RDWR|os.O_CREATE, 0660)
+	if err != nil {
+		t.Fatal(err)
+	}
+	fi, err = f.Stat()
+	f.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if fi.Mode().Perm() != 0600 {
+		t.Errorf("existing shared/file has mode %v, want 0600", fi.Mode())
+	}
+
+	if _, err := OpenFileSharedBeneath(dir, "shared/file", os.O_RDWR|os.O_CREATE|os.O_EXCL, 0660); !os.IsExist(err) {
+		t.Errorf("OpenFileSharedBeneath(O_EXCL) = %v, want ErrExist", err)
+	}
+	if err := MkdirSharedBeneath(dir, "../escape", gid, 0770); err == nil {
+		t.Error("MkdirSharedBeneath(../escape) succeeded")
+	}
+}
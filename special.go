@@ -0,0 +1,90 @@
+// Copyright 2024 Google LLC.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package safeopen
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"sync/atomic"
+	"syscall"
+)
+
+var allowSpecialFiles atomic.Bool
+
+// SetAllowSpecialFiles allows or refuses, for the whole program, opening special files: named
+// pipes (FIFOs), devices and sockets. By default, OpenAt, OpenBeneath, the related functions and
+// Dir fail with an error wrapping ErrNotRegularFile if the file opened is neither a regular file
+// nor a directory. Otherwise, whoever can create files in the directory could make a reader
+// block forever on a FIFO pre-created under the expected name, or have a device opened instead
+// of a file.
+//
+// Files are opened with O_NONBLOCK and checked with fstat before being returned, so that opening
+// a FIFO never blocks, and O_NONBLOCK is cleared again unless requested. SetAllowSpecialFiles
+// has no effect on Windows, where special files are not found in directories, nor on
+// WebAssembly.
+func SetAllowSpecialFiles(allow bool) {
+	allowSpecialFiles.Store(allow)
+}
+
+// checkRegularOrDir returns an error reporting ErrNotRegularFile if mode is neither the mode of a
+// regular file nor the mode of a directory.
+func checkRegularOrDir(mode fs.FileMode) error {
+	if mode.IsRegular() || mode.IsDir() {
+		return nil
+	}
+	return specialFileError(fmt.Errorf("file type %v", mode.Type()))
+}
+
+// specialFileError returns err, the reason why a special file was refused, reported as
+// ErrNotRegularFile.
+func specialFileError(err error) error {
+	return &rejectionError{kind: ErrNotRegularFile, err: err}
+}
+
+// openFileRegularOrDir calls open with flag, and returns the file it opens if that is a regular
+// file or a directory, or if special files are allowed. open is called with nonblockFlag, which
+// is cleared again unless flag holds it. Special files are reported as openRegularOrDir does, with
+// name as the path of the error.
+func openFileRegularOrDir(name string, flag int, open func(flag int) (*os.File, error)) (*os.File, error) {
+	if nonblockFlag == 0 || allowSpecialFiles.Load() {
+		return open(flag)
+	}
+	f, err := open(flag | nonblockFlag)
+	var pe *os.PathError
+	if errors.As(err, &pe) && pe.Err == syscall.ENXIO {
+		// Only special files fail this way, e.g. sockets, or FIFOs opened for writing without a
+		// reader.
+		return nil, &os.PathError{Op: "openat", Path: name, Err: specialFileError(pe.Err)}
+	}
+	if err != nil {
+		return nil, err
+	}
+	fi, err := f.Stat()
+	if err != nil {
+		f.Close()
+		return nil, err
+	}
+	if err := checkRegularOrDir(fi.Mode()); err != nil {
+		f.Close()
+		return nil, &os.PathError{Op: "openat", Path: name, Err: err}
+	}
+	if flag&nonblockFlag == 0 {
+		// Fd puts the file back in blocking mode.
+		f.Fd()
+	}
+	return f, nil
+}
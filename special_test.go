@@ -0,0 +1,129 @@
+// Copyright 2024 Google LLC.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+//go:build unix
+// +build unix
+
+package safeopen
+
+import (
+	"errors"
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"golang.org/x/sys/unix"
+)
+
+// withTimeout runs fn, failing the test if it blocks.
+func withTimeout(t *testing.T, name string, fn func() error) error {
+	t.Helper()
+	done := make(chan error, 1)
+	go func() { done <- fn() }()
+	select {
+	case err := <-done:
+		return err
+	case <-time.After(10 * time.Second):
+		t.Fatalf("%s blocked", name)
+		return nil
+	}
+}
+
+func TestRejectSpecialFiles(t *testing.T) {
+	dir := t.TempDir()
+	if err := unix.Mkfifo(filepath.Join(dir, "fifo"), 0644); err != nil {
+		t.Skipf("Mkfifo() error: %v", err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "data.txt"), []byte("hello"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	openers := map[string]func() error{
+		"OpenAt": func() error {
+			_, err := OpenAt(dir, "fifo")
+			return err
+		},
+		"OpenFileAt(O_WRONLY)": func() error {
+			_, err := OpenFileAt(dir, "fifo", os.O_WRONLY, 0)
+			return err
+		},
+		"ReadFileBeneath": func() error {
+			_, err := ReadFileBeneath(dir, "fifo")
+			return err
+		},
+		"OpenFileBeneath(O_WRONLY)": func() error {
+			_, err := OpenFileBeneath(dir, "fifo", os.O_WRONLY, 0)
+			return err
+		},
+		"Dir.Open": func() error {
+			d, err := OpenDir(dir)
+			if err != nil {
+				return err
+			}
+			defer d.Close()
+			_, err = d.Open("fifo")
+			return err
+		},
+	}
+	for name, open := range openers {
+		err := withTimeout(t, name, open)
+		if !errors.Is(err, ErrNotRegularFile) {
+			t.Errorf("%s(fifo) error = %v, want %v", name, err, ErrNotRegularFile)
+		}
+		// All the backends report special files the same way.
+		var pe *os.PathError
+		var re *rejectionError
+		if !errors.As(err, &pe) || pe.Path != filepath.Join(dir, "fifo") || !errors.As(err, &re) {
+			t.Errorf("%s(fifo) error = %#v, want a PathError for %q wrapping a rejectionError", name, err, filepath.Join(dir, "fifo"))
+		}
+	}
+
+	if l, err := net.Listen("unix", filepath.Join(dir, "sock")); err == nil {
+		defer l.Close()
+		if _, err := OpenAt(dir, "sock"); !errors.Is(err, ErrNotRegularFile) {
+			t.Errorf("OpenAt(sock) error = %v, want %v", err, ErrNotRegularFile)
+		}
+	}
+
+	// Regular files are returned in blocking mode.
+	f, err := OpenAt(dir, "data.txt")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	conn, err := f.SyscallConn()
+	if err != nil {
+		t.Fatal(err)
+	}
+	var flags int
+	conn.Control(func(fd uintptr) { flags, err = unix.FcntlInt(fd, unix.F_GETFL, 0) })
+	if err != nil || flags&unix.O_NONBLOCK != 0 {
+		t.Errorf("F_GETFL = %#x, %v, want no O_NONBLOCK", flags, err)
+	}
+
+	SetAllowSpecialFiles(true)
+	defer SetAllowSpecialFiles(false)
+	err = withTimeout(t, "OpenFileAt(O_NONBLOCK)", func() error {
+		f, err := OpenFileAt(dir, "fifo", os.O_RDONLY|unix.O_NONBLOCK, 0)
+		if err == nil {
+			f.Close()
+		}
+		return err
+	})
+	if err != nil {
+		t.Errorf("OpenFileAt(fifo) with special files allowed error: %v", err)
+	}
+}
@@ -0,0 +1,55 @@
+// Copyright 2024 Google LLC.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+//go:build unix
+// +build unix
+
+package safeopen
+
+import "golang.org/x/sys/unix"
+
+// nonblockFlag is the flag making opening a FIFO return at once, so that it can be checked.
+const nonblockFlag = unix.O_NONBLOCK
+
+// openRegularOrDir calls open with flag, and returns the descriptor it opens if that is a regular
+// file or a directory, or if special files are allowed. open is called with O_NONBLOCK, which
+// is cleared again unless flag holds it. The errors reporting special files wrap
+// ErrNotRegularFile, and are wrapped by the callers in a PathError with their path.
+func openRegularOrDir(flag int, open func(flag int) (int, error)) (int, error) {
+	if allowSpecialFiles.Load() {
+		return open(flag)
+	}
+	fd, err := open(flag | unix.O_NONBLOCK)
+	if err == unix.ENXIO {
+		// Only special files fail this way, e.g. sockets, or FIFOs opened for writing without a
+		// reader.
+		return -1, specialFileError(err)
+	}
+	if err != nil {
+		return fd, err
+	}
+	var st unix.Stat_t
+	err = unix.Fstat(fd, &st)
+	if err == nil {
+		err = checkRegularOrDir(unixFileMode(uint32(st.Mode)))
+	}
+	if err == nil && flag&unix.O_NONBLOCK == 0 {
+		err = unix.SetNonblock(fd, false)
+	}
+	if err != nil {
+		unix.Close(fd)
+		return -1, err
+	}
+	return fd, nil
+}
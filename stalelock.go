@@ -0,0 +1,118 @@
+// Copyright 2024 Google LLC.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package safeopen
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"errors"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strconv"
+	"time"
+)
+
+// maxLockFileSize is the size above which a file is not considered a lock file.
+const maxLockFileSize = 4096
+
+// RemoveStaleLocksBeneath removes the stale lock files in the tree rooted at the named
+// directory, and returns their paths, relative to the directory. match selects the lock files
+// by name, e.g. by extension. A lock file is stale if it is either
+//   - a lease file (see LeaseAt) whose lease has expired, or
+//   - a pid file holding the decimal id of a process which is not running anymore.
+//
+// Other files are left alone. Symbolic links are never followed.
+//
+// Each stale file is checked again before being removed, under the lock CompareAndSwapFileAt
+// uses, so a lock file taken over or refreshed concurrently is never removed. Note that process ids may be
+// reused: a pid file whose process id has been given to an unrelated process is not removed.
+func RemoveStaleLocksBeneath(directory string, match func(name string) bool) ([]string, error) {
+	dfd, err := openDirHandle(directory)
+	if err != nil {
+		return nil, err
+	}
+	defer closeHandle(dfd)
+
+	var candidates []string
+	err = walkIn(dfd, ".", func(file string, fi fs.FileInfo) error {
+		if fi.Mode().IsRegular() && fi.Size() <= maxLockFileSize && match(filepath.Base(file)) {
+			candidates = append(candidates, file)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	var removed []string
+	for _, file := range candidates {
+		ok, err := removeStaleLockIn(dfd, directory, file)
+		if err != nil {
+			return removed, err
+		}
+		if ok {
+			removed = append(removed, file)
+		}
+	}
+	return removed, nil
+}
+
+// removeStaleLockIn removes file beneath dfd if it is a stale lock file, and reports whether
+// it did.
+func removeStaleLockIn(dfd handle, directory, file string) (bool, error) {
+	f, err := openFileNoFollowIn(dfd, directory, file, os.O_RDONLY, 0)
+	if errors.Is(err, fs.ErrNotExist) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	data, err := io.ReadAll(io.LimitReader(f, maxLockFileSize+1))
+	f.Close()
+	if err != nil || len(data) > maxLockFileSize || !isStaleLock(data) {
+		return false, err
+	}
+
+	pfd, base, err := openParentIn(dfd, file)
+	if err != nil {
+		return false, err
+	}
+	if pfd != dfd {
+		defer closeHandle(pfd)
+	}
+	err = removeIfUnchangedIn(pfd, directory, base, sha256.Sum256(data))
+	if errors.Is(err, ErrFileChanged) || errors.Is(err, ErrFileBusy) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+// isStaleLock reports whether data is the content of an expired lease file, or of a pid file
+// whose process is gone.
+func isStaleLock(data []byte) bool {
+	if expiry, err := parseLease(data); err == nil {
+		return !time.Now().Before(expiry)
+	}
+	pid, err := strconv.Atoi(string(bytes.TrimSpace(data)))
+	if err != nil || pid <= 0 {
+		return false
+	}
+	return !processExists(pid)
+}
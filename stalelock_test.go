@@ -0,0 +1,84 @@
+// Copyright 2024 Google LLC.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package safeopen
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"runtime"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestRemoveStaleLocksBeneath(t *testing.T) {
+	if runtime.GOOS == "wasip1" {
+		t.Skip("processes cannot be checked on WASI")
+	}
+	tmpDir := t.TempDir()
+	if err := os.Mkdir(filepath.Join(tmpDir, "workers"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	live, err := LeaseAt(tmpDir, "live.lock", time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer live.Release()
+	if _, err := LeaseAt(filepath.Join(tmpDir, "workers"), "expired.lock", time.Nanosecond); err != nil {
+		t.Fatal(err)
+	}
+	files := map[string]string{
+		"self.pid":                 strconv.Itoa(os.Getpid()) + "\n",
+		"workers/gone.pid":         "2147483646\n",
+		"workers/unrelated.txt":    "2147483646\n",
+		"workers/not-a-pid.pid":    "hello",
+		"workers/negative-pid.pid": "-1",
+	}
+	for file, content := range files {
+		if err := os.WriteFile(filepath.Join(tmpDir, file), []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	time.Sleep(time.Millisecond)
+
+	match := func(name string) bool {
+		return strings.HasSuffix(name, ".lock") || strings.HasSuffix(name, ".pid")
+	}
+	got, err := RemoveStaleLocksBeneath(tmpDir, match)
+	if err != nil {
+		t.Fatalf("RemoveStaleLocksBeneath(%q) error: %v", tmpDir, err)
+	}
+	want := []string{filepath.Join("workers", "expired.lock"), filepath.Join("workers", "gone.pid")}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("RemoveStaleLocksBeneath(%q) = %q, want %q", tmpDir, got, want)
+	}
+	for _, file := range want {
+		if _, err := os.Stat(filepath.Join(tmpDir, file)); !os.IsNotExist(err) {
+			t.Errorf("%s still exists: %v", file, err)
+		}
+	}
+	entries, err := os.ReadDir(filepath.Join(tmpDir, "workers"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 3 {
+		t.Errorf("workers holds %d files, want 3", len(entries))
+	}
+	if err := live.Refresh(time.Hour); err != nil {
+		t.Errorf("Refresh() of a live lease error: %v", err)
+	}
+}
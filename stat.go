@@ -0,0 +1,83 @@
+// Copyright 2024 Google LLC.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package safeopen
+
+import (
+	"io/fs"
+	"os"
+	"path/filepath"
+)
+
+// StatAt returns a FileInfo describing the named file in the named directory, without opening
+// it. file may not contain path separators. If the file is a symbolic link, the returned
+// FileInfo describes the link itself, as symbolic links are never followed by the At functions.
+// If there is an error, it will be of type *PathError.
+func StatAt(directory, file string) (fs.FileInfo, error) {
+	if !isFilename(file) {
+		return nil, &os.PathError{Op: "StatAt", Path: file, Err: ErrInvalidFilename}
+	}
+	return LstatBeneath(directory, file)
+}
+
+// StatBeneath returns a FileInfo describing the named file in the named directory, or a
+// subdirectory, without opening it. file may not contain .. path traversal entries.
+//
+// Symbolic links are followed as long as they point beneath the directory; those pointing out
+// of it are rejected. The links are resolved first, and the resulting path is then queried
+// without following any link. Links are not followed on Windows.
+// If there is an error, it will be of type *PathError.
+func StatBeneath(directory, file string) (fs.FileInfo, error) {
+	dfd, err := openDirHandle(directory)
+	if err != nil {
+		return nil, err
+	}
+	defer closeHandle(dfd)
+
+	resolved, err := resolveIn(dfd, file, true, allowAllSymlinks, nil)
+	if err != nil {
+		return nil, err
+	}
+	fi, err := statIn(dfd, resolved)
+	if err != nil {
+		return nil, err
+	}
+	if name := filepath.Base(file); fi.Name() != name {
+		fi = renamedFileInfo{fi, name}
+	}
+	return fi, nil
+}
+
+// LstatBeneath returns a FileInfo describing the named file in the named directory, or a
+// subdirectory, without opening it. file may not contain .. path traversal entries. If the file
+// is a symbolic link, the returned FileInfo describes the link itself.
+// If there is an error, it will be of type *PathError.
+func LstatBeneath(directory, file string) (fs.FileInfo, error) {
+	dfd, err := openDirHandle(directory)
+	if err != nil {
+		return nil, err
+	}
+	defer closeHandle(dfd)
+
+	return statIn(dfd, file)
+}
+
+// renamedFileInfo is a FileInfo reporting the name it was looked up with, rather than the name
+// of the target of the symbolic links it was resolved through.
+type renamedFileInfo struct {
+	fs.FileInfo
+	name string
+}
+
+func (fi renamedFileInfo) Name() string { return fi.name }
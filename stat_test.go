@@ -0,0 +1,84 @@
+// Copyright 2024 Google LLC.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package safeopen
+
+import (
+	"errors"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+)
+
+func TestStat(t *testing.T) {
+	dir := t.TempDir()
+	outside := t.TempDir()
+	if err := os.Mkdir(filepath.Join(dir, "sub"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "sub", "file"), []byte("hello"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	for name, stat := range map[string]func(string, string) (fs.FileInfo, error){
+		"StatBeneath":  StatBeneath,
+		"LstatBeneath": LstatBeneath,
+	} {
+		fi, err := stat(dir, filepath.Join("sub", "file"))
+		if err != nil {
+			t.Fatalf("%s(sub/file) error: %v", name, err)
+		}
+		if fi.Name() != "file" || fi.Size() != 5 || !fi.Mode().IsRegular() {
+			t.Errorf("%s(sub/file) = %v %v %d, want a regular file of 5 bytes", name, fi.Name(), fi.Mode(), fi.Size())
+		}
+		if _, err := stat(dir, filepath.Join("sub", "missing")); !errors.Is(err, fs.ErrNotExist) {
+			t.Errorf("%s(sub/missing) = %v, want ErrNotExist", name, err)
+		}
+		if _, err := stat(dir, filepath.Join("..", filepath.Base(outside))); err == nil {
+			t.Errorf("%s(../) should have been an error", name)
+		}
+	}
+	if fi, err := StatAt(dir, "sub"); err != nil || !fi.IsDir() {
+		t.Errorf("StatAt(sub) = %v, %v, want a directory", fi, err)
+	}
+	if _, err := StatAt(dir, filepath.Join("sub", "file")); err == nil {
+		t.Error("StatAt(sub/file) should have been an error")
+	}
+
+	if err := os.Symlink(filepath.Join("sub", "file"), filepath.Join(dir, "link")); err != nil {
+		t.Skipf("cannot create symbolic links: %v", err)
+	}
+	if err := os.Symlink(outside, filepath.Join(dir, "escape")); err != nil {
+		t.Fatal(err)
+	}
+	for name, stat := range map[string]func(string, string) (fs.FileInfo, error){
+		"StatAt":       StatAt,
+		"LstatBeneath": LstatBeneath,
+	} {
+		if fi, err := stat(dir, "link"); err != nil || fi.Mode()&fs.ModeSymlink == 0 {
+			t.Errorf("%s(link) = %v, %v, want a symbolic link", name, fi, err)
+		}
+	}
+	if runtime.GOOS == "windows" {
+		return
+	}
+	if fi, err := StatBeneath(dir, "link"); err != nil || fi.Name() != "link" || fi.Size() != 5 {
+		t.Errorf("StatBeneath(link) = %v, %v, want the file it points to, named link", fi, err)
+	}
+	if _, err := StatBeneath(dir, "escape"); err == nil {
+		t.Error("StatBeneath(escape) followed a symbolic link out of the directory")
+	}
+}
@@ -0,0 +1,119 @@
+// Copyright 2024 Google LLC.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package safeopen
+
+import (
+	"io/fs"
+	"os"
+	"path/filepath"
+	"time"
+
+	"golang.org/x/sys/unix"
+)
+
+// StatxInfo describes a file with the Linux specific attributes reported by statx(2).
+// Its Sys method returns a *unix.Statx_t.
+type StatxInfo struct {
+	fs.FileInfo
+	// MountID identifies the mount containing the file, or is zero if the kernel does not report
+	// it (before Linux 5.8).
+	MountID uint64
+	// Attributes holds the STATX_ATTR_* flags of the file, and AttributesMask the flags the
+	// filesystem supports.
+	Attributes, AttributesMask uint64
+	// BirthTime is the creation time, or the zero time if the filesystem does not report it.
+	BirthTime time.Time
+}
+
+// Immutable reports whether the file is marked immutable (chattr +i).
+func (s *StatxInfo) Immutable() bool { return s.Attributes&unix.STATX_ATTR_IMMUTABLE != 0 }
+
+// AppendOnly reports whether the file is marked append only (chattr +a).
+func (s *StatxInfo) AppendOnly() bool { return s.Attributes&unix.STATX_ATTR_APPEND != 0 }
+
+// MountRoot reports whether the file is the root of a mount.
+func (s *StatxInfo) MountRoot() bool { return s.Attributes&unix.STATX_ATTR_MOUNT_ROOT != 0 }
+
+// StatxAt returns the StatxInfo of the named file in the named directory.
+// file may not contain path separators. Symbolic links are not followed.
+func StatxAt(directory, file string) (*StatxInfo, error) {
+	if !isFilename(file) {
+		return nil, &os.PathError{Op: "StatxAt", Path: file, Err: ErrInvalidFilename}
+	}
+	return StatxBeneath(directory, file)
+}
+
+// StatxBeneath returns the StatxInfo of the named file in the named directory, or a
+// subdirectory.
+// file may not contain .. path traversal entries. Symbolic links are not followed in the last
+// element of file.
+func StatxBeneath(directory, file string) (*StatxInfo, error) {
+	dfd, err := openDirHandle(directory)
+	if err != nil {
+		return nil, err
+	}
+	defer closeHandle(dfd)
+
+	return statxIn(dfd, file)
+}
+
+func statxIn(dfd handle, file string) (*StatxInfo, error) {
+	pfd, base, err := openParentIn(dfd, file)
+	if err != nil {
+		return nil, err
+	}
+	if pfd != dfd {
+		defer unix.Close(pfd)
+	}
+	if base == "" {
+		base = "."
+	}
+
+	st := &statxFileInfo{name: filepath.Base(file)}
+	mask := unix.STATX_BASIC_STATS | unix.STATX_BTIME | unix.STATX_MNT_ID
+	if err := unix.Statx(pfd, base, unix.AT_SYMLINK_NOFOLLOW|unix.AT_STATX_SYNC_AS_STAT, mask, &st.sys); err != nil {
+		return nil, &os.PathError{Op: "statx", Path: file, Err: err}
+	}
+
+	info := &StatxInfo{
+		FileInfo:       st,
+		Attributes:     st.sys.Attributes,
+		AttributesMask: st.sys.Attributes_mask,
+	}
+	if st.sys.Mask&unix.STATX_MNT_ID != 0 {
+		info.MountID = st.sys.Mnt_id
+	}
+	if st.sys.Mask&unix.STATX_BTIME != 0 {
+		info.BirthTime = statxTime(st.sys.Btime)
+	}
+	return info, nil
+}
+
+// statxFileInfo implements fs.FileInfo on top of unix.Statx_t.
+type statxFileInfo struct {
+	name string
+	sys  unix.Statx_t
+}
+
+func (st *statxFileInfo) Name() string       { return st.name }
+func (st *statxFileInfo) Size() int64        { return int64(st.sys.Size) }
+func (st *statxFileInfo) Mode() fs.FileMode  { return unixFileMode(uint32(st.sys.Mode)) }
+func (st *statxFileInfo) ModTime() time.Time { return statxTime(st.sys.Mtime) }
+func (st *statxFileInfo) IsDir() bool        { return st.Mode().IsDir() }
+func (st *statxFileInfo) Sys() any           { return &st.sys }
+
+func statxTime(ts unix.StatxTimestamp) time.Time {
+	return time.Unix(ts.Sec, int64(ts.Nsec))
+}
@@ -0,0 +1,68 @@
+// Copyright 2024 Google LLC.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package safeopen
+
+import (
+	"os"
+	"path"
+	"testing"
+)
+
+func TestStatx(t *testing.T) {
+	tmpDir := t.TempDir()
+	if err := os.Mkdir(path.Join(tmpDir, "subdir"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	file := path.Join("subdir", "data.txt")
+	if err := os.WriteFile(path.Join(tmpDir, file), []byte("content"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	info, err := StatxBeneath(tmpDir, file)
+	if err != nil {
+		t.Fatalf("StatxBeneath(%q, %q) error: %v", tmpDir, file, err)
+	}
+	if info.Name() != "data.txt" || info.Size() != 7 || info.Mode() != 0644 {
+		t.Errorf("StatxBeneath(%q, %q) = (%q, %d, %v), want (%q, 7, %v)", tmpDir, file, info.Name(), info.Size(), info.Mode(), "data.txt", os.FileMode(0644))
+	}
+	if info.Immutable() || info.AppendOnly() {
+		t.Errorf("StatxBeneath(%q, %q) reports an immutable or append only file", tmpDir, file)
+	}
+	fi, err := os.Stat(path.Join(tmpDir, file))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !info.ModTime().Equal(fi.ModTime()) {
+		t.Errorf("StatxBeneath(%q, %q).ModTime() = %v, want %v", tmpDir, file, info.ModTime(), fi.ModTime())
+	}
+
+	dir, err := StatxAt(tmpDir, "subdir")
+	if err != nil {
+		t.Fatalf("StatxAt(%q, %q) error: %v", tmpDir, "subdir", err)
+	}
+	if !dir.IsDir() {
+		t.Errorf("StatxAt(%q, %q).IsDir() = false, want true", tmpDir, "subdir")
+	}
+	if dir.MountID != info.MountID {
+		t.Errorf("StatxAt(%q, %q).MountID = %d, want %d", tmpDir, "subdir", dir.MountID, info.MountID)
+	}
+
+	if _, err := StatxAt(tmpDir, file); err == nil {
+		t.Errorf("StatxAt(%q, %q) succeeded, want error for a path with separators", tmpDir, file)
+	}
+	if _, err := StatxBeneath(tmpDir, "../data.txt"); err == nil {
+		t.Errorf("StatxBeneath(%q, %q) succeeded, want error", tmpDir, "../data.txt")
+	}
+}
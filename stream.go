@@ -0,0 +1,78 @@
+// Copyright 2024 Google LLC.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package safeopen
+
+import (
+	"errors"
+	"io"
+	"io/fs"
+	"os"
+)
+
+// ErrFileTooLarge is returned by WriteFromReaderLimitAt if the input exceeds the limit.
+var ErrFileTooLarge = errors.New("file too large")
+
+// WriteFromReaderAt creates or replaces the named file in the named directory with the content
+// read from r until EOF, and returns the number of bytes written.
+// file may not contain path separators.
+//
+// The input is streamed to a temporary file in the same directory, which replaces file only once
+// the whole input has been written successfully: a failed transfer never leaves a partially
+// written file behind. The data and the directory are synced to disk, so that after a crash file
+// holds either the old or the new content. If the file does not exist, it is created with mode perm (before umask).
+func WriteFromReaderAt(directory, file string, r io.Reader, perm fs.FileMode) (int64, error) {
+	return WriteFromReaderLimitAt(directory, file, r, perm, -1)
+}
+
+// WriteFromReaderLimitAt is like WriteFromReaderAt, but fails with an error wrapping
+// ErrFileTooLarge if r provides more than limit bytes. A negative limit means no limit.
+func WriteFromReaderLimitAt(directory, file string, r io.Reader, perm fs.FileMode, limit int64) (int64, error) {
+	if !isFilename(file) {
+		return 0, &os.PathError{Op: "WriteFromReaderAt", Path: file, Err: ErrInvalidFilename}
+	}
+
+	dfd, err := openDirHandle(directory)
+	if err != nil {
+		return 0, err
+	}
+	defer closeHandle(dfd)
+
+	f, tmp, err := createTempIn(dfd, directory, "."+file+".tmp", perm)
+	if err != nil {
+		return 0, err
+	}
+	if limit >= 0 {
+		// Read one byte more than allowed to detect oversized input.
+		r = io.LimitReader(r, limit+1)
+	}
+	n, err := io.Copy(f, r)
+	if err == nil && limit >= 0 && n > limit {
+		err = &os.PathError{Op: "WriteFromReaderAt", Path: file, Err: ErrFileTooLarge}
+	}
+	if err == nil {
+		err = f.Sync()
+	}
+	if err1 := f.Close(); err1 != nil && err == nil {
+		err = err1
+	}
+	if err != nil {
+		removeIn(dfd, tmp)
+		return 0, err
+	}
+	if err := commitTempIn(dfd, tmp, file); err != nil {
+		return 0, err
+	}
+	return n, nil
+}
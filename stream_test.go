@@ -0,0 +1,74 @@
+// Copyright 2024 Google LLC.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package safeopen
+
+import (
+	"bytes"
+	"errors"
+	"os"
+	"path"
+	"strings"
+	"testing"
+)
+
+func TestWriteFromReaderAt(t *testing.T) {
+	filename := "upload.bin"
+	edata := bytes.Repeat([]byte("0123456789"), 100000)
+
+	tmpDir := t.TempDir()
+	n, err := WriteFromReaderAt(tmpDir, filename, bytes.NewReader(edata), 0644)
+	if err != nil {
+		t.Fatalf("WriteFromReaderAt(%q, %q) error: %v", tmpDir, filename, err)
+	}
+	if n != int64(len(edata)) {
+		t.Errorf("WriteFromReaderAt(%q, %q) = %d, want %d", tmpDir, filename, n, len(edata))
+	}
+	adata, err := os.ReadFile(path.Join(tmpDir, filename))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(adata, edata) {
+		t.Errorf("WriteFromReaderAt(%q, %q) wrote %d bytes, want %d", tmpDir, filename, len(adata), len(edata))
+	}
+
+	_, err = WriteFromReaderLimitAt(tmpDir, filename, strings.NewReader("too large"), 0644, 3)
+	if !errors.Is(err, ErrFileTooLarge) {
+		t.Errorf("WriteFromReaderLimitAt(%q, %q) = %v, want %v", tmpDir, filename, err, ErrFileTooLarge)
+	}
+	// The previous content must have been kept, without any leftovers.
+	adata, err = os.ReadFile(path.Join(tmpDir, filename))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(adata, edata) {
+		t.Errorf("failed WriteFromReaderLimitAt(%q, %q) modified the file", tmpDir, filename)
+	}
+	entries, err := os.ReadDir(tmpDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 1 {
+		t.Errorf("WriteFromReaderLimitAt(%q, %q) left %d entries behind, want 1", tmpDir, filename, len(entries))
+	}
+
+	n, err = WriteFromReaderLimitAt(tmpDir, filename, strings.NewReader("fits"), 0644, 4)
+	if err != nil || n != 4 {
+		t.Errorf("WriteFromReaderLimitAt(%q, %q) = %d, %v, want 4, nil", tmpDir, filename, n, err)
+	}
+
+	if _, err := WriteFromReaderAt(tmpDir, "../"+filename, strings.NewReader("x"), 0644); err == nil {
+		t.Errorf("WriteFromReaderAt(%q, %q) should have been an error", tmpDir, "../"+filename)
+	}
+}
@@ -0,0 +1,48 @@
+// Copyright 2024 Google LLC.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package safeopen
+
+import (
+	"errors"
+	"sync/atomic"
+)
+
+// ErrLegacyResolution is returned in strict mode when a path would have to be resolved by the
+// legacy implementation, which walks the path one component at a time, because openat2 with
+// RESOLVE_BENEATH is not available.
+var ErrLegacyResolution = errors.New("legacy path resolution refused in strict mode")
+
+var strictMode atomic.Bool
+
+// SetStrictMode enables or disables strict mode for the whole program. In strict mode, paths
+// made of more than one component are never resolved by the legacy implementation: the
+// functions of this package fail with ErrLegacyResolution instead. This concerns Linux kernels
+// without openat2 (before 5.6) as well as the Unix systems other than Linux. Single file names
+// are still opened, as the legacy implementation is just as safe for them. On macOS 11 and
+// later, files are opened in a single call with O_NOFOLLOW_ANY, which strict mode allows.
+//
+// Strict mode has no effect on Windows.
+func SetStrictMode(strict bool) {
+	strictMode.Store(strict)
+}
+
+// WithStrictResolution makes OpenDir fail with ErrLegacyResolution if paths beneath the
+// directory cannot be resolved with openat2 and RESOLVE_BENEATH. It does not enable strict mode
+// for the rest of the program.
+func WithStrictResolution() Option {
+	return func(o *options) {
+		o.strict = true
+	}
+}
@@ -0,0 +1,177 @@
+// Copyright 2024 Google LLC.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package safeopen
+
+import (
+	"errors"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// ErrSymlinkNotAllowed is returned when a symbolic link is rejected by a SymlinkPolicy.
+var ErrSymlinkNotAllowed = errors.New("symbolic link not allowed")
+
+// maxSymlinkHops bounds the number of symbolic links followed while resolving a single path.
+const maxSymlinkHops = 40
+
+// SymlinkPolicy decides whether a symbolic link met while resolving a path beneath a Dir may be
+// followed. link is the path of the link, and target the path it points to, both relative to
+// the Dir and cleaned. A non-nil error rejects the link.
+//
+// Links that are absolute or point outside the Dir are always rejected, without consulting the
+// policy. On Windows, absolute targets beneath the Dir, as held by junctions, are made relative
+// to it instead.
+type SymlinkPolicy func(link, target string) error
+
+// AllowSymlinkPrefixes returns a SymlinkPolicy that accepts the links whose target is one of
+// prefixes, or beneath one of them. prefixes are relative to the Dir.
+func AllowSymlinkPrefixes(prefixes ...string) SymlinkPolicy {
+	cleaned := make([]string, len(prefixes))
+	for i, p := range prefixes {
+		cleaned[i] = filepath.Clean(filepath.FromSlash(p))
+	}
+	return func(link, target string) error {
+		for _, p := range cleaned {
+			if p == "." || target == p || strings.HasPrefix(target, p+string(filepath.Separator)) {
+				return nil
+			}
+		}
+		return ErrSymlinkNotAllowed
+	}
+}
+
+// AllowSameDirSymlinks returns a SymlinkPolicy that accepts the links whose target is in the
+// same directory as the link itself.
+func AllowSameDirSymlinks() SymlinkPolicy {
+	return func(link, target string) error {
+		if filepath.Dir(link) != filepath.Dir(target) {
+			return ErrSymlinkNotAllowed
+		}
+		return nil
+	}
+}
+
+// WithSymlinkPolicy makes d follow the symbolic links accepted by policy, and reject all the
+// others. Without a policy, symbolic links beneath d are handled as by OpenBeneath.
+//
+// Symbolic links are resolved by d itself, and the resulting path is then opened without
+// following any link, so a link swapped in concurrently makes the operation fail rather than
+// bypass the policy. On Windows, junctions are handled as symbolic links.
+func WithSymlinkPolicy(policy SymlinkPolicy) Option {
+	return func(o *options) {
+		o.symlinkPolicy = policy
+	}
+}
+
+// allowAllSymlinks is a SymlinkPolicy that accepts all the links pointing beneath the Dir.
+func allowAllSymlinks(link, target string) error {
+	return nil
+}
+
+// resolveIn resolves the symbolic links in file beneath dfd, checking each of them against
+// policy. The last element of file is resolved only if followLast is set. The returned path,
+// relative to dfd, contains no symbolic link as of the time it was resolved. If trace is not
+// nil, the components looked up are appended to it.
+func resolveIn(dfd handle, file string, followLast bool, policy SymlinkPolicy, trace *[]ResolveStep) (string, error) {
+	clean, ok := cleanRelPath(file)
+	if !ok {
+		return "", &os.PathError{Op: "OpenBeneath", Path: file, Err: beneathPathError(file)}
+	}
+
+	var resolved []string
+	rest := splitRelPath(clean)
+	for hops := 0; len(rest) > 0; {
+		seg := rest[0]
+		rest = rest[1:]
+		cur := filepath.Join(append(resolved, seg)...)
+		last := len(rest) == 0
+		if last && !followLast && trace == nil {
+			return cur, nil
+		}
+
+		fi, err := statIn(dfd, cur)
+		if errors.Is(err, fs.ErrNotExist) {
+			if trace != nil {
+				*trace = append(*trace, ResolveStep{Path: cur, Missing: true})
+			}
+			// Leave it to the caller to fail or create the file.
+			return filepath.Join(append([]string{cur}, rest...)...), nil
+		}
+		if err != nil {
+			return "", err
+		}
+		if fi.Mode()&fs.ModeSymlink == 0 || (last && !followLast) {
+			if trace != nil {
+				*trace = append(*trace, ResolveStep{Path: cur, Type: fi.Mode().Type()})
+			}
+			resolved = append(resolved, seg)
+			continue
+		}
+
+		if hops++; hops > maxSymlinkHops {
+			return "", &os.PathError{Op: "OpenBeneath", Path: file, Err: errors.New("too many levels of symbolic links")}
+		}
+		target, err := readlinkIn(dfd, cur)
+		if err != nil {
+			return "", err
+		}
+		if trace != nil {
+			*trace = append(*trace, ResolveStep{Path: cur, Type: fs.ModeSymlink, Target: target})
+		}
+		if filepath.IsAbs(target) || filepath.VolumeName(target) != "" || strings.HasPrefix(filepath.ToSlash(target), "/") {
+			// Absolute targets beneath dfd, as held by junctions, are followed on Windows only.
+			target, ok = rootRelativeIn(dfd, target)
+		} else {
+			target, ok = cleanRelPath(filepath.Join(filepath.Join(resolved...), target))
+		}
+		if !ok {
+			return "", &os.PathError{Op: "OpenBeneath", Path: file, Err: symlinkRejected(ErrSymlinkNotAllowed)}
+		}
+		if err := policy(cur, target); err != nil {
+			return "", &os.PathError{Op: "OpenBeneath", Path: file, Err: symlinkRejected(err)}
+		}
+		resolved = nil
+		rest = append(splitRelPath(target), rest...)
+	}
+	if len(resolved) == 0 {
+		return ".", nil
+	}
+	return filepath.Join(resolved...), nil
+}
+
+// cleanRelPath cleans the relative path p, and reports whether it stays beneath its base.
+func cleanRelPath(p string) (string, bool) {
+	if p == "" {
+		return "", false
+	}
+	p = filepath.Clean(filepath.FromSlash(p))
+	p = strings.TrimLeft(p, string(filepath.Separator))
+	if p == "" {
+		p = "."
+	}
+	if p == ".." || strings.HasPrefix(p, ".."+string(filepath.Separator)) {
+		return "", false
+	}
+	return p, true
+}
+
+func splitRelPath(p string) []string {
+	if p == "." {
+		return nil
+	}
+	return strings.Split(p, string(filepath.Separator))
+}
@@ -0,0 +1,112 @@
+// Copyright 2024 Google LLC.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package safeopen
+
+import (
+	"errors"
+	"os"
+	"path"
+	"runtime"
+	"testing"
+)
+
+func TestSymlinkPolicy(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("symbolic links are not followed on Windows")
+	}
+	tmpDir := t.TempDir()
+	for _, dir := range []string{"releases/v2", "other"} {
+		if err := os.MkdirAll(path.Join(tmpDir, dir), 0755); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for _, file := range []string{"releases/v2/data.txt", "other/data.txt", "config.txt"} {
+		if err := os.WriteFile(path.Join(tmpDir, file), []byte(file), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	links := map[string]string{
+		"current":      "releases/v2",
+		"elsewhere":    "other",
+		"config.link":  "config.txt",
+		"releases/v1":  "v2",
+		"escape":       "../outside",
+		"absolute":     "/etc",
+		"releases/old": "../other",
+	}
+	for link, target := range links {
+		if err := os.Symlink(target, path.Join(tmpDir, link)); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	tests := []struct {
+		name    string
+		policy  SymlinkPolicy
+		file    string
+		want    string
+		wantErr error
+	}{
+		{"prefix", AllowSymlinkPrefixes("releases"), "current/data.txt", "releases/v2/data.txt", nil},
+		{"prefix chained", AllowSymlinkPrefixes("releases"), "releases/v1/data.txt", "releases/v2/data.txt", nil},
+		{"prefix rejected", AllowSymlinkPrefixes("releases"), "elsewhere/data.txt", "", ErrSymlinkNotAllowed},
+		{"prefix partial name", AllowSymlinkPrefixes("rel"), "current/data.txt", "", ErrSymlinkNotAllowed},
+		{"same dir", AllowSameDirSymlinks(), "config.link", "config.txt", nil},
+		{"same dir nested", AllowSameDirSymlinks(), "releases/v1/data.txt", "releases/v2/data.txt", nil},
+		{"same dir rejected", AllowSameDirSymlinks(), "releases/old/data.txt", "", ErrSymlinkNotAllowed},
+		{"escape", AllowSymlinkPrefixes("."), "escape/data.txt", "", ErrSymlinkNotAllowed},
+		{"absolute", AllowSymlinkPrefixes("."), "absolute/passwd", "", ErrSymlinkNotAllowed},
+		{"no link", AllowSymlinkPrefixes(), "other/data.txt", "other/data.txt", nil},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			d, err := OpenDir(tmpDir, WithSymlinkPolicy(tc.policy))
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer d.Close()
+
+			got, err := d.ReadFile(tc.file)
+			if tc.wantErr != nil {
+				if !errors.Is(err, tc.wantErr) {
+					t.Errorf("ReadFile(%q) error = %v, want %v", tc.file, err, tc.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("ReadFile(%q) error: %v", tc.file, err)
+			}
+			if string(got) != tc.want {
+				t.Errorf("ReadFile(%q) = %q, want %q", tc.file, got, tc.want)
+			}
+		})
+	}
+
+	d, err := OpenDir(tmpDir, WithSymlinkPolicy(AllowSymlinkPrefixes("releases")))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer d.Close()
+	fi, err := d.Lstat("current")
+	if err != nil {
+		t.Fatalf("Lstat(%q) error: %v", "current", err)
+	}
+	if fi.Mode()&os.ModeSymlink == 0 {
+		t.Errorf("Lstat(%q) = %v, want a symbolic link", "current", fi.Mode())
+	}
+	if _, err := d.Lstat("current/data.txt"); err != nil {
+		t.Errorf("Lstat(%q) error: %v", "current/data.txt", err)
+	}
+}
@@ -0,0 +1,64 @@
+// Copyright 2024 Google LLC.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package safeopen
+
+import (
+	"os"
+	"path/filepath"
+)
+
+// SymlinkBeneath creates linkname, in the named directory or a subdirectory, as a symbolic link
+// to target. linkname may not contain .. path traversal entries, and the directories leading to
+// it are resolved as by OpenBeneath. SymlinkBeneath is not supported on Windows, AIX and
+// Solaris.
+//
+// If policy is nil, target is stored as is. Otherwise, target must be relative and point beneath
+// the directory, as resolved from the directory containing linkname, and it must be accepted by
+// policy, which gets the paths of the link and of its target relative to the directory, as when
+// a Dir resolves the link; AllowSymlinkPrefixes(".") accepts any such target. This is checked
+// lexically: the target may not exist yet, and whether the links it goes through stay beneath
+// the directory is checked when following them. Otherwise, the error wraps ErrSymlinkNotAllowed.
+// If there is an error, it will be of type *PathError.
+func SymlinkBeneath(directory, target, linkname string, policy SymlinkPolicy) error {
+	if policy != nil {
+		if err := checkSymlinkTarget(target, linkname, policy); err != nil {
+			return &os.PathError{Op: "symlink", Path: filepath.Join(directory, linkname), Err: err}
+		}
+	}
+	return inParentBeneath(directory, linkname, "symlink", func(pfd handle, base string) error {
+		return symlinkIn(pfd, target, base)
+	})
+}
+
+// checkSymlinkTarget checks that a symbolic link link to target, both relative to a directory,
+// points beneath the directory and is accepted by policy, if not nil, with the same rules as
+// resolveIn.
+func checkSymlinkTarget(target, link string, policy SymlinkPolicy) error {
+	clink, ok := cleanRelPath(link)
+	if !ok {
+		return beneathPathError(link)
+	}
+	if target == "" || filepath.IsAbs(target) || filepath.VolumeName(target) != "" {
+		return ErrSymlinkNotAllowed
+	}
+	ctarget, ok := cleanRelPath(filepath.Join(filepath.Dir(clink), target))
+	if !ok {
+		return ErrSymlinkNotAllowed
+	}
+	if policy == nil {
+		return nil
+	}
+	return policy(clink, ctarget)
+}
@@ -0,0 +1,83 @@
+// Copyright 2024 Google LLC.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package safeopen
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+)
+
+func TestSymlinkBeneath(t *testing.T) {
+	switch runtime.GOOS {
+	case "windows", "aix", "solaris", "illumos":
+		t.Skipf("SymlinkBeneath is not supported on %s", runtime.GOOS)
+	}
+	dir := t.TempDir()
+	if err := os.Mkdir(filepath.Join(dir, "sub"), 0755); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := SymlinkBeneath(dir, "/etc/passwd", "abs", nil); err != nil {
+		t.Errorf("SymlinkBeneath(abs) without policy error: %v", err)
+	}
+	if got, err := os.Readlink(filepath.Join(dir, "abs")); err != nil || got != "/etc/passwd" {
+		t.Errorf("abs points to %q, %v, want /etc/passwd", got, err)
+	}
+
+	policy := AllowSymlinkPrefixes(".")
+	for _, tc := range []struct{ target, link string }{
+		{"file", "link"},
+		{"../file", filepath.Join("sub", "link")},
+		{"sub/missing", "dangling"},
+	} {
+		if err := SymlinkBeneath(dir, tc.target, tc.link, policy); err != nil {
+			t.Errorf("SymlinkBeneath(%q, %q) error: %v", tc.target, tc.link, err)
+			continue
+		}
+		if got, err := os.Readlink(filepath.Join(dir, tc.link)); err != nil || got != tc.target {
+			t.Errorf("%s points to %q, %v, want %q", tc.link, got, err, tc.target)
+		}
+	}
+
+	for _, tc := range []struct{ target, link string }{
+		{"/etc/passwd", "abs2"},
+		{"../outside", "up"},
+		{"../../outside", filepath.Join("sub", "up")},
+		{"", "empty"},
+	} {
+		err := SymlinkBeneath(dir, tc.target, tc.link, policy)
+		if !errors.Is(err, ErrSymlinkNotAllowed) {
+			t.Errorf("SymlinkBeneath(%q, %q) = %v, want ErrSymlinkNotAllowed", tc.target, tc.link, err)
+		}
+		if _, err := os.Lstat(filepath.Join(dir, tc.link)); err == nil {
+			t.Errorf("%s should not have been created", tc.link)
+		}
+	}
+	if err := SymlinkBeneath(dir, "secret", "denied", AllowSymlinkPrefixes("sub")); !errors.Is(err, ErrSymlinkNotAllowed) {
+		t.Errorf("SymlinkBeneath(secret) = %v, want ErrSymlinkNotAllowed", err)
+	}
+
+	if err := SymlinkBeneath(dir, "file", "link", nil); err == nil {
+		t.Error("SymlinkBeneath over an existing link should have been an error")
+	}
+	for _, link := range []string{"../escape", "", "."} {
+		if err := SymlinkBeneath(dir, "file", link, nil); err == nil {
+			t.Errorf("SymlinkBeneath(%q) should have been an error", link)
+		}
+	}
+}
@@ -0,0 +1,123 @@
+// Copyright 2024 Google LLC.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package safeopen
+
+import (
+	"errors"
+	"io"
+	"io/fs"
+	"os"
+)
+
+// Tail follows a file beneath a directory as it grows, in the way of tail -F: when the file is
+// truncated, it is read again from the start, and when it is replaced (e.g. by log rotation),
+// the rest of the old file is read before switching to the new one. The directory is kept open,
+// so the successor is always looked up beneath the very same directory.
+//
+// Tail is not safe for concurrent use.
+type Tail struct {
+	directory string
+	file      string
+	dfd       handle
+	f         *os.File
+}
+
+// TailBeneath starts following the named file in the named directory, or a subdirectory.
+// file may not contain .. path traversal entries. Reading starts at the end of the file if
+// fromEnd is set, at its start otherwise.
+func TailBeneath(directory, file string, fromEnd bool) (*Tail, error) {
+	dfd, err := openDirHandle(directory)
+	if err != nil {
+		return nil, err
+	}
+	f, err := openFileBeneathIn(dfd, directory, file, os.O_RDONLY, 0)
+	if err != nil {
+		closeHandle(dfd)
+		return nil, err
+	}
+	if fromEnd {
+		if _, err := f.Seek(0, io.SeekEnd); err != nil {
+			f.Close()
+			closeHandle(dfd)
+			return nil, err
+		}
+	}
+	return &Tail{directory: directory, file: file, dfd: dfd, f: f}, nil
+}
+
+// Read reads the data appended to the file since the last call. It does not wait for new
+// data: once all the available data has been read, it returns 0 and io.EOF, and may be called
+// again later, typically after a short sleep.
+func (t *Tail) Read(p []byte) (int, error) {
+	n, err := t.f.Read(p)
+	if n > 0 || err != io.EOF {
+		return n, err
+	}
+	switched, err := t.reopen()
+	if err != nil || !switched {
+		return 0, err
+	}
+	return t.f.Read(p)
+}
+
+// reopen checks, at the end of the current file, whether the file was truncated or replaced,
+// and reports whether reading should go on from the start of the current file or its successor.
+func (t *Tail) reopen() (bool, error) {
+	cur, err := t.f.Stat()
+	if err != nil {
+		return false, err
+	}
+	nf, err := openFileBeneathIn(t.dfd, t.directory, t.file, os.O_RDONLY, 0)
+	if errors.Is(err, fs.ErrNotExist) {
+		// Rotation in progress, the successor is not there yet.
+		return false, io.EOF
+	}
+	if err != nil {
+		return false, err
+	}
+	next, err := nf.Stat()
+	if err != nil {
+		nf.Close()
+		return false, err
+	}
+
+	if !os.SameFile(cur, next) {
+		t.f.Close()
+		t.f = nf
+		return true, nil
+	}
+	nf.Close()
+	pos, err := t.f.Seek(0, io.SeekCurrent)
+	if err != nil {
+		return false, err
+	}
+	if cur.Size() >= pos {
+		return false, io.EOF
+	}
+	// Truncated.
+	if _, err := t.f.Seek(0, io.SeekStart); err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+// Close stops following the file.
+func (t *Tail) Close() error {
+	err := t.f.Close()
+	if err1 := closeHandle(t.dfd); err1 != nil && err == nil {
+		err = err1
+	}
+	return err
+}
@@ -0,0 +1,78 @@
+// Copyright 2024 Google LLC.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package safeopen
+
+import (
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestTailBeneath(t *testing.T) {
+	tmpDir := t.TempDir()
+	if err := os.Mkdir(filepath.Join(tmpDir, "logs"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	file := filepath.Join("logs", "app.log")
+	full := filepath.Join(tmpDir, file)
+	appendLog := func(s string) {
+		f, err := os.OpenFile(full, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0644)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer f.Close()
+		if _, err := f.WriteString(s); err != nil {
+			t.Fatal(err)
+		}
+	}
+	appendLog("old\n")
+
+	tail, err := TailBeneath(tmpDir, file, true)
+	if err != nil {
+		t.Fatalf("TailBeneath(%q, %q) error: %v", tmpDir, file, err)
+	}
+	defer tail.Close()
+	expect := func(want string) {
+		t.Helper()
+		got, err := io.ReadAll(tail)
+		if err != nil {
+			t.Fatalf("Read() error: %v", err)
+		}
+		if string(got) != want {
+			t.Errorf("Read() = %q, want %q", got, want)
+		}
+	}
+
+	expect("")
+	appendLog("one\n")
+	expect("one\n")
+
+	// Rotation: the rest of the old file is read first.
+	appendLog("two\n")
+	if err := os.Rename(full, full+".1"); err != nil {
+		t.Fatal(err)
+	}
+	expect("two\n")
+	appendLog("three\n")
+	expect("three\n")
+
+	// Truncation.
+	if err := os.Truncate(full, 0); err != nil {
+		t.Fatal(err)
+	}
+	appendLog("four\n")
+	expect("four\n")
+}
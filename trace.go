@@ -0,0 +1,43 @@
+// Copyright 2024 Google LLC.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package safeopen
+
+import "io/fs"
+
+// ResolveStep describes one path component looked up while resolving a file beneath a Dir.
+type ResolveStep struct {
+	// Path is the path of the component, relative to the Dir.
+	Path string
+	// Type holds the type bits of the component: zero for a regular file, fs.ModeDir for a
+	// directory, fs.ModeSymlink for a symbolic link, and so on.
+	Type fs.FileMode
+	// Target is the content of the symbolic link, if the component is one.
+	Target string
+	// Missing is set if the component does not exist. It is then the last step.
+	Missing bool
+}
+
+// WithResolveTrace makes d call fn after each lookup of a file, with the components that were
+// resolved and the error of the operation, if any. Failed lookups are reported as well, so fn
+// may be used to log the files accessed and the symbolic links followed or rejected.
+//
+// With WithSymlinkPolicy, the steps are exactly the ones resolved by d. Without it, the path is
+// resolved by the operating system, and the steps are gathered by a separate lookup that may
+// not reflect concurrent changes to the directory.
+func WithResolveTrace(fn func(file string, steps []ResolveStep, err error)) Option {
+	return func(o *options) {
+		o.resolveTrace = fn
+	}
+}
@@ -0,0 +1,81 @@
+// Copyright 2024 Google LLC.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package safeopen
+
+import (
+	"errors"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"reflect"
+	"runtime"
+	"testing"
+)
+
+func TestResolveTrace(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("symbolic links are not followed on Windows")
+	}
+	tmpDir := t.TempDir()
+	if err := os.MkdirAll(path.Join(tmpDir, "releases/v2"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path.Join(tmpDir, "releases/v2/data.txt"), []byte("content"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Symlink("releases/v2", path.Join(tmpDir, "current")); err != nil {
+		t.Fatal(err)
+	}
+
+	var gotFile string
+	var gotSteps []ResolveStep
+	var gotErr error
+	d, err := OpenDir(tmpDir, WithSymlinkPolicy(AllowSymlinkPrefixes("releases")), WithResolveTrace(func(file string, steps []ResolveStep, err error) {
+		gotFile, gotSteps, gotErr = file, steps, err
+	}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer d.Close()
+
+	if _, err := d.ReadFile("current/data.txt"); err != nil {
+		t.Fatalf("ReadFile(%q) error: %v", "current/data.txt", err)
+	}
+	want := []ResolveStep{
+		{Path: "current", Type: fs.ModeSymlink, Target: "releases/v2"},
+		{Path: "releases", Type: fs.ModeDir},
+		{Path: filepath.FromSlash("releases/v2"), Type: fs.ModeDir},
+		{Path: filepath.FromSlash("releases/v2/data.txt")},
+	}
+	if gotFile != "current/data.txt" || gotErr != nil || !reflect.DeepEqual(gotSteps, want) {
+		t.Errorf("ReadFile(%q) traced (%q, %+v, %v), want (%q, %+v, nil)", "current/data.txt", gotFile, gotSteps, gotErr, "current/data.txt", want)
+	}
+
+	if _, err := d.ReadFile("current/missing.txt"); !errors.Is(err, fs.ErrNotExist) {
+		t.Fatalf("ReadFile(%q) error = %v, want %v", "current/missing.txt", err, fs.ErrNotExist)
+	}
+	if last := gotSteps[len(gotSteps)-1]; !last.Missing || !errors.Is(gotErr, fs.ErrNotExist) {
+		t.Errorf("ReadFile(%q) traced last step %+v and error %v, want a missing step and %v", "current/missing.txt", last, gotErr, fs.ErrNotExist)
+	}
+
+	if _, err := d.Lstat("current"); err != nil {
+		t.Fatalf("Lstat(%q) error: %v", "current", err)
+	}
+	want = []ResolveStep{{Path: "current", Type: fs.ModeSymlink}}
+	if !reflect.DeepEqual(gotSteps, want) {
+		t.Errorf("Lstat(%q) traced %+v, want %+v", "current", gotSteps, want)
+	}
+}
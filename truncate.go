@@ -0,0 +1,44 @@
+// Copyright 2024 Google LLC.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package safeopen
+
+import "os"
+
+// TruncateAt changes the size of the named file in the named directory, as os.Truncate does.
+// file may not contain path separators, and is opened for writing as by OpenFileAt.
+// If there is an error, it will be of type *PathError.
+func TruncateAt(directory, file string, size int64) error {
+	return truncate(directory, file, size, OpenFileAt)
+}
+
+// TruncateBeneath changes the size of the named file in the named directory, or a subdirectory,
+// as os.Truncate does. file may not contain .. path traversal entries, and is opened for writing
+// as by OpenFileBeneath.
+// If there is an error, it will be of type *PathError.
+func TruncateBeneath(directory, file string, size int64) error {
+	return truncate(directory, file, size, OpenFileBeneath)
+}
+
+func truncate(directory, file string, size int64, opener openerFunc) error {
+	f, err := opener(directory, file, os.O_WRONLY, 0)
+	if err != nil {
+		return err
+	}
+	err = f.Truncate(size)
+	if err1 := f.Close(); err1 != nil && err == nil {
+		err = err1
+	}
+	return err
+}
@@ -0,0 +1,58 @@
+// Copyright 2024 Google LLC.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package safeopen
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestTruncate(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.Mkdir(filepath.Join(dir, "sub"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	for _, file := range []string{"file", filepath.Join("sub", "file")} {
+		if err := os.WriteFile(filepath.Join(dir, file), []byte("hello world"), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if err := TruncateAt(dir, "file", 5); err != nil {
+		t.Errorf("TruncateAt(file) error: %v", err)
+	}
+	if err := TruncateBeneath(dir, filepath.Join("sub", "file"), 20); err != nil {
+		t.Errorf("TruncateBeneath(sub/file) error: %v", err)
+	}
+	for file, want := range map[string]int64{"file": 5, filepath.Join("sub", "file"): 20} {
+		if fi, err := os.Stat(filepath.Join(dir, file)); err != nil || fi.Size() != want {
+			t.Errorf("%s has size %d, %v, want %d", file, fi.Size(), err, want)
+		}
+	}
+
+	if err := TruncateAt(dir, filepath.Join("sub", "file"), 0); err == nil {
+		t.Error("TruncateAt(sub/file) should have been an error")
+	}
+	if err := TruncateBeneath(dir, filepath.Join("..", "file"), 0); err == nil {
+		t.Error("TruncateBeneath(../file) should have been an error")
+	}
+	if err := TruncateAt(dir, "missing", 0); !os.IsNotExist(err) {
+		t.Errorf("TruncateAt(missing) = %v, want ErrNotExist", err)
+	}
+	if _, err := os.Stat(filepath.Join(dir, "missing")); err == nil {
+		t.Error("TruncateAt(missing) created the file")
+	}
+}
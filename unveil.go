@@ -0,0 +1,29 @@
+// Copyright 2024 Google LLC.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package safeopen
+
+// RestrictTo limits the file system view of the whole process to the named directories and
+// their contents, which remain readable, writable and creatable, and locks that view so that it
+// can no longer be widened. It complements the checks done on each open by this package with a
+// restriction enforced by the kernel: even code not using this package, or a bug in it, cannot
+// reach files outside of dirs.
+//
+// RestrictTo relies on unveil(2) and is only supported on OpenBSD. Elsewhere, it fails with an
+// error wrapping syscall.ENOTSUP. It is irreversible: it is meant to be called once at startup,
+// after opening any file needed outside of dirs. Calling it with no directories leaves no file
+// system access at all.
+func RestrictTo(dirs ...string) error {
+	return restrictTo(dirs)
+}
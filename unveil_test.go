@@ -0,0 +1,31 @@
+// Copyright 2024 Google LLC.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+//go:build !openbsd
+// +build !openbsd
+
+package safeopen
+
+import (
+	"errors"
+	"syscall"
+	"testing"
+)
+
+// RestrictTo is not tested on OpenBSD, as it would restrict the whole test process.
+func TestRestrictToUnsupported(t *testing.T) {
+	if err := RestrictTo(t.TempDir()); !errors.Is(err, syscall.ENOTSUP) {
+		t.Errorf("RestrictTo() error = %v, want %v", err, syscall.ENOTSUP)
+	}
+}
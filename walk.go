@@ -0,0 +1,128 @@
+// Copyright 2024 Google LLC.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package safeopen
+
+import (
+	"io/fs"
+	"path/filepath"
+	"sort"
+)
+
+// WalkBeneath walks the file tree rooted at the named directory, calling fn for each file or
+// directory in the tree, including the root, as fs.WalkDir does. The paths passed to fn are
+// relative to the directory, the root being ".", so that they can be passed to the other Beneath
+// functions. The files are walked in lexical order, and fn may return fs.SkipDir or fs.SkipAll
+// as with fs.WalkDir.
+//
+// Each directory is opened relative to the handle of its parent, never by path, and symbolic
+// links are reported to fn but never followed, so the walk cannot escape the directory even if
+// the tree is modified concurrently. The Info method of the entries passed to fn stats them
+// relative to the handle of their directory as well, while fn runs.
+func WalkBeneath(directory string, fn fs.WalkDirFunc) error {
+	dfd, err := openDirHandle(directory)
+	var fi fs.FileInfo
+	if err == nil {
+		defer closeHandle(dfd)
+		fi, err = statIn(dfd, ".")
+	}
+	if err != nil {
+		err = fn(".", nil, routedError("lstat", err, directory))
+	} else {
+		err = walkDirIn(dfd, directory, ".", ".", fs.FileInfoToDirEntry(fi), fn)
+	}
+	if err == fs.SkipDir || err == fs.SkipAll {
+		return nil
+	}
+	return err
+}
+
+// walkDirIn walks name, in the directory referred by pfd and named pname, which is reported to fn
+// as path and described by d. If it is a directory, its entries are walked as well.
+func walkDirIn(pfd handle, pname, name, path string, d fs.DirEntry, fn fs.WalkDirFunc) error {
+	if err := fn(path, d, nil); err != nil || !d.IsDir() {
+		if err == fs.SkipDir && d.IsDir() {
+			err = nil
+		}
+		return err
+	}
+
+	dir, err := openDirNoFollowIn(pfd, pname, name)
+	var entries []fs.DirEntry
+	if err == nil {
+		defer dir.Close()
+		entries, err = readDirIn(dir)
+	}
+	if err != nil {
+		// Report the error a second time, as fs.WalkDir does when reading a directory fails.
+		if err := fn(path, d, routedError("readdir", err, filepath.Join(pname, name))); err != nil {
+			if err == fs.SkipDir {
+				err = nil
+			}
+			return err
+		}
+	}
+
+	for _, e := range entries {
+		if err := walkDirIn(handle(dir.Fd()), dir.Name(), e.Name(), filepath.Join(path, e.Name()), e, fn); err != nil {
+			if err == fs.SkipDir {
+				break
+			}
+			return err
+		}
+	}
+	return nil
+}
+
+// readDirNamesIn returns the names of the entries of dir beneath dfd, sorted. Symbolic links
+// are not followed.
+func readDirNamesIn(dfd handle, dir string) ([]string, error) {
+	f, err := openDirNoFollowIn(dfd, "", dir)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	names, err := f.Readdirnames(-1)
+	if err != nil {
+		return nil, err
+	}
+	sort.Strings(names)
+	return names, nil
+}
+
+// walkIn calls fn for each file beneath dir, recursively, in lexical order, and with file
+// relative to dfd. dir itself is not reported. Symbolic links are reported, but not followed.
+func walkIn(dfd handle, dir string, fn func(file string, fi fs.FileInfo) error) error {
+	names, err := readDirNamesIn(dfd, dir)
+	if err != nil {
+		return err
+	}
+	for _, name := range names {
+		file := filepath.Join(dir, name)
+		fi, err := statIn(dfd, file)
+		if err != nil {
+			return err
+		}
+		if err := fn(file, fi); err != nil {
+			return err
+		}
+		if fi.IsDir() {
+			if err := walkIn(dfd, file, fn); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,128 @@
+// Copyright 2024 Google LLC.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package safeopen
+
+import (
+	"io/fs"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestWalkBeneath(t *testing.T) {
+	dir := t.TempDir()
+	outside := t.TempDir()
+	for _, sub := range []string{"a", filepath.Join("a", "skipped"), "b"} {
+		if err := os.Mkdir(filepath.Join(dir, sub), 0755); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for _, file := range []string{filepath.Join("a", "file"), filepath.Join("a", "skipped", "file"), filepath.Join("b", "file"), "c"} {
+		if err := os.WriteFile(filepath.Join(dir, file), nil, 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := os.WriteFile(filepath.Join(outside, "secret"), nil, 0644); err != nil {
+		t.Fatal(err)
+	}
+	want := []string{".", "a", filepath.Join("a", "file"), filepath.Join("a", "skipped"), "b", filepath.Join("b", "file"), "c"}
+	if err := os.Symlink(outside, filepath.Join(dir, "link")); err == nil {
+		want = append(want, "link")
+	}
+
+	var got []string
+	err := WalkBeneath(dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		got = append(got, path)
+		if d.Name() == "skipped" {
+			return fs.SkipDir
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("WalkBeneath error: %v", err)
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("WalkBeneath walked %v, want %v", got, want)
+	}
+
+	got = nil
+	err = WalkBeneath(dir, func(path string, d fs.DirEntry, err error) error {
+		got = append(got, path)
+		if path == filepath.Join("a", "file") {
+			return fs.SkipAll
+		}
+		return nil
+	})
+	if want := []string{".", "a", filepath.Join("a", "file")}; err != nil || !reflect.DeepEqual(got, want) {
+		t.Errorf("WalkBeneath with SkipAll walked %v, %v, want %v", got, err, want)
+	}
+
+	var rootErr error
+	err = WalkBeneath(filepath.Join(dir, "missing"), func(path string, d fs.DirEntry, err error) error {
+		if path != "." || d != nil {
+			t.Errorf("fn(%q, %v) called for a missing root", path, d)
+		}
+		rootErr = err
+		return err
+	})
+	if err == nil || err != rootErr {
+		t.Errorf("WalkBeneath(missing) = %v, want the error passed to fn, %v", err, rootErr)
+	}
+}
+
+func TestWalkBeneathInfoAfterSwap(t *testing.T) {
+	tmpDir := t.TempDir()
+	root, outside := filepath.Join(tmpDir, "root"), filepath.Join(tmpDir, "outside")
+	for _, dir := range []string{filepath.Join(root, "sub"), outside} {
+		if err := os.MkdirAll(dir, 0755); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := os.WriteFile(filepath.Join(root, "sub", "file"), []byte("x"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(outside, "file"), []byte("outside the root"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	err := WalkBeneath(root, func(path string, d fs.DirEntry, err error) error {
+		if err != nil || path != filepath.Join("sub", "file") {
+			return err
+		}
+		// Replace sub with a link to a directory outside the root between the read of its
+		// entries and the call to Info. Windows does not rename open directories.
+		if err := os.Rename(filepath.Join(root, "sub"), filepath.Join(root, "old")); err != nil {
+			t.Skipf("os.Rename() error: %v", err)
+		}
+		if err := os.Symlink(outside, filepath.Join(root, "sub")); err != nil {
+			t.Skipf("os.Symlink() error: %v", err)
+		}
+		fi, err := d.Info()
+		if err != nil {
+			return err
+		}
+		if fi.Size() != 1 {
+			t.Errorf("Info().Size() = %d, want 1", fi.Size())
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("WalkBeneath error: %v", err)
+	}
+}
@@ -0,0 +1,33 @@
+// Copyright 2024 Google LLC.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package safeopen
+
+import "errors"
+
+// ErrCrossDevice is wrapped by the errors returned by a Dir configured with WithNoCrossDevice
+// when a lookup crosses a mount point.
+var ErrCrossDevice = errors.New("path crosses a file system boundary")
+
+// WithNoCrossDevice makes d refuse to open files if their lookup crosses a mount point, such as
+// a bind mount inside the directory, with an error wrapping ErrCrossDevice. On Linux, this
+// relies on openat2 with RESOLVE_NO_XDEV, which also rejects symbolic links pointing to another
+// file system. Elsewhere, and on kernels without openat2, the device of each directory on the
+// way, and of the file itself, is compared with the one of d; symbolic links are not followed
+// then. On Windows, mount points are reparse points, which are never followed anyway.
+func WithNoCrossDevice() Option {
+	return func(o *options) {
+		o.noXDev = true
+	}
+}
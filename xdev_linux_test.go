@@ -0,0 +1,74 @@
+// Copyright 2024 Google LLC.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+//go:build linux
+// +build linux
+
+package safeopen
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"golang.org/x/sys/unix"
+)
+
+func TestNoCrossDevice(t *testing.T) {
+	origForceLegacyMode := forceLegacyMode
+	defer func() { forceLegacyMode = origForceLegacyMode }()
+
+	var root, proc unix.Stat_t
+	if unix.Stat("/", &root) != nil || unix.Stat("/proc", &proc) != nil || root.Dev == proc.Dev {
+		t.Skip("/proc is not mounted on another device than /")
+	}
+	tmpDir := t.TempDir()
+	if err := os.Mkdir(filepath.Join(tmpDir, "sub"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(tmpDir, "sub", "file"), []byte("data"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, legacy := range []bool{false, true} {
+		forceLegacyMode = legacy
+		d, err := OpenDir("/", WithNoCrossDevice())
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := d.ReadFile("proc/version"); !errors.Is(err, ErrCrossDevice) {
+			t.Errorf("ReadFile(proc/version) with forceLegacyMode=%v error = %v, want %v", legacy, err, ErrCrossDevice)
+		}
+		if _, err := d.ReadFile("proc"); !errors.Is(err, ErrCrossDevice) {
+			t.Errorf("ReadFile(proc) with forceLegacyMode=%v error = %v, want %v", legacy, err, ErrCrossDevice)
+		}
+		d.Close()
+
+		d, err = OpenDir(tmpDir, WithNoCrossDevice())
+		if err != nil {
+			t.Fatal(err)
+		}
+		if data, err := d.ReadFile(filepath.Join("sub", "file")); err != nil || string(data) != "data" {
+			t.Errorf("ReadFile(sub/file) with forceLegacyMode=%v = %q, %v, want %q", legacy, data, err, "data")
+		}
+		if err := d.WriteFile(filepath.Join("sub", "new"), []byte("new"), 0644); err != nil {
+			t.Errorf("WriteFile(sub/new) with forceLegacyMode=%v error: %v", legacy, err)
+		}
+		if _, err := d.ReadFile(filepath.Join("..", "file")); !errors.Is(err, ErrTraversal) {
+			t.Errorf("ReadFile(../file) with forceLegacyMode=%v error = %v, want %v", legacy, err, ErrTraversal)
+		}
+		d.Close()
+	}
+}
@@ -0,0 +1,64 @@
+// Copyright 2024 Google LLC.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package safeopen
+
+import (
+	"io/fs"
+	"os"
+	"time"
+)
+
+// ExtendedFileInfo describes a file with metadata beyond fs.FileInfo, in a platform independent
+// form.
+type ExtendedFileInfo struct {
+	fs.FileInfo
+	// Owner and Group identify the owner user and group of the file: the decimal uid and gid on
+	// Unix, the string form of the SIDs on Windows.
+	Owner, Group string
+	// Device and Inode identify the file within the system: the device and inode numbers on
+	// Unix, the volume serial number and the file index on Windows.
+	Device, Inode uint64
+	// Links is the number of hard links to the file.
+	Links uint64
+	// AccessTime is the time of the last access.
+	AccessTime time.Time
+	// ChangeTime is the time of the last change of the content or the metadata.
+	ChangeTime time.Time
+	// BirthTime is the creation time, or the zero time if the platform does not report it.
+	BirthTime time.Time
+}
+
+// ExtendedStatAt returns the ExtendedFileInfo of the named file in the named directory.
+// file may not contain path separators. Symbolic links are not followed.
+func ExtendedStatAt(directory, file string) (*ExtendedFileInfo, error) {
+	if !isFilename(file) {
+		return nil, &os.PathError{Op: "ExtendedStatAt", Path: file, Err: ErrInvalidFilename}
+	}
+	return ExtendedStatBeneath(directory, file)
+}
+
+// ExtendedStatBeneath returns the ExtendedFileInfo of the named file in the named directory, or
+// a subdirectory.
+// file may not contain .. path traversal entries. Symbolic links are not followed in the last
+// element of file.
+func ExtendedStatBeneath(directory, file string) (*ExtendedFileInfo, error) {
+	dfd, err := openDirHandle(directory)
+	if err != nil {
+		return nil, err
+	}
+	defer closeHandle(dfd)
+
+	return extendedStatIn(dfd, file)
+}
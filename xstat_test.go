@@ -0,0 +1,69 @@
+// Copyright 2024 Google LLC.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package safeopen
+
+import (
+	"os"
+	"path"
+	"runtime"
+	"testing"
+)
+
+func TestExtendedStat(t *testing.T) {
+	tmpDir := t.TempDir()
+	if err := os.Mkdir(path.Join(tmpDir, "subdir"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	file := path.Join("subdir", "data.txt")
+	if err := os.WriteFile(path.Join(tmpDir, file), []byte("content"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Link(path.Join(tmpDir, file), path.Join(tmpDir, "link.txt")); err != nil {
+		t.Fatal(err)
+	}
+
+	xfi, err := ExtendedStatBeneath(tmpDir, file)
+	if err != nil {
+		t.Fatalf("ExtendedStatBeneath(%q, %q) error: %v", tmpDir, file, err)
+	}
+	if xfi.Size() != 7 || !xfi.Mode().IsRegular() {
+		t.Errorf("ExtendedStatBeneath(%q, %q) = size %d, mode %v, want a 7 byte regular file", tmpDir, file, xfi.Size(), xfi.Mode())
+	}
+	if xfi.Links != 2 {
+		t.Errorf("ExtendedStatBeneath(%q, %q).Links = %d, want 2", tmpDir, file, xfi.Links)
+	}
+	// WASI has no owners.
+	if runtime.GOOS != "wasip1" && (xfi.Owner == "" || xfi.Group == "") {
+		t.Errorf("ExtendedStatBeneath(%q, %q) = owner %q, group %q, want both set", tmpDir, file, xfi.Owner, xfi.Group)
+	}
+	if xfi.AccessTime.IsZero() || xfi.ChangeTime.IsZero() {
+		t.Errorf("ExtendedStatBeneath(%q, %q) returned zero access or change time", tmpDir, file)
+	}
+
+	link, err := ExtendedStatAt(tmpDir, "link.txt")
+	if err != nil {
+		t.Fatalf("ExtendedStatAt(%q, %q) error: %v", tmpDir, "link.txt", err)
+	}
+	if link.Device != xfi.Device || link.Inode != xfi.Inode {
+		t.Errorf("ExtendedStatAt(%q, %q) = (%d, %d), want the same file as %q (%d, %d)", tmpDir, "link.txt", link.Device, link.Inode, file, xfi.Device, xfi.Inode)
+	}
+
+	if _, err := ExtendedStatAt(tmpDir, file); err == nil {
+		t.Errorf("ExtendedStatAt(%q, %q) succeeded, want error for a path with separators", tmpDir, file)
+	}
+	if _, err := ExtendedStatBeneath(tmpDir, "../data.txt"); err == nil {
+		t.Errorf("ExtendedStatBeneath(%q, %q) succeeded, want error", tmpDir, "../data.txt")
+	}
+}